@@ -0,0 +1,198 @@
+package jsonware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIKey describes an api key and who it belongs to.
+type APIKey struct {
+	ID     string
+	Owner  string
+	Scopes []string
+
+	// Hash is the SHA-256 of the key, the key a client presents is compared
+	// against it in constant time. Keys themselves should never be stored.
+	Hash []byte
+
+	Disabled bool
+	// Expires is when the key stops working, the zero value never expires.
+	Expires time.Time
+}
+
+// HasScope checks if the key has been granted scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HashAPIKey hashes a key for storing in APIKey.Hash.
+func HashAPIKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// APIKeyLookup finds the api key with the given id, it returns nil when
+// there's no such key.
+type APIKeyLookup func(ctx context.Context, id string) (*APIKey, error)
+
+type apiKeyKey struct{}
+
+// APIKeyFrom retrieves the api key that authenticated the request from its
+// context, as put there by APIKeys.
+func APIKeyFrom(ctx context.Context) (*APIKey, bool) {
+	k, ok := ctx.Value(apiKeyKey{}).(*APIKey)
+	return k, ok
+}
+
+// WithAPIKey returns a copy of ctx that carries the api key, this is mostly
+// useful for testing handlers without APIKeys in front of them.
+func WithAPIKey(ctx context.Context, k *APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, k)
+}
+
+var errBadAPIKey = errors.New("api key is invalid")
+
+func init() {
+	// Handlers may take the *APIKey as an argument directly.
+	RegisterExtractor(func(r *http.Request) (*APIKey, error) {
+		k, ok := APIKeyFrom(r.Context())
+		if !ok {
+			return nil, Err{Status: http.StatusUnauthorized, Err: errNoAPIKey}
+		}
+		return k, nil
+	})
+}
+
+/*
+APIKeys authenticates every request that passes through the handlers it
+wraps with an api key, rejecting requests without a valid one with a 401.
+Handlers can get the key with APIKeyFrom or by declaring an *APIKey argument,
+and can require scopes with RequireScopes.
+
+The key is read from the X-Api-Key header, or from an Authorization header
+with the Bearer scheme. Keys are made of an id and a secret separated by a
+dot, like "k3x9.s3cr3t", and Lookup is called with the id. Keys without a
+dot are their own id. The whole key is then compared against the hash of the
+key that was found in constant time. Disabled and expired keys are rejected.
+
+If Lookup returns an error the request gets a 401 when it's an Err, and
+otherwise the error is cloaked.
+
+	keys := &jsonware.APIKeys{Lookup: func(ctx context.Context, id string) (*jsonware.APIKey, error) {
+		return db.FindAPIKey(ctx, id)
+	}}
+	http.Handle("/reports", keys.Wrap(jsonware.Handler(listReports).RequireScopes("reports:read")))
+*/
+type APIKeys struct {
+	Lookup APIKeyLookup
+
+	// Log is where errors are reported, the global logger is used when it's
+	// nil.
+	Log io.Writer
+
+	// now is swapped out in tests.
+	now func() time.Time
+}
+
+// Wrap returns a handler that authenticates requests before serving them
+// with h.
+func (a *APIKeys) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k, err := a.authenticate(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, a.Log, err)
+			return
+		}
+
+		h.ServeHTTP(w, r.WithContext(WithAPIKey(r.Context(), k)))
+	})
+}
+
+// authenticate finds and checks the request's api key.
+func (a *APIKeys) authenticate(r *http.Request) (*APIKey, error) {
+	presented := strings.TrimSpace(r.Header.Get("X-Api-Key"))
+	if len(presented) == 0 {
+		scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if strings.EqualFold(scheme, "Bearer") {
+			presented = strings.TrimSpace(token)
+		}
+	}
+	if len(presented) == 0 {
+		return nil, Err{Status: http.StatusUnauthorized, Err: errNoAPIKey}
+	}
+
+	id, _, _ := strings.Cut(presented, ".")
+	k, err := a.Lookup(r.Context(), id)
+	if err != nil {
+		if e, ok := err.(Err); ok {
+			e.Status = http.StatusUnauthorized
+			return nil, e
+		}
+		return nil, fmt.Errorf("failed to look up api key: %w", err)
+	}
+
+	// Hash even when there's no key so that unknown ids take as long as
+	// known ones.
+	hash := HashAPIKey(presented)
+	if k == nil || subtle.ConstantTimeCompare(hash, k.Hash) != 1 {
+		return nil, Err{Status: http.StatusUnauthorized, Err: errBadAPIKey}
+	}
+
+	now := time.Now()
+	if a.now != nil {
+		now = a.now()
+	}
+	if k.Disabled || (!k.Expires.IsZero() && !now.Before(k.Expires)) {
+		return nil, Err{Status: http.StatusUnauthorized, Err: errors.New("api key is disabled or expired")}
+	}
+
+	return k, nil
+}
+
+/*
+RequireScopes restricts the JSONHandler to requests made with an api key
+that has been granted every one of scopes. Requests without an api key get a
+401 and keys that are missing a scope get a 403. It needs an APIKeys in front
+of the handler to authenticate the key, or the scopes of a Subject put in the
+request's context with WithSubject.
+*/
+func (j *JSONHandler) RequireScopes(scopes ...string) *JSONHandler {
+	j.scopes = append(j.scopes, scopes...)
+	return j
+}
+
+// checkScopes checks the request's api key has the required scopes.
+func (j JSONHandler) checkScopes(r *http.Request) error {
+	k, ok := APIKeyFrom(r.Context())
+	if !ok {
+		s, ok := SubjectFrom(r.Context())
+		if !ok {
+			return Err{Status: http.StatusUnauthorized, Err: errNoAPIKey}
+		}
+		for _, scope := range j.scopes {
+			if !hasAny(s.Scopes, []string{scope}) {
+				return Err{Status: http.StatusForbidden, Err: fmt.Errorf("missing scope: %s", scope)}
+			}
+		}
+		return nil
+	}
+	for _, scope := range j.scopes {
+		if !k.HasScope(scope) {
+			return Err{Status: http.StatusForbidden, Err: fmt.Errorf("api key is missing scope: %s", scope)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package jsonware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIKeys(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000, 0)
+	db := map[string]*APIKey{
+		"k1": {ID: "k1", Owner: "acme", Scopes: []string{"reports:read"}, Hash: HashAPIKey("k1.secret")},
+		"k2": {ID: "k2", Owner: "acme", Hash: HashAPIKey("k2.secret"), Disabled: true},
+		"k3": {ID: "k3", Owner: "acme", Hash: HashAPIKey("k3.secret"), Expires: now},
+		"k4": {ID: "k4", Owner: "umbrella", Scopes: []string{"reports:read", "reports:write"}, Hash: HashAPIKey("k4.secret")},
+	}
+	log := &bytes.Buffer{}
+	keys := &APIKeys{
+		Lookup: func(ctx context.Context, id string) (*APIKey, error) {
+			switch id {
+			case "broken":
+				return nil, errors.New("db went away")
+			case "banned":
+				return nil, Err{Err: errors.New("owner is banned")}
+			}
+			return db[id], nil
+		},
+		Log: log,
+		now: func() time.Time { return now },
+	}
+
+	owner := func(r *http.Request, k *APIKey) *testType { return &testType{k.Owner} }
+	read := keys.Wrap(Handler(owner).RequireScopes("reports:read"))
+	write := keys.Wrap(Handler(owner).RequireScopes("reports:read", "reports:write"))
+
+	var tests = []struct {
+		handler http.Handler
+		header  string
+		value   string
+		status  int
+		resbody string
+	}{
+		{read, "X-Api-Key", "k1.secret", 200, `{"name":"acme"}`},
+		{read, "Authorization", "Bearer k1.secret", 200, `{"name":"acme"}`},
+		{read, "", "", 401, `{"error":"api key required"}`},
+		{read, "Authorization", "Basic abc", 401, `{"error":"api key required"}`},
+		{read, "X-Api-Key", "k1.wrong", 401, `{"error":"api key is invalid"}`},
+		{read, "X-Api-Key", "nobody.secret", 401, `{"error":"api key is invalid"}`},
+		{read, "X-Api-Key", "k2.secret", 401, `{"error":"api key is disabled or expired"}`},
+		{read, "X-Api-Key", "k3.secret", 401, `{"error":"api key is disabled or expired"}`},
+		{read, "X-Api-Key", "banned.secret", 401, `{"error":"owner is banned"}`},
+		{read, "X-Api-Key", "broken.secret", 500, `{"error":"an internal server error occurred"}`},
+		{write, "X-Api-Key", "k1.secret", 403, `{"error":"api key is missing scope: reports:write"}`},
+		{write, "X-Api-Key", "k4.secret", 200, `{"name":"umbrella"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		if len(test.header) != 0 {
+			req.Header.Set(test.header, test.value)
+		}
+		test.handler.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := res.Body.String(); strings.TrimSuffix(b, "\n") != test.resbody {
+			t.Errorf("Test %d: body was wrong: %s", i, b)
+		}
+	}
+
+	if !strings.Contains(log.String(), "failed to look up api key: db went away") {
+		t.Error("Expected the lookup failure to be logged:", log.String())
+	}
+}
+
+func TestRequireScopesWithoutKey(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(func(r *http.Request) *testType { return &testType{"bob"} }).RequireScopes("admin")
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(res, req)
+
+	if res.Code != http.StatusUnauthorized {
+		t.Error("Expected status 401, got:", res.Code)
+	}
+}
@@ -0,0 +1,246 @@
+package jsonware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+var (
+	errNoSubject = errors.New("authentication required")
+	errForbidden = errors.New("forbidden")
+)
+
+// Subject is who a request is made by, as authorization sees them.
+type Subject struct {
+	ID     string   `json:"id"`
+	Roles  []string `json:"roles,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+	// Claims holds anything else a Policy may decide on, like the claims of
+	// a token.
+	Claims map[string]interface{} `json:"claims,omitempty"`
+}
+
+type subjectKey struct{}
+
+// SubjectFrom retrieves the Subject of the request from its context.
+func SubjectFrom(ctx context.Context) (Subject, bool) {
+	s, ok := ctx.Value(subjectKey{}).(Subject)
+	return s, ok
+}
+
+// WithSubject returns a copy of ctx that carries the subject, middleware that
+// authenticates requests puts the Subject they're made by there for
+// RequireRole, RequireScopes and Policy to check.
+func WithSubject(ctx context.Context, s Subject) context.Context {
+	return context.WithValue(ctx, subjectKey{}, s)
+}
+
+// subjectOf finds the Subject of a request: the one put in its context, or
+// else the owner of its api key or its client certificate.
+func subjectOf(r *http.Request) (Subject, bool) {
+	if s, ok := SubjectFrom(r.Context()); ok {
+		return s, true
+	}
+	if k, ok := APIKeyFrom(r.Context()); ok {
+		return Subject{ID: k.Owner, Scopes: k.Scopes}, true
+	}
+	if p, ok := PrincipalFrom(r.Context()); ok {
+		return Subject{ID: p.CommonName}, true
+	}
+	return Subject{}, false
+}
+
+// Requirement is what a handler requires of the requests it serves.
+type Requirement struct {
+	Method string   `json:"method"`
+	Path   string   `json:"path"`
+	Scopes []string `json:"scopes,omitempty"`
+	Roles  []string `json:"roles,omitempty"`
+}
+
+/*
+Policy decides if a subject may make a request, for authorization rules that
+are more than a list of scopes and roles, or that live outside the service
+like in OPA. Allow returning false gets the client a 403, an error is written
+like a handler's errors.
+*/
+type Policy interface {
+	Allow(r *http.Request, s Subject, req Requirement) (bool, error)
+}
+
+// PolicyFunc is a function that's a Policy.
+type PolicyFunc func(r *http.Request, s Subject, req Requirement) (bool, error)
+
+// Allow calls fn.
+func (fn PolicyFunc) Allow(r *http.Request, s Subject, req Requirement) (bool, error) {
+	return fn(r, s, req)
+}
+
+// DefaultPolicy is the Policy of JSONHandlers that weren't given one with
+// their Policy option, when it's nil their scopes and roles are checked
+// against the Subject as they are. Not safe for use by multiple goroutines,
+// set it before your http server has been started.
+var DefaultPolicy Policy
+
+/*
+RequireRole restricts the JSONHandler to subjects that have at least one of
+roles. Requests without a subject get a 401 and subjects without any of the
+roles get a 403. The Subject is the one put in the request's context by
+WithSubject.
+
+	jsonware.Handler(deleteUser).RequireRole("admin")
+*/
+func (j *JSONHandler) RequireRole(roles ...string) *JSONHandler {
+	j.roles = append(j.roles, roles...)
+	return j
+}
+
+/*
+Policy makes p decide who may make requests to the JSONHandler, in place of
+checking its RequireScopes and RequireRole as they are. p is given those as
+the Requirement, along with the request's Subject, which is the zero Subject
+for anonymous requests.
+*/
+func (j *JSONHandler) Policy(p Policy) *JSONHandler {
+	j.policy = p
+	return j
+}
+
+// authorizes checks if the handler has anything to authorize.
+func (j JSONHandler) authorizes() bool {
+	return j.policy != nil || len(j.roles) != 0 || (DefaultPolicy != nil && len(j.scopes) != 0)
+}
+
+// authorize checks the request is allowed by the handler's policy.
+func (j JSONHandler) authorize(r *http.Request) error {
+	policy := j.policy
+	if policy == nil {
+		policy = DefaultPolicy
+	}
+
+	s, ok := subjectOf(r)
+	if policy == nil {
+		if !ok {
+			return Err{Status: http.StatusUnauthorized, Err: errNoSubject}
+		}
+		if !hasAny(s.Roles, j.roles) {
+			return Err{Status: http.StatusForbidden, Err: fmt.Errorf("requires one of roles: %s", strings.Join(j.roles, ", "))}
+		}
+		return nil
+	}
+
+	req := Requirement{Method: r.Method, Path: r.URL.Path, Scopes: j.scopes, Roles: j.roles}
+	allowed, err := policy.Allow(r, s, req)
+	return denied(allowed, ok, err)
+}
+
+// denied is the error of a policy's decision, authenticated says if the
+// request has a Subject.
+func denied(allowed, authenticated bool, err error) error {
+	switch {
+	case err != nil:
+		return err
+	case allowed:
+		return nil
+	case !authenticated:
+		return Err{Status: http.StatusUnauthorized, Err: errNoSubject}
+	}
+	return Err{Status: http.StatusForbidden, Err: errForbidden}
+}
+
+/*
+AuthorizeInput makes fn decide if a request may be served once its body has
+been decoded and validated, just before the handler is called. fn gets the
+request's Subject, the zero Subject for anonymous requests, and the request
+object the handler is about to get, or nil when it takes none. It's for rules
+that depend on what's being asked for, which can then be enforced in one place
+rather than in every handler. Returning false gets the client a 403, an error
+is written like a handler's errors.
+
+	onlyOwnProfile := func(r *http.Request, s jsonware.Subject, in interface{}) (bool, error) {
+		return in.(*UpdateProfile).UserID == s.ID, nil
+	}
+	jsonware.Handler(updateProfile).AuthorizeInput(onlyOwnProfile)
+*/
+func (j *JSONHandler) AuthorizeInput(fn func(r *http.Request, s Subject, in interface{}) (bool, error)) *JSONHandler {
+	j.inputPolicy = fn
+	return j
+}
+
+// authorizeInput checks the request object in is allowed by the handler's
+// input policy.
+func (j JSONHandler) authorizeInput(r *http.Request, in reflect.Value) error {
+	var v interface{}
+	if in.IsValid() {
+		v = in.Interface()
+	}
+	s, ok := subjectOf(r)
+	allowed, err := j.inputPolicy(r, s, v)
+	return denied(allowed, ok, err)
+}
+
+func hasAny(have, want []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+OPAPolicy is a Policy that asks an Open Policy Agent server, url is the
+document to query like http://localhost:8181/v1/data/httpapi/allow. The input
+is the Subject as subject and the Requirement's fields, and the result must
+be a bool. client is http.DefaultClient when it's nil.
+*/
+func OPAPolicy(url string, client *http.Client) Policy {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return PolicyFunc(func(r *http.Request, s Subject, req Requirement) (bool, error) {
+		input := map[string]interface{}{
+			"input": map[string]interface{}{
+				"subject": s,
+				"method":  req.Method,
+				"path":    req.Path,
+				"scopes":  req.Scopes,
+				"roles":   req.Roles,
+			},
+		}
+		b, err := json.Marshal(input)
+		if err != nil {
+			return false, err
+		}
+
+		query, err := http.NewRequestWithContext(r.Context(), http.MethodPost, url, bytes.NewReader(b))
+		if err != nil {
+			return false, err
+		}
+		query.Header.Set("Content-Type", "application/json")
+		res, err := client.Do(query)
+		if err != nil {
+			return false, fmt.Errorf("failed to query opa: %w", err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return false, fmt.Errorf("failed to query opa: %s", res.Status)
+		}
+
+		var result struct {
+			Result *bool `json:"result"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+			return false, fmt.Errorf("failed to decode opa result: %w", err)
+		}
+		return result.Result != nil && *result.Result, nil
+	})
+}
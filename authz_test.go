@@ -0,0 +1,164 @@
+package jsonware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthorization(t *testing.T) {
+	t.Parallel()
+
+	admin := Subject{ID: "ann", Roles: []string{"admin"}, Scopes: []string{"orders:read"}}
+	clerk := Subject{ID: "bob", Roles: []string{"clerk"}, Scopes: []string{"orders:read", "orders:write"}}
+	fn := func(r *http.Request) (*testType, error) { return &testType{Name: "ok"}, nil }
+
+	ownOrders := PolicyFunc(func(r *http.Request, s Subject, req Requirement) (bool, error) {
+		if s.ID == "broken" {
+			return false, errors.New("policy store is down")
+		}
+		return len(s.ID) != 0 && strings.HasPrefix(req.Path, "/orders/"+s.ID+"/") && req.Method == "GET", nil
+	})
+
+	var tests = []struct {
+		handler *JSONHandler
+		subject *Subject
+		path    string
+		status  int
+		want    string
+	}{
+		{Handler(fn).RequireRole("admin", "owner"), &admin, "/", 200, `{"name":"ok"}`},
+		{Handler(fn).RequireRole("admin", "owner"), &clerk, "/", 403, `{"error":"requires one of roles: admin, owner"}`},
+		{Handler(fn).RequireRole("admin"), nil, "/", 401, `{"error":"authentication required"}`},
+		{Handler(fn).RequireScopes("orders:write"), &clerk, "/", 200, `{"name":"ok"}`},
+		{Handler(fn).RequireScopes("orders:write"), &admin, "/", 403, `{"error":"missing scope: orders:write"}`},
+		{Handler(fn).RequireScopes("orders:write").RequireRole("clerk"), &clerk, "/", 200, `{"name":"ok"}`},
+		{Handler(fn).Policy(ownOrders), &clerk, "/orders/bob/1", 200, `{"name":"ok"}`},
+		{Handler(fn).Policy(ownOrders), &clerk, "/orders/ann/1", 403, `{"error":"forbidden"}`},
+		{Handler(fn).Policy(ownOrders).RequireRole("nobody"), &clerk, "/orders/bob/1", 200, `{"name":"ok"}`},
+		{Handler(fn).Policy(ownOrders), nil, "/orders/bob/1", 401, `{"error":"authentication required"}`},
+		{Handler(fn).Policy(ownOrders).Log(&strings.Builder{}), &Subject{ID: "broken"}, "/", 500, `{"error":"an internal server error occurred"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", test.path, nil)
+		req.Header.Set("Accept", "application/json")
+		if test.subject != nil {
+			req = req.WithContext(WithSubject(req.Context(), *test.subject))
+		}
+		test.handler.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
+
+func TestOPAPolicy(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var query struct {
+			Input struct {
+				Subject Subject  `json:"subject"`
+				Method  string   `json:"method"`
+				Roles   []string `json:"roles"`
+			} `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if query.Input.Subject.ID == "down" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		allowed := query.Input.Subject.ID == "ann" && query.Input.Method == "GET" && query.Input.Roles[0] == "auditor"
+		json.NewEncoder(w).Encode(map[string]bool{"result": allowed})
+	}))
+	defer srv.Close()
+
+	h := Handler(func(r *http.Request) (*testType, error) { return &testType{Name: "ok"}, nil }).
+		RequireRole("auditor").Policy(OPAPolicy(srv.URL, nil)).Log(&strings.Builder{})
+
+	var tests = []struct {
+		subject string
+		status  int
+	}{
+		{"ann", 200},
+		{"bob", 403},
+		{"down", 500},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		req = req.WithContext(WithSubject(req.Context(), Subject{ID: test.subject}))
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+	}
+}
+
+func TestAuthorizeInput(t *testing.T) {
+	t.Parallel()
+
+	type profile struct {
+		UserID string `json:"user_id"`
+		Name   string `json:"name" required:"true"`
+	}
+	called := false
+	h := Handler(func(r *http.Request, in *profile) (*profile, error) {
+		called = true
+		return in, nil
+	}).AuthorizeInput(func(r *http.Request, s Subject, in interface{}) (bool, error) {
+		if s.ID == "broken" {
+			return false, Err{Status: http.StatusConflict, Err: errors.New("profile is locked")}
+		}
+		return in.(*profile).UserID == s.ID, nil
+	})
+
+	var tests = []struct {
+		subject string
+		body    string
+		status  int
+		want    string
+	}{
+		{"bob", `{"user_id":"bob","name":"Bob"}`, 200, `{"user_id":"bob","name":"Bob"}`},
+		{"bob", `{"user_id":"ann","name":"Ann"}`, 403, `{"error":"forbidden"}`},
+		{"", `{"user_id":"ann","name":"Ann"}`, 401, `{"error":"authentication required"}`},
+		{"bob", `{"user_id":"bob"}`, 422, `missing required fields`},
+		{"broken", `{"user_id":"broken","name":"B"}`, 409, `{"error":"profile is locked"}`},
+	}
+
+	for i, test := range tests {
+		called = false
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/profile", strings.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		if len(test.subject) != 0 {
+			req = req.WithContext(WithSubject(req.Context(), Subject{ID: test.subject}))
+		}
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); !strings.Contains(b, test.want) {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+		if called != (test.status == 200) {
+			t.Errorf("Test %d: expected the handler to be called %t", i, test.status == 200)
+		}
+	}
+}
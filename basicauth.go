@@ -0,0 +1,43 @@
+package jsonware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+var errBasicAuth = errors.New("valid credentials are required")
+
+/*
+BasicAuth protects the JSONHandler with http basic authentication, for admin
+and debug endpoints that don't need anything fancier. verify is given the
+user and password of each request and decides if they're allowed. Requests
+without valid credentials get a 401 json error along with a WWW-Authenticate
+header naming realm.
+
+verify should compare passwords in constant time, with
+crypto/subtle.ConstantTimeCompare for example.
+
+	jsonware.Handler(dumpCaches).BasicAuth("admin", func(user, pass string) bool {
+		return user == "admin" && subtle.ConstantTimeCompare([]byte(pass), adminPass) == 1
+	})
+*/
+func (j *JSONHandler) BasicAuth(realm string, verify func(user, pass string) bool) *JSONHandler {
+	if verify == nil {
+		panic("BasicAuth must be given a verify function")
+	}
+	j.basicRealm = realm
+	j.basicVerify = verify
+	return j
+}
+
+// checkBasicAuth checks the request's basic auth credentials.
+func (j JSONHandler) checkBasicAuth(w http.ResponseWriter, r *http.Request) error {
+	user, pass, ok := r.BasicAuth()
+	if ok && j.basicVerify(user, pass) {
+		return nil
+	}
+
+	w.Header().Set("WWW-Authenticate", "Basic realm="+strconv.Quote(j.basicRealm)+`, charset="UTF-8"`)
+	return Err{Status: http.StatusUnauthorized, Err: errBasicAuth}
+}
@@ -0,0 +1,54 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(func(r *http.Request) *testType { return &testType{"bob"} }).BasicAuth("admin", func(user, pass string) bool {
+		return user == "admin" && pass == "hunter2"
+	})
+
+	var tests = []struct {
+		user, pass string
+		set        bool
+		status     int
+		resbody    string
+	}{
+		{"admin", "hunter2", true, 200, `{"name":"bob"}`},
+		{"admin", "wrong", true, 401, `{"error":"valid credentials are required"}`},
+		{"", "", false, 401, `{"error":"valid credentials are required"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		if test.set {
+			req.SetBasicAuth(test.user, test.pass)
+		}
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := res.Body.String(); b != test.resbody+"\n" {
+			t.Errorf("Test %d: body was wrong: %s", i, b)
+		}
+		if ct := res.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Test %d: expected a json error, got: %s", i, ct)
+		}
+
+		want := ""
+		if test.status == 401 {
+			want = `Basic realm="admin", charset="UTF-8"`
+		}
+		if auth := res.Header().Get("WWW-Authenticate"); auth != want {
+			t.Errorf("Test %d: expected WWW-Authenticate %q, got: %q", i, want, auth)
+		}
+	}
+}
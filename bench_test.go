@@ -0,0 +1,155 @@
+package jsonware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+/*
+Baseline numbers, go1.27 linux/amd64 on a single core. The generated handler
+benchmark is in cmd/jsonwaregen/internal/example and decodes a similar sized
+body:
+
+	BenchmarkDecodeEncode             645746    3801 ns/op   1328 B/op    17 allocs/op
+	BenchmarkEncodeOnly              1888185    1312 ns/op    248 B/op     6 allocs/op
+	BenchmarkCloakedError            3547260     703 ns/op    136 B/op     6 allocs/op
+	BenchmarkErr                     1000000    2266 ns/op    760 B/op    20 allocs/op
+	BenchmarkNotAcceptable          12077404     190 ns/op     80 B/op     2 allocs/op
+	BenchmarkGeneratedDecodeEncode   1000000    2437 ns/op    776 B/op     9 allocs/op
+
+There's no generic handler path to compare against, jsonwaretest.Request is
+generic but it's only for tests.
+
+Only allocations are checked by the tests, time per op depends too much on
+the machine. Compare ns/op against the numbers above with benchstat when
+changing the request path. Handlers must leave the request they're given
+alone, the benchmarks reuse theirs and TestAllocBudgets checks it isn't
+changed, otherwise ns/op grows with b.N.
+*/
+
+// allocBudgets are the most allocations each benchmark may make per request,
+// they're a little above the baseline to leave room for noise between go
+// versions.
+var allocBudgets = map[string]float64{
+	"DecodeEncode":  18,
+	"EncodeOnly":    8,
+	"CloakedError":  8,
+	"Err":           24,
+	"NotAcceptable": 3,
+}
+
+type benchUser struct {
+	ID    int      `json:"id"`
+	Name  string   `json:"name"`
+	Email string   `json:"email"`
+	Tags  []string `json:"tags"`
+}
+
+var benchBody = []byte(`{"id":5,"name":"bob","email":"bob@example.com","tags":["admin","staff"]}`)
+
+// benchBodyReader is a request body that can be reused between requests
+// without allocating.
+type benchBodyReader struct {
+	*bytes.Reader
+}
+
+func (benchBodyReader) Close() error { return nil }
+
+// benchWriter is a ResponseWriter that throws everything away.
+type benchWriter struct {
+	header http.Header
+}
+
+func (b *benchWriter) Header() http.Header         { return b.header }
+func (b *benchWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (b *benchWriter) WriteHeader(int)             {}
+
+// benchCases are the request paths the benchmarks and allocation tests cover.
+func benchCases() map[string]func() (http.Handler, *http.Request, func()) {
+	get := func(h http.Handler, accept string) func() (http.Handler, *http.Request, func()) {
+		return func() (http.Handler, *http.Request, func()) {
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept", accept)
+			return h, req, func() {}
+		}
+	}
+
+	return map[string]func() (http.Handler, *http.Request, func()){
+		"DecodeEncode": func() (http.Handler, *http.Request, func()) {
+			h := Handler(func(r *http.Request, u *benchUser) (*benchUser, error) { return u, nil })
+			body := benchBodyReader{bytes.NewReader(benchBody)}
+			req, _ := http.NewRequest("POST", "/", body)
+			req.Header.Set("Accept", "application/json")
+			return h, req, func() {
+				body.Reset(benchBody)
+				req.Body = body
+			}
+		},
+		"EncodeOnly": get(Handler(func(r *http.Request) *benchUser {
+			return &benchUser{ID: 5, Name: "bob", Email: "bob@example.com", Tags: []string{"admin"}}
+		}), "*/*"),
+		"CloakedError": get(Handler(func(r *http.Request) error {
+			return errors.New("kaboom")
+		}).Log(discard{}), "*/*"),
+		"Err": get(Handler(func(r *http.Request) error {
+			return Err{Status: http.StatusBadRequest, Err: errors.New("bad"), Reason: []string{"field"}}
+		}), "*/*"),
+		"NotAcceptable": get(Handler(func(r *http.Request) error { return nil }), "text/html"),
+	}
+}
+
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
+
+func benchmarkCase(b *testing.B, name string) {
+	h, req, reset := benchCases()[name]()
+	w := &benchWriter{header: make(http.Header)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reset()
+		h.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkDecodeEncode(b *testing.B)  { benchmarkCase(b, "DecodeEncode") }
+func BenchmarkEncodeOnly(b *testing.B)    { benchmarkCase(b, "EncodeOnly") }
+func BenchmarkCloakedError(b *testing.B)  { benchmarkCase(b, "CloakedError") }
+func BenchmarkErr(b *testing.B)           { benchmarkCase(b, "Err") }
+func BenchmarkNotAcceptable(b *testing.B) { benchmarkCase(b, "NotAcceptable") }
+
+func TestAllocBudgets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("allocation budgets are not checked in short mode")
+	}
+	if raceEnabled {
+		t.Skip("allocation budgets are not checked with the race detector")
+	}
+
+	for name, mk := range benchCases() {
+		h, req, reset := mk()
+		w := &benchWriter{header: make(http.Header)}
+		body := req.Body
+
+		allocs := testing.AllocsPerRun(100, func() {
+			h.ServeHTTP(w, req)
+			if req.Body != body {
+				t.Fatalf("%s changed the request's body", name)
+			}
+			reset()
+		})
+
+		budget, ok := allocBudgets[name]
+		if !ok {
+			t.Errorf("%s has no allocation budget", name)
+			continue
+		}
+		if allocs > budget {
+			t.Errorf("%s made %v allocations per request, budget is %v", name, allocs, budget)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package jsonware
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+/*
+PathParams looks up the value of a named path parameter of a request, it
+returns the empty string when there's no such parameter.
+
+Routers store the path parameters they matched in the request with
+WithPathParams, otherwise the wildcards matched by http.ServeMux are used.
+JSONHandlers use them to fill in the fields of the request object that are
+tagged with path.
+
+	type GetUser struct {
+		ID int `path:"id"`
+	}
+
+	// Served with /users/{id} on a router
+	func getUser(r *http.Request, in *GetUser) (*User, error)
+
+Fields tagged with cookie are filled in from the request's cookies in the
+same way.
+
+	type Profile struct {
+		Session string `cookie:"session_id"`
+	}
+
+Request objects with path or cookie fields may be used for GET and DELETE
+requests, in which case they're only filled in from the path and cookies.
+*/
+type PathParams func(name string) string
+
+type pathParamsKey struct{}
+
+// WithPathParams returns a shallow copy of r that looks up path parameters
+// with params. This is for integrating with routers.
+func WithPathParams(r *http.Request, params PathParams) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, params))
+}
+
+// pathParamsFrom finds the PathParams for the request, when no router has
+// set them they are the wildcards matched by http.ServeMux.
+func pathParamsFrom(r *http.Request) PathParams {
+	if params, ok := r.Context().Value(pathParamsKey{}).(PathParams); ok {
+		return params
+	}
+	return r.PathValue
+}
+
+/*
+WriteError writes err to w in the same way a JSONHandler would were err
+returned from the handler, logging cloaked errors to logger or the global
+logger when it's nil. This is for integrating with routers and frameworks so
+that their errors look the same as the handlers'.
+*/
+func WriteError(w http.ResponseWriter, logger io.Writer, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	writeError(w, logger, err)
+}
+
+// pathField is a field of a request object that is bound to a path
+// parameter.
+type pathField struct {
+	index int
+	name  string
+}
+
+// pathFields finds the fields of typ, a request object type, that are tagged
+// with path. Anything other than a pointer to a struct has none.
+func pathFields(typ reflect.Type) []pathField {
+	return taggedFields(typ, "path")
+}
+
+// cookieFields finds the fields of typ, a request object type, that are
+// tagged with cookie.
+func cookieFields(typ reflect.Type) []pathField {
+	return taggedFields(typ, "cookie")
+}
+
+// taggedFields finds the fields of typ that are tagged with tag and checks
+// they can be set from strings.
+func taggedFields(typ reflect.Type, tag string) []pathField {
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	typ = typ.Elem()
+
+	var fields []pathField
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, ok := f.Tag.Lookup(tag)
+		if !ok || len(f.PkgPath) != 0 {
+			continue
+		}
+		if !isBindable(f.Type) {
+			panic(fmt.Sprintf("Field %s.%s cannot be bound to a %s parameter", typ.Name(), f.Name, tag))
+		}
+		fields = append(fields, pathField{index: i, name: name})
+	}
+
+	return fields
+}
+
+// bindPath fills in the path fields of obj, a pointer to a struct, from the
+// path parameters of the request. Fields without a parameter are zeroed so
+// they can't be set from the request body instead.
+func bindPath(r *http.Request, obj reflect.Value, fields []pathField) error {
+	params := pathParamsFrom(r)
+
+	obj = obj.Elem()
+	for _, f := range fields {
+		field := obj.Field(f.index)
+		field.Set(reflect.Zero(field.Type()))
+
+		s := params(f.name)
+		if len(s) == 0 {
+			continue
+		}
+		if err := setString(field, s); err != nil {
+			return Err{
+				Status: http.StatusBadRequest,
+				Err:    fmt.Errorf("invalid path parameter %s", f.name),
+			}
+		}
+	}
+
+	return nil
+}
+
+// bindCookies fills in the cookie fields of obj, a pointer to a struct, from
+// the cookies of the request. Fields whose cookie is missing are zeroed so
+// they can't be set from the request body instead.
+func bindCookies(r *http.Request, obj reflect.Value, fields []pathField) error {
+	obj = obj.Elem()
+	for _, f := range fields {
+		field := obj.Field(f.index)
+		field.Set(reflect.Zero(field.Type()))
+
+		c, err := r.Cookie(f.name)
+		if err != nil || len(c.Value) == 0 {
+			continue
+		}
+		if err := setString(field, c.Value); err != nil {
+			return Err{
+				Status: http.StatusBadRequest,
+				Err:    fmt.Errorf("invalid cookie %s", f.name),
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+CookieSetter can be implemented by a handler's output type to set cookies
+along with the response, WithCookies is a ready made one.
+*/
+type CookieSetter interface {
+	Cookies() []*http.Cookie
+}
+
+/*
+WithCookies wraps the value a handler responds with to set cookies on the
+response. Only Value is serialized, and it goes through the handler's Codec,
+filters and views like an unwrapped value.
+
+	func login(r *http.Request, in *Login) (*jsonware.WithCookies, error) {
+		session, err := sessions.Start(in.User, in.Password)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonware.WithCookies{
+			Value:      session.User,
+			SetCookies: []*http.Cookie{{Name: "session_id", Value: session.ID, HttpOnly: true}},
+		}, nil
+	}
+*/
+type WithCookies struct {
+	Value      interface{}
+	SetCookies []*http.Cookie
+}
+
+// Cookies returns the cookies to set.
+func (w WithCookies) Cookies() []*http.Cookie {
+	return w.SetCookies
+}
+
+// MarshalJSON serializes the wrapped value.
+func (w WithCookies) MarshalJSON() ([]byte, error) {
+	return json.Marshal(w.Value)
+}
+
+func (w WithCookies) envelope() (interface{}, http.Header, int) {
+	header := make(http.Header)
+	for _, c := range w.SetCookies {
+		if v := c.String(); len(v) != 0 {
+			header.Add("Set-Cookie", v)
+		}
+	}
+	return w.Value, header, 0
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isBindable checks if setString can set values of typ.
+func isBindable(typ reflect.Type) bool {
+	if reflect.PtrTo(typ).Implements(textUnmarshalerType) {
+		return true
+	}
+
+	switch typ.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Ptr:
+		return isBindable(typ.Elem())
+	}
+	return false
+}
+
+// setString parses s into v according to v's type.
+func setString(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		ptr := reflect.New(v.Type().Elem())
+		if err := setString(ptr.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot set %s from a string", v.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package jsonware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindUser struct {
+	ID      int            `path:"id" json:"id"`
+	Slug    *string        `path:"slug" json:"slug"`
+	Active  bool           `path:"active" json:"active"`
+	Score   float64        `path:"score" json:"score"`
+	Since   time.Time      `path:"since" json:"since"`
+	Name    string         `json:"name"`
+	ignored map[string]int `path:"ignored"`
+}
+
+func bindGetHandler(r *http.Request, in *bindUser) *bindUser { return in }
+
+func TestBindPath(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		handler interface{}
+		method  string
+		params  map[string]string
+		reqbody string
+		status  int
+		resbody string
+	}{
+		{
+			bindGetHandler, "GET",
+			map[string]string{"id": "5", "slug": "bob", "active": "true", "score": "1.5", "since": "2020-01-02T03:04:05Z"},
+			"", 200,
+			`{"id":5,"slug":"bob","active":true,"score":1.5,"since":"2020-01-02T03:04:05Z","name":""}`,
+		},
+		{
+			bindGetHandler, "DELETE", map[string]string{"id": "5"}, "", 200,
+			`{"id":5,"slug":null,"active":false,"score":0,"since":"0001-01-01T00:00:00Z","name":""}`,
+		},
+		{
+			bindGetHandler, "POST", map[string]string{"id": "5"}, `{"id":6,"slug":"evil","active":true,"name":"bob"}`, 200,
+			`{"id":5,"slug":null,"active":false,"score":0,"since":"0001-01-01T00:00:00Z","name":"bob"}`,
+		},
+		{bindGetHandler, "GET", map[string]string{"id": "five"}, "", 400, `{"error":"invalid path parameter id"}`},
+		{bindGetHandler, "GET", nil, "", 200, `"id":0`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/", bytes.NewBufferString(test.reqbody))
+		req.Header = http.Header{"Accept": []string{"*/*"}}
+		if test.params != nil {
+			params := test.params
+			req = WithPathParams(req, func(name string) string { return params[name] })
+		}
+
+		Handler(test.handler).ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, res.Code)
+		}
+
+		if b := strings.TrimSpace(res.Body.String()); !strings.Contains(b, test.resbody) {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+	}
+}
+
+func TestBindPathBadField(t *testing.T) {
+	t.Parallel()
+
+	type bad struct {
+		Tags []string `path:"tags"`
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic")
+		}
+	}()
+
+	Handler(func(r *http.Request, in *bad) error { return nil })
+}
+
+type cookieType struct {
+	Session string `cookie:"session_id"`
+	Visits  int    `cookie:"visits"`
+	Name    string `json:"name"`
+}
+
+func TestBindCookies(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(func(r *http.Request, in *cookieType) *cookieType { return in })
+
+	var tests = []struct {
+		method  string
+		cookies []*http.Cookie
+		reqbody string
+		status  int
+		resbody string
+	}{
+		{"GET", []*http.Cookie{{Name: "session_id", Value: "abc"}, {Name: "visits", Value: "3"}}, "", 200, `{"Session":"abc","Visits":3,"name":""}`},
+		{"GET", nil, "", 200, `{"Session":"","Visits":0,"name":""}`},
+		{"POST", []*http.Cookie{{Name: "session_id", Value: "abc"}}, `{"Session":"evil","name":"bob"}`, 200, `{"Session":"abc","Visits":0,"name":"bob"}`},
+		{"POST", nil, `{"Session":"evil","Visits":9,"name":"bob"}`, 200, `{"Session":"","Visits":0,"name":"bob"}`},
+		{"GET", []*http.Cookie{{Name: "visits", Value: "many"}}, "", 400, `{"error":"invalid cookie visits"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/", strings.NewReader(test.reqbody))
+		req.Header.Set("Accept", "application/json")
+		for _, c := range test.cookies {
+			req.AddCookie(c)
+		}
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := res.Body.String(); b != test.resbody+"\n" {
+			t.Errorf("Test %d: body was wrong: %s", i, b)
+		}
+	}
+}
+
+func TestWithCookies(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(func(r *http.Request) *WithCookies {
+		return &WithCookies{
+			Value:      &testType{"bob"},
+			SetCookies: []*http.Cookie{{Name: "session_id", Value: "abc", HttpOnly: true}},
+		}
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(res, req)
+
+	if b := res.Body.String(); b != `{"name":"bob"}`+"\n" {
+		t.Error("Body was wrong:", b)
+	}
+	if c := res.Header().Get("Set-Cookie"); c != "session_id=abc; HttpOnly" {
+		t.Error("Cookie was wrong:", c)
+	}
+}
+
+func TestWithCookiesCodec(t *testing.T) {
+	t.Parallel()
+
+	type user struct {
+		UserName string `json:"user_name"`
+	}
+
+	h := Handler(func(r *http.Request) *WithCookies {
+		return &WithCookies{
+			Value:      &user{UserName: "bob"},
+			SetCookies: []*http.Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}},
+		}
+	}).Codec(&Codec{Keys: CamelCase})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(res, req)
+
+	if b := res.Body.String(); b != `{"userName":"bob"}`+"\n" {
+		t.Errorf("expected camel cased body, got: %s", b)
+	}
+	if c := res.Header().Values("Set-Cookie"); len(c) != 2 || c[0] != "a=1" || c[1] != "b=2" {
+		t.Errorf("expected both cookies, got: %q", c)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	t.Parallel()
+
+	res := httptest.NewRecorder()
+	WriteError(res, nil, Err{Status: http.StatusMethodNotAllowed, Err: errors.New("nope")})
+
+	if res.Code != http.StatusMethodNotAllowed {
+		t.Error("Status was wrong:", res.Code)
+	}
+	if ct := res.Header().Get("Content-Type"); ct != "application/json" {
+		t.Error("Content type was wrong:", ct)
+	}
+}
@@ -0,0 +1,167 @@
+package jsonware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	errCircuitOpen = errors.New("service is temporarily unavailable")
+	// errPanicked is the failure recorded for handlers that panic.
+	errPanicked = errors.New("handler panicked")
+)
+
+// BreakerPolicy decides when a JSONHandler's circuit breaker opens and for
+// how long.
+type BreakerPolicy struct {
+	// Failures is how many failures in a row open the breaker, 5 when it's
+	// zero.
+	Failures int
+	// Timeout is how long the handler may take before it counts as a
+	// failure, the handler's request context is canceled when it's up.
+	// Handlers aren't timed when it's zero.
+	Timeout time.Duration
+	// Cooldown is how long the breaker stays open before a request is let
+	// through to try the handler again, 30 seconds when it's zero.
+	Cooldown time.Duration
+}
+
+/*
+CircuitBreaker stops calling the handler once it fails Failures times in a
+row, with a cloaked error or by running longer than Timeout, so that a
+struggling dependency isn't buried under retries. While the breaker is open
+requests get a 503 Service Unavailable with a Retry-After header straight
+away. After Cooldown one request is let through, the breaker closes again if
+it succeeds and stays open for another Cooldown if it doesn't.
+
+Errors the client is told about with an Err aren't failures, a client sending
+bad requests can't open the breaker for everyone else. Requests rejected
+before the handler is called don't count either way, and handlers that panic
+are failures.
+
+	h := jsonware.Handler(getQuote).CircuitBreaker(jsonware.BreakerPolicy{
+		Failures: 3,
+		Timeout:  2 * time.Second,
+	})
+*/
+func (j *JSONHandler) CircuitBreaker(policy BreakerPolicy) *JSONHandler {
+	if policy.Failures <= 0 {
+		policy.Failures = 5
+	}
+	if policy.Cooldown <= 0 {
+		policy.Cooldown = 30 * time.Second
+	}
+	j.breaker = &breaker{policy: policy, now: time.Now}
+	return j
+}
+
+type breaker struct {
+	policy BreakerPolicy
+
+	mut      sync.Mutex
+	failures int
+	// openUntil is when the breaker lets a trial request through, it's
+	// zero while the breaker is closed.
+	openUntil time.Time
+	// trial is set while the trial request is running.
+	trial bool
+
+	// now is swapped out in tests.
+	now func() time.Time
+}
+
+// enter checks if a request may call the handler, returning how long the
+// client should wait when it may not.
+func (b *breaker) enter() (time.Duration, bool) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if b.openUntil.IsZero() {
+		return 0, true
+	}
+	if wait := b.openUntil.Sub(b.now()); wait > 0 {
+		return wait, false
+	}
+	if b.trial {
+		return time.Second, false
+	}
+	b.trial = true
+	return 0, true
+}
+
+// leave records how a request that called the handler went.
+func (b *breaker) leave(failed bool) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if !failed {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		b.trial = false
+		return
+	}
+
+	b.failures++
+	if b.trial || b.failures >= b.policy.Failures {
+		b.failures = 0
+		b.openUntil = b.now().Add(b.policy.Cooldown)
+		b.trial = false
+	}
+}
+
+// release lets another trial request through after one that never called
+// the handler, without counting it either way.
+func (b *breaker) release() {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.trial = false
+}
+
+// guard checks the breaker before a request, it returns the request to call
+// the handler with and a function to call once the request is done with
+// whether the handler was called and the error it returned. It writes the 503
+// and returns a nil request when the breaker is open.
+func (b *breaker) guard(w http.ResponseWriter, r *http.Request, logger io.Writer) (*http.Request, func(called bool, err error)) {
+	wait, ok := b.enter()
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeError(w, logger, Err{Status: http.StatusServiceUnavailable, Err: errCircuitOpen})
+		return nil, nil
+	}
+
+	start := b.now()
+	cancel := func() {}
+	if b.policy.Timeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(r.Context(), b.policy.Timeout)
+		r = r.WithContext(ctx)
+	}
+
+	return r, func(called bool, err error) {
+		cancel()
+		if !called {
+			b.release()
+			return
+		}
+		timedOut := b.policy.Timeout > 0 && b.now().Sub(start) > b.policy.Timeout
+		b.leave(timedOut || isCloaked(err))
+	}
+}
+
+// isCloaked checks if err is hidden from clients by writeError.
+func isCloaked(err error) bool {
+	if err == nil {
+		return false
+	}
+	if m, ok := asMultiErr(err); ok {
+		return m.StatusCode() >= 500
+	}
+	_, ok := asErr(err)
+	return !ok
+}
@@ -0,0 +1,181 @@
+package jsonware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	var fail error
+	var calls int
+	h := Handler(func(r *http.Request) (*testType, error) {
+		calls++
+		if fail != nil {
+			return nil, fail
+		}
+		return &testType{Name: "a"}, nil
+	}).Log(&bytes.Buffer{}).CircuitBreaker(BreakerPolicy{Failures: 2, Cooldown: 10 * time.Second})
+
+	now := time.Unix(1000, 0)
+	h.breaker.now = func() time.Time { return now }
+
+	var tests = []struct {
+		fail       error
+		advance    time.Duration
+		status     int
+		called     bool
+		retryAfter string
+	}{
+		{nil, 0, 200, true, ""},
+		{errors.New("db down"), 0, 500, true, ""},
+		// Errors clients are told about don't count.
+		{Err{Status: 404, Err: errors.New("not found")}, 0, 404, true, ""},
+		{errors.New("db down"), 0, 500, true, ""},
+		{errors.New("db down"), 0, 500, true, ""},
+		{nil, 0, 503, false, "10"},
+		{nil, 7500 * time.Millisecond, 503, false, "3"},
+		// The trial fails and the breaker opens again.
+		{errors.New("db down"), 3 * time.Second, 500, true, ""},
+		{nil, 0, 503, false, "10"},
+		// The trial succeeds and the breaker closes.
+		{nil, 10 * time.Second, 200, true, ""},
+		{errors.New("db down"), 0, 500, true, ""},
+		{nil, 0, 200, true, ""},
+	}
+
+	for i, test := range tests {
+		fail, calls = test.fail, 0
+		now = now.Add(test.advance)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if (calls != 0) != test.called {
+			t.Errorf("Test %d: expected called %t, got: %t", i, test.called, calls != 0)
+		}
+		if got := res.Header().Get("Retry-After"); got != test.retryAfter {
+			t.Errorf("Test %d: expected Retry-After %q, got: %q", i, test.retryAfter, got)
+		}
+	}
+}
+
+func TestCircuitBreakerTimeout(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(func(r *http.Request) (*testType, error) {
+		<-r.Context().Done()
+		return &testType{Name: "slow"}, nil
+	}).CircuitBreaker(BreakerPolicy{Failures: 1, Timeout: 10 * time.Millisecond})
+
+	for i, want := range []int{200, 503} {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != want {
+			t.Errorf("Test %d: expected status %d, got: %d", i, want, res.Code)
+		}
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000, 0)
+	b := &breaker{policy: BreakerPolicy{Failures: 1, Cooldown: time.Second}, now: func() time.Time { return now }}
+
+	b.leave(true)
+	if _, ok := b.enter(); ok {
+		t.Error("expected the breaker to be open")
+	}
+
+	now = now.Add(time.Second)
+	if _, ok := b.enter(); !ok {
+		t.Error("expected a trial request to be let through")
+	}
+	if wait, ok := b.enter(); ok || wait != time.Second {
+		t.Errorf("expected other requests to wait for the trial, got: %s %t", wait, ok)
+	}
+}
+
+func TestCircuitBreakerNotCalled(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	h := Handler(func(r *http.Request, in *testType) (*testType, error) {
+		calls++
+		if in.Name == "fail" {
+			return nil, errors.New("db down")
+		}
+		return in, nil
+	}).Log(&bytes.Buffer{}).CircuitBreaker(BreakerPolicy{Failures: 3, Cooldown: time.Second})
+
+	now := time.Unix(1000, 0)
+	h.breaker.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		h.breaker.leave(true)
+	}
+	now = now.Add(time.Second)
+
+	var tests = []struct {
+		body   string
+		status int
+	}{
+		// The trial is rejected before the handler's called, the next
+		// request is still a trial and opens the breaker again.
+		{`{"name"`, 400},
+		{`{"name":"fail"}`, 500},
+		{`{"name":"a"}`, 503},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got: %d", calls)
+	}
+}
+
+func TestCircuitBreakerPanic(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(func(r *http.Request) (*testType, error) {
+		panic("boom")
+	}).CircuitBreaker(BreakerPolicy{Failures: 1})
+
+	serve := func() (code int, panicked bool) {
+		defer func() { panicked = recover() != nil }()
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+		return res.Code, false
+	}
+
+	if _, panicked := serve(); !panicked {
+		t.Error("expected the panic to be passed on")
+	}
+	if code, _ := serve(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected the breaker to open, got: %d", code)
+	}
+}
@@ -0,0 +1,138 @@
+package jsonware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeoutHeader is the header a request's time budget is read from, and sent
+// to the services called by clients made with Client.
+const TimeoutHeader = "X-Request-Timeout"
+
+var (
+	errBadTimeout    = errors.New("invalid request timeout")
+	errBudgetExpired = errors.New("request deadline has passed")
+)
+
+/*
+Budget gives requests the deadline their clients ask for, so that a request
+that's passed through several services stops everywhere once its caller has
+given up on it. The budget is read from the X-Request-Timeout header, a
+duration like 250ms or 1.5s or a number of seconds, or from a grpc-timeout
+header like 250m. Requests that ask for a budget that's already spent get a
+504 Gateway Timeout without being served, and ones that ask for one that
+can't be read get a 400.
+
+Handlers see the budget as their request context's deadline, and clients
+made with Client send what's left of it to the services they call.
+
+	budget := jsonware.Budget{Default: 10 * time.Second, Max: time.Minute}
+	http.Handle("/", budget.Wrap(mux))
+*/
+type Budget struct {
+	// Default is the budget of requests that don't ask for one, they have
+	// none when it's zero.
+	Default time.Duration
+	// Max caps the budget requests may ask for, there's no cap when it's
+	// zero.
+	Max time.Duration
+
+	// Log is where errors are reported, the global logger is used when it's
+	// nil.
+	Log io.Writer
+}
+
+// Wrap returns a handler that serves requests with h within their budget.
+func (b Budget) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budget, asked, err := requestBudget(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, b.Log, Err{Status: http.StatusBadRequest, Err: err})
+			return
+		}
+		if !asked {
+			budget = b.Default
+		}
+		if b.Max > 0 && budget > b.Max {
+			budget = b.Max
+		}
+		if asked && budget <= 0 {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, b.Log, Err{Status: http.StatusGatewayTimeout, Err: errBudgetExpired})
+			return
+		}
+		if budget <= 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), budget)
+		defer cancel()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestBudget reads the budget a request asks for.
+func requestBudget(r *http.Request) (time.Duration, bool, error) {
+	if v := strings.TrimSpace(r.Header.Get(TimeoutHeader)); len(v) != 0 {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(secs * float64(time.Second)), true, nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, true, errBadTimeout
+		}
+		return d, true, nil
+	}
+
+	if v := strings.TrimSpace(r.Header.Get("Grpc-Timeout")); len(v) != 0 {
+		d, err := parseGRPCTimeout(v)
+		return d, true, err
+	}
+	return 0, false, nil
+}
+
+// parseGRPCTimeout reads a grpc-timeout, at most 8 digits followed by a unit
+// of H, M, S, m, u or n.
+func parseGRPCTimeout(v string) (time.Duration, error) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, errBadTimeout
+	}
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, errBadTimeout
+	}
+
+	var unit time.Duration
+	switch v[len(v)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, errBadTimeout
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// formatBudget writes what's left of a budget for TimeoutHeader.
+func formatBudget(remaining time.Duration) string {
+	if remaining < time.Millisecond {
+		return "0s"
+	}
+	return remaining.Truncate(time.Millisecond).String()
+}
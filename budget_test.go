@@ -0,0 +1,89 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		budget  Budget
+		header  string
+		value   string
+		status  int
+		timeout time.Duration
+	}{
+		{Budget{}, "", "", 200, 0},
+		{Budget{Default: time.Second}, "", "", 200, time.Second},
+		{Budget{}, TimeoutHeader, "250ms", 200, 250 * time.Millisecond},
+		{Budget{}, TimeoutHeader, "1.5", 200, 1500 * time.Millisecond},
+		{Budget{Max: time.Second}, TimeoutHeader, "1h", 200, time.Second},
+		{Budget{Default: time.Second}, "Grpc-Timeout", "300m", 200, 300 * time.Millisecond},
+		{Budget{}, "Grpc-Timeout", "2S", 200, 2 * time.Second},
+		{Budget{}, TimeoutHeader, "0", 504, 0},
+		{Budget{}, TimeoutHeader, "-1s", 504, 0},
+		{Budget{}, TimeoutHeader, "soon", 400, 0},
+		{Budget{}, "Grpc-Timeout", "5x", 400, 0},
+		{Budget{}, "Grpc-Timeout", "123456789S", 400, 0},
+	}
+
+	for i, test := range tests {
+		var got time.Duration
+		called := false
+		h := test.budget.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			if deadline, ok := r.Context().Deadline(); ok {
+				got = time.Until(deadline)
+			}
+		}))
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		if len(test.header) != 0 {
+			req.Header.Set(test.header, test.value)
+		}
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if called != (test.status == 200) {
+			t.Errorf("Test %d: expected the handler to be called %t", i, test.status == 200)
+		}
+		if got > test.timeout || got < test.timeout-100*time.Millisecond {
+			t.Errorf("Test %d: expected a budget of %v, got: %v", i, test.timeout, got)
+		}
+	}
+}
+
+func TestBudgetPropagates(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(TimeoutHeader)
+	}))
+	defer srv.Close()
+
+	h := Budget{}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, err := Client(r).Get(srv.URL)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		res.Body.Close()
+	}))
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(TimeoutHeader, "2s")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	remaining, err := time.ParseDuration(<-received)
+	if err != nil || remaining > 2*time.Second || remaining < time.Second {
+		t.Errorf("expected the remaining budget to be sent, got: %v %v", remaining, err)
+	}
+}
@@ -0,0 +1,72 @@
+package jsonware
+
+import (
+	"io"
+	"net/http"
+)
+
+/*
+CountBytes makes the JSONHandler call fn with how many bytes of the request
+body it read and how many bytes of response body it wrote once it's done
+with each request, so bandwidth can be put down to the endpoint that used it
+in metrics and access logs. Headers aren't counted, and the bytes are the
+ones that passed through the handler, so they're counted before a compressing
+wrapper outside it and after one inside it.
+
+	h.CountBytes(func(r *http.Request, read, written int64) {
+		ingress.WithLabelValues("get_order").Add(float64(read))
+		egress.WithLabelValues("get_order").Add(float64(written))
+	})
+*/
+func (j *JSONHandler) CountBytes(fn func(r *http.Request, read, written int64)) *JSONHandler {
+	j.countBytes = fn
+	return j
+}
+
+// counted wraps the body of r and w to count the bytes that pass through
+// them, and returns the func that reports the counts.
+func (j JSONHandler) counted(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request, func()) {
+	cw := &countingWriter{ResponseWriter: w}
+	var cr *countingReader
+	if r.Body != nil && r.Body != http.NoBody {
+		cr = &countingReader{ReadCloser: r.Body}
+		r2 := *r
+		r2.Body = cr
+		r = &r2
+	}
+
+	return cw, r, func() {
+		var read int64
+		if cr != nil {
+			read = cr.n
+		}
+		j.countBytes(r, read, cw.n)
+	}
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written to a response body.
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (c *countingWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
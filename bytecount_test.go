@@ -0,0 +1,54 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCountBytes(t *testing.T) {
+	t.Parallel()
+
+	post := func(r *http.Request, in *testType) (*testType, error) { return &testType{Name: "bob"}, nil }
+	get := func(r *http.Request) (*testType, error) { return &testType{Name: "bob"}, nil }
+
+	var tests = []struct {
+		fn      interface{}
+		method  string
+		body    string
+		read    int64
+		written int64
+	}{
+		{post, "POST", `{"name":"bob"}`, 14, 15},
+		{post, "POST", `{"name":1}`, 10, 52},
+		{get, "GET", ``, 0, 15},
+	}
+
+	for i, test := range tests {
+		var read, written int64
+		calls := 0
+		h := Handler(test.fn).CountBytes(func(r *http.Request, rd, wr int64) {
+			calls++
+			read, written = rd, wr
+		})
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/", strings.NewReader(test.body))
+		if len(test.body) == 0 {
+			req.Body = http.NoBody
+		}
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if calls != 1 {
+			t.Errorf("Test %d: expected 1 call, got: %d", i, calls)
+		}
+		if read != test.read {
+			t.Errorf("Test %d: expected %d bytes read, got: %d", i, test.read, read)
+		}
+		if written != test.written || written != int64(res.Body.Len()) {
+			t.Errorf("Test %d: expected %d bytes written, got: %d (body is %d)", i, test.written, written, res.Body.Len())
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package jsonware
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// utf8BOM is the byte order mark some clients put at the start of utf-8
+// text, json has no use for it and the decoder chokes on it.
+const utf8BOM = "\xef\xbb\xbf"
+
+var charsets = map[string]func(io.Reader) io.Reader{
+	"utf-8":    nil,
+	"utf8":     nil,
+	"us-ascii": nil,
+}
+
+/*
+RegisterCharset makes JSONHandlers accept request bodies in a charset other
+than utf-8, name is the charset as it appears in the charset parameter of the
+Content-Type header. newDecoder wraps a body in a reader that transcodes it to
+utf-8. utf-8 and us-ascii are always registered.
+
+Request bodies with a charset that isn't registered are rejected with a 415.
+
+	jsonware.RegisterCharset("iso-8859-1", func(r io.Reader) io.Reader {
+		return charmap.ISO8859_1.NewDecoder().Reader(r)
+	})
+*/
+func RegisterCharset(name string, newDecoder func(r io.Reader) io.Reader) {
+	if len(name) == 0 || newDecoder == nil {
+		panic("RegisterCharset must be given a name and a decoder constructor")
+	}
+	charsets[strings.ToLower(name)] = newDecoder
+}
+
+// decodeCharset returns a copy of the request whose body is utf-8 without a
+// byte order mark, the caller's request is left alone so that reusing it
+// doesn't stack readers.
+func decodeCharset(r *http.Request) (*http.Request, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return r, nil
+	}
+
+	var rd io.Reader = r.Body
+	ct := r.Header.Get("Content-Type")
+	// Parsing allocates, most requests don't name a charset at all.
+	if strings.Contains(strings.ToLower(ct), "charset") {
+		_, params, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return nil, Err{
+				Status: http.StatusBadRequest,
+				Err:    fmt.Errorf("invalid content type: %s", ct),
+			}
+		}
+
+		name := strings.ToLower(strings.TrimSpace(params["charset"]))
+		newDecoder, ok := charsets[name]
+		if !ok && len(name) != 0 {
+			return nil, Err{
+				Status: http.StatusUnsupportedMediaType,
+				Err:    fmt.Errorf("unsupported charset: %s", name),
+			}
+		}
+		if newDecoder != nil {
+			rd = newDecoder(rd)
+		}
+	}
+
+	// Whether there's a byte order mark is only known once the body is read,
+	// so the stripper is always needed, but it costs nothing after the check.
+	r2 := *r
+	r2.Body = &bomStripper{r: rd, orig: r.Body}
+	return &r2, nil
+}
+
+// bomStripper drops a utf-8 byte order mark from the start of a body.
+type bomStripper struct {
+	r    io.Reader
+	orig io.Closer
+
+	checked bool
+	head    [3]byte
+	// pending is the part of head that's still to be read.
+	pending []byte
+}
+
+func (b *bomStripper) Read(p []byte) (int, error) {
+	if !b.checked {
+		b.checked = true
+		n, err := io.ReadFull(b.r, b.head[:])
+		if string(b.head[:n]) != utf8BOM {
+			b.pending = b.head[:n]
+		}
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return 0, err
+		}
+	}
+
+	if len(b.pending) != 0 {
+		n := copy(p, b.pending)
+		b.pending = b.pending[n:]
+		return n, nil
+	}
+	return b.r.Read(p)
+}
+
+func (b *bomStripper) Close() error {
+	return b.orig.Close()
+}
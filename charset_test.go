@@ -0,0 +1,74 @@
+package jsonware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func init() {
+	// Registered up front since the charsets aren't safe to change while
+	// parallel tests are serving requests.
+	RegisterCharset("x-test-upper", func(r io.Reader) io.Reader {
+		b, _ := io.ReadAll(r)
+		return strings.NewReader(strings.Replace(string(b), "bob", "BOB", 1))
+	})
+}
+
+func TestCharset(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		contentType string
+		body        string
+		status      int
+		resbody     string
+	}{
+		{"application/json", `{"name":"bob"}`, 200, `{"name":"bob"}`},
+		{"application/json; charset=utf-8", `{"name":"bob"}`, 200, `{"name":"bob"}`},
+		{"application/json; charset=UTF-8", `{"name":"bob"}`, 200, `{"name":"bob"}`},
+		{"application/json; charset=us-ascii", `{"name":"bob"}`, 200, `{"name":"bob"}`},
+		{"application/json", "\xef\xbb\xbf" + `{"name":"bob"}`, 200, `{"name":"bob"}`},
+		{"application/json; charset=utf-8", "\xef\xbb\xbf" + `{"name":"bob"}`, 200, `{"name":"bob"}`},
+		{"application/json; charset=x-test-upper", `{"name":"bob"}`, 200, `{"name":"BOB"}`},
+		{"application/json; charset=utf-16", `{"name":"bob"}`, 415, "unsupported charset: utf-16"},
+		{"application/json; charset=", `{"name":"bob"}`, 400, "invalid content type"},
+		{"application/json", "\xef\xbb", 400, "could not deserialize"},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Content-Type", test.contentType)
+		Handler(func(r *http.Request, in *testType) *testType { return in }).ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d %s", i, test.status, res.Code, res.Body.String())
+		}
+		if b := res.Body.String(); !strings.Contains(b, test.resbody) {
+			t.Errorf("Test %d: expected body to contain %s, got: %s", i, test.resbody, b)
+		}
+	}
+}
+
+func TestCharsetLeavesRequest(t *testing.T) {
+	t.Parallel()
+
+	body := io.NopCloser(strings.NewReader(`{"name":"bob"}`))
+	req, _ := http.NewRequest("POST", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Body = body
+
+	res := httptest.NewRecorder()
+	Handler(func(r *http.Request, in *testType) *testType { return in }).ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Errorf("expected status 200, got: %d", res.Code)
+	}
+	if req.Body != body {
+		t.Error("expected the caller's request body to be left alone")
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Package chiadapter registers jsonware handlers on chi routers.
+
+Path parameters matched by chi are bound to the path tagged fields of the
+handlers' request objects, OPTIONS requests are answered with the methods a
+pattern supports, and requests with the wrong method get a json 405 error
+unless KeepMethodNotAllowed is used.
+
+	r := chi.NewRouter()
+	reg := &jsonware.Registry{}
+	chiadapter.Route(r, "GET", "/users/{id}", getUser, chiadapter.Registry(reg))
+*/
+package chiadapter
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aarondl/jsonware"
+	"github.com/go-chi/chi/v5"
+)
+
+// methods are the methods checked when working out which methods a path
+// supports.
+var methods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// Option configures a route.
+type Option func(*options)
+
+type options struct {
+	registry       *jsonware.Registry
+	logger         io.Writer
+	keepNotAllowed bool
+}
+
+// Registry adds the route to reg as well, so it can be used for mock servers
+// and documentation.
+func Registry(reg *jsonware.Registry) Option {
+	return func(o *options) {
+		o.registry = reg
+	}
+}
+
+// Log sets the handler's logger, see JSONHandler.Log.
+func Log(logger io.Writer) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// KeepMethodNotAllowed leaves the router's own handler for requests with the
+// wrong method alone, instead of replacing it with one that writes a json
+// 405 error. It's needed on every route added after the router's handler is
+// set.
+func KeepMethodNotAllowed() Option {
+	return func(o *options) {
+		o.keepNotAllowed = true
+	}
+}
+
+// Route creates a JSONHandler from fn (see jsonware.Handler) and registers it
+// on r for method and pattern.
+func Route(r chi.Router, method, pattern string, fn interface{}, opts ...Option) *jsonware.JSONHandler {
+	h := jsonware.Handler(fn)
+	Add(r, method, pattern, h, opts...)
+	return h
+}
+
+// Add registers an existing JSONHandler on r for method and pattern.
+func Add(r chi.Router, method, pattern string, h *jsonware.JSONHandler, opts ...Option) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.logger != nil {
+		h.Log(o.logger)
+	}
+	if o.registry != nil {
+		o.registry.Add(method, pattern, h)
+	}
+
+	r.Method(method, pattern, Bind(h))
+	r.Options(pattern, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Allow", strings.Join(append(allowed(r, req), "OPTIONS"), ", "))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	if !o.keepNotAllowed {
+		r.MethodNotAllowed(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Allow", strings.Join(append(allowed(r, req), "OPTIONS"), ", "))
+			jsonware.WriteError(w, o.logger, jsonware.Err{
+				Status: http.StatusMethodNotAllowed,
+				Err:    errors.New("method not allowed"),
+			})
+		})
+	}
+}
+
+// Bind wraps h so that it receives the path parameters chi matched.
+func Bind(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, jsonware.WithPathParams(r, func(name string) string {
+			return chi.URLParam(r, name)
+		}))
+	})
+}
+
+// allowed finds the methods r has routes for on the request's path.
+func allowed(r chi.Router, req *http.Request) []string {
+	path := req.URL.RawPath
+	if len(path) == 0 {
+		path = req.URL.Path
+	}
+	if rctx := chi.RouteContext(req.Context()); rctx != nil && len(rctx.RoutePath) != 0 {
+		path = rctx.RoutePath
+	}
+
+	var allow []string
+	for _, m := range methods {
+		if r.Match(chi.NewRouteContext(), m, path) {
+			allow = append(allow, m)
+		}
+	}
+	return allow
+}
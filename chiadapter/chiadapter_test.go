@@ -0,0 +1,95 @@
+package chiadapter
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aarondl/jsonware"
+	"github.com/go-chi/chi/v5"
+)
+
+type user struct {
+	ID   int    `path:"id" json:"id"`
+	Name string `json:"name"`
+}
+
+func getUser(r *http.Request, in *user) *user { return in }
+
+func updateUser(r *http.Request, in *user) (*user, error) { return in, nil }
+
+func TestRoute(t *testing.T) {
+	t.Parallel()
+
+	reg := &jsonware.Registry{}
+	r := chi.NewRouter()
+	Route(r, "GET", "/users/{id}", getUser, Registry(reg))
+	Route(r, "PUT", "/users/{id}", updateUser, Registry(reg))
+	r.Route("/v2", func(sub chi.Router) {
+		Route(sub, "GET", "/users/{id}", getUser)
+	})
+
+	var tests = []struct {
+		method  string
+		path    string
+		reqbody string
+		status  int
+		allow   string
+		resbody string
+	}{
+		{"GET", "/users/5", "", 200, "", `{"id":5,"name":""}`},
+		{"PUT", "/users/5", `{"name":"bob"}`, 200, "", `{"id":5,"name":"bob"}`},
+		{"GET", "/users/x", "", 400, "", `{"error":"invalid path parameter id"}`},
+		{"GET", "/v2/users/7", "", 200, "", `{"id":7,"name":""}`},
+		{"OPTIONS", "/users/5", "", 204, "GET, PUT, OPTIONS", ""},
+		{"DELETE", "/users/5", "", 405, "GET, PUT, OPTIONS", `{"error":"method not allowed"}`},
+		{"DELETE", "/v2/users/5", "", 405, "GET, OPTIONS", `{"error":"method not allowed"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, bytes.NewBufferString(test.reqbody))
+		req.Header.Set("Accept", "application/json")
+		r.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, res.Code)
+		}
+
+		if allow := res.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected allow: %q, got: %q", test.allow, allow)
+		}
+
+		if b := strings.TrimSpace(res.Body.String()); b != test.resbody {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+	}
+
+	if routes := reg.Routes(); len(routes) != 2 || routes[0].Pattern != "/users/{id}" || routes[1].Method != "PUT" {
+		t.Errorf("Registry was wrong: %#v", routes)
+	}
+}
+
+func TestRouteKeepsMethodNotAllowed(t *testing.T) {
+	t.Parallel()
+
+	r := chi.NewRouter()
+	Route(r, "GET", "/users/{id}", getUser)
+	r.MethodNotAllowed(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	Route(r, "PUT", "/users/{id}", updateUser, KeepMethodNotAllowed())
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/users/5", nil)
+	r.ServeHTTP(res, req)
+
+	if res.Code != http.StatusTeapot {
+		t.Errorf("expected the router's own 405 handler to be kept, got: %d", res.Code)
+	}
+}
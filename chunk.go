@@ -0,0 +1,91 @@
+package jsonware
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+)
+
+/*
+Chunking sends large list responses an item at a time instead of encoding
+all of them before anything is sent, so that the encoded response never has
+to be held in memory whole. The response is the same json either way, it's
+only sent in pieces, flushed as they're encoded.
+
+Responses the handler needs whole, to sign, digest, check against a schema,
+pass to AfterEncode hooks or emit, are never chunked, and neither are the
+responses of handlers with a MaxResponseSize, which decides itself.
+*/
+type Chunking struct {
+	// Threshold is how many items a slice response must have to be chunked.
+	Threshold int
+	// Metrics is called with every response that's encoded, with whether it
+	// was chunked and how many bytes of it were sent.
+	Metrics func(r *http.Request, chunked bool, size int)
+}
+
+/*
+ChunkLists makes the JSONHandler chunk its large list responses as c says.
+
+	h := jsonware.Handler(exportOrders).ChunkLists(jsonware.Chunking{Threshold: 1000})
+
+Chunked responses are encoded with the handler's Codec, or a zero Codec in
+place of encoding/json, since encoding/json only encodes values whole.
+*/
+func (j *JSONHandler) ChunkLists(c Chunking) *JSONHandler {
+	j.chunking = &c
+	return j
+}
+
+// chunks checks if the response v is chunked.
+func (c *Chunking) chunks(j JSONHandler, v interface{}) bool {
+	if _, raw := v.(Raw); raw || j.responseLimit != nil || needsWholeBody(j) {
+		return false
+	}
+	list := reflect.ValueOf(v)
+	for list.IsValid() && (list.Kind() == reflect.Pointer || list.Kind() == reflect.Interface) && !list.IsNil() {
+		list = list.Elem()
+	}
+	return list.IsValid() && list.Kind() == reflect.Slice && list.Len() > c.Threshold
+}
+
+// needsWholeBody checks if a handler does something with its encoded
+// responses that can't be done a piece at a time.
+func needsWholeBody(j JSONHandler) bool {
+	return len(j.afterEncode) != 0 || j.jws != nil || len(j.digest) != 0 || j.responseSchema != nil ||
+		(j.responseCheck != nil && j.responseCheck.Schema != nil) || j.emitter != nil
+}
+
+// encodeTo serializes v straight to w as it's encoded, writing it out in
+// chunks.
+func encodeTo(w io.Writer, v interface{}, c *Codec) error {
+	if c == nil {
+		c = &Codec{}
+	}
+
+	e := &codecState{codec: c, out: w}
+	if err := e.value(reflect.ValueOf(v), false); err != nil {
+		return err
+	}
+	e.WriteByte('\n')
+	_, err := e.WriteTo(w)
+	return err
+}
+
+// flushWriter flushes everything written to it to the client, counting how
+// much that was.
+type flushWriter struct {
+	rc      *http.ResponseController
+	w       io.Writer
+	written int
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	f.written += n
+	if err != nil {
+		return n, err
+	}
+	_ = f.rc.Flush()
+	return n, nil
+}
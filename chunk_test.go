@@ -0,0 +1,63 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChunkLists(t *testing.T) {
+	t.Parallel()
+
+	small := limitItems(3)
+	large := limitItems(5000)
+	want, _ := (&Codec{}).Marshal(large)
+
+	type metric struct {
+		chunked bool
+		size    int
+	}
+	var tests = []struct {
+		items   []limitItem
+		digest  bool
+		chunked bool
+		flushes bool
+	}{
+		{small, false, false, false},
+		{large, false, true, true},
+		{large, true, false, false},
+	}
+
+	for i, test := range tests {
+		var got []metric
+		items := test.items
+		h := Handler(func(r *http.Request) (*[]limitItem, error) { return &items, nil }).
+			ChunkLists(Chunking{Threshold: 100, Metrics: func(r *http.Request, chunked bool, size int) {
+				got = append(got, metric{chunked, size})
+			}})
+		if test.digest {
+			h.Digest("sha-256")
+		}
+
+		res := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		body := res.Body.String()
+		expect, _ := (&Codec{}).Marshal(test.items)
+		if body != string(expect)+"\n" {
+			t.Errorf("Test %d: expected the items, got %d bytes: %.100s", i, len(body), body)
+		}
+		if len(got) != 1 || got[0].chunked != test.chunked || got[0].size != len(body) {
+			t.Errorf("Test %d: expected a metric of chunked %t and %d bytes, got: %v", i, test.chunked, len(body), got)
+		}
+		if flushed := len(res.flushes) > 1; flushed != test.flushes {
+			t.Errorf("Test %d: expected flushes %t, got %d flushes", i, test.flushes, len(res.flushes))
+		}
+		if test.chunked && !strings.HasPrefix(body, string(want[:100])) {
+			t.Errorf("Test %d: expected the chunked response to match encoding it whole", i)
+		}
+	}
+}
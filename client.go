@@ -0,0 +1,101 @@
+package jsonware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// PropagatedHeaders are the headers of an incoming request that clients made
+// by Client and Transport forward to the services they call, the ones of the
+// common tracing formats and request ids.
+var PropagatedHeaders = []string{
+	"Traceparent",
+	"Tracestate",
+	"Baggage",
+	"X-Request-Id",
+	"X-Correlation-Id",
+	"X-B3-Traceid",
+	"X-B3-Spanid",
+	"X-B3-Parentspanid",
+	"X-B3-Sampled",
+	"B3",
+	"X-Cloud-Trace-Context",
+	"X-Amzn-Trace-Id",
+}
+
+/*
+Client returns an http client for calling other services while serving r. It
+forwards the tracing and request id headers of r that are in
+PropagatedHeaders, and calls made with it give up once r's deadline has
+passed, so that a chain of services stays tied to the request that started
+it. What's left of the deadline is sent in the X-Request-Timeout header for
+a Budget in the service that's called to pick up.
+
+	func getOrder(r *http.Request) (*Order, error) {
+		res, err := jsonware.Client(r).Get(inventoryURL + "/stock")
+		...
+	}
+*/
+func Client(r *http.Request) *http.Client {
+	return &http.Client{Transport: Transport(r, nil)}
+}
+
+// Transport wraps base so that the requests it makes carry on the trace and
+// deadline of r, like Client. base is http.DefaultTransport when it's nil.
+// Headers the outgoing request already has are left alone.
+func Transport(r *http.Request, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &propagator{from: r, base: base}
+}
+
+// propagator is the RoundTripper that forwards the headers and deadline of
+// the request it's from.
+type propagator struct {
+	from *http.Request
+	base http.RoundTripper
+}
+
+func (p *propagator) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	cancel := func() {}
+	if deadline, ok := p.from.Context().Deadline(); ok {
+		if own, ok := ctx.Deadline(); !ok || deadline.Before(own) {
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+		}
+	}
+
+	out := req.Clone(ctx)
+	for _, name := range PropagatedHeaders {
+		if v := p.from.Header.Values(name); len(v) != 0 && len(out.Header.Values(name)) == 0 {
+			out.Header[http.CanonicalHeaderKey(name)] = append([]string(nil), v...)
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok && len(out.Header.Get(TimeoutHeader)) == 0 {
+		out.Header.Set(TimeoutHeader, formatBudget(time.Until(deadline)))
+	}
+
+	res, err := p.base.RoundTrip(out)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	res.Body = cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+// cancelOnClose cancels the context of a request once its response has been
+// read.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,70 @@
+package jsonware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}))
+	defer srv.Close()
+
+	in, _ := http.NewRequest("GET", "/", nil)
+	in.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	in.Header.Add("Baggage", "a=1")
+	in.Header.Add("Baggage", "b=2")
+	in.Header.Set("X-Request-Id", "abc")
+	in.Header.Set("Authorization", "Bearer secret")
+
+	out, _ := http.NewRequest("GET", srv.URL, nil)
+	out.Header.Set("X-Request-Id", "own")
+	res, err := Client(in).Do(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	h := <-received
+	if h.Get("Traceparent") != in.Header.Get("Traceparent") {
+		t.Errorf("expected the trace to be forwarded, got: %v", h)
+	}
+	if b := h.Values("Baggage"); len(b) != 2 || b[1] != "b=2" {
+		t.Errorf("expected all baggage to be forwarded, got: %v", b)
+	}
+	if id := h.Get("X-Request-Id"); id != "own" {
+		t.Errorf("expected the outgoing request's own id to be kept, got: %s", id)
+	}
+	if len(h.Get("Authorization")) != 0 {
+		t.Errorf("expected credentials not to be forwarded")
+	}
+	if len(out.Header.Get("Traceparent")) != 0 {
+		t.Errorf("expected the outgoing request not to be changed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = Client(in.WithContext(ctx)).Get(srv.URL + "/slow")
+	<-received
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the call to give up at the deadline, got: %v", err)
+	}
+	if took := time.Since(start); took > 2*time.Second {
+		t.Errorf("expected the call to be canceled, it took: %v", took)
+	}
+}
@@ -0,0 +1,244 @@
+package jsonware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type clientIPKey struct{}
+
+// ClientIP returns the ip address of the client that made the request. It's
+// the address a RealIP resolved for the request if there was one in front
+// of the handler, otherwise it's the address of the connection.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// remoteIP is the ip address of the connection a request came in on.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+/*
+RealIP finds the real ip address of clients behind the proxies and load
+balancers listed in TrustedProxies, and stores it in the request's context
+for ClientIP to return. TrustedProxies are CIDRs like "10.0.0.0/8" or single
+addresses.
+
+Forwarding headers are only believed when the connection comes from a
+trusted proxy, since anyone can send them. The Forwarded header is used if
+there is one, then X-Forwarded-For, then X-Real-IP. The addresses in the
+forwarding chain are walked from the nearest proxy back towards the client,
+and the first one that isn't trusted is the client.
+
+	realIP := &jsonware.RealIP{TrustedProxies: []string{"10.0.0.0/8"}}
+	limit := &jsonware.RateLimit{Rate: jsonware.Rate{PerSecond: 5, Burst: 10}}
+	http.Handle("/users/", realIP.Wrap(limit.Wrap(jsonware.Handler(getUser))))
+
+RealIP panics if one of TrustedProxies can't be parsed.
+*/
+type RealIP struct {
+	TrustedProxies []string
+
+	once    sync.Once
+	trusted []netip.Prefix
+}
+
+// Wrap returns a handler that resolves the client's ip before serving
+// requests with h.
+func (ri *RealIP) Wrap(h http.Handler) http.Handler {
+	ri.parse()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := ri.Resolve(r)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+	})
+}
+
+// Resolve works out the ip address of the client that made the request.
+func (ri *RealIP) Resolve(r *http.Request) string {
+	ri.parse()
+
+	remote := remoteIP(r)
+	if !ri.isTrusted(remote) {
+		return remote
+	}
+
+	var chain []string
+	switch {
+	case len(r.Header.Values("Forwarded")) != 0:
+		chain = forwardedFor(r.Header.Values("Forwarded"))
+	case len(r.Header.Values("X-Forwarded-For")) != 0:
+		for _, v := range r.Header.Values("X-Forwarded-For") {
+			for _, ip := range strings.Split(v, ",") {
+				chain = append(chain, strings.TrimSpace(ip))
+			}
+		}
+	case len(r.Header.Get("X-Real-Ip")) != 0:
+		chain = []string{strings.TrimSpace(r.Header.Get("X-Real-Ip"))}
+	}
+
+	client := remote
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := chain[i]
+		if _, err := netip.ParseAddr(ip); err != nil {
+			// Whatever's past garbage can't be trusted either.
+			break
+		}
+		client = ip
+		if !ri.isTrusted(ip) {
+			break
+		}
+	}
+
+	return client
+}
+
+func (ri *RealIP) parse() {
+	ri.once.Do(func() {
+		for _, cidr := range ri.TrustedProxies {
+			if !strings.Contains(cidr, "/") {
+				addr, err := netip.ParseAddr(cidr)
+				if err != nil {
+					panic(fmt.Sprintf("RealIP cannot parse trusted proxy %s: %v", cidr, err))
+				}
+				ri.trusted = append(ri.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+				continue
+			}
+
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				panic(fmt.Sprintf("RealIP cannot parse trusted proxy %s: %v", cidr, err))
+			}
+			ri.trusted = append(ri.trusted, prefix.Masked())
+		}
+	})
+}
+
+func (ri *RealIP) isTrusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range ri.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor collects the for parameters of RFC 7239 Forwarded headers.
+func forwardedFor(values []string) []string {
+	var chain []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(key, "for") {
+					continue
+				}
+				chain = append(chain, forwardedNode(strings.Trim(val, `"`)))
+			}
+		}
+	}
+	return chain
+}
+
+// forwardedNode strips the port and brackets from a Forwarded node.
+func forwardedNode(node string) string {
+	if strings.HasPrefix(node, "[") {
+		if end := strings.Index(node, "]"); end > 0 {
+			return node[1:end]
+		}
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return node
+}
+
+/*
+RateLimit limits how often each client can make requests to the handlers it
+wraps, rejecting requests over the limit with a 429 Too Many Requests. Key
+decides who the client is, by default it's ClientIP so put a RealIP in front
+of the RateLimit when running behind proxies, or every client looks like the
+proxy.
+
+	limit := &jsonware.RateLimit{Rate: jsonware.Rate{PerSecond: 5, Burst: 10}}
+	http.Handle("/login", limit.Wrap(jsonware.Handler(login)))
+*/
+type RateLimit struct {
+	Rate Rate
+	Key  func(r *http.Request) string
+	// Group is the group whose Config can change Rate while the server is
+	// running, see SetConfig.
+	Group string
+
+	// Log is where errors are reported, the global logger is used when it's
+	// nil.
+	Log io.Writer
+
+	buckets buckets
+	// now is swapped out in tests.
+	now func() time.Time
+}
+
+// Wrap returns a handler that rate limits requests before serving them with
+// h.
+func (rl *RateLimit) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := ClientIP(r)
+		if rl.Key != nil {
+			key = rl.Key(r)
+		}
+
+		if wait, ok := rl.take(key); !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, rl.Log, Err{
+				Status: http.StatusTooManyRequests,
+				Err:    errors.New("rate limit exceeded"),
+			})
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// take uses up one request from the client's limit, if there's none left it
+// returns how long until there will be.
+func (rl *RateLimit) take(key string) (time.Duration, bool) {
+	rate := rl.Rate
+	if cfg, ok := groupConfig(rl.Group); ok && cfg.Rate != (Rate{}) {
+		rate = cfg.Rate
+	}
+	if rate.PerSecond <= 0 && rate.Burst <= 0 {
+		return 0, true
+	}
+
+	now := time.Now()
+	if rl.now != nil {
+		now = rl.now()
+	}
+
+	return rl.buckets.take(key, rate, now)
+}
@@ -0,0 +1,156 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRealIP(t *testing.T) {
+	t.Parallel()
+
+	ri := &RealIP{TrustedProxies: []string{"10.0.0.0/8", "2001:db8::/32", "192.168.1.1"}}
+
+	var tests = []struct {
+		remote string
+		header string
+		value  string
+		ip     string
+	}{
+		{"1.2.3.4:5000", "", "", "1.2.3.4"},
+		// Untrusted connections can't claim to be someone else.
+		{"1.2.3.4:5000", "X-Forwarded-For", "5.6.7.8", "1.2.3.4"},
+		{"10.0.0.1:5000", "X-Forwarded-For", "5.6.7.8", "5.6.7.8"},
+		{"10.0.0.1:5000", "X-Forwarded-For", "9.9.9.9, 5.6.7.8, 10.0.0.2", "5.6.7.8"},
+		{"10.0.0.1:5000", "X-Forwarded-For", "10.0.0.3, 10.0.0.2", "10.0.0.3"},
+		{"10.0.0.1:5000", "X-Forwarded-For", "5.6.7.8, garbage", "10.0.0.1"},
+		{"192.168.1.1:5000", "X-Real-Ip", "5.6.7.8", "5.6.7.8"},
+		{"192.168.1.2:5000", "X-Real-Ip", "5.6.7.8", "192.168.1.2"},
+		{"10.0.0.1:5000", "Forwarded", `for=5.6.7.8;proto=https, for="[2001:db8::1]:4711"`, "5.6.7.8"},
+		{"[2001:db8::2]:443", "Forwarded", `For="[2001:db9::1]:4711"`, "2001:db9::1"},
+	}
+
+	for i, test := range tests {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = test.remote
+		if len(test.header) != 0 {
+			req.Header.Set(test.header, test.value)
+		}
+
+		if ip := ri.Resolve(req); ip != test.ip {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.ip, ip)
+		}
+	}
+}
+
+func TestRealIPWrap(t *testing.T) {
+	t.Parallel()
+
+	var got string
+	h := (&RealIP{TrustedProxies: []string{"10.0.0.0/8"}}).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ClientIP(r)
+	}))
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-Forwarded-For", "5.6.7.8")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != "5.6.7.8" {
+		t.Error("Expected the resolved ip, got:", got)
+	}
+
+	// Without a RealIP it's the connection's address.
+	if ip := ClientIP(req); ip != "10.0.0.1" {
+		t.Error("Expected the remote address, got:", ip)
+	}
+}
+
+func TestRealIPPanics(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic")
+		}
+	}()
+	(&RealIP{TrustedProxies: []string{"10.0.0.0/99"}}).Wrap(nil)
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(0, 0)
+	realIP := &RealIP{TrustedProxies: []string{"10.0.0.0/8"}}
+	limit := &RateLimit{
+		Rate: Rate{PerSecond: 1, Burst: 2},
+		now:  func() time.Time { return now },
+	}
+	h := realIP.Wrap(limit.Wrap(Handler(func(r *http.Request) *testType { return &testType{"bob"} })))
+
+	var tests = []struct {
+		client     string
+		advance    time.Duration
+		status     int
+		retryAfter string
+	}{
+		{"5.6.7.8", 0, 200, ""},
+		{"5.6.7.8", 0, 200, ""},
+		{"5.6.7.8", 0, 429, "1"},
+		// Clients behind the same proxy have their own limits.
+		{"9.9.9.9", 0, 200, ""},
+		{"5.6.7.8", time.Second, 200, ""},
+	}
+
+	for i, test := range tests {
+		now = now.Add(test.advance)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("X-Forwarded-For", test.client)
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if ra := res.Header().Get("Retry-After"); ra != test.retryAfter {
+			t.Errorf("Test %d: expected Retry-After %q, got: %q", i, test.retryAfter, ra)
+		}
+	}
+}
+
+func TestRateLimitSweeps(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(0, 0)
+	limit := &RateLimit{
+		Rate: Rate{PerSecond: 1, Burst: 2},
+		now:  func() time.Time { return now },
+	}
+
+	for i := 0; i < sweepBuckets-1; i++ {
+		limit.take(strconv.Itoa(i))
+	}
+	limit.take("busy")
+	limit.take("busy")
+	if n := len(limit.buckets.buckets); n != sweepBuckets {
+		t.Fatalf("expected %d buckets before they filled up, got: %d", sweepBuckets, n)
+	}
+
+	// Every bucket but busy's has filled back up a second later.
+	now = now.Add(time.Second)
+	limit.take("new")
+	if n := len(limit.buckets.buckets); n != 2 {
+		t.Errorf("expected the full buckets to be swept out, got: %d", n)
+	}
+	if _, ok := limit.take("busy"); !ok {
+		t.Error("expected busy to have a request left")
+	}
+	if _, ok := limit.take("busy"); ok {
+		t.Error("expected busy's bucket to have been kept")
+	}
+}
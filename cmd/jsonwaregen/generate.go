@@ -0,0 +1,523 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const directive = "//jsonware:route "
+
+// route is a handler function marked with the route directive.
+type route struct {
+	Method  string
+	Pattern string
+	Func    string
+	Args    []string
+	Body    *body
+
+	HasValue bool
+	HasErr   bool
+}
+
+// body is the request object of a handler.
+type body struct {
+	Type string
+	// Elem is the type pointed to when Type is a pointer.
+	Elem  string
+	Paths []pathBind
+}
+
+// pathBind is a field of a request object that is bound to a path parameter.
+type pathBind struct {
+	Field string
+	Name  string
+	// Parse parses the string s into v and err, it's empty for strings.
+	Parse string
+	// Convert converts v to the type of the field.
+	Convert string
+}
+
+// parsers are the expressions that parse path parameters for the field types
+// that can be bound.
+var parsers = map[string]string{
+	"bool":    "strconv.ParseBool(s)",
+	"int":     "strconv.ParseInt(s, 10, 0)",
+	"int8":    "strconv.ParseInt(s, 10, 8)",
+	"int16":   "strconv.ParseInt(s, 10, 16)",
+	"int32":   "strconv.ParseInt(s, 10, 32)",
+	"int64":   "strconv.ParseInt(s, 10, 64)",
+	"uint":    "strconv.ParseUint(s, 10, 0)",
+	"uint8":   "strconv.ParseUint(s, 10, 8)",
+	"uint16":  "strconv.ParseUint(s, 10, 16)",
+	"uint32":  "strconv.ParseUint(s, 10, 32)",
+	"uint64":  "strconv.ParseUint(s, 10, 64)",
+	"float32": "strconv.ParseFloat(s, 32)",
+	"float64": "strconv.ParseFloat(s, 64)",
+}
+
+// Generate generates the source of the handlers for the package in dir,
+// ignoring the file named out which is assumed to be previously generated
+// output.
+func Generate(dir, out, register string) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == out {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no go files in %s", dir)
+	}
+
+	structs := make(map[string]*ast.StructType)
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if spec, ok := n.(*ast.TypeSpec); ok {
+				if st, ok := spec.Type.(*ast.StructType); ok {
+					structs[spec.Name.Name] = st
+				}
+			}
+			return true
+		})
+	}
+
+	g := &generator{
+		Package:  files[0].Name.Name,
+		Register: register,
+		imports:  make(map[string]string),
+		structs:  structs,
+	}
+
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Doc == nil {
+				continue
+			}
+
+			for _, c := range fn.Doc.List {
+				if !strings.HasPrefix(c.Text, directive) {
+					continue
+				}
+
+				if err := g.add(f, fn, strings.Fields(strings.TrimPrefix(c.Text, directive))); err != nil {
+					return nil, fmt.Errorf("%s: %v", fset.Position(fn.Pos()), err)
+				}
+			}
+		}
+	}
+
+	return g.generate()
+}
+
+type generator struct {
+	Package  string
+	Register string
+	Routes   []*route
+
+	// imports maps import paths needed by request objects to their names.
+	imports map[string]string
+	structs map[string]*ast.StructType
+}
+
+func (g *generator) add(f *ast.File, fn *ast.FuncDecl, fields []string) error {
+	if len(fields) != 2 {
+		return fmt.Errorf("route directive must be: %sMETHOD /pattern", directive)
+	}
+	if fn.Recv != nil {
+		return fmt.Errorf("%s: routes must be functions, not methods", fn.Name.Name)
+	}
+
+	rt := &route{Method: fields[0], Pattern: fields[1], Func: fn.Name.Name}
+
+	hasRequest := false
+	for _, field := range fn.Type.Params.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+
+		for i := 0; i < n; i++ {
+			switch typ := types.ExprString(field.Type); typ {
+			case "http.ResponseWriter":
+				rt.Args = append(rt.Args, "w")
+			case "*http.Request":
+				hasRequest = true
+				rt.Args = append(rt.Args, "r")
+			case "context.Context":
+				hasRequest = true
+				rt.Args = append(rt.Args, "r.Context()")
+			default:
+				if rt.Body != nil {
+					return fmt.Errorf("%s: only one argument may be the request body, %s is unsupported", rt.Func, typ)
+				}
+				b, err := g.body(f, field.Type)
+				if err != nil {
+					return fmt.Errorf("%s: %v", rt.Func, err)
+				}
+				rt.Body = b
+				rt.Args = append(rt.Args, "in")
+			}
+		}
+	}
+	if !hasRequest {
+		return fmt.Errorf("%s: handler must take a *http.Request or a context.Context", rt.Func)
+	}
+
+	var results []ast.Expr
+	if fn.Type.Results != nil {
+		for _, field := range fn.Type.Results.List {
+			for i := 0; i < len(field.Names) || i == 0; i++ {
+				results = append(results, field.Type)
+			}
+		}
+	}
+
+	switch len(results) {
+	case 1:
+		if types.ExprString(results[0]) == "error" {
+			rt.HasErr = true
+		} else {
+			rt.HasValue = true
+		}
+	case 2:
+		if types.ExprString(results[1]) != "error" {
+			return fmt.Errorf("%s: second return must be an error", rt.Func)
+		}
+		rt.HasValue, rt.HasErr = true, true
+	default:
+		return fmt.Errorf("%s: handler must have one or two returns: [*object or interface{}], [error]", rt.Func)
+	}
+
+	if rt.HasValue && !isResponseType(results[0]) {
+		return fmt.Errorf("%s: response must be an empty *object, map, slice or interface{}", rt.Func)
+	}
+
+	g.Routes = append(g.Routes, rt)
+	return nil
+}
+
+// body works out how to decode a request object of type expr.
+func (g *generator) body(f *ast.File, expr ast.Expr) (*body, error) {
+	b := &body{Type: types.ExprString(expr)}
+
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		b.Elem = types.ExprString(t.X)
+		if ident, ok := t.X.(*ast.Ident); ok {
+			if st, ok := g.structs[ident.Name]; ok {
+				paths, err := pathBinds(ident.Name, st)
+				if err != nil {
+					return nil, err
+				}
+				b.Paths = paths
+			}
+		}
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return nil, fmt.Errorf("request object must be an *object, map, or slice, not %s", b.Type)
+		}
+	case *ast.MapType:
+	default:
+		return nil, fmt.Errorf("request object must be an *object, map, or slice, not %s", b.Type)
+	}
+
+	// Anything the type refers to in other packages must be imported.
+	var err error
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		pkg, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+
+		path, ok := importPath(f, pkg.Name)
+		if !ok {
+			err = fmt.Errorf("cannot find the import for %s", pkg.Name)
+			return false
+		}
+		g.imports[path] = pkg.Name
+		return false
+	})
+
+	return b, err
+}
+
+// isResponseType checks that expr can be returned from a handler as the
+// response.
+func isResponseType(expr ast.Expr) bool {
+	switch t := expr.(type) {
+	case *ast.StarExpr, *ast.MapType:
+		return true
+	case *ast.ArrayType:
+		return t.Len == nil
+	case *ast.InterfaceType:
+		return len(t.Methods.List) == 0
+	case *ast.Ident:
+		return t.Name == "any"
+	}
+	return false
+}
+
+// importPath finds the path of the package imported by f as name.
+func importPath(f *ast.File, name string) (string, bool) {
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if imp.Name != nil {
+			if imp.Name.Name == name {
+				return path, true
+			}
+			continue
+		}
+		if path == name || strings.HasSuffix(path, "/"+name) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// pathBinds finds the path tagged fields of a struct.
+func pathBinds(name string, st *ast.StructType) ([]pathBind, error) {
+	var binds []pathBind
+	for _, field := range st.Fields.List {
+		if field.Tag == nil || len(field.Names) == 0 {
+			continue
+		}
+		tag, _ := strconv.Unquote(field.Tag.Value)
+		param, ok := reflect.StructTag(tag).Lookup("path")
+		if !ok {
+			continue
+		}
+
+		typ := types.ExprString(field.Type)
+		for _, fieldName := range field.Names {
+			if !fieldName.IsExported() {
+				continue
+			}
+
+			bind := pathBind{Field: fieldName.Name, Name: param}
+			if typ != "string" {
+				parse, ok := parsers[typ]
+				if !ok {
+					return nil, fmt.Errorf("field %s.%s of type %s cannot be bound to a path parameter", name, fieldName.Name, typ)
+				}
+				bind.Parse = parse
+				bind.Convert = typ + "(v)"
+			}
+			binds = append(binds, bind)
+		}
+	}
+	return binds, nil
+}
+
+func (g *generator) generate() ([]byte, error) {
+	imports := map[string]string{"net/http": ""}
+	if len(g.Routes) != 0 {
+		imports["io"] = ""
+		imports["strings"] = ""
+		imports["github.com/aarondl/jsonware"] = ""
+	}
+	for _, rt := range g.Routes {
+		if rt.Body != nil || rt.HasValue {
+			imports["encoding/json"] = ""
+			imports["errors"] = ""
+		}
+		if rt.HasValue {
+			imports["bytes"] = ""
+		}
+		if rt.Body != nil {
+			for _, p := range rt.Body.Paths {
+				if len(p.Parse) != 0 {
+					imports["strconv"] = ""
+				}
+			}
+		}
+	}
+	for path, name := range g.imports {
+		if strings.HasSuffix(path, "/"+name) || path == name {
+			name = ""
+		}
+		imports[path] = name
+	}
+
+	// The standard library comes first, then everything else.
+	var paths []string
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if isStd(paths[i]) != isStd(paths[j]) {
+			return isStd(paths[i])
+		}
+		return paths[i] < paths[j]
+	})
+
+	var importLines []string
+	for i, path := range paths {
+		if i > 0 && isStd(paths[i-1]) && !isStd(path) {
+			importLines = append(importLines, "")
+		}
+		if name := imports[path]; len(name) != 0 {
+			importLines = append(importLines, name+" "+strconv.Quote(path))
+		} else {
+			importLines = append(importLines, strconv.Quote(path))
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err := tpl.Execute(buf, struct {
+		*generator
+		Imports []string
+	}{g, importLines})
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid code: %v\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+// isStd checks if path is a package from the standard library.
+func isStd(path string) bool {
+	return !strings.Contains(strings.SplitN(path, "/", 2)[0], ".")
+}
+
+var tpl = template.Must(template.New("").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(`// Code generated by jsonwaregen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Imports}}
+	{{.}}
+{{- end}}
+)
+{{range .Routes}}
+// {{.Func}}JSON serves {{.Func}} for {{.Method}} {{.Pattern}}.
+func {{.Func}}JSON(w http.ResponseWriter, r *http.Request) {
+	if ah := r.Header.Get("Accept"); !strings.Contains(ah, "*/*") && !strings.Contains(ah, "application/json") {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "this endpoint only responds to json-accepting clients")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+{{with .Body}}
+	{{if .Elem}}in := new({{.Elem}}){{else}}var in {{.Type}}{{end}}
+	{{- if .Paths}}
+	if r.Method != "GET" && r.Method != "HEAD" && r.Method != "DELETE" {
+	{{- end}}
+	if err := json.NewDecoder(r.Body).Decode({{if not .Elem}}&{{end}}in); err != nil {
+		jsonware.WriteError(w, nil, jsonware.Err{
+			Status: http.StatusBadRequest,
+			Err:    errors.New("could not deserialize json request body"),
+		})
+		return
+	}
+	{{- if .Paths}}
+	}
+	{{- end}}
+{{range .Paths}}
+	if s := r.PathValue({{printf "%q" .Name}}); len(s) != 0 {
+		{{- if .Parse}}
+		v, err := {{.Parse}}
+		if err != nil {
+			jsonware.WriteError(w, nil, jsonware.Err{
+				Status: http.StatusBadRequest,
+				Err:    errors.New({{printf "%q" (print "invalid path parameter " .Name)}}),
+			})
+			return
+		}
+		in.{{.Field}} = {{.Convert}}
+		{{- else}}
+		in.{{.Field}} = s
+		{{- end}}
+	}
+{{end}}
+	if v, ok := interface{}({{if not .Elem}}&{{end}}in).(jsonware.Validator); ok {
+		if err := v.Validate(); err != nil {
+			switch err.(type) {
+			case jsonware.Err, jsonware.MultiErr:
+			default:
+				err = jsonware.Err{Status: http.StatusUnprocessableEntity, Err: err}
+			}
+			jsonware.WriteError(w, nil, err)
+			return
+		}
+	}
+{{end}}
+	{{- if and .HasValue .HasErr}}
+	out, err := {{.Func}}({{join .Args ", "}})
+	if err != nil {
+		jsonware.WriteError(w, nil, err)
+		return
+	}
+	{{- else if .HasErr}}
+	if err := {{.Func}}({{join .Args ", "}}); err != nil {
+		jsonware.WriteError(w, nil, err)
+	}
+	{{- else}}
+	out := {{.Func}}({{join .Args ", "}})
+	{{- end}}
+{{- if .HasValue}}
+
+	if out == nil {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(out); err != nil {
+		jsonware.WriteError(w, nil, jsonware.Err{
+			Status: http.StatusInternalServerError,
+			Err:    errors.New("problem preparing response"),
+		})
+		return
+	}
+
+	if sc, ok := interface{}(out).(jsonware.StatusCoder); ok {
+		w.WriteHeader(sc.StatusCode())
+	}
+	buf.WriteTo(w)
+{{- end}}
+}
+{{end}}
+// {{.Register}} registers the generated handlers on mux.
+func {{.Register}}(mux *http.ServeMux) {
+{{- range .Routes}}
+	mux.HandleFunc({{printf "%q" (print .Method " " .Pattern)}}, {{.Func}}JSON)
+{{- end}}
+}
+`))
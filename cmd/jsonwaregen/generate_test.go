@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateUpToDate(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join("internal", "example")
+	src, err := Generate(dir, "jsonware_gen.go", "RegisterRoutes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existing, err := os.ReadFile(filepath.Join(dir, "jsonware_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(src) != string(existing) {
+		t.Errorf("Generated code is out of date, run go generate in %s, got:\n%s", dir, src)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		src string
+		err string
+	}{
+		{
+			"//jsonware:route GET\nfunc a(r *http.Request) error { return nil }",
+			"route directive must be",
+		},
+		{
+			"type T struct{}\n//jsonware:route GET /\nfunc (T) a(r *http.Request) error { return nil }",
+			"routes must be functions",
+		},
+		{
+			"//jsonware:route GET /\nfunc a(in *T) error { return nil }",
+			"must take a *http.Request",
+		},
+		{
+			"//jsonware:route GET /\nfunc a(r *http.Request, a, b *T) error { return nil }",
+			"only one argument may be the request body",
+		},
+		{
+			"//jsonware:route GET /\nfunc a(r *http.Request, t T) error { return nil }",
+			"request object must be",
+		},
+		{
+			"//jsonware:route GET /\nfunc a(r *http.Request) T { return T{} }",
+			"response must be",
+		},
+		{
+			"//jsonware:route GET /\nfunc a(r *http.Request) (*T, int) { return nil, 0 }",
+			"second return must be an error",
+		},
+		{
+			"type T struct { D []int `path:\"d\"` }\n//jsonware:route GET /{d}\nfunc a(r *http.Request, in *T) error { return nil }",
+			"cannot be bound to a path parameter",
+		},
+		{
+			"//jsonware:route GET /\nfunc a(r *http.Request, in *other.T) error { return nil }",
+			"cannot find the import for other",
+		},
+	}
+
+	for i, test := range tests {
+		dir := t.TempDir()
+		src := "package p\n\nimport \"net/http\"\n\n" + test.src + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := Generate(dir, "jsonware_gen.go", "RegisterRoutes")
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Test %d: expected error containing %q, got: %v", i, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Package example has handlers that jsonwaregen generates code for, it's used
+// to check that the generated code compiles and behaves like jsonware.
+package example
+
+//go:generate go run github.com/aarondl/jsonware/cmd/jsonwaregen
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aarondl/jsonware"
+)
+
+// User is a user.
+type User struct {
+	ID      int       `path:"id" json:"id"`
+	Name    string    `json:"name"`
+	Org     string    `path:"org" json:"org"`
+	Created time.Time `json:"created"`
+}
+
+// Validate checks the user is complete.
+func (u *User) Validate() error {
+	if len(u.Name) == 0 {
+		return errors.New("name is required")
+	}
+	if u.Name == "root" {
+		return jsonware.MultiErr{{Status: http.StatusConflict, Err: errors.New("name is reserved")}}
+	}
+	return nil
+}
+
+// Lookup is a user lookup.
+type Lookup struct {
+	ID int `path:"id"`
+}
+
+//jsonware:route GET /users/{id}
+func getUser(r *http.Request, in *Lookup) (*User, error) {
+	if in.ID == 0 {
+		return nil, errors.New("no user zero")
+	}
+	return &User{ID: in.ID, Name: "bob"}, nil
+}
+
+//jsonware:route PUT /orgs/{org}/users/{id}
+func updateUser(ctx context.Context, in *User) *User {
+	return in
+}
+
+//jsonware:route POST /users/bulk
+func bulkUsers(w http.ResponseWriter, r *http.Request, in []*User) error {
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+//jsonware:route GET /users
+func listUsers(r *http.Request) map[string]*User {
+	return map[string]*User{"bob": {ID: 1, Name: "bob"}}
+}
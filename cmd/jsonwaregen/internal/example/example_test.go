@@ -0,0 +1,89 @@
+package example
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGenerated(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	RegisterRoutes(mux)
+
+	var tests = []struct {
+		method  string
+		path    string
+		accept  string
+		reqbody string
+		status  int
+		resbody string
+	}{
+		{"GET", "/users/5", "*/*", "", 200, `{"id":5,"name":"bob","org":"","created":"0001-01-01T00:00:00Z"}`},
+		{"HEAD", "/users/5", "*/*", "", 200, `{"id":5,"name":"bob","org":"","created":"0001-01-01T00:00:00Z"}`},
+		{"GET", "/users/0", "*/*", "", 500, `{"error":"an internal server error occurred"}`},
+		{"GET", "/users/x", "*/*", "", 400, `{"error":"invalid path parameter id"}`},
+		{"GET", "/users/5", "text/html", "", 400, "this endpoint only responds to json-accepting clients"},
+		{"PUT", "/orgs/acme/users/5", "application/json", `{"id":1,"name":"bob"}`, 200, `{"id":5,"name":"bob","org":"acme","created":"0001-01-01T00:00:00Z"}`},
+		{"PUT", "/orgs/acme/users/5", "application/json", `{"name":""}`, 422, `{"error":"name is required"}`},
+		{"PUT", "/orgs/acme/users/5", "application/json", `{"name":"root"}`, 409, `{"errors":[{"status":409,"error":"name is reserved"}]}`},
+		{"PUT", "/orgs/acme/users/5", "application/json", `{"name"`, 400, `{"error":"could not deserialize json request body"}`},
+		{"POST", "/users/bulk", "*/*", `[{"name":"bob"}]`, 202, ""},
+		{"GET", "/users", "*/*", "", 200, `{"bob":{"id":1,"name":"bob","org":"","created":"0001-01-01T00:00:00Z"}}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, bytes.NewBufferString(test.reqbody))
+		req.Header.Set("Accept", test.accept)
+		mux.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, res.Code)
+		}
+
+		if b := strings.TrimSpace(res.Body.String()); b != test.resbody {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+	}
+}
+
+// nopBody is a request body that can be reused between requests without
+// allocating.
+type nopBody struct {
+	*bytes.Reader
+}
+
+func (nopBody) Close() error { return nil }
+
+type nopWriter struct {
+	header http.Header
+}
+
+func (n *nopWriter) Header() http.Header         { return n.header }
+func (n *nopWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (n *nopWriter) WriteHeader(int)             {}
+
+// BenchmarkGeneratedDecodeEncode is the generated counterpart of
+// jsonware's BenchmarkDecodeEncode.
+func BenchmarkGeneratedDecodeEncode(b *testing.B) {
+	raw := []byte(`{"id":5,"name":"bob","org":"acme","created":"2020-01-01T00:00:00Z"}`)
+	body := nopBody{bytes.NewReader(raw)}
+	req, _ := http.NewRequest("PUT", "/orgs/acme/users/5", body)
+	req.Header.Set("Accept", "application/json")
+	req.SetPathValue("org", "acme")
+	req.SetPathValue("id", "5")
+	w := &nopWriter{header: make(http.Header)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		body.Reset(raw)
+		updateUserJSON(w, req)
+	}
+}
@@ -0,0 +1,234 @@
+// Code generated by jsonwaregen. DO NOT EDIT.
+
+package example
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aarondl/jsonware"
+)
+
+// getUserJSON serves getUser for GET /users/{id}.
+func getUserJSON(w http.ResponseWriter, r *http.Request) {
+	if ah := r.Header.Get("Accept"); !strings.Contains(ah, "*/*") && !strings.Contains(ah, "application/json") {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "this endpoint only responds to json-accepting clients")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	in := new(Lookup)
+	if r.Method != "GET" && r.Method != "HEAD" && r.Method != "DELETE" {
+		if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+			jsonware.WriteError(w, nil, jsonware.Err{
+				Status: http.StatusBadRequest,
+				Err:    errors.New("could not deserialize json request body"),
+			})
+			return
+		}
+	}
+
+	if s := r.PathValue("id"); len(s) != 0 {
+		v, err := strconv.ParseInt(s, 10, 0)
+		if err != nil {
+			jsonware.WriteError(w, nil, jsonware.Err{
+				Status: http.StatusBadRequest,
+				Err:    errors.New("invalid path parameter id"),
+			})
+			return
+		}
+		in.ID = int(v)
+	}
+
+	if v, ok := interface{}(in).(jsonware.Validator); ok {
+		if err := v.Validate(); err != nil {
+			switch err.(type) {
+			case jsonware.Err, jsonware.MultiErr:
+			default:
+				err = jsonware.Err{Status: http.StatusUnprocessableEntity, Err: err}
+			}
+			jsonware.WriteError(w, nil, err)
+			return
+		}
+	}
+
+	out, err := getUser(r, in)
+	if err != nil {
+		jsonware.WriteError(w, nil, err)
+		return
+	}
+
+	if out == nil {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(out); err != nil {
+		jsonware.WriteError(w, nil, jsonware.Err{
+			Status: http.StatusInternalServerError,
+			Err:    errors.New("problem preparing response"),
+		})
+		return
+	}
+
+	if sc, ok := interface{}(out).(jsonware.StatusCoder); ok {
+		w.WriteHeader(sc.StatusCode())
+	}
+	buf.WriteTo(w)
+}
+
+// updateUserJSON serves updateUser for PUT /orgs/{org}/users/{id}.
+func updateUserJSON(w http.ResponseWriter, r *http.Request) {
+	if ah := r.Header.Get("Accept"); !strings.Contains(ah, "*/*") && !strings.Contains(ah, "application/json") {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "this endpoint only responds to json-accepting clients")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	in := new(User)
+	if r.Method != "GET" && r.Method != "HEAD" && r.Method != "DELETE" {
+		if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+			jsonware.WriteError(w, nil, jsonware.Err{
+				Status: http.StatusBadRequest,
+				Err:    errors.New("could not deserialize json request body"),
+			})
+			return
+		}
+	}
+
+	if s := r.PathValue("id"); len(s) != 0 {
+		v, err := strconv.ParseInt(s, 10, 0)
+		if err != nil {
+			jsonware.WriteError(w, nil, jsonware.Err{
+				Status: http.StatusBadRequest,
+				Err:    errors.New("invalid path parameter id"),
+			})
+			return
+		}
+		in.ID = int(v)
+	}
+
+	if s := r.PathValue("org"); len(s) != 0 {
+		in.Org = s
+	}
+
+	if v, ok := interface{}(in).(jsonware.Validator); ok {
+		if err := v.Validate(); err != nil {
+			switch err.(type) {
+			case jsonware.Err, jsonware.MultiErr:
+			default:
+				err = jsonware.Err{Status: http.StatusUnprocessableEntity, Err: err}
+			}
+			jsonware.WriteError(w, nil, err)
+			return
+		}
+	}
+
+	out := updateUser(r.Context(), in)
+
+	if out == nil {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(out); err != nil {
+		jsonware.WriteError(w, nil, jsonware.Err{
+			Status: http.StatusInternalServerError,
+			Err:    errors.New("problem preparing response"),
+		})
+		return
+	}
+
+	if sc, ok := interface{}(out).(jsonware.StatusCoder); ok {
+		w.WriteHeader(sc.StatusCode())
+	}
+	buf.WriteTo(w)
+}
+
+// bulkUsersJSON serves bulkUsers for POST /users/bulk.
+func bulkUsersJSON(w http.ResponseWriter, r *http.Request) {
+	if ah := r.Header.Get("Accept"); !strings.Contains(ah, "*/*") && !strings.Contains(ah, "application/json") {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "this endpoint only responds to json-accepting clients")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var in []*User
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		jsonware.WriteError(w, nil, jsonware.Err{
+			Status: http.StatusBadRequest,
+			Err:    errors.New("could not deserialize json request body"),
+		})
+		return
+	}
+
+	if v, ok := interface{}(&in).(jsonware.Validator); ok {
+		if err := v.Validate(); err != nil {
+			switch err.(type) {
+			case jsonware.Err, jsonware.MultiErr:
+			default:
+				err = jsonware.Err{Status: http.StatusUnprocessableEntity, Err: err}
+			}
+			jsonware.WriteError(w, nil, err)
+			return
+		}
+	}
+
+	if err := bulkUsers(w, r, in); err != nil {
+		jsonware.WriteError(w, nil, err)
+	}
+}
+
+// listUsersJSON serves listUsers for GET /users.
+func listUsersJSON(w http.ResponseWriter, r *http.Request) {
+	if ah := r.Header.Get("Accept"); !strings.Contains(ah, "*/*") && !strings.Contains(ah, "application/json") {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "this endpoint only responds to json-accepting clients")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	out := listUsers(r)
+
+	if out == nil {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(out); err != nil {
+		jsonware.WriteError(w, nil, jsonware.Err{
+			Status: http.StatusInternalServerError,
+			Err:    errors.New("problem preparing response"),
+		})
+		return
+	}
+
+	if sc, ok := interface{}(out).(jsonware.StatusCoder); ok {
+		w.WriteHeader(sc.StatusCode())
+	}
+	buf.WriteTo(w)
+}
+
+// RegisterRoutes registers the generated handlers on mux.
+func RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /users/{id}", getUserJSON)
+	mux.HandleFunc("PUT /orgs/{org}/users/{id}", updateUserJSON)
+	mux.HandleFunc("POST /users/bulk", bulkUsersJSON)
+	mux.HandleFunc("GET /users", listUsersJSON)
+}
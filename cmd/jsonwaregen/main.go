@@ -0,0 +1,62 @@
+/*
+Command jsonwaregen generates reflection free jsonware handlers.
+
+It scans the Go files of a package for functions marked with a route
+directive and generates an http.HandlerFunc for each of them that decodes,
+validates, calls and encodes like jsonware.Handler does, but with plain code
+instead of reflection. A registration function that mounts all of them on an
+http.ServeMux is generated as well.
+
+	//jsonware:route GET /users/{id}
+	func getUser(r *http.Request, in *GetUser) (*User, error) {
+		...
+	}
+
+	//go:generate jsonwaregen
+
+The generated handler for getUser is named getUserJSON and is registered by
+RegisterRoutes(mux *http.ServeMux). Handlers take the same forms as for
+jsonware.Handler, except that provided and extracted arguments aren't
+supported. Path tagged fields of request objects are bound from the
+wildcards of the pattern and must be strings, bools or numbers.
+
+The generated handlers only cover the core of jsonware.Handler, they leave
+out:
+
+  - Method checks, only the patterns registered on the mux limit methods.
+  - Location headers, Created responses are sent as 201 without one and
+    SelfURLers aren't treated specially.
+  - Codecs, bodies are always plain encoding/json.
+  - HEAD handling, the response body is written and dropped by net/http.
+  - Every JSONHandler option, like Log, Cache or CircuitBreaker.
+
+Usage:
+
+	jsonwaregen [-dir .] [-out jsonware_gen.go] [-register RegisterRoutes]
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory of the package to generate handlers for")
+	out := flag.String("out", "jsonware_gen.go", "file to write, relative to dir")
+	register := flag.String("register", "RegisterRoutes", "name of the generated registration function")
+	flag.Parse()
+
+	src, err := Generate(*dir, *out, *register)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "jsonwaregen:", err)
+		os.Exit(1)
+	}
+
+	if err = os.WriteFile(filepath.Join(*dir, *out), src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "jsonwaregen:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,890 @@
+package jsonware
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+/*
+Codec controls how responses are serialized to json, for when the defaults
+of encoding/json don't suit the clients of an api. A JSONHandler uses the
+Codec given to its Codec option, or DefaultCodec, and encoding/json as is
+when there's neither.
+
+	jsonware.DefaultCodec = &jsonware.Codec{EmptyCollections: true}
+
+A Codec walks responses itself so that it can change how values are written,
+but otherwise follows the rules of encoding/json: json struct tags, their
+omitempty and string options, embedded structs, and types that implement
+json.Marshaler or encoding.TextMarshaler all behave the same. Values nested
+more than 1000 deep, like one that refers back to itself, are an error instead
+of recursing until the stack runs out, the same as encoding/json's cycle
+detection.
+*/
+type Codec struct {
+	// EmptyCollections writes nil slices as [] and nil maps as {} instead of
+	// null, for clients that break on a null where they expect an array.
+	EmptyCollections bool
+
+	// Keys rewrites the keys of struct fields into a naming convention, so
+	// that an api can speak a convention other than that of its json tags.
+	// Request bodies in the convention are decoded as if they used the
+	// tagged names.
+	Keys KeyStyle
+
+	// Lenient decodes strings into number and bool fields when they hold
+	// one, like "42" and "true", as if every such field had the string
+	// option. It's for clients that stringify everything.
+	Lenient bool
+
+	// TimeLayout is the layout times are written in, RFC 3339 when it's
+	// empty. TimeZone is the location they're converted to first, they stay
+	// in their own when it's nil.
+	TimeLayout string
+	TimeZone   *time.Location
+
+	// Formatters override how values of their types are written, whatever
+	// a formatter returns is written in the value's place. They take
+	// precedence over the type's own MarshalJSON, which makes them useful
+	// for types from other packages like decimals:
+	//
+	//	Formatters: map[reflect.Type]func(interface{}) interface{}{
+	//		reflect.TypeOf(decimal.Decimal{}): jsonware.Fixed(2),
+	//	}
+	//
+	// A formatter must not return a value of the type it formats.
+	Formatters map[reflect.Type]func(v interface{}) interface{}
+
+	// BigIntStrings writes integers that JavaScript can't hold exactly,
+	// those beyond 2^53-1 either way, as strings so that clients don't
+	// silently round them, without tagging every int64 field with the
+	// string option. Integer fields of request bodies take strings as well
+	// as numbers then.
+	BigIntStrings bool
+
+	// NonFinite is what's done with NaN and infinite floats, which json
+	// can't hold. By default they fail the response, the same as with
+	// encoding/json. Giving a handler its own Codec sets it for just that
+	// handler:
+	//
+	//	jsonware.Handler(getStats).Codec(&jsonware.Codec{NonFinite: jsonware.FloatNull})
+	NonFinite FloatPolicy
+}
+
+// FloatPolicy is how a Codec writes NaN and infinite floats.
+type FloatPolicy int
+
+// FloatPolicies a Codec can have.
+const (
+	// FloatError fails the response with a 500.
+	FloatError FloatPolicy = iota
+	// FloatNull writes them as null.
+	FloatNull
+	// FloatString writes them as the strings "NaN", "Infinity" and
+	// "-Infinity", which JavaScript's Number parses back.
+	FloatString
+)
+
+// KeyStyle is a naming convention for the keys of json objects.
+type KeyStyle int
+
+// KeyStyles a Codec can rewrite keys into.
+const (
+	// TaggedKeys leaves keys as they are in json tags and field names.
+	TaggedKeys KeyStyle = iota
+	// CamelCase keys look like userId.
+	CamelCase
+	// PascalCase keys look like UserId.
+	PascalCase
+	// SnakeCase keys look like user_id.
+	SnakeCase
+)
+
+// DefaultCodec is the Codec of JSONHandlers that weren't given one with
+// their Codec option. Not safe for use by multiple goroutines, set it before
+// your http server has been started.
+var DefaultCodec *Codec
+
+// Codec sets the Codec the JSONHandler serializes its responses with.
+func (j *JSONHandler) Codec(c *Codec) *JSONHandler {
+	j.codec = c
+	return j
+}
+
+// jsonCodec is the Codec the handler serializes with, nil when it's
+// encoding/json.
+func (j JSONHandler) jsonCodec() *Codec {
+	if j.codec != nil {
+		return j.codec
+	}
+	return DefaultCodec
+}
+
+// Marshal serializes v to json. A nil Codec is encoding/json.
+func (c *Codec) Marshal(v interface{}) ([]byte, error) {
+	if c == nil {
+		return json.Marshal(v)
+	}
+
+	e := &codecState{codec: c}
+	if err := e.value(reflect.ValueOf(v), false); err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// Unmarshal deserializes json into v. A nil Codec is encoding/json.
+func (c *Codec) Unmarshal(data []byte, v interface{}) error {
+	return c.decode(json.NewDecoder(bytes.NewReader(data)), v)
+}
+
+// decode deserializes the next json value of dec into v.
+func (c *Codec) decode(dec *json.Decoder, v interface{}) error {
+	if c == nil || (c.Keys == TaggedKeys && !c.Lenient && !c.BigIntStrings) {
+		return dec.Decode(v)
+	}
+
+	// The body is rewritten into what encoding/json expects: keys are put
+	// back the way they're tagged and strings are turned into the numbers
+	// and bools they hold.
+	var raw interface{}
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	raw = c.rewrite(raw, reflect.TypeOf(v))
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// rewrite prepares raw for decoding into typ. The keys of the objects that
+// are decoded into struct fields are renamed from the Codec's KeyStyle to
+// their tagged names, and when the Codec is Lenient strings are coerced into
+// numbers and bools.
+func (c *Codec) rewrite(raw interface{}, typ reflect.Type) interface{} {
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ != nil && (reflect.PointerTo(typ).Implements(jsonUnmarshalerType) || typ.Kind() == reflect.Interface) {
+		return raw
+	}
+
+	switch val := raw.(type) {
+	case map[string]interface{}:
+		if typ != nil && typ.Kind() == reflect.Map {
+			for k, elem := range val {
+				val[k] = c.rewrite(elem, typ.Elem())
+			}
+			return val
+		}
+		if typ == nil || typ.Kind() != reflect.Struct {
+			return val
+		}
+
+		fields := codecFields(typ)
+		out := make(map[string]interface{}, len(val))
+		for k, elem := range val {
+			var match *codecField
+			for i := range fields {
+				if c.key(fields[i].name) == k {
+					match = &fields[i]
+					break
+				}
+				// encoding/json matches keys regardless of case.
+				if match == nil && strings.EqualFold(fields[i].name, k) {
+					match = &fields[i]
+				}
+			}
+
+			name, elemType := k, reflect.Type(nil)
+			if match != nil {
+				if c.key(match.name) == k {
+					name = match.name
+				}
+				// Fields with the string option already take strings.
+				if !match.quoted {
+					elemType = match.typ
+				}
+			}
+			out[name] = c.rewrite(elem, elemType)
+		}
+		return out
+	case []interface{}:
+		if typ != nil && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+			for i, elem := range val {
+				val[i] = c.rewrite(elem, typ.Elem())
+			}
+		}
+		return val
+	case string:
+		if typ == nil || reflect.PointerTo(typ).Implements(textUnmarshalerType) {
+			return val
+		}
+		if !c.Lenient {
+			if c.BigIntStrings && isInteger(typ) && isIntegerString(val) {
+				return json.Number(val)
+			}
+			return val
+		}
+		s := strings.TrimSpace(val)
+		switch typ.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			if _, err := strconv.ParseFloat(s, 64); err == nil {
+				return json.Number(s)
+			}
+		case reflect.Bool:
+			if b, err := strconv.ParseBool(s); err == nil {
+				return b
+			}
+		}
+	}
+	return raw
+}
+
+// isInteger checks if t is an integer kind.
+func isInteger(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// isIntegerString checks if s is a json integer.
+func isIntegerString(s string) bool {
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	}
+	if len(s) == 0 || (s[0] == '0' && len(s) > 1) {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+type styledKey struct {
+	style KeyStyle
+	name  string
+}
+
+var styledKeys sync.Map
+
+// key rewrites a field name into the Codec's KeyStyle.
+func (c *Codec) key(name string) string {
+	if c.Keys == TaggedKeys {
+		return name
+	}
+	if k, ok := styledKeys.Load(styledKey{c.Keys, name}); ok {
+		return k.(string)
+	}
+
+	words := splitWords(name)
+	for i, w := range words {
+		switch {
+		case c.Keys == SnakeCase, c.Keys == CamelCase && i == 0:
+			words[i] = strings.ToLower(w)
+		default:
+			r := []rune(strings.ToLower(w))
+			r[0] = unicode.ToUpper(r[0])
+			words[i] = string(r)
+		}
+	}
+
+	sep := ""
+	if c.Keys == SnakeCase {
+		sep = "_"
+	}
+	k := strings.Join(words, sep)
+	if len(k) == 0 {
+		k = name
+	}
+	styledKeys.Store(styledKey{c.Keys, name}, k)
+	return k
+}
+
+/*
+Fixed returns a Codec formatter that writes decimals as strings with places
+digits after the decimal point, for money and other amounts that clients must
+not read into floats. It formats floats, big.Rat, big.Float and types with a
+StringFixed(int32) string method like shopspring's decimal.Decimal.
+*/
+func Fixed(places int) func(v interface{}) interface{} {
+	return func(v interface{}) interface{} {
+		switch d := v.(type) {
+		case interface{ StringFixed(int32) string }:
+			return d.StringFixed(int32(places))
+		case *big.Rat:
+			return d.FloatString(places)
+		case big.Rat:
+			return d.FloatString(places)
+		case *big.Float:
+			return d.Text('f', places)
+		case big.Float:
+			return d.Text('f', places)
+		case float64:
+			return strconv.FormatFloat(d, 'f', places, 64)
+		case float32:
+			return strconv.FormatFloat(float64(d), 'f', places, 32)
+		}
+		return fmt.Sprint(v)
+	}
+}
+
+// fieldKey is the key of a field as the Codec writes it, a nil Codec leaves
+// it alone.
+func (c *Codec) fieldKey(name string) string {
+	if c == nil {
+		return name
+	}
+	return c.key(name)
+}
+
+// splitWords splits a name in any of the KeyStyles into its words. Runs of
+// capitals are kept together as an acronym, so userID is user and ID.
+func splitWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i, r := range runes {
+		if r == '_' || r == '-' || r == ' ' {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+		if i > start && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// maxSafeInteger is the largest integer a float64, and so JavaScript, holds
+// exactly along with every integer below it.
+const maxSafeInteger = 1<<53 - 1
+
+// maxEncodeDepth is how deeply values may nest before a Codec gives up on
+// serializing them. Real responses are nowhere near it, a value that gets
+// there almost certainly refers back to itself and would otherwise recurse
+// until the stack runs out.
+const maxEncodeDepth = 1000
+
+// streamChunkSize is how much output a codecState that's streaming holds
+// before writing it out.
+const streamChunkSize = 32 << 10
+
+// codecState is the output of a Codec serializing a single value.
+type codecState struct {
+	bytes.Buffer
+	codec   *Codec
+	scratch [64]byte
+	depth   int
+
+	// limit is how large the output may get before serializing fails with
+	// errResponseTooLarge, it's not limited when it's zero.
+	limit int
+	// out is where the output is streamed to as it's written, it's all held
+	// until the end when it's nil.
+	out io.Writer
+}
+
+// over checks if the output is larger than the limit.
+func (e *codecState) over() bool {
+	return e.limit > 0 && e.Len() > e.limit
+}
+
+func (e *codecState) value(v reflect.Value, quoted bool) error {
+	if !v.IsValid() {
+		e.WriteString("null")
+		return nil
+	}
+
+	if e.over() {
+		return errResponseTooLarge
+	}
+	if e.out != nil && e.Len() >= streamChunkSize {
+		if _, err := e.WriteTo(e.out); err != nil {
+			return err
+		}
+	}
+
+	t := v.Type()
+	e.depth++
+	defer func() { e.depth-- }()
+	if e.depth > maxEncodeDepth {
+		return fmt.Errorf("json: values nested more than %d deep, there may be a cycle through %s", maxEncodeDepth, t)
+	}
+	if t.Kind() == reflect.Pointer && !v.IsNil() && !e.formats(t) && e.formats(t.Elem()) {
+		v, t = v.Elem(), t.Elem()
+	}
+	if v.CanInterface() && e.formats(t) {
+		return e.format(v)
+	}
+
+	if v.CanInterface() {
+		// Pointer receivers count only when the value is addressable, the
+		// same as encoding/json.
+		if t.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(t).Implements(jsonMarshalerType) {
+			v, t = v.Addr(), v.Addr().Type()
+		}
+		if t.Implements(jsonMarshalerType) {
+			if (t.Kind() == reflect.Pointer || t.Kind() == reflect.Interface) && v.IsNil() {
+				e.WriteString("null")
+				return nil
+			}
+			return e.marshaler(v.Interface().(json.Marshaler))
+		}
+
+		if t.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(t).Implements(textMarshalerType) {
+			v, t = v.Addr(), v.Addr().Type()
+		}
+		if t.Implements(textMarshalerType) {
+			if (t.Kind() == reflect.Pointer || t.Kind() == reflect.Interface) && v.IsNil() {
+				e.WriteString("null")
+				return nil
+			}
+			text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return fmt.Errorf("json: error calling MarshalText for type %s: %w", t, err)
+			}
+			e.string(string(text))
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		e.quoted(quoted, strconv.AppendBool(e.scratch[:0], v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		big := e.codec.BigIntStrings && (n > maxSafeInteger || n < -maxSafeInteger)
+		e.quoted(quoted || big, strconv.AppendInt(e.scratch[:0], n, 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := v.Uint()
+		big := e.codec.BigIntStrings && n > maxSafeInteger
+		e.quoted(quoted || big, strconv.AppendUint(e.scratch[:0], n, 10))
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); e.codec.NonFinite != FloatError && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			e.nonFinite(f)
+			return nil
+		}
+		b, err := appendFloat(e.scratch[:0], v.Float(), t.Bits())
+		if err != nil {
+			return err
+		}
+		e.quoted(quoted, b)
+	case reflect.String:
+		if quoted {
+			inner, _ := json.Marshal(v.String())
+			e.string(string(inner))
+		} else {
+			e.string(v.String())
+		}
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			e.WriteString("null")
+			return nil
+		}
+		return e.value(v.Elem(), quoted)
+	case reflect.Struct:
+		return e.object(v)
+	case reflect.Map:
+		return e.mapping(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			if e.codec.EmptyCollections && t.Elem().Kind() != reflect.Uint8 {
+				e.WriteString("[]")
+			} else if e.codec.EmptyCollections {
+				e.WriteString(`""`)
+			} else {
+				e.WriteString("null")
+			}
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 && !implementsMarshaler(t.Elem()) {
+			e.WriteByte('"')
+			e.WriteString(base64.StdEncoding.EncodeToString(v.Bytes()))
+			e.WriteByte('"')
+			return nil
+		}
+		return e.array(v)
+	case reflect.Array:
+		return e.array(v)
+	default:
+		return fmt.Errorf("json: unsupported type: %s", t)
+	}
+
+	return nil
+}
+
+// formats checks if values of t are written by the Codec's formatting
+// options rather than as they'd normally be.
+func (e *codecState) formats(t reflect.Type) bool {
+	if t == timeType {
+		return len(e.codec.TimeLayout) != 0 || e.codec.TimeZone != nil
+	}
+	_, ok := e.codec.Formatters[t]
+	return ok
+}
+
+func (e *codecState) format(v reflect.Value) error {
+	if tm, ok := v.Interface().(time.Time); ok {
+		if e.codec.TimeZone != nil {
+			tm = tm.In(e.codec.TimeZone)
+		}
+		layout := e.codec.TimeLayout
+		if len(layout) == 0 {
+			layout = time.RFC3339Nano
+		}
+		e.string(tm.Format(layout))
+		return nil
+	}
+
+	return e.value(reflect.ValueOf(e.codec.Formatters[v.Type()](v.Interface())), false)
+}
+
+// nonFinite writes a NaN or infinite float as the Codec's NonFinite says.
+func (e *codecState) nonFinite(f float64) {
+	switch {
+	case e.codec.NonFinite == FloatNull:
+		e.WriteString("null")
+	case math.IsNaN(f):
+		e.WriteString(`"NaN"`)
+	case f > 0:
+		e.WriteString(`"Infinity"`)
+	default:
+		e.WriteString(`"-Infinity"`)
+	}
+}
+
+// quoted writes a scalar, in a string when the field has the string option.
+func (e *codecState) quoted(quoted bool, b []byte) {
+	if quoted {
+		e.WriteByte('"')
+	}
+	e.Write(b)
+	if quoted {
+		e.WriteByte('"')
+	}
+}
+
+// string writes s as a json string, escaped the same as encoding/json.
+func (e *codecState) string(s string) {
+	b, _ := json.Marshal(s)
+	e.Write(b)
+}
+
+func (e *codecState) marshaler(m json.Marshaler) error {
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("json: error calling MarshalJSON for type %T: %w", m, err)
+	}
+	if err = json.Compact(&e.Buffer, b); err != nil {
+		return fmt.Errorf("json: error calling MarshalJSON for type %T: %w", m, err)
+	}
+	return nil
+}
+
+func (e *codecState) array(v reflect.Value) error {
+	e.WriteByte('[')
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			e.WriteByte(',')
+		}
+		if err := e.value(v.Index(i), false); err != nil {
+			return err
+		}
+	}
+	e.WriteByte(']')
+	return nil
+}
+
+func (e *codecState) mapping(v reflect.Value) error {
+	if v.IsNil() {
+		if e.codec.EmptyCollections {
+			e.WriteString("{}")
+		} else {
+			e.WriteString("null")
+		}
+		return nil
+	}
+
+	type member struct {
+		key string
+		val reflect.Value
+	}
+	members := make([]member, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := mapKey(iter.Key())
+		if err != nil {
+			return err
+		}
+		members = append(members, member{key, iter.Value()})
+	}
+	sort.Slice(members, func(i, k int) bool { return members[i].key < members[k].key })
+
+	e.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			e.WriteByte(',')
+		}
+		e.string(m.key)
+		e.WriteByte(':')
+		if err := e.value(m.val, false); err != nil {
+			return err
+		}
+	}
+	e.WriteByte('}')
+	return nil
+}
+
+// mapKey resolves the json object key for a map key.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("json: unsupported map key type: %s", k.Type())
+}
+
+func (e *codecState) object(v reflect.Value) error {
+	e.WriteByte('{')
+	first := true
+	for _, f := range codecFields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+
+		if !first {
+			e.WriteByte(',')
+		}
+		first = false
+		e.string(e.codec.key(f.name))
+		e.WriteByte(':')
+		if err := e.value(fv, f.quoted); err != nil {
+			return err
+		}
+	}
+	e.WriteByte('}')
+	return nil
+}
+
+// appendFloat formats a float the same way as encoding/json.
+func appendFloat(b []byte, f float64, bits int) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("json: unsupported value: %s", strconv.FormatFloat(f, 'g', -1, bits))
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b = strconv.AppendFloat(b, f, format, -1, bits)
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b, nil
+}
+
+func implementsMarshaler(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	return t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) ||
+		pt.Implements(jsonMarshalerType) || pt.Implements(textMarshalerType)
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+// fieldByIndex finds a possibly promoted field, it's not ok when the field
+// is behind a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// codecField is a struct field as serialized to json.
+type codecField struct {
+	name      string
+	typ       reflect.Type
+	index     []int
+	depth     int
+	tagged    bool
+	omitEmpty bool
+	quoted    bool
+}
+
+var codecFieldCache sync.Map
+
+// codecFields lists the fields of a struct that are serialized, in order,
+// with the fields of embedded structs promoted like encoding/json does.
+func codecFields(t reflect.Type) []codecField {
+	if fields, ok := codecFieldCache.Load(t); ok {
+		return fields.([]codecField)
+	}
+
+	var all []codecField
+	collectFields(t, nil, map[reflect.Type]bool{t: true}, &all)
+
+	// Of the fields that share a name the shallowest wins, a tie is broken
+	// by a json tag and otherwise none of them are serialized.
+	var fields []codecField
+	for i, f := range all {
+		dominant, tied := true, false
+		for k, other := range all {
+			if k == i || other.name != f.name {
+				continue
+			}
+			switch {
+			case other.depth < f.depth:
+				dominant = false
+			case other.depth == f.depth && other.tagged == f.tagged:
+				tied = true
+			case other.depth == f.depth && other.tagged:
+				dominant = false
+			}
+		}
+		if dominant && !tied {
+			fields = append(fields, f)
+		}
+	}
+
+	codecFieldCache.Store(t, fields)
+	return fields
+}
+
+func collectFields(t reflect.Type, index []int, parents map[reflect.Type]bool, fields *[]codecField) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		if sf.Anonymous {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if !sf.IsExported() && ft.Kind() != reflect.Struct {
+				continue
+			}
+			if len(name) == 0 && ft.Kind() == reflect.Struct {
+				if !parents[ft] {
+					parents[ft] = true
+					collectFields(ft, fieldIndex, parents, fields)
+					delete(parents, ft)
+				}
+				continue
+			}
+		} else if !sf.IsExported() {
+			continue
+		}
+
+		f := codecField{name: name, typ: sf.Type, index: fieldIndex, depth: len(index), tagged: len(name) != 0}
+		if !f.tagged {
+			f.name = sf.Name
+		}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "omitempty":
+				f.omitEmpty = true
+			case "string":
+				ft := sf.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				switch ft.Kind() {
+				case reflect.Bool, reflect.String,
+					reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+					reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+					reflect.Float32, reflect.Float64:
+					f.quoted = true
+				}
+			}
+		}
+		*fields = append(*fields, f)
+	}
+}
@@ -0,0 +1,582 @@
+package jsonware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type codecEmbedded struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Shade string
+}
+
+type codecText struct{ s string }
+
+func (c codecText) MarshalText() ([]byte, error) { return []byte("text:" + c.s), nil }
+
+type codecPtrJSON struct{ n int }
+
+func (c *codecPtrJSON) MarshalJSON() ([]byte, error) { return []byte(` { "n" : 1 } `), nil }
+
+type codecFailing struct{}
+
+func (codecFailing) MarshalJSON() ([]byte, error) { return nil, errors.New("fail") }
+
+type codecStruct struct {
+	codecEmbedded
+	Text *codecText `json:"text,omitempty"`
+
+	Name     string            `json:"display_name"`
+	Skip     string            `json:"-"`
+	hidden   string            //nolint:unused
+	Count    int64             `json:"count,string"`
+	Ratio    float64           `json:"ratio,omitempty"`
+	Flag     *bool             `json:"flag"`
+	Tags     []string          `json:"tags"`
+	Bytes    []byte            `json:"bytes"`
+	Labels   map[string]int    `json:"labels"`
+	Keys     map[int]string    `json:"keys,omitempty"`
+	TextKeys map[codecText]int `json:"text_keys,omitempty"`
+	When     time.Time         `json:"when"`
+	Any      interface{}       `json:"any"`
+	Ptr      codecPtrJSON      `json:"ptr"`
+	Arr      [2]uint8          `json:"arr"`
+	HTML     string            `json:"html"`
+	Quoted   string            `json:",string"`
+	Tiny     float32
+	Huge     float64
+}
+
+func TestCodecMatchesEncodingJSON(t *testing.T) {
+	t.Parallel()
+
+	yes := true
+	var tests = []interface{}{
+		nil,
+		5,
+		"hi <b>",
+		[]int(nil),
+		map[string]int(nil),
+		[]string{"a", "b"},
+		&codecStruct{},
+		&codecStruct{
+			codecEmbedded: codecEmbedded{ID: 5, Name: "shadowed", Shade: "x"},
+			Text:          &codecText{"t"},
+			Name:          "bob",
+			Skip:          "skip",
+			hidden:        "hidden",
+			Count:         1 << 60,
+			Ratio:         0.5,
+			Flag:          &yes,
+			Tags:          []string{"a"},
+			Bytes:         []byte("raw"),
+			Labels:        map[string]int{"b": 2, "a": 1},
+			Keys:          map[int]string{10: "ten", 2: "two"},
+			TextKeys:      map[codecText]int{{"k"}: 1},
+			When:          time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Any:           []interface{}{1.5, "x", nil},
+			Arr:           [2]uint8{1, 2},
+			HTML:          "<a href='x'>&</a>",
+			Quoted:        `say "hi"`,
+			Tiny:          1e-7,
+			Huge:          1e22,
+		},
+		codecStruct{Ptr: codecPtrJSON{5}},
+	}
+
+	for i, test := range tests {
+		want, err := json.Marshal(test)
+		if err != nil {
+			t.Fatalf("Test %d: %v", i, err)
+		}
+		got, err := (&Codec{}).Marshal(test)
+		if err != nil {
+			t.Errorf("Test %d: unexpected error: %v", i, err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("Test %d: expected:\n%s\ngot:\n%s", i, want, got)
+		}
+	}
+}
+
+type codecCycle struct {
+	Name string      `json:"name"`
+	Next *codecCycle `json:"next"`
+}
+
+func TestCodecErrors(t *testing.T) {
+	t.Parallel()
+
+	loop := &codecCycle{Name: "a"}
+	loop.Next = &codecCycle{Name: "b", Next: loop}
+	self := map[string]interface{}{}
+	self["self"] = self
+
+	var tests = []interface{}{
+		math.NaN(),
+		math.Inf(1),
+		make(chan int),
+		codecFailing{},
+		map[bool]int{true: 1},
+		loop,
+		self,
+	}
+
+	for i, test := range tests {
+		if _, err := (&Codec{}).Marshal(test); err == nil {
+			t.Errorf("Test %d: expected an error", i)
+		}
+	}
+}
+
+func TestCodecEmptyCollections(t *testing.T) {
+	t.Parallel()
+
+	type nested struct {
+		Tags   []string          `json:"tags"`
+		Labels map[string]string `json:"labels"`
+		Bytes  []byte            `json:"bytes"`
+		Omit   []string          `json:"omit,omitempty"`
+		Inner  *nested           `json:"inner,omitempty"`
+	}
+
+	var tests = []struct {
+		value interface{}
+		want  string
+	}{
+		{[]int(nil), `[]`},
+		{map[string]int(nil), `{}`},
+		{&nested{}, `{"tags":[],"labels":{},"bytes":""}`},
+		{&nested{Inner: &nested{}}, `{"tags":[],"labels":{},"bytes":"","inner":{"tags":[],"labels":{},"bytes":""}}`},
+		{[]*nested{nil, {Tags: []string{"a"}}}, `[null,{"tags":["a"],"labels":{},"bytes":""}]`},
+		{map[string][]int{"a": nil}, `{"a":[]}`},
+	}
+
+	c := &Codec{EmptyCollections: true}
+	for i, test := range tests {
+		got, err := c.Marshal(test.value)
+		if err != nil {
+			t.Errorf("Test %d: unexpected error: %v", i, err)
+		}
+		if string(got) != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, got)
+		}
+	}
+}
+
+func TestCodecHandler(t *testing.T) {
+	t.Parallel()
+
+	type list struct {
+		Items []string `json:"items"`
+	}
+
+	c := &Codec{EmptyCollections: true}
+	var tests = []struct {
+		handler *JSONHandler
+		want    string
+	}{
+		{Handler(func(r *http.Request) *list { return &list{} }), `{"items":null}` + "\n"},
+		{Handler(func(r *http.Request) *list { return &list{} }).Codec(c), `{"items":[]}` + "\n"},
+		{Handler(func(r *http.Request) []*list { return []*list{{}} }).Codec(c), `[{"items":[]}]` + "\n"},
+		{Handler(func(r *http.Request) func(func(*list) bool) {
+			return func(yield func(*list) bool) { yield(&list{}) }
+		}).Codec(c), `{"items":[]}` + "\n"},
+		{Handler(func(r *http.Request) *list { return &list{} }).Codec(&Codec{}), `{"items":null}` + "\n"},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/x-ndjson, application/json")
+		test.handler.ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("Test %d: expected status 200, got: %d", i, res.Code)
+		}
+		if b := res.Body.String(); b != test.want {
+			t.Errorf("Test %d: expected %q, got: %q", i, test.want, b)
+		}
+	}
+}
+
+func TestCodecKeys(t *testing.T) {
+	t.Parallel()
+
+	type inner struct {
+		PostCode string `json:"post_code"`
+	}
+	type user struct {
+		UserID    int               `json:"user_id"`
+		FirstName string            `json:"first_name"`
+		HTTPProxy string            `json:"HTTPProxy"`
+		Address   *inner            `json:"home_address"`
+		Extra     map[string]string `json:"extra_info"`
+	}
+
+	u := &user{
+		UserID:    5,
+		FirstName: "bob",
+		HTTPProxy: "p",
+		Address:   &inner{PostCode: "A1"},
+		Extra:     map[string]string{"keep_me": "x"},
+	}
+
+	var tests = []struct {
+		style KeyStyle
+		want  string
+	}{
+		{TaggedKeys, `{"user_id":5,"first_name":"bob","HTTPProxy":"p","home_address":{"post_code":"A1"},"extra_info":{"keep_me":"x"}}`},
+		{CamelCase, `{"userId":5,"firstName":"bob","httpProxy":"p","homeAddress":{"postCode":"A1"},"extraInfo":{"keep_me":"x"}}`},
+		{PascalCase, `{"UserId":5,"FirstName":"bob","HttpProxy":"p","HomeAddress":{"PostCode":"A1"},"ExtraInfo":{"keep_me":"x"}}`},
+		{SnakeCase, `{"user_id":5,"first_name":"bob","http_proxy":"p","home_address":{"post_code":"A1"},"extra_info":{"keep_me":"x"}}`},
+	}
+
+	for i, test := range tests {
+		c := &Codec{Keys: test.style}
+		got, err := c.Marshal(u)
+		if err != nil {
+			t.Errorf("Test %d: unexpected error: %v", i, err)
+		}
+		if string(got) != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, got)
+		}
+
+		var back user
+		if err = c.Unmarshal(got, &back); err != nil {
+			t.Errorf("Test %d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(&back, u) {
+			t.Errorf("Test %d: expected %#v, got: %#v", i, u, &back)
+		}
+	}
+}
+
+func TestCodecKeysHandler(t *testing.T) {
+	t.Parallel()
+
+	type user struct {
+		FirstName string `json:"first_name"`
+	}
+
+	h := Handler(func(r *http.Request, u *user) *user { return u }).Codec(&Codec{Keys: CamelCase})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"firstName":"bob"}`))
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(res, req)
+
+	if b := res.Body.String(); b != `{"firstName":"bob"}`+"\n" {
+		t.Errorf("expected camel cased body, got: %s", b)
+	}
+}
+
+type codecDecimal struct{ units int64 }
+
+func (d codecDecimal) StringFixed(places int32) string {
+	return fmt.Sprintf("%d.%0*d", d.units/100, int(places), d.units%100)
+}
+
+func (d codecDecimal) MarshalJSON() ([]byte, error) { return []byte("1"), nil }
+
+func TestCodecFormatters(t *testing.T) {
+	t.Parallel()
+
+	type order struct {
+		Total   codecDecimal  `json:"total"`
+		Tax     *codecDecimal `json:"tax"`
+		Rate    *big.Rat      `json:"rate"`
+		Placed  time.Time     `json:"placed"`
+		Shipped *time.Time    `json:"shipped"`
+		Paid    *time.Time    `json:"paid"`
+	}
+
+	zone := time.FixedZone("X", 2*60*60)
+	placed := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	o := &order{
+		Total:   codecDecimal{1050},
+		Tax:     &codecDecimal{105},
+		Rate:    big.NewRat(1, 3),
+		Placed:  placed,
+		Shipped: &placed,
+	}
+	fixed := map[reflect.Type]func(interface{}) interface{}{
+		reflect.TypeOf(codecDecimal{}): Fixed(2),
+		reflect.TypeOf(&big.Rat{}):     Fixed(3),
+	}
+
+	var tests = []struct {
+		codec *Codec
+		want  string
+	}{
+		{
+			&Codec{},
+			`{"total":1,"tax":1,"rate":"1/3","placed":"2024-01-02T03:04:05.000000006Z","shipped":"2024-01-02T03:04:05.000000006Z","paid":null}`,
+		},
+		{
+			&Codec{Formatters: fixed},
+			`{"total":"10.50","tax":"1.05","rate":"0.333","placed":"2024-01-02T03:04:05.000000006Z","shipped":"2024-01-02T03:04:05.000000006Z","paid":null}`,
+		},
+		{
+			&Codec{TimeLayout: time.DateTime, TimeZone: zone},
+			`{"total":1,"tax":1,"rate":"1/3","placed":"2024-01-02 05:04:05","shipped":"2024-01-02 05:04:05","paid":null}`,
+		},
+		{
+			&Codec{TimeZone: zone},
+			`{"total":1,"tax":1,"rate":"1/3","placed":"2024-01-02T05:04:05.000000006+02:00","shipped":"2024-01-02T05:04:05.000000006+02:00","paid":null}`,
+		},
+	}
+
+	for i, test := range tests {
+		got, err := test.codec.Marshal(o)
+		if err != nil {
+			t.Errorf("Test %d: unexpected error: %v", i, err)
+		}
+		if string(got) != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, got)
+		}
+	}
+}
+
+func TestFixed(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		value interface{}
+		want  interface{}
+	}{
+		{1.005, "1.00"},
+		{float32(2.5), "2.50"},
+		{big.NewRat(5, 4), "1.25"},
+		{*big.NewRat(1, 8), "0.13"},
+		{big.NewFloat(3), "3.00"},
+		{codecDecimal{7}, "0.07"},
+		{5, "5"},
+	}
+
+	for i, test := range tests {
+		if got := Fixed(2)(test.value); got != test.want {
+			t.Errorf("Test %d: expected %v, got: %v", i, test.want, got)
+		}
+	}
+}
+
+func TestCodecLenient(t *testing.T) {
+	t.Parallel()
+
+	type item struct {
+		Count   int                `json:"count"`
+		Price   *float64           `json:"price"`
+		Active  bool               `json:"active"`
+		Name    string             `json:"name"`
+		IDs     []uint             `json:"ids"`
+		Flags   map[string]bool    `json:"flags"`
+		When    time.Time          `json:"when"`
+		Any     interface{}        `json:"any"`
+		Quoted  int                `json:"quoted,string"`
+		Nested  struct{ Size int } `json:"nested"`
+		Ignored int                `json:"-"`
+	}
+
+	var tests = []struct {
+		codec *Codec
+		body  string
+		want  string
+		err   bool
+	}{
+		{&Codec{}, `{"count":"42"}`, ``, true},
+		{&Codec{Lenient: true}, `{"count":"42"}`, `{"count":42,"price":null,"active":false,"name":"","ids":null,"flags":null,"when":"0001-01-01T00:00:00Z","any":null,"quoted":"0","nested":{"Size":0}}`, false},
+		{
+			&Codec{Lenient: true},
+			`{"count":" 7 ","price":"1.5","active":"true","name":"5","ids":["1",2],"flags":{"a":"0"},"when":"2024-01-02T00:00:00Z","any":"3","quoted":"9","nested":{"size":"3"}}`,
+			`{"count":7,"price":1.5,"active":true,"name":"5","ids":[1,2],"flags":{"a":false},"when":"2024-01-02T00:00:00Z","any":"3","quoted":"9","nested":{"Size":3}}`,
+			false,
+		},
+		{&Codec{Lenient: true}, `{"count":"many"}`, ``, true},
+		{&Codec{Lenient: true, Keys: CamelCase}, `{"IDs":["4"]}`, `{"count":0,"price":null,"active":false,"name":"","ids":[4],"flags":null,"when":"0001-01-01T00:00:00Z","any":null,"quoted":"0","nested":{"size":0}}`, false},
+	}
+
+	for i, test := range tests {
+		var it item
+		err := test.codec.Unmarshal([]byte(test.body), &it)
+		if test.err != (err != nil) {
+			t.Errorf("Test %d: expected error %t, got: %v", i, test.err, err)
+		}
+		if test.err {
+			continue
+		}
+
+		b, _ := test.codec.Marshal(&it)
+		if string(b) != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
+
+func TestCodecCycle(t *testing.T) {
+	t.Parallel()
+
+	deep := &codecCycle{Name: "0"}
+	for i := 1; i < maxEncodeDepth/3; i++ {
+		deep = &codecCycle{Name: fmt.Sprint(i), Next: deep}
+	}
+	if _, err := (&Codec{}).Marshal(deep); err != nil {
+		t.Errorf("expected deep values to serialize, got: %v", err)
+	}
+
+	loop := &codecCycle{Name: "a"}
+	loop.Next = loop
+	for i, c := range []*Codec{nil, {}} {
+		logs := &strings.Builder{}
+		h := Handler(func(r *http.Request) *codecCycle { return loop }).Codec(c).Log(logs)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusInternalServerError {
+			t.Errorf("Test %d: expected status 500, got: %d", i, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != `{"error":"problem preparing response"}` {
+			t.Errorf("Test %d: expected the error to be hidden, got: %s", i, b)
+		}
+		if l := logs.String(); !strings.Contains(l, "failed to encode response to GET /") || !strings.Contains(l, "cycle") {
+			t.Errorf("Test %d: expected the cycle to be logged, got: %s", i, l)
+		}
+	}
+}
+
+func TestCodecNonFinite(t *testing.T) {
+	t.Parallel()
+
+	type stats struct {
+		Mean  float64  `json:"mean"`
+		Max   float32  `json:"max"`
+		Ratio float64  `json:"ratio,string"`
+		Min   *float64 `json:"min"`
+	}
+	negInf := math.Inf(-1)
+	value := stats{Mean: math.NaN(), Max: float32(math.Inf(1)), Ratio: math.NaN(), Min: &negInf}
+
+	var tests = []struct {
+		policy FloatPolicy
+		value  interface{}
+		want   string
+	}{
+		{FloatNull, value, `{"mean":null,"max":null,"ratio":null,"min":null}`},
+		{FloatString, value, `{"mean":"NaN","max":"Infinity","ratio":"NaN","min":"-Infinity"}`},
+		{FloatString, []float64{1.5, math.Inf(1)}, `[1.5,"Infinity"]`},
+		{FloatNull, map[string]float64{"a": 2}, `{"a":2}`},
+		{FloatError, value, ``},
+	}
+
+	for i, test := range tests {
+		got, err := (&Codec{NonFinite: test.policy}).Marshal(test.value)
+		if len(test.want) == 0 {
+			if err == nil {
+				t.Errorf("Test %d: expected an error, got: %s", i, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: unexpected error: %v", i, err)
+		}
+		if string(got) != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, got)
+		}
+	}
+}
+
+func TestCodecNonFiniteHandler(t *testing.T) {
+	t.Parallel()
+
+	type score struct {
+		Score float64 `json:"score"`
+	}
+	fn := func(r *http.Request) *score { return &score{Score: math.NaN()} }
+
+	var tests = []struct {
+		handler *JSONHandler
+		status  int
+		want    string
+	}{
+		{Handler(fn).Log(io.Discard), http.StatusInternalServerError, `{"error":"problem preparing response"}`},
+		{Handler(fn).Codec(&Codec{NonFinite: FloatNull}), http.StatusOK, `{"score":null}`},
+		{Handler(fn).Codec(&Codec{NonFinite: FloatString}).CheckResponses(ResponseCheck{Reject: true}), http.StatusOK, `{"score":"NaN"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		test.handler.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
+
+func TestCodecBigIntStrings(t *testing.T) {
+	t.Parallel()
+
+	type ids struct {
+		ID     int64   `json:"id"`
+		Seq    uint64  `json:"seq"`
+		Small  int32   `json:"small"`
+		Parent *int64  `json:"parent"`
+		Refs   []int64 `json:"refs"`
+		Label  string  `json:"label"`
+	}
+	parent := int64(-1 << 60)
+	value := ids{ID: 1<<53 - 1, Seq: 1 << 53, Small: -5, Parent: &parent, Refs: []int64{1, -(1 << 53)}}
+
+	c := &Codec{BigIntStrings: true}
+	got, err := c.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":9007199254740991,"seq":"9007199254740992","small":-5,"parent":"-1152921504606846976","refs":[1,"-9007199254740992"],"label":""}`
+	if string(got) != want {
+		t.Errorf("expected %s, got: %s", want, got)
+	}
+
+	var tests = []struct {
+		body string
+		want ids
+		err  bool
+	}{
+		{want, value, false},
+		{`{"id":"12","seq":13,"label":"14"}`, ids{ID: 12, Seq: 13, Label: "14"}, false},
+		{`{"seq":"18446744073709551615"}`, ids{Seq: 1<<64 - 1}, false},
+		{`{"id":"1.5"}`, ids{}, true},
+		{`{"id":" 1"}`, ids{}, true},
+		{`{"small":"01"}`, ids{}, true},
+	}
+
+	for i, test := range tests {
+		var got ids
+		err := c.Unmarshal([]byte(test.body), &got)
+		if test.err != (err != nil) {
+			t.Errorf("Test %d: expected error %t, got: %v", i, test.err, err)
+		}
+		if !test.err && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Test %d: expected %#v, got: %#v", i, test.want, got)
+		}
+	}
+}
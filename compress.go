@@ -0,0 +1,401 @@
+package jsonware
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoder compresses everything written to it into the writer it was last
+// Reset with. Encoders are pooled and reused between responses.
+type Encoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+	Flush() error
+}
+
+// contentCoding is a registered content coding and the pool of its encoders.
+type contentCoding struct {
+	name string
+	pool *sync.Pool
+}
+
+var encodings = make(map[string]*contentCoding)
+
+// DefaultEncodingPreference is the order a Compress prefers content codings
+// in when the client has no preference between them and its Prefer field is
+// nil. Codings that are not listed are preferred least, in the order they
+// were registered.
+var DefaultEncodingPreference = []string{"zstd", "br", "gzip"}
+
+// encodingOrder is the order encodings were registered in.
+var encodingOrder []string
+
+func init() {
+	RegisterEncoding("gzip", func() Encoder { return gzip.NewWriter(nil) })
+	RegisterDecoding("gzip", func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) })
+}
+
+/*
+RegisterEncoding makes a content coding available for a Compress to
+negotiate with clients, name is the coding's token as it appears in the
+Accept-Encoding header. newEncoder creates encoders for the coding's pool,
+registering a name again replaces it. gzip is always registered, the
+compression package registers br and zstd.
+
+	jsonware.RegisterEncoding("gzip", func() jsonware.Encoder {
+		w, _ := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+		return w
+	})
+*/
+func RegisterEncoding(name string, newEncoder func() Encoder) {
+	if len(name) == 0 || newEncoder == nil {
+		panic("RegisterEncoding must be given a name and an encoder constructor")
+	}
+
+	name = strings.ToLower(name)
+	if _, ok := encodings[name]; !ok {
+		encodingOrder = append(encodingOrder, name)
+	}
+	encodings[name] = &contentCoding{
+		name: name,
+		pool: &sync.Pool{New: func() interface{} { return newEncoder() }},
+	}
+}
+
+// defaultMinCompressSize is the MinSize of a Compress that leaves it zero.
+const defaultMinCompressSize = 512
+
+/*
+Compress compresses the responses of the handlers it wraps with the content
+coding the client prefers out of the registered ones. Responses that are
+smaller than MinSize bytes are not worth compressing and are sent as is, 512
+is used when it's zero. Responses are always compressed once the handler
+flushes them, which streaming handlers do, since their size can't be known.
+Responses that already have a Content-Encoding are left alone. The ETag of a
+compressed response is made weak, since a strong one would be the same for
+the compressed and the uncompressed body.
+
+Prefer sets which codings are preferred when the client accepts several
+equally, DefaultEncodingPreference is used when it's nil.
+
+	c := &jsonware.Compress{}
+	http.Handle("/users/", c.Wrap(jsonware.Handler(listUsers)))
+*/
+type Compress struct {
+	MinSize int
+	Prefer  []string
+}
+
+// Wrap returns a handler that serves requests with h and compresses its
+// responses.
+func (c *Compress) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		enc := c.negotiate(r.Header.Get("Accept-Encoding"))
+		if enc == nil || r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, enc: enc, min: c.MinSize}
+		if cw.min == 0 {
+			cw.min = defaultMinCompressSize
+		}
+		defer cw.close()
+
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// negotiate picks the encoding to compress with from an Accept-Encoding
+// header, returning nil when the response shouldn't be compressed.
+func (c *Compress) negotiate(accept string) *contentCoding {
+	if len(accept) == 0 {
+		return nil
+	}
+
+	qualities := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.ToLower(key) != "q" {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(val, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		if name == "*" {
+			wildcard = q
+		} else {
+			qualities[name] = q
+		}
+	}
+
+	prefer := c.Prefer
+	if prefer == nil {
+		prefer = DefaultEncodingPreference
+	}
+	order := append(append([]string{}, prefer...), encodingOrder...)
+
+	var best *contentCoding
+	bestQ := 0.0
+	for _, name := range order {
+		enc, ok := encodings[name]
+		if !ok {
+			continue
+		}
+		q, ok := qualities[name]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+
+	return best
+}
+
+// compressWriter holds back the start of a response until it knows whether
+// it's big enough to compress, and compresses it if so.
+type compressWriter struct {
+	http.ResponseWriter
+
+	enc *contentCoding
+	min int
+
+	status  int
+	buf     []byte
+	decided bool
+	encoder Encoder
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	switch {
+	case c.decided:
+		c.ResponseWriter.WriteHeader(status)
+	case status >= 100 && status < 200:
+		// Informational responses don't have bodies.
+		c.ResponseWriter.WriteHeader(status)
+	case c.status == 0:
+		c.status = status
+	}
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if !c.decided {
+		c.buf = append(c.buf, b...)
+		if len(c.buf) < c.min {
+			return len(b), nil
+		}
+		if err := c.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if c.encoder != nil {
+		return c.encoder.Write(b)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Flush compresses whatever has been held back so it can be sent.
+func (c *compressWriter) Flush() {
+	if !c.decided {
+		if c.decide(true) != nil {
+			return
+		}
+	}
+	if c.encoder != nil && c.encoder.Flush() != nil {
+		return
+	}
+	_ = http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// decide sends the headers, compressing the rest of the response if
+// compress is set and the response can be compressed.
+func (c *compressWriter) decide(compress bool) error {
+	c.decided = true
+
+	header := c.Header()
+	switch {
+	case c.status == http.StatusNoContent, c.status == http.StatusNotModified:
+		compress = false
+	case len(header.Get("Content-Encoding")) != 0:
+		compress = false
+	}
+
+	if compress {
+		header.Set("Content-Encoding", c.enc.name)
+		header.Del("Content-Length")
+		// Digests of the uncompressed body don't match anymore.
+		header.Del("Content-Digest")
+		header.Del("Content-MD5")
+		// Strong etags must differ between codings, the weak one still
+		// matches conditional requests since they compare weakly.
+		if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			header.Set("ETag", "W/"+etag)
+		}
+		c.encoder = c.enc.pool.Get().(Encoder)
+		c.encoder.Reset(c.ResponseWriter)
+	}
+
+	if c.status != 0 {
+		c.ResponseWriter.WriteHeader(c.status)
+	}
+
+	buf := c.buf
+	c.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if c.encoder != nil {
+		_, err := c.encoder.Write(buf)
+		return err
+	}
+	_, err := c.ResponseWriter.Write(buf)
+	return err
+}
+
+// close finishes the response.
+func (c *compressWriter) close() {
+	if !c.decided {
+		// Nothing big enough to compress was written.
+		_ = c.decide(false)
+	}
+	if c.encoder != nil {
+		_ = c.encoder.Close()
+		c.enc.pool.Put(c.encoder)
+		c.encoder = nil
+	}
+}
+
+var decodings = make(map[string]func(io.Reader) (io.ReadCloser, error))
+
+// MaxDecompressedSize is the most bytes a compressed request body may
+// decompress to, bodies that decompress to more are rejected with a 413 to
+// protect against decompression bombs.
+var MaxDecompressedSize int64 = 10 << 20
+
+/*
+RegisterDecoding makes JSONHandlers accept request bodies compressed with a
+content coding, name is the coding's token as it appears in the
+Content-Encoding header. newDecoder wraps a compressed body in a reader that
+decompresses it. gzip is always registered, the compression package
+registers zstd.
+
+Request bodies with a Content-Encoding that isn't registered are rejected
+with a 415.
+*/
+func RegisterDecoding(name string, newDecoder func(r io.Reader) (io.ReadCloser, error)) {
+	if len(name) == 0 || newDecoder == nil {
+		panic("RegisterDecoding must be given a name and a decoder constructor")
+	}
+	decodings[strings.ToLower(name)] = newDecoder
+}
+
+// errBodyTooLarge is the error reading bodies that decompress to more than
+// MaxDecompressedSize.
+var errBodyTooLarge = errors.New("decompressed request body is too large")
+
+// decompressBody returns a copy of a request that has a Content-Encoding with
+// a body that decompresses it, the caller's request is left alone.
+func decompressBody(r *http.Request) (*http.Request, error) {
+	ce := r.Header.Get("Content-Encoding")
+	if len(ce) == 0 || r.Body == nil || r.Body == http.NoBody {
+		return r, nil
+	}
+
+	codings := strings.Split(ce, ",")
+	body := &decompressedBody{orig: r.Body}
+	var rd io.Reader = r.Body
+	// Codings are listed in the order they were applied.
+	for i := len(codings) - 1; i >= 0; i-- {
+		name := strings.ToLower(strings.TrimSpace(codings[i]))
+		if name == "identity" || len(name) == 0 {
+			continue
+		}
+
+		newDecoder, ok := decodings[name]
+		if !ok {
+			return nil, Err{
+				Status: http.StatusUnsupportedMediaType,
+				Err:    fmt.Errorf("unsupported content encoding: %s", name),
+			}
+		}
+		dec, err := newDecoder(rd)
+		if err != nil {
+			body.Close()
+			return nil, Err{
+				Status: http.StatusBadRequest,
+				Err:    fmt.Errorf("could not decompress request body"),
+			}
+		}
+		body.decoders = append(body.decoders, dec)
+		rd = dec
+	}
+
+	body.r = rd
+	body.left = MaxDecompressedSize
+	r2 := *r
+	r2.Body = body
+	r2.ContentLength = -1
+	r2.Header = r.Header.Clone()
+	r2.Header.Del("Content-Encoding")
+	r2.Header.Del("Content-Length")
+	return &r2, nil
+}
+
+// decompressedBody reads a decompressed request body, failing once more
+// than its limit has been read.
+type decompressedBody struct {
+	r        io.Reader
+	left     int64
+	decoders []io.ReadCloser
+	orig     io.ReadCloser
+}
+
+func (d *decompressedBody) Read(b []byte) (int, error) {
+	if d.left <= 0 {
+		// Only fail if there really is more.
+		var one [1]byte
+		if n, _ := d.r.Read(one[:]); n != 0 {
+			return 0, errBodyTooLarge
+		}
+		return 0, io.EOF
+	}
+
+	if int64(len(b)) > d.left {
+		b = b[:d.left]
+	}
+	n, err := d.r.Read(b)
+	d.left -= int64(n)
+	return n, err
+}
+
+func (d *decompressedBody) Close() error {
+	for _, dec := range d.decoders {
+		dec.Close()
+	}
+	return d.orig.Close()
+}
@@ -0,0 +1,264 @@
+package jsonware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type bigType struct {
+	Names []string `json:"names"`
+}
+
+func bigHandler(r *http.Request) *bigType {
+	b := &bigType{}
+	for i := 0; i < 100; i++ {
+		b.Names = append(b.Names, "bob")
+	}
+	return b
+}
+
+func TestCompress(t *testing.T) {
+	t.Parallel()
+
+	small := Handler(func(r *http.Request) *testType { return &testType{"bob"} })
+	big := Handler(bigHandler)
+	raw := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("a"), 1024))
+	})
+	encoded := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "custom")
+		w.Write(bytes.Repeat([]byte("a"), 1024))
+	})
+
+	var tests = []struct {
+		handler  http.Handler
+		method   string
+		accept   string
+		encoding string
+	}{
+		{big, "GET", "gzip", "gzip"},
+		{big, "GET", "deflate, gzip;q=0.5", "gzip"},
+		{big, "GET", "*", "gzip"},
+		{big, "GET", "gzip;q=0", ""},
+		{big, "GET", "*, gzip;q=0", ""},
+		{big, "GET", "", ""},
+		{big, "GET", "deflate", ""},
+		{raw, "HEAD", "gzip", ""},
+		{small, "GET", "gzip", ""},
+		{encoded, "GET", "gzip", "custom"},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/", nil)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Accept-Encoding", test.accept)
+
+		(&Compress{}).Wrap(test.handler).ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("Test %d: expected status 200, got: %d", i, res.Code)
+		}
+		if enc := res.Header().Get("Content-Encoding"); enc != test.encoding {
+			t.Errorf("Test %d: expected encoding %q, got: %q", i, test.encoding, enc)
+		}
+		if vary := res.Header().Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("Test %d: expected vary header, got: %q", i, vary)
+		}
+		if test.encoding != "gzip" {
+			continue
+		}
+
+		gz, err := gzip.NewReader(res.Body)
+		if err != nil {
+			t.Fatalf("Test %d: %v", i, err)
+		}
+		b, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatalf("Test %d: %v", i, err)
+		}
+		if !strings.HasPrefix(string(b), `{"names":["bob",`) {
+			t.Errorf("Test %d: body was wrong: %s", i, b)
+		}
+	}
+}
+
+func TestCompressETag(t *testing.T) {
+	t.Parallel()
+
+	h := (&Compress{}).Wrap(Handler(bigHandler).ETags(&MemoryETagStore{}, nil))
+	send := func(accept, etag string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Accept-Encoding", accept)
+		if len(etag) != 0 {
+			req.Header.Set("If-None-Match", etag)
+		}
+		h.ServeHTTP(res, req)
+		return res
+	}
+
+	plain := send("", "").Header().Get("ETag")
+	compressed := send("gzip", "").Header().Get("ETag")
+	if !strings.HasPrefix(plain, `"`) || compressed != "W/"+plain {
+		t.Errorf("expected the compressed etag to be the weak %s, got: %s", plain, compressed)
+	}
+
+	for i, etag := range []string{plain, compressed} {
+		if res := send("gzip", etag); res.Code != http.StatusNotModified {
+			t.Errorf("Test %d: expected a 304 for %s, got: %d", i, etag, res.Code)
+		}
+	}
+}
+
+func TestCompressStatus(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(func(r *http.Request) error {
+		return Err{Status: http.StatusNotFound, Err: io.EOF}
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+	(&Compress{}).Wrap(h).ServeHTTP(res, req)
+
+	if res.Code != http.StatusNotFound {
+		t.Error("Expected status 404, got:", res.Code)
+	}
+	if b := res.Body.String(); b != `{"error":"EOF"}`+"\n" {
+		t.Error("Body was wrong:", b)
+	}
+}
+
+func TestCompressStream(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(func(r *http.Request) iter.Seq[int] {
+		return func(yield func(int) bool) {
+			for i := 0; i < 3; i++ {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+	})
+
+	res := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.Header.Set("Accept-Encoding", "gzip")
+	(&Compress{}).Wrap(h).ServeHTTP(res, req)
+
+	if enc := res.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatal("Expected a small stream to be compressed, got:", enc)
+	}
+	if len(res.flushes) == 0 {
+		t.Error("Expected flushes to reach the client")
+	}
+
+	gz, err := gzip.NewReader(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "0\n1\n2\n" {
+		t.Errorf("Body was wrong: %q", b)
+	}
+}
+
+func TestCompressNegotiate(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		prefer []string
+		accept string
+		want   string
+	}{
+		{nil, "gzip", "gzip"},
+		{nil, "GZIP;Q=0.8", "gzip"},
+		{nil, "br", ""},
+		{[]string{"gzip"}, "*;q=0.1", "gzip"},
+		{nil, "gzip;q=abc", "gzip"},
+	}
+
+	for i, test := range tests {
+		enc := (&Compress{Prefer: test.prefer}).negotiate(test.accept)
+		got := ""
+		if enc != nil {
+			got = enc.name
+		}
+		if got != test.want {
+			t.Errorf("Test %d: expected %q, got: %q", i, test.want, got)
+		}
+	}
+}
+
+func TestRegisterEncodingPanics(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic")
+		}
+	}()
+	RegisterEncoding("", nil)
+}
+
+func gzipped(s string) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	gz.Write([]byte(s))
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestDecompressRequest(t *testing.T) {
+	t.Parallel()
+
+	huge := `{"name":"` + strings.Repeat("a", int(MaxDecompressedSize)) + `"}`
+
+	var tests = []struct {
+		encoding string
+		body     []byte
+		status   int
+		resbody  string
+	}{
+		{"gzip", gzipped(`{"name":"bob"}`), http.StatusOK, `{"name":"bob"}`},
+		{"identity, GZIP", gzipped(`{"name":"bob"}`), http.StatusOK, `{"name":"bob"}`},
+		{"", []byte(`{"name":"bob"}`), http.StatusOK, `{"name":"bob"}`},
+		{"compress", []byte("abc"), http.StatusUnsupportedMediaType, `{"error":"unsupported content encoding: compress"}`},
+		{"gzip", []byte("abc"), http.StatusBadRequest, `{"error":"could not decompress request body"}`},
+		{"gzip", gzipped(huge), http.StatusRequestEntityTooLarge, `{"error":"decompressed request body is too large"}`},
+	}
+
+	h := Handler(func(r *http.Request, in *testType) *testType { return in })
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", bytes.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Content-Encoding", test.encoding)
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := res.Body.String(); b != test.resbody+"\n" {
+			t.Errorf("Test %d: body was wrong: %s", i, b)
+		}
+		if enc := req.Header.Get("Content-Encoding"); enc != test.encoding {
+			t.Errorf("Test %d: expected the caller's headers to be left alone, got: %q", i, enc)
+		}
+	}
+}
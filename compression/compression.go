@@ -0,0 +1,54 @@
+/*
+Package compression registers the br and zstd content codings with jsonware
+so that a jsonware.Compress can negotiate them alongside gzip, and so that
+JSONHandlers accept request bodies compressed with them. Importing it is
+enough to register both at their default levels:
+
+	import _ "github.com/aarondl/jsonware/compression"
+
+Brotli and Zstd can be registered again to change the level.
+
+	jsonware.RegisterEncoding("br", compression.Brotli(brotli.BestCompression))
+*/
+package compression
+
+import (
+	"io"
+
+	"github.com/aarondl/jsonware"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func init() {
+	jsonware.RegisterEncoding("br", Brotli(brotli.DefaultCompression))
+	jsonware.RegisterEncoding("zstd", Zstd(zstd.SpeedDefault))
+
+	jsonware.RegisterDecoding("br", func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(brotli.NewReader(r)), nil
+	})
+	jsonware.RegisterDecoding("zstd", func(r io.Reader) (io.ReadCloser, error) {
+		dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	})
+}
+
+// Brotli creates brotli encoders that compress at level.
+func Brotli(level int) func() jsonware.Encoder {
+	return func() jsonware.Encoder {
+		return brotli.NewWriterLevel(nil, level)
+	}
+}
+
+// Zstd creates zstd encoders that compress at level. Each encoder compresses
+// on the goroutine writing to it, a response has no use for more.
+func Zstd(level zstd.EncoderLevel) func() jsonware.Encoder {
+	return func() jsonware.Encoder {
+		// The options are all valid so this cannot fail.
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1))
+		return enc
+	}
+}
@@ -0,0 +1,118 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aarondl/jsonware"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+type names struct {
+	Names []string `json:"names"`
+}
+
+func listNames(r *http.Request) *names {
+	n := &names{}
+	for i := 0; i < 100; i++ {
+		n.Names = append(n.Names, "bob")
+	}
+	return n
+}
+
+func TestEncodings(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		accept   string
+		encoding string
+	}{
+		{"br", "br"},
+		{"zstd", "zstd"},
+		{"gzip, br, zstd", "zstd"},
+		{"gzip, br;q=0.9, zstd;q=0.5", "gzip"},
+		{"br, zstd;q=0.5", "br"},
+	}
+
+	h := (&jsonware.Compress{}).Wrap(jsonware.Handler(listNames))
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Accept-Encoding", test.accept)
+		h.ServeHTTP(res, req)
+
+		if enc := res.Header().Get("Content-Encoding"); enc != test.encoding {
+			t.Errorf("Test %d: expected encoding %q, got: %q", i, test.encoding, enc)
+			continue
+		}
+
+		var r io.Reader
+		switch test.encoding {
+		case "br":
+			r = brotli.NewReader(res.Body)
+		case "zstd":
+			dec, err := zstd.NewReader(res.Body)
+			if err != nil {
+				t.Fatalf("Test %d: %v", i, err)
+			}
+			defer dec.Close()
+			r = dec
+		default:
+			continue
+		}
+
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("Test %d: %v", i, err)
+		}
+		if !strings.HasPrefix(string(b), `{"names":["bob",`) {
+			t.Errorf("Test %d: body was wrong: %s", i, b)
+		}
+	}
+}
+
+func TestDecodings(t *testing.T) {
+	t.Parallel()
+
+	body := `{"names":["bob"]}`
+
+	br := &bytes.Buffer{}
+	bw := brotli.NewWriter(br)
+	bw.Write([]byte(body))
+	bw.Close()
+
+	zs := &bytes.Buffer{}
+	zw, _ := zstd.NewWriter(zs)
+	zw.Write([]byte(body))
+	zw.Close()
+
+	var tests = []struct {
+		encoding string
+		body     []byte
+	}{
+		{"br", br.Bytes()},
+		{"zstd", zs.Bytes()},
+	}
+
+	h := jsonware.Handler(func(r *http.Request, n *names) *names { return n })
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", bytes.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Content-Encoding", test.encoding)
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("Test %d: expected status 200, got: %d", i, res.Code)
+		}
+		if b := res.Body.String(); b != body+"\n" {
+			t.Errorf("Test %d: body was wrong: %s", i, b)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package jsonware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// errBodyLimit is the error reading bodies larger than their group's
+// MaxBodySize.
+var errBodyLimit = errors.New("request body is too large")
+
+// LogLevel is how much a group of handlers logs.
+type LogLevel int
+
+const (
+	// LogErrors logs cloaked errors and other problems, it's the default.
+	LogErrors LogLevel = iota
+	// LogNone logs nothing, for a group that's flooding the logs with an
+	// error that's already known about.
+	LogNone
+)
+
+// Config is the configuration of a group of handlers that can be changed
+// while the server is running, see SetConfig. The zero value changes nothing.
+type Config struct {
+	// Rate is the rate limit of RateLimits for the group, it's used in
+	// place of their own Rate when it's not zero.
+	Rate Rate
+	// MaxBodySize is the most bytes a request body may have, larger ones
+	// are rejected with a 413. Bodies aren't limited when it's zero.
+	MaxBodySize int64
+	// Flags turns the feature flags of the group's handlers on and off,
+	// flags that aren't in it are left to the handler's FlagProvider.
+	Flags    map[string]bool
+	LogLevel LogLevel
+}
+
+// ConfigProvider finds the configuration of a group of handlers, see
+// JSONHandler.Group. Handlers that aren't in a group are in the group "".
+// It's called on every request so it should be cheap.
+type ConfigProvider interface {
+	Config(group string) Config
+}
+
+// StaticConfig is a ConfigProvider with a fixed Config for each group.
+type StaticConfig map[string]Config
+
+// Config returns the group's Config.
+func (s StaticConfig) Config(group string) Config {
+	return s[group]
+}
+
+var configProvider atomic.Pointer[ConfigProvider]
+
+/*
+SetConfig makes handlers take their configuration from provider, or go back
+to none when it's nil. It's safe to call at any time, so limits can be tuned
+without restarting the server by swapping in a new provider, or with a
+provider that reads a config service.
+
+	jsonware.SetConfig(jsonware.StaticConfig{
+		"search": {Rate: jsonware.Rate{PerSecond: 2, Burst: 5}, MaxBodySize: 4 << 10},
+		"billing": {Flags: map[string]bool{"invoices-v2": false}},
+	})
+*/
+func SetConfig(provider ConfigProvider) {
+	if provider == nil {
+		configProvider.Store(nil)
+		return
+	}
+	configProvider.Store(&provider)
+}
+
+// groupConfig finds the Config of a group, it's not ok when there's no
+// ConfigProvider.
+func groupConfig(group string) (Config, bool) {
+	provider := configProvider.Load()
+	if provider == nil {
+		return Config{}, false
+	}
+	return (*provider).Config(group), true
+}
+
+// apply applies the parts of the config that don't depend on the handler to
+// a request, returning a copy of it when its body has to be limited.
+func (c Config) apply(r *http.Request, logger io.Writer) (*http.Request, io.Writer) {
+	if c.MaxBodySize > 0 && r.Body != nil && r.Body != http.NoBody {
+		r2 := *r
+		r2.Body = &limitedBody{ReadCloser: r.Body, left: c.MaxBodySize}
+		r = &r2
+	}
+	if c.LogLevel == LogNone {
+		return r, io.Discard
+	}
+	return r, logger
+}
+
+// limitedBody fails reading a body once more than its limit has been read.
+type limitedBody struct {
+	io.ReadCloser
+	left int64
+}
+
+func (l *limitedBody) Read(b []byte) (int, error) {
+	if l.left <= 0 {
+		// Only fail if there really is more.
+		var one [1]byte
+		if n, _ := l.ReadCloser.Read(one[:]); n != 0 {
+			return 0, errBodyLimit
+		}
+		return 0, io.EOF
+	}
+
+	if int64(len(b)) > l.left {
+		b = b[:l.left]
+	}
+	n, err := l.ReadCloser.Read(b)
+	l.left -= int64(n)
+	return n, err
+}
+
+// isBodyTooLarge checks if err is from reading a body that's over a limit.
+func isBodyTooLarge(err error) bool {
+	return err == errBodyTooLarge || err == errBodyLimit
+}
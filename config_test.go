@@ -0,0 +1,87 @@
+package jsonware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestConfig isn't parallel, the config would affect the other tests.
+func TestConfig(t *testing.T) {
+	defer SetConfig(nil)
+
+	logger := &bytes.Buffer{}
+	handler := func(group string) *JSONHandler {
+		return Handler(func(r *http.Request, in *testType) (*testType, error) {
+			if in.Name == "fail" {
+				return nil, errBodyLimit
+			}
+			return in, nil
+		}).Group(group).Log(logger).FeatureFlag("v2", nil, 0)
+	}
+
+	var tests = []struct {
+		config StaticConfig
+		group  string
+		body   string
+		status int
+		logged bool
+	}{
+		{nil, "", `{"name":"a"}`, 404, false},
+		{StaticConfig{"": {Flags: map[string]bool{"v2": true}}}, "", `{"name":"a"}`, 200, false},
+		{StaticConfig{"": {Flags: map[string]bool{"v2": true}}}, "search", `{"name":"a"}`, 404, false},
+		{StaticConfig{"search": {Flags: map[string]bool{"v2": true}, MaxBodySize: 8}}, "search", `{"name":"a"}`, 413, false},
+		{StaticConfig{"search": {Flags: map[string]bool{"v2": true}, MaxBodySize: 12}}, "search", `{"name":"a"}`, 200, false},
+		{StaticConfig{"": {Flags: map[string]bool{"v2": true}}}, "", `{"name":"fail"}`, 500, true},
+		{StaticConfig{"": {Flags: map[string]bool{"v2": true}, LogLevel: LogNone}}, "", `{"name":"fail"}`, 500, false},
+	}
+
+	for i, test := range tests {
+		if test.config == nil {
+			SetConfig(nil)
+		} else {
+			SetConfig(test.config)
+		}
+		logger.Reset()
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		body := req.Body
+		handler(test.group).ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if req.Body != body {
+			t.Errorf("Test %d: expected the caller's request body to be left alone", i)
+		}
+		if logged := logger.Len() != 0; logged != test.logged {
+			t.Errorf("Test %d: expected logged %t, got: %q", i, test.logged, logger.String())
+		}
+	}
+}
+
+// TestConfigRate isn't parallel, the config would affect the other tests.
+func TestConfigRate(t *testing.T) {
+	defer SetConfig(nil)
+
+	now := time.Unix(1000, 0)
+	rl := &RateLimit{Rate: Rate{PerSecond: 1, Burst: 1}, Group: "login", now: func() time.Time { return now }}
+
+	if _, ok := rl.take("a"); !ok {
+		t.Error("expected the first request to be allowed")
+	}
+	if _, ok := rl.take("a"); ok {
+		t.Error("expected the second request to be limited")
+	}
+
+	SetConfig(StaticConfig{"login": {Rate: Rate{PerSecond: 100, Burst: 100}}})
+	now = now.Add(50 * time.Millisecond)
+	if _, ok := rl.take("a"); !ok {
+		t.Error("expected the config's rate to be used")
+	}
+}
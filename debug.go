@@ -0,0 +1,191 @@
+package jsonware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultDebugHeader is the header that asks a DebugCapture for a capture
+// when its Header field is empty.
+const DefaultDebugHeader = "Jsonware-Debug"
+
+// Capture is everything a DebugCapture saw of a request.
+type Capture struct {
+	// ID is the value of the debug header, so captures can be told apart in
+	// a log.
+	ID            string      `json:"id"`
+	Method        string      `json:"method"`
+	URI           string      `json:"uri"`
+	RequestHeader http.Header `json:"request_header,omitempty"`
+	RequestBody   string      `json:"request_body,omitempty"`
+
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+	ResponseBody   string      `json:"response_body,omitempty"`
+
+	// Decode is how long it took to get to calling the handler, Handler how
+	// long the handler ran and Encode how long it took to send its response
+	// after, in nanoseconds. Decode and Handler are zero for requests that
+	// weren't served by a JSONHandler.
+	Decode  time.Duration `json:"decode_ns"`
+	Handler time.Duration `json:"handler_ns"`
+	Encode  time.Duration `json:"encode_ns"`
+	Total   time.Duration `json:"total_ns"`
+}
+
+/*
+DebugCapture captures the request and response bodies and the timings of
+single requests that ask for it with a debug header, to debug a live issue
+without turning on verbose logging for everyone. Requests are only captured
+when Authorize allows it, nothing is captured when Authorize is nil.
+
+Captures are written to Log as a line of json each, with the response's
+Jsonware-Debug-Id header set to the capture's id to find it by. When Log is
+nil the capture is sent back to the client as json in a Jsonware-Debug
+trailer instead. Headers in DefaultRedactedHeaders are redacted.
+
+	debug := &jsonware.DebugCapture{
+		Authorize: func(r *http.Request) bool { return isStaff(r) },
+	}
+	http.Handle("/", debug.Wrap(mux))
+
+	curl -H 'Jsonware-Debug: ticket-42' ...
+*/
+type DebugCapture struct {
+	// Header is the header that asks for a capture, its value is the
+	// capture's id. DefaultDebugHeader is used when it's empty.
+	Header    string
+	Authorize func(r *http.Request) bool
+	// BodyLimit is how many bytes of each body are captured, 8KB when it's
+	// zero.
+	BodyLimit int
+	Log       io.Writer
+}
+
+type captureKey struct{}
+
+// capturing is a capture in progress.
+type capturing struct {
+	start, called, returned time.Time
+}
+
+// captureFrom finds the capture in progress of a request, if there is one.
+func captureFrom(ctx context.Context) *capturing {
+	c, _ := ctx.Value(captureKey{}).(*capturing)
+	return c
+}
+
+// Wrap returns a handler that serves requests with h and captures the ones
+// that ask for it.
+func (d *DebugCapture) Wrap(h http.Handler) http.Handler {
+	header := d.Header
+	if len(header) == 0 {
+		header = DefaultDebugHeader
+	}
+	limit := d.BodyLimit
+	if limit <= 0 {
+		limit = 8 << 10
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(header)
+		if len(id) == 0 || d.Authorize == nil || !d.Authorize(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		c := &capturing{start: time.Now()}
+		// The body is only replaced on the copy WithContext makes, the
+		// caller's request is left alone.
+		r2 := r.WithContext(context.WithValue(r.Context(), captureKey{}, c))
+		var body *snippetReader
+		if r2.Body != nil && r2.Body != http.NoBody {
+			body = &snippetReader{ReadCloser: r2.Body, limit: limit}
+			r2.Body = body
+		}
+		r = r2
+
+		if d.Log != nil {
+			w.Header().Set("Jsonware-Debug-Id", id)
+		} else {
+			w.Header().Add("Trailer", "Jsonware-Debug")
+		}
+		tee := &captureWriter{ResponseWriter: w, limit: limit}
+		h.ServeHTTP(tee, r)
+		end := time.Now()
+
+		capture := Capture{
+			ID:             id,
+			Method:         r.Method,
+			URI:            r.URL.RequestURI(),
+			RequestHeader:  redactHeader(r.Header, DefaultRedactedHeaders),
+			Status:         tee.status,
+			ResponseHeader: redactHeader(w.Header(), DefaultRedactedHeaders),
+			ResponseBody:   tee.body.String(),
+			Total:          end.Sub(c.start),
+		}
+		capture.ResponseHeader.Del("Trailer")
+		if capture.Status == 0 {
+			capture.Status = http.StatusOK
+		}
+		if body != nil {
+			capture.RequestBody = body.snippet.String()
+		}
+		if !c.called.IsZero() {
+			capture.Decode = c.called.Sub(c.start)
+			capture.Handler = c.returned.Sub(c.called)
+			capture.Encode = end.Sub(c.returned)
+		}
+
+		b, err := json.Marshal(capture)
+		if err != nil {
+			logf(d.Log, "failed to encode debug capture: %v", err)
+			return
+		}
+		if d.Log != nil {
+			b = append(b, '\n')
+			if _, err := d.Log.Write(b); err != nil {
+				logf(nil, "failed to write debug capture: %v", err)
+			}
+			return
+		}
+		w.Header().Set("Jsonware-Debug", string(b))
+	})
+}
+
+// captureWriter passes everything through to the ResponseWriter while
+// keeping the status and the start of the body.
+type captureWriter struct {
+	http.ResponseWriter
+
+	limit  int
+	status int
+	body   bytes.Buffer
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if room := c.limit - c.body.Len(); room > 0 {
+		if room > len(b) {
+			room = len(b)
+		}
+		c.body.Write(b[:room])
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (c *captureWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
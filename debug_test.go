@@ -0,0 +1,98 @@
+package jsonware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugCapture(t *testing.T) {
+	t.Parallel()
+
+	type input struct {
+		Name string `json:"name"`
+	}
+	logs := &strings.Builder{}
+	debug := &DebugCapture{
+		Authorize: func(r *http.Request) bool { return r.Header.Get("X-Staff") == "yes" },
+	}
+	logged := &DebugCapture{Header: "X-Debug", Authorize: func(r *http.Request) bool { return true }, Log: logs}
+	h := Handler(func(r *http.Request, in *input) (*input, error) { return in, nil })
+
+	srv := httptest.NewServer(debug.Wrap(h))
+	defer srv.Close()
+
+	var tests = []struct {
+		header map[string]string
+		want   bool
+	}{
+		{map[string]string{}, false},
+		{map[string]string{"Jsonware-Debug": "ticket-1"}, false},
+		{map[string]string{"Jsonware-Debug": "ticket-1", "X-Staff": "yes"}, true},
+	}
+
+	for i, test := range tests {
+		req, _ := http.NewRequest("POST", srv.URL+"/users?x=1", strings.NewReader(`{"name":"bob"}`))
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+		for k, v := range test.header {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+
+		trailer := res.Trailer.Get("Jsonware-Debug")
+		if !test.want {
+			if len(trailer) != 0 {
+				t.Errorf("Test %d: expected no capture, got: %s", i, trailer)
+			}
+			continue
+		}
+
+		var c Capture
+		if err := json.Unmarshal([]byte(trailer), &c); err != nil {
+			t.Fatalf("Test %d: expected a capture, got %q: %v", i, trailer, err)
+		}
+		if c.ID != "ticket-1" || c.Method != "POST" || c.URI != "/users?x=1" || c.Status != 200 {
+			t.Errorf("Test %d: expected the request, got: %#v", i, c)
+		}
+		if c.RequestBody != `{"name":"bob"}` || c.ResponseBody != `{"name":"bob"}`+"\n" {
+			t.Errorf("Test %d: expected the bodies, got: %q, %q", i, c.RequestBody, c.ResponseBody)
+		}
+		if c.RequestHeader.Get("Authorization") != redacted {
+			t.Errorf("Test %d: expected the authorization to be redacted, got: %v", i, c.RequestHeader)
+		}
+		if c.Handler <= 0 || c.Total < c.Decode+c.Handler+c.Encode {
+			t.Errorf("Test %d: expected timings, got: %v %v %v %v", i, c.Decode, c.Handler, c.Encode, c.Total)
+		}
+	}
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Debug", "ticket-2")
+	req.Body = io.NopCloser(strings.NewReader(""))
+	body := req.Body
+	logged.Wrap(Handler(func(r *http.Request) *testType { return &testType{Name: "hi"} })).ServeHTTP(res, req)
+
+	if req.Body != body {
+		t.Error("expected the caller's request body to be left alone")
+	}
+	if id := res.Header().Get("Jsonware-Debug-Id"); id != "ticket-2" {
+		t.Errorf("expected the capture's id, got: %q", id)
+	}
+	var c Capture
+	if err := json.Unmarshal([]byte(logs.String()), &c); err != nil {
+		t.Fatalf("expected a logged capture, got %q: %v", logs.String(), err)
+	}
+	if c.ID != "ticket-2" || !strings.Contains(c.ResponseBody, `"hi"`) {
+		t.Errorf("expected the logged capture, got: %#v", c)
+	}
+}
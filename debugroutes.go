@@ -0,0 +1,103 @@
+package jsonware
+
+import "net/http"
+
+// DefaultRoutesPath is the path RoutesHandler is meant to be served at.
+const DefaultRoutesPath = "/_debug/routes"
+
+// RouteInfo describes a registered route and the options of its handler, for
+// debugging which handler is serving what and how.
+type RouteInfo struct {
+	DiscoveryRoute
+
+	// Input and Output are the names of the handler's request and response
+	// types.
+	Input  string `json:"input,omitempty"`
+	Output string `json:"output,omitempty"`
+	Stream bool   `json:"stream,omitempty"`
+
+	Group       string `json:"group,omitempty"`
+	FeatureFlag string `json:"feature_flag,omitempty"`
+
+	MaxResponseSize int    `json:"max_response_size,omitempty"`
+	OversizePolicy  string `json:"oversize_policy,omitempty"`
+	MaxUploadFiles  int    `json:"max_upload_files,omitempty"`
+	MaxUploadSize   int64  `json:"max_upload_size,omitempty"`
+
+	CacheControl string `json:"cache_control,omitempty"`
+	// ETags is set when the handler's Version or ETags answers conditional
+	// requests.
+	ETags bool `json:"etags,omitempty"`
+
+	CircuitBreaker bool   `json:"circuit_breaker,omitempty"`
+	Timeout        string `json:"timeout,omitempty"`
+	Watchdog       string `json:"watchdog,omitempty"`
+}
+
+// Describe describes every route in the registry, in the order they were
+// registered.
+func (reg *Registry) Describe() []RouteInfo {
+	infos := make([]RouteInfo, 0, len(reg.routes))
+	for _, route := range reg.routes {
+		infos = append(infos, route.Handler.routeInfo(route.Method, route.Pattern))
+	}
+	return infos
+}
+
+/*
+RoutesHandler returns a handler that responds with the description of every
+route in the registry as json, for finding out what a running service
+serves. Requests are only answered when authorize allows them, everyone else
+gets a 403, and so does everyone when authorize is nil. It's meant to be
+served at DefaultRoutesPath, outside of the registry so that it isn't one of
+the routes it describes.
+
+	mux.Handle(jsonware.DefaultRoutesPath, reg.RoutesHandler(isStaff))
+*/
+func (reg *Registry) RoutesHandler(authorize func(r *http.Request) bool) http.Handler {
+	return Handler(func(r *http.Request) ([]RouteInfo, error) {
+		if authorize == nil || !authorize(r) {
+			return nil, Err{Status: http.StatusForbidden, Err: errForbidden}
+		}
+		return reg.Describe(), nil
+	})
+}
+
+// routeInfo describes the handler when it's registered for method and
+// pattern.
+func (j JSONHandler) routeInfo(method, pattern string) RouteInfo {
+	info := RouteInfo{
+		DiscoveryRoute: j.discoveryRoute(method, pattern),
+		Stream:         j.stream != streamNone,
+		Group:          j.group,
+		FeatureFlag:    j.flag,
+		CacheControl:   j.cacheControl,
+		ETags:          j.version != nil || j.etags != nil,
+		CircuitBreaker: j.breaker != nil,
+	}
+	if j.in != nil {
+		info.Input = j.in.String()
+	}
+	if j.out != nil {
+		info.Output = j.out.String()
+	}
+
+	if j.responseLimit != nil {
+		info.MaxResponseSize = j.responseLimit.size
+		info.OversizePolicy = [...]string{SizeFail: "fail", SizeStream: "stream", SizeTruncate: "truncate"}[j.responseLimit.policy]
+	}
+	if j.uploads {
+		limits := DefaultUploadLimits
+		if j.uploadLimits != nil {
+			limits = *j.uploadLimits
+		}
+		info.MaxUploadFiles, info.MaxUploadSize = limits.MaxFiles, limits.MaxFileSize
+	}
+	if j.breaker != nil && j.breaker.policy.Timeout > 0 {
+		info.Timeout = j.breaker.policy.Timeout.String()
+	}
+	if j.watchdog > 0 {
+		info.Watchdog = j.watchdog.String()
+	}
+	return info
+}
@@ -0,0 +1,55 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoutesHandler(t *testing.T) {
+	t.Parallel()
+
+	get := func(r *http.Request) (*testType, error) { return &testType{}, nil }
+	post := func(r *http.Request, in *testType) (*testType, error) { return in, nil }
+
+	reg := &Registry{}
+	reg.Handle("GET", "/users/{id}", get).CacheControl("no-cache").Watchdog(time.Second, 2).
+		CircuitBreaker(BreakerPolicy{Timeout: 3 * time.Second})
+	reg.Handle("POST", "/users", post).MaxResponseSize(1024, SizeTruncate).Group("users")
+
+	staff := func(r *http.Request) bool { return r.Header.Get("X-Staff") == "yes" }
+
+	var tests = []struct {
+		handler http.Handler
+		staff   bool
+		status  int
+		want    string
+	}{
+		{reg.RoutesHandler(staff), true, 200, `[` +
+			`{"method":"GET","path":"/users/{id}","produces":["application/json"],"output":"*jsonware.testType",` +
+			`"cache_control":"no-cache","circuit_breaker":true,"timeout":"3s","watchdog":"2s"},` +
+			`{"method":"POST","path":"/users","consumes":["application/json"],"produces":["application/json"],` +
+			`"input":"*jsonware.testType","output":"*jsonware.testType","group":"users","max_response_size":1024,"oversize_policy":"truncate"}]`},
+		{reg.RoutesHandler(staff), false, 403, `{"error":"forbidden"}`},
+		{reg.RoutesHandler(nil), true, 403, `{"error":"forbidden"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", DefaultRoutesPath, nil)
+		req.Header.Set("Accept", "application/json")
+		if test.staff {
+			req.Header.Set("X-Staff", "yes")
+		}
+		test.handler.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected:\n%s\ngot:\n%s", i, test.want, b)
+		}
+	}
+}
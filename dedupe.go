@@ -0,0 +1,64 @@
+package jsonware
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+/*
+DedupeLog wraps logger so that identical messages written within window of
+each other are only logged once. When the window closes on a message that was
+repeated it's logged again with a count of how many times it was suppressed.
+This stops a failing dependency from flooding the log with thousands of the
+same cloaked error every second.
+
+	jsonware.Log(jsonware.DedupeLog(os.Stderr, time.Minute))
+*/
+func DedupeLog(logger io.Writer, window time.Duration) io.Writer {
+	return &dedupeWriter{
+		logger: logger,
+		window: window,
+		seen:   make(map[string]*int),
+		after:  func(d time.Duration, fn func()) { time.AfterFunc(d, fn) },
+	}
+}
+
+type dedupeWriter struct {
+	logger io.Writer
+	window time.Duration
+
+	mut  sync.Mutex
+	seen map[string]*int
+	// after is swapped out in tests.
+	after func(time.Duration, func())
+}
+
+func (d *dedupeWriter) Write(b []byte) (int, error) {
+	msg := string(b)
+
+	d.mut.Lock()
+	if suppressed, ok := d.seen[msg]; ok {
+		*suppressed++
+		d.mut.Unlock()
+		return len(b), nil
+	}
+
+	suppressed := new(int)
+	d.seen[msg] = suppressed
+	d.mut.Unlock()
+
+	d.after(d.window, func() {
+		d.mut.Lock()
+		delete(d.seen, msg)
+		n := *suppressed
+		d.mut.Unlock()
+
+		if n > 0 {
+			fmt.Fprintf(d.logger, "%s (repeated %d times in %s)", msg, n, d.window)
+		}
+	})
+
+	return d.logger.Write(b)
+}
@@ -0,0 +1,42 @@
+package jsonware
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDedupeLog(t *testing.T) {
+	t.Parallel()
+
+	log := &bytes.Buffer{}
+	d := DedupeLog(log, time.Minute).(*dedupeWriter)
+
+	var closers []func()
+	d.after = func(_ time.Duration, fn func()) { closers = append(closers, fn) }
+
+	for i := 0; i < 5; i++ {
+		fmt.Fprint(d, "internal error: db down|")
+	}
+	fmt.Fprint(d, "internal error: cache down|")
+
+	if l := log.String(); l != "internal error: db down|internal error: cache down|" {
+		t.Error("Log was wrong:", l)
+	}
+
+	log.Reset()
+	for _, fn := range closers {
+		fn()
+	}
+	if l := log.String(); l != "internal error: db down| (repeated 4 times in 1m0s)" {
+		t.Error("Log was wrong:", l)
+	}
+
+	// Once the window has closed the message gets logged again.
+	log.Reset()
+	fmt.Fprint(d, "internal error: db down|")
+	if l := log.String(); l != "internal error: db down|" {
+		t.Error("Log was wrong:", l)
+	}
+}
@@ -0,0 +1,159 @@
+package jsonware
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// digestAlgorithms are the hash algorithms digests can be verified with,
+// by their RFC 9530 names.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+	"md5":     md5.New,
+}
+
+var errDigestMismatch = errors.New("request body does not match its digest")
+
+/*
+Digest makes the JSONHandler send a Content-Digest header (RFC 9530) with its
+responses, computed with alg which is either "sha-256" or "sha-512". Only
+successful non-streaming responses carry a digest. A Compress in front of the
+handler drops the header when it compresses the response since it would no
+longer match.
+
+Request bodies are always verified against the digests they carry, whether
+or not Digest is used. The Content-Digest header of RFC 9530, the Digest
+header of RFC 3230 and the Content-MD5 header are checked against the body as
+it was sent, and the Repr-Digest header of RFC 9530 against the body once its
+Content-Encoding has been undone. A body that doesn't match is rejected with
+a 400. Digests with algorithms other than sha-256, sha-512 and md5 are
+ignored.
+*/
+func (j *JSONHandler) Digest(alg string) *JSONHandler {
+	if alg != "sha-256" && alg != "sha-512" {
+		panic("Digest algorithm must be sha-256 or sha-512")
+	}
+	j.digest = alg
+	return j
+}
+
+// digestValue computes the RFC 9530 header value for body.
+func digestValue(alg string, body []byte) string {
+	h := digestAlgorithms[alg]()
+	h.Write(body)
+	return alg + "=:" + base64.StdEncoding.EncodeToString(h.Sum(nil)) + ":"
+}
+
+// contentDigests collects the digests a request carries for its body as it
+// was sent, keyed by algorithm.
+func contentDigests(header http.Header) map[string][]string {
+	digests := make(map[string][]string)
+	addDictionary(digests, header.Get("Content-Digest"))
+
+	// RFC 3230: SHA-256=base64, which also covers MD5.
+	for _, member := range strings.Split(header.Get("Digest"), ",") {
+		if alg, value, ok := strings.Cut(member, "="); ok {
+			addDigest(digests, alg, value)
+		}
+	}
+
+	if md := header.Get("Content-MD5"); len(md) != 0 {
+		addDigest(digests, "md5", md)
+	}
+
+	return digests
+}
+
+// reprDigests collects the digests a request carries for its body once its
+// content coding has been undone, keyed by algorithm.
+func reprDigests(header http.Header) map[string][]string {
+	digests := make(map[string][]string)
+	addDictionary(digests, header.Get("Repr-Digest"))
+	return digests
+}
+
+// addDictionary adds the digests of an RFC 9530 dictionary like
+// sha-256=:base64:, sha-512=:base64: to digests.
+func addDictionary(digests map[string][]string, dictionary string) {
+	for _, member := range strings.Split(dictionary, ",") {
+		alg, value, ok := strings.Cut(member, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+			continue
+		}
+		addDigest(digests, alg, value[1:len(value)-1])
+	}
+}
+
+// addDigest adds a digest to digests if its algorithm is one that's known.
+func addDigest(digests map[string][]string, alg, value string) {
+	alg = strings.ToLower(strings.TrimSpace(alg))
+	if _, ok := digestAlgorithms[alg]; ok {
+		digests[alg] = append(digests[alg], strings.TrimSpace(value))
+	}
+}
+
+// verifyDigest checks the request body as it was sent against the digests
+// in its headers, returning a request whose body can be read again.
+func verifyDigest(r *http.Request) (*http.Request, error) {
+	if len(r.Header["Content-Digest"]) == 0 && len(r.Header["Digest"]) == 0 && len(r.Header["Content-Md5"]) == 0 {
+		return r, nil
+	}
+	return checkDigests(r, contentDigests(r.Header))
+}
+
+// verifyReprDigest checks the request body against the digests in its
+// Repr-Digest header, it's called once the body's content coding has been
+// undone since that's what the digests are of.
+func verifyReprDigest(r *http.Request) (*http.Request, error) {
+	if len(r.Header["Repr-Digest"]) == 0 {
+		return r, nil
+	}
+	return checkDigests(r, reprDigests(r.Header))
+}
+
+// checkDigests checks the request body against digests, returning a copy of
+// the request whose body can be read again.
+func checkDigests(r *http.Request, digests map[string][]string) (*http.Request, error) {
+	if len(digests) == 0 || r.Body == nil || r.Body == http.NoBody {
+		return r, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if isBodyTooLarge(err) {
+		return nil, Err{Status: http.StatusRequestEntityTooLarge, Err: err}
+	} else if err != nil {
+		return nil, Err{Status: http.StatusBadRequest, Err: errors.New("could not read request body")}
+	}
+	r2 := *r
+	r2.Body = io.NopCloser(bytes.NewReader(body))
+
+	for alg, values := range digests {
+		h := digestAlgorithms[alg]()
+		h.Write(body)
+		sum := h.Sum(nil)
+
+		for _, value := range values {
+			want, err := base64.StdEncoding.DecodeString(value)
+			if err != nil || subtle.ConstantTimeCompare(want, sum) != 1 {
+				return nil, Err{Status: http.StatusBadRequest, Err: errDigestMismatch}
+			}
+		}
+	}
+
+	return &r2, nil
+}
@@ -0,0 +1,157 @@
+package jsonware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVerifyDigest(t *testing.T) {
+	t.Parallel()
+
+	body := `{"name":"bob"}`
+	sha256 := "kac+cT88yuCj8pD5djFv/cW3GC+5brrFdMksQkRztcY="
+
+	var tests = []struct {
+		header string
+		value  string
+		status int
+	}{
+		{"", "", http.StatusOK},
+		{"Content-Digest", "sha-256=:" + sha256 + ":", http.StatusOK},
+		{"Repr-Digest", "sha-256=:" + sha256 + ":", http.StatusOK},
+		{"Digest", "SHA-256=" + sha256, http.StatusOK},
+		{"Content-Digest", "sha-256=:AAAA:", http.StatusBadRequest},
+		{"Content-Digest", "sha-256=:!!!:", http.StatusBadRequest},
+		{"Digest", "SHA-256=" + sha256 + ",MD5=AAAA", http.StatusBadRequest},
+		{"Content-MD5", "AAAA", http.StatusBadRequest},
+		{"Content-Digest", "unknown=:AAAA:", http.StatusOK},
+	}
+
+	h := Handler(func(r *http.Request, in *testType) *testType { return in })
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Accept", "application/json")
+		if len(test.header) != 0 {
+			req.Header.Set(test.header, test.value)
+		}
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if test.status == http.StatusBadRequest && res.Body.String() != `{"error":"request body does not match its digest"}`+"\n" {
+			t.Errorf("Test %d: body was wrong: %s", i, res.Body.String())
+		}
+	}
+}
+
+func TestVerifyDigestCompressed(t *testing.T) {
+	t.Parallel()
+
+	// Digests are of the body as it was sent.
+	body := gzipped(`{"name":"bob"}`)
+	h := Handler(func(r *http.Request, in *testType) *testType { return in })
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", bytes.NewReader(body))
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Content-Digest", digestValue("sha-256", body))
+	h.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Error("Expected status 200, got:", res.Code)
+	}
+}
+
+func TestVerifyReprDigestCompressed(t *testing.T) {
+	t.Parallel()
+
+	// Repr-Digest is of the body once it's been decompressed.
+	plain := `{"name":"bob"}`
+	body := gzipped(plain)
+	h := Handler(func(r *http.Request, in *testType) *testType { return in })
+
+	var tests = []struct {
+		repr   string
+		status int
+	}{
+		{digestValue("sha-256", []byte(plain)), http.StatusOK},
+		{digestValue("sha-256", body), http.StatusBadRequest},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", bytes.NewReader(body))
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("Content-Digest", digestValue("sha-256", body))
+		req.Header.Set("Repr-Digest", test.repr)
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+	}
+}
+
+func TestVerifyDigestTooLarge(t *testing.T) {
+	t.Parallel()
+
+	body := `{"name":"bob"}`
+	req, _ := http.NewRequest("POST", "/", nil)
+	req.Body = &limitedBody{ReadCloser: io.NopCloser(strings.NewReader(body)), left: 4}
+	req.Header.Set("Content-Digest", digestValue("sha-256", []byte(body)))
+
+	if _, err := verifyDigest(req); StatusOf(err) != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected a 413, got: %v", err)
+	}
+}
+
+func TestVerifyDigestCopies(t *testing.T) {
+	t.Parallel()
+
+	body := `{"name":"bob"}`
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("Content-Digest", digestValue("sha-256", []byte(body)))
+	orig := req.Body
+
+	r, err := verifyDigest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Body != orig {
+		t.Error("expected the caller's request body to be left alone")
+	}
+	if b, _ := io.ReadAll(r.Body); string(b) != body {
+		t.Errorf("expected the body to be readable again, got: %s", b)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(func(r *http.Request) *testType { return &testType{"bob"} }).Digest("sha-256")
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(res, req)
+
+	want := "sha-256=:" + "1OE3iS9sOeA8qUj539DEGlBR5vk5hemIWUKcw7jJVGo=" + ":"
+	if d := res.Header().Get("Content-Digest"); d != digestValue("sha-256", res.Body.Bytes()) || d != want {
+		t.Errorf("Digest was wrong: %s", d)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for an unknown algorithm")
+		}
+	}()
+	h.Digest("crc32")
+}
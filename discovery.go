@@ -0,0 +1,119 @@
+package jsonware
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+)
+
+// DefaultDiscoveryPath is where ServeDiscovery serves the discovery document
+// when it's given no path.
+const DefaultDiscoveryPath = "/.well-known/api"
+
+// Discovery is a document describing the routes of a service, for clients
+// and gateways to find out what it serves.
+type Discovery struct {
+	Service string `json:"service,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Versions are the versions of the api the routes are under, from the
+	// first segment of their paths like /v2/users.
+	Versions []string         `json:"versions,omitempty"`
+	Routes   []DiscoveryRoute `json:"routes"`
+}
+
+// DiscoveryRoute describes a route of a Discovery document.
+type DiscoveryRoute struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Consumes and Produces are the media types of request and response
+	// bodies.
+	Consumes []string `json:"consumes,omitempty"`
+	Produces []string `json:"produces"`
+	// Auth are the ways requests are authenticated and authorized: basic,
+	// client_certificate, scopes, roles and policy.
+	Auth   []string `json:"auth,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+	Roles  []string `json:"roles,omitempty"`
+}
+
+var versionSegment = regexp.MustCompile(`^/(v[0-9]+(?:\.[0-9]+)?)/`)
+
+// Discovery describes the routes in the registry, with service and version
+// being the name and version of the service they're in.
+func (reg *Registry) Discovery(service, version string) Discovery {
+	d := Discovery{Service: service, Version: version, Routes: make([]DiscoveryRoute, 0, len(reg.routes))}
+
+	versions := make(map[string]bool)
+	for _, route := range reg.routes {
+		d.Routes = append(d.Routes, route.Handler.discoveryRoute(route.Method, route.Pattern))
+		if m := versionSegment.FindStringSubmatch(route.Pattern); m != nil && !versions[m[1]] {
+			versions[m[1]] = true
+			d.Versions = append(d.Versions, m[1])
+		}
+	}
+	sort.Strings(d.Versions)
+	return d
+}
+
+/*
+ServeDiscovery registers a route for GET path that responds with the
+Discovery document of the registry, DefaultDiscoveryPath when path is empty.
+The document is built when it's asked for, so it includes routes registered
+after ServeDiscovery, along with the discovery route itself.
+
+	reg.ServeDiscovery("", "orders", "1.4.2")
+	reg.Mount(mux)
+*/
+func (reg *Registry) ServeDiscovery(path, service, version string) *JSONHandler {
+	if len(path) == 0 {
+		path = DefaultDiscoveryPath
+	}
+	return reg.Handle(http.MethodGet, path, func(r *http.Request) (*Discovery, error) {
+		d := reg.Discovery(service, version)
+		return &d, nil
+	})
+}
+
+// discoveryRoute describes the handler when it's registered for method and
+// pattern.
+func (j JSONHandler) discoveryRoute(method, pattern string) DiscoveryRoute {
+	route := DiscoveryRoute{
+		Method: method,
+		Path:   pattern,
+		Scopes: j.scopes,
+		Roles:  j.roles,
+	}
+
+	switch {
+	case j.uploads:
+		route.Consumes = []string{"multipart/form-data"}
+	case j.in != nil && isDataMethod(method):
+		route.Consumes = []string{"application/json"}
+	}
+
+	switch {
+	case j.stream != streamNone:
+		route.Produces = []string{"application/json", "application/x-ndjson", "text/event-stream"}
+	case j.jws != nil && !j.jws.Detached:
+		route.Produces = []string{joseContentType}
+	default:
+		route.Produces = []string{"application/json"}
+	}
+
+	if len(j.basicRealm) != 0 {
+		route.Auth = append(route.Auth, "basic")
+	}
+	if len(j.allowClients) != 0 {
+		route.Auth = append(route.Auth, "client_certificate")
+	}
+	if len(j.scopes) != 0 {
+		route.Auth = append(route.Auth, "scopes")
+	}
+	if len(j.roles) != 0 {
+		route.Auth = append(route.Auth, "roles")
+	}
+	if j.policy != nil || j.inputPolicy != nil {
+		route.Auth = append(route.Auth, "policy")
+	}
+	return route
+}
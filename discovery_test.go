@@ -0,0 +1,49 @@
+package jsonware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscovery(t *testing.T) {
+	t.Parallel()
+
+	get := func(r *http.Request) (*testType, error) { return &testType{}, nil }
+	post := func(r *http.Request, in *testType) (*testType, error) { return in, nil }
+
+	reg := &Registry{}
+	reg.Handle("GET", "/v1/users/{id}", get).RequireScopes("users:read")
+	reg.Handle("POST", "/v2/users", post).BasicAuth("api", func(user, pass string) bool { return true }).RequireRole("admin")
+	reg.ServeDiscovery("", "users", "1.2.0")
+
+	mux := http.NewServeMux()
+	reg.Mount(mux)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", DefaultDiscoveryPath, nil)
+	req.Header.Set("Accept", "application/json")
+	mux.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got: %d", res.Code)
+	}
+
+	want := `{"service":"users","version":"1.2.0","versions":["v1","v2"],"routes":[` +
+		`{"method":"GET","path":"/v1/users/{id}","produces":["application/json"],"auth":["scopes"],"scopes":["users:read"]},` +
+		`{"method":"POST","path":"/v2/users","consumes":["application/json"],"produces":["application/json"],"auth":["basic","roles"],"roles":["admin"]},` +
+		`{"method":"GET","path":"/.well-known/api","produces":["application/json"]}]}`
+	var got, expected interface{}
+	if err := json.Unmarshal(res.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &expected); err != nil {
+		t.Fatal(err)
+	}
+	gotB, _ := json.Marshal(got)
+	wantB, _ := json.Marshal(expected)
+	if string(gotB) != string(wantB) {
+		t.Errorf("expected:\n%s\ngot:\n%s", wantB, gotB)
+	}
+}
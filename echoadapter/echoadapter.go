@@ -0,0 +1,26 @@
+/*
+Package echoadapter lets jsonware handlers be served by echo.
+
+The path parameters matched by echo are bound to the path tagged fields of the
+handlers' request objects, and errors are written by jsonware as usual rather
+than going through echo's error handler.
+
+	e := echo.New()
+	e.GET("/users/:id", echoadapter.Handler(jsonware.Handler(getUser)))
+*/
+package echoadapter
+
+import (
+	"net/http"
+
+	"github.com/aarondl/jsonware"
+	"github.com/labstack/echo/v4"
+)
+
+// Handler converts h, typically a JSONHandler, into an echo.HandlerFunc.
+func Handler(h http.Handler) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		h.ServeHTTP(c.Response(), jsonware.WithPathParams(c.Request(), c.Param))
+		return nil
+	}
+}
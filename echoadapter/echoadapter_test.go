@@ -0,0 +1,61 @@
+package echoadapter
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aarondl/jsonware"
+	"github.com/labstack/echo/v4"
+)
+
+type user struct {
+	ID   int    `path:"id" json:"id"`
+	Name string `json:"name"`
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	e := echo.New()
+	e.GET("/users/:id", Handler(jsonware.Handler(func(r *http.Request, in *user) *user { return in })))
+	e.PUT("/users/:id", Handler(jsonware.Handler(func(r *http.Request, in *user) (*user, error) {
+		if in.Name == "" {
+			return nil, jsonware.Err{Status: http.StatusUnprocessableEntity, Err: errors.New("name is required")}
+		}
+		return in, nil
+	})))
+
+	var tests = []struct {
+		method  string
+		path    string
+		reqbody string
+		status  int
+		resbody string
+	}{
+		{"GET", "/users/5", "", 200, `{"id":5,"name":""}`},
+		{"GET", "/users/x", "", 400, `{"error":"invalid path parameter id"}`},
+		{"PUT", "/users/5", `{"name":"bob"}`, 200, `{"id":5,"name":"bob"}`},
+		{"PUT", "/users/5", `{}`, 422, `{"error":"name is required"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, bytes.NewBufferString(test.reqbody))
+		req.Header.Set("Accept", "application/json")
+		e.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, res.Code)
+		}
+
+		if b := strings.TrimSpace(res.Body.String()); b != test.resbody {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+	}
+}
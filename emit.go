@@ -0,0 +1,130 @@
+package jsonware
+
+import (
+	"context"
+	"net/http"
+)
+
+// Publisher sends events to a message queue. key is what the queue should
+// partition the event by, it may be empty.
+type Publisher interface {
+	Publish(ctx context.Context, topic, key string, event []byte) error
+}
+
+// PublisherFunc is a function that's a Publisher.
+type PublisherFunc func(ctx context.Context, topic, key string, event []byte) error
+
+// Publish calls fn.
+func (fn PublisherFunc) Publish(ctx context.Context, topic, key string, event []byte) error {
+	return fn(ctx, topic, key, event)
+}
+
+// Message is an event sent by a ChannelPublisher.
+type Message struct {
+	Topic string
+	Key   string
+	Event []byte
+}
+
+// ChannelPublisher sends events to ch, waiting for room in it until the
+// request's context is done.
+func ChannelPublisher(ch chan<- Message) Publisher {
+	return PublisherFunc(func(ctx context.Context, topic, key string, event []byte) error {
+		select {
+		case ch <- Message{Topic: topic, Key: key, Event: event}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// NATSConn is the part of a nats.Conn that NATSPublisher uses.
+type NATSConn interface {
+	Publish(subject string, data []byte) error
+}
+
+// NATSPublisher publishes events to the subject named by their topic, the
+// key isn't used.
+func NATSPublisher(conn NATSConn) Publisher {
+	return PublisherFunc(func(ctx context.Context, topic, key string, event []byte) error {
+		return conn.Publish(topic, event)
+	})
+}
+
+// KafkaWriter writes a message to a kafka topic, it's implemented with a few
+// lines around the producer of whichever kafka client is in use.
+type KafkaWriter interface {
+	WriteMessage(ctx context.Context, topic string, key, value []byte) error
+}
+
+// KafkaPublisher writes events to the kafka topic named by their topic,
+// keyed by their key.
+func KafkaPublisher(w KafkaWriter) Publisher {
+	return PublisherFunc(func(ctx context.Context, topic, key string, event []byte) error {
+		var k []byte
+		if len(key) != 0 {
+			k = []byte(key)
+		}
+		return w.WriteMessage(ctx, topic, k, event)
+	})
+}
+
+/*
+Emitter publishes the response of a handler as an event once the handler has
+succeeded. The event is the response as it was encoded for the client, unless
+Event makes a different value of it, so handlers don't serialize their
+changes a second time for the queue.
+
+	emitter := &jsonware.Emitter{
+		Topic:     "orders",
+		Publisher: jsonware.NATSPublisher(nc),
+		Key: func(r *http.Request, v interface{}) string {
+			return v.(*Order).ID
+		},
+	}
+	http.Handle("/orders", jsonware.Handler(createOrder).Emit(emitter))
+*/
+type Emitter struct {
+	Topic     string
+	Publisher Publisher
+	// Key finds the key of an event from the response, events have no key
+	// when it's nil.
+	Key func(r *http.Request, v interface{}) string
+	// Event makes the value that's published from the response, the
+	// response is published when it's nil.
+	Event func(r *http.Request, v interface{}) interface{}
+}
+
+/*
+Emit publishes the responses of successful POST, PUT, PATCH and DELETE
+requests with e, after they've been sent. Requests that fail, or that have no
+response, aren't published. Events that can't be published are logged as
+cloaked errors, the client has already been told the request succeeded.
+*/
+func (j *JSONHandler) Emit(e *Emitter) *JSONHandler {
+	j.emitter = e
+	return j
+}
+
+// emit publishes the response v of a request, encoded is what was sent to
+// the client.
+func (j JSONHandler) emit(r *http.Request, v interface{}, encoded []byte) {
+	e := j.emitter
+	event := encoded
+	if e.Event != nil {
+		var err error
+		if event, err = j.jsonCodec().Marshal(e.Event(r, v)); err != nil {
+			logf(j.logger, "failed to encode %s event: %v", e.Topic, err)
+			return
+		}
+	}
+
+	var key string
+	if e.Key != nil {
+		key = e.Key(r, v)
+	}
+	if err := e.Publisher.Publish(r.Context(), e.Topic, key, event); err != nil {
+		logf(j.logger, "failed to publish %s event: %v", e.Topic, err)
+	}
+}
@@ -0,0 +1,150 @@
+package jsonware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type emitted struct {
+	subject string
+	data    []byte
+}
+
+type fakeNATS struct{ published []emitted }
+
+func (f *fakeNATS) Publish(subject string, data []byte) error {
+	f.published = append(f.published, emitted{subject, data})
+	return nil
+}
+
+type fakeKafka struct {
+	topic      string
+	key, value []byte
+}
+
+func (f *fakeKafka) WriteMessage(ctx context.Context, topic string, key, value []byte) error {
+	f.topic, f.key, f.value = topic, key, value
+	return nil
+}
+
+func TestEmit(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		method string
+		status int
+		event  func(r *http.Request, v interface{}) interface{}
+		want   string
+	}{
+		{"POST", 0, nil, `{"name":"a"}`},
+		{"DELETE", 0, nil, `{"name":"a"}`},
+		{"PUT", 0, func(r *http.Request, v interface{}) interface{} {
+			return map[string]interface{}{"type": "updated", "user": v}
+		}, `{"type":"updated","user":{"name":"a"}}`},
+		{"GET", 0, nil, ``},
+		{"POST", 400, nil, ``},
+	}
+
+	for i, test := range tests {
+		ch := make(chan Message, 1)
+		emitter := &Emitter{
+			Topic:     "users",
+			Publisher: ChannelPublisher(ch),
+			Key:       func(r *http.Request, v interface{}) string { return v.(*testType).Name },
+			Event:     test.event,
+		}
+		status := test.status
+		respond := func() (*testType, error) {
+			if status != 0 {
+				return nil, Err{Status: status, Err: errors.New("failed")}
+			}
+			return &testType{Name: "a"}, nil
+		}
+
+		var h *JSONHandler
+		req, _ := http.NewRequest(test.method, "/", nil)
+		if test.method == "POST" || test.method == "PUT" {
+			h = Handler(func(r *http.Request, in *testType) (*testType, error) { return respond() })
+			req, _ = http.NewRequest(test.method, "/", strings.NewReader(`{}`))
+		} else {
+			h = Handler(func(r *http.Request) (*testType, error) { return respond() })
+		}
+		h.Emit(emitter)
+
+		res := httptest.NewRecorder()
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		select {
+		case msg := <-ch:
+			if len(test.want) == 0 {
+				t.Errorf("Test %d: expected no event, got: %s", i, msg.Event)
+			} else if msg.Topic != "users" || msg.Key != "a" || string(msg.Event) != test.want {
+				t.Errorf("Test %d: expected %s to users keyed a, got: %s to %s keyed %s", i, test.want, msg.Event, msg.Topic, msg.Key)
+			}
+		default:
+			if len(test.want) != 0 {
+				t.Errorf("Test %d: expected an event", i)
+			}
+		}
+	}
+}
+
+func TestEmitPublishFails(t *testing.T) {
+	t.Parallel()
+
+	logger := &bytes.Buffer{}
+	emitter := &Emitter{
+		Topic: "users",
+		Publisher: PublisherFunc(func(ctx context.Context, topic, key string, event []byte) error {
+			return errors.New("queue is down")
+		}),
+	}
+	h := Handler(func(r *http.Request, in *testType) *testType { return in }).Emit(emitter).Log(logger)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"name":"a"}`))
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Errorf("expected the request to succeed, got: %d", res.Code)
+	}
+	if want := "failed to publish users event: queue is down"; !strings.Contains(logger.String(), want) {
+		t.Errorf("expected log %q, got: %q", want, logger.String())
+	}
+}
+
+func TestPublishers(t *testing.T) {
+	t.Parallel()
+
+	nats := &fakeNATS{}
+	if err := NATSPublisher(nats).Publish(context.Background(), "users.created", "k", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(nats.published) != 1 || nats.published[0].subject != "users.created" || string(nats.published[0].data) != `{}` {
+		t.Errorf("nats publish was wrong: %#v", nats.published)
+	}
+
+	kafka := &fakeKafka{}
+	if err := KafkaPublisher(kafka).Publish(context.Background(), "users", "k", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if kafka.topic != "users" || string(kafka.key) != "k" || string(kafka.value) != `{}` {
+		t.Errorf("kafka write was wrong: %#v", kafka)
+	}
+	if err := KafkaPublisher(kafka).Publish(context.Background(), "users", "", []byte(`{}`)); err != nil || kafka.key != nil {
+		t.Errorf("expected no key, got: %q %v", kafka.key, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ChannelPublisher(make(chan Message)).Publish(ctx, "users", "", nil); err != context.Canceled {
+		t.Errorf("expected canceled, got: %v", err)
+	}
+}
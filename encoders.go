@@ -0,0 +1,62 @@
+package jsonware
+
+import "sync/atomic"
+
+// MarshalFunc serializes a value to json, like json.Marshal or the Marshal
+// of another json library.
+type MarshalFunc func(v interface{}) ([]byte, error)
+
+/*
+Encoders makes the JSONHandler serialize its responses with small when
+they're expected to be smaller than threshold bytes and with large when
+they're not, for json libraries that are faster than encoding/json only once
+there's enough to encode to make up for their setup. A response's size is
+expected to be around that of the handler's recent responses, the first is
+expected to be small.
+
+	h.Encoders(json.Marshal, sonic.Marshal, 16<<10)
+
+The encoders take the place of the handler's Codec for the responses they
+serialize, the Codec is still used for responses that are streamed or
+limited with MaxResponseSize.
+*/
+func (j *JSONHandler) Encoders(small, large MarshalFunc, threshold int) *JSONHandler {
+	j.encoders = &adaptiveEncoders{small: small, large: large, threshold: int64(threshold), avg: -1}
+	return j
+}
+
+// adaptiveEncoders picks the encoder for a response by the average size of
+// the responses before it.
+type adaptiveEncoders struct {
+	small, large MarshalFunc
+	threshold    int64
+	// avg is a moving average of the sizes of responses, -1 until there's
+	// been one.
+	avg int64
+}
+
+func (a *adaptiveEncoders) marshal(v interface{}) ([]byte, error) {
+	marshal := a.small
+	if atomic.LoadInt64(&a.avg) >= a.threshold {
+		marshal = a.large
+	}
+	b, err := marshal(v)
+	if err == nil {
+		a.observe(int64(len(b)))
+	}
+	return b, err
+}
+
+// observe adds size to the moving average, each response weighs an eighth.
+func (a *adaptiveEncoders) observe(size int64) {
+	for {
+		old := atomic.LoadInt64(&a.avg)
+		avg := size
+		if old >= 0 {
+			avg = old + (size-old)/8
+		}
+		if atomic.CompareAndSwapInt64(&a.avg, old, avg) {
+			return
+		}
+	}
+}
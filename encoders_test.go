@@ -0,0 +1,57 @@
+package jsonware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEncoders(t *testing.T) {
+	t.Parallel()
+
+	var used []string
+	small := func(v interface{}) ([]byte, error) {
+		used = append(used, "small")
+		return json.Marshal(v)
+	}
+	large := func(v interface{}) ([]byte, error) {
+		used = append(used, "large")
+		return json.Marshal(v)
+	}
+
+	size := 0
+	h := Handler(func(r *http.Request) (*testType, error) {
+		return &testType{Name: strings.Repeat("a", size)}, nil
+	}).Encoders(small, large, 100)
+
+	// The first response is expected to be small, the ones after are
+	// expected to be as large as the average of the ones before.
+	var tests = []struct {
+		size int
+		want string
+	}{
+		{0, "small"},
+		{1000, "small"},
+		{0, "large"},
+		{0, "large"},
+		{0, "large"},
+		{0, "small"},
+	}
+
+	for i, test := range tests {
+		size = test.size
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if got := used[len(used)-1]; got != test.want {
+			t.Errorf("Test %d: expected the %s encoder, got: %s", i, test.want, got)
+		}
+		if want := `{"name":"` + strings.Repeat("a", size) + `"}` + "\n"; res.Body.String() != want {
+			t.Errorf("Test %d: expected %q, got: %q", i, want, res.Body.String())
+		}
+	}
+}
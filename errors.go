@@ -0,0 +1,194 @@
+package jsonware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var errInvalidFields = errors.New("invalid fields")
+
+// newStatusErr is an Err with status and msg, or the status's text when msg is
+// empty.
+func newStatusErr(status int, msg string) Err {
+	if len(msg) == 0 {
+		msg = strings.ToLower(http.StatusText(status))
+	}
+	return Err{Status: status, Err: errors.New(msg)}
+}
+
+// NotFound is a 404 Not Found error with msg, "not found" when it's empty.
+func NotFound(msg string) error {
+	return newStatusErr(http.StatusNotFound, msg)
+}
+
+// Conflict is a 409 Conflict error with msg, "conflict" when it's empty.
+func Conflict(msg string) error {
+	return newStatusErr(http.StatusConflict, msg)
+}
+
+// Unauthorized is a 401 Unauthorized error with msg, "unauthorized" when it's
+// empty. It's for requests that aren't authenticated, Forbidden is for the
+// ones that are but may not do what they ask.
+func Unauthorized(msg string) error {
+	return newStatusErr(http.StatusUnauthorized, msg)
+}
+
+// Forbidden is a 403 Forbidden error with msg, "forbidden" when it's empty.
+func Forbidden(msg string) error {
+	return newStatusErr(http.StatusForbidden, msg)
+}
+
+/*
+UnprocessableEntity is a 422 Unprocessable Entity error for a request object
+with fields that aren't valid, fields maps their json names to what's wrong
+with them and is sent as the error's reason.
+
+	return nil, jsonware.UnprocessableEntity(map[string]string{
+		"email": "must be an email address",
+	})
+
+Responds with:
+
+	{"error":"invalid fields","reason":{"email":"must be an email address"}}
+*/
+func UnprocessableEntity(fields map[string]string) error {
+	e := Err{Status: http.StatusUnprocessableEntity, Err: errInvalidFields}
+	if len(fields) != 0 {
+		e.Reason = fields
+	}
+	return e
+}
+
+/*
+WrapStatus wraps err with the status it should be sent to clients with and
+msg, the message they're sent in place of err's, which is the status's text
+when it's empty. The status survives being wrapped again with %w, so that
+errors from layers that know nothing of http can carry what they mean to the
+handler that returns them. err is still there for errors.Is and errors.As,
+and WrapStatus returns nil when it's nil.
+
+	func (s *Store) Order(id string) (*Order, error) {
+		...
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, jsonware.WrapStatus(err, http.StatusNotFound, "order not found")
+		}
+	}
+
+	// In the handler, the 404 is sent with "order not found".
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order %s: %w", id, err)
+	}
+*/
+func WrapStatus(err error, status int, msg string) error {
+	if err == nil {
+		return nil
+	}
+	if len(msg) == 0 {
+		msg = strings.ToLower(http.StatusText(status))
+	}
+	return Err{Status: status, Err: causedErr{msg: msg, cause: err}}
+}
+
+// causedErr is a message for clients in place of the error that caused it.
+type causedErr struct {
+	msg   string
+	cause error
+}
+
+func (c causedErr) Error() string { return c.msg }
+func (c causedErr) Unwrap() error { return c.cause }
+
+/*
+StatusOf is the status err is sent to clients with when a handler returns
+it, looking through errors wrapped with %w for an Err or a StatusCoder. It's
+500 for errors that have neither and 200 for nil.
+*/
+func StatusOf(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	if m, ok := asMultiErr(err); ok {
+		return m.StatusCode()
+	}
+	if e, ok := asErr(err); ok {
+		if e.Status == 0 {
+			return http.StatusOK
+		}
+		return e.Status
+	}
+	return cloakedStatus(err)
+}
+
+// asErr finds the Err that err is sent to clients as, the Err it wraps or a
+// StatusCoder with a 4xx status it wraps. Errors that are cloaked have none.
+func asErr(err error) (Err, bool) {
+	var e Err
+	if walkErrs(err, func(err error) bool {
+		var ok bool
+		e, ok = err.(Err)
+		return ok
+	}) {
+		return e, true
+	}
+
+	var sc StatusCoder
+	if walkErrs(err, func(err error) bool {
+		sc, _ = err.(StatusCoder)
+		return sc != nil
+	}) && sc.StatusCode() < 500 {
+		if scErr, ok := sc.(error); ok {
+			err = scErr
+		}
+		return Err{Status: sc.StatusCode(), Err: err}, true
+	}
+	return Err{}, false
+}
+
+// asMultiErr finds the MultiErr that err wraps, if it has any errors.
+func asMultiErr(err error) (MultiErr, bool) {
+	var m MultiErr
+	walkErrs(err, func(err error) bool {
+		m, _ = err.(MultiErr)
+		return m != nil
+	})
+	return m, len(m) != 0
+}
+
+// cloakedStatus is the status of a cloaked error, the one of the StatusCoder
+// it wraps or 500.
+func cloakedStatus(err error) int {
+	var sc StatusCoder
+	if walkErrs(err, func(err error) bool {
+		sc, _ = err.(StatusCoder)
+		return sc != nil
+	}) {
+		return sc.StatusCode()
+	}
+	return http.StatusInternalServerError
+}
+
+// walkErrs calls found with err and the errors it wraps in the order
+// errors.As looks at them, until found returns true. It's errors.As without
+// the allocations, which add up on the path of every error response.
+func walkErrs(err error, found func(error) bool) bool {
+	for err != nil {
+		if found(err) {
+			return true
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			err = u.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, err := range u.Unwrap() {
+				if walkErrs(err, found) {
+					return true
+				}
+			}
+			return false
+		default:
+			return false
+		}
+	}
+	return false
+}
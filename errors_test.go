@@ -0,0 +1,103 @@
+package jsonware
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorConstructors(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		err    error
+		status int
+		want   string
+	}{
+		{NotFound("order not found"), 404, `{"error":"order not found"}`},
+		{NotFound(""), 404, `{"error":"not found"}`},
+		{Conflict(""), 409, `{"error":"conflict"}`},
+		{Unauthorized(""), 401, `{"error":"unauthorized"}`},
+		{Forbidden("not your order"), 403, `{"error":"not your order"}`},
+		{UnprocessableEntity(nil), 422, `{"error":"invalid fields"}`},
+		{UnprocessableEntity(map[string]string{"email": "must be an email address"}), 422, `{"error":"invalid fields","reason":{"email":"must be an email address"}}`},
+	}
+
+	for i, test := range tests {
+		err := test.err
+		h := Handler(func(r *http.Request) (*testType, error) {
+			return nil, err
+		})
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
+
+func TestWrapStatus(t *testing.T) {
+	t.Parallel()
+
+	wrapped := fmt.Errorf("failed to get order: %w", fmt.Errorf("store: %w",
+		WrapStatus(sql.ErrNoRows, http.StatusNotFound, "order not found")))
+
+	var tests = []struct {
+		err    error
+		status int
+		want   string
+	}{
+		{WrapStatus(sql.ErrNoRows, http.StatusNotFound, "order not found"), 404, `{"error":"order not found"}`},
+		{wrapped, 404, `{"error":"order not found"}`},
+		{fmt.Errorf("outer: %w", WrapStatus(sql.ErrNoRows, http.StatusConflict, "")), 409, `{"error":"conflict"}`},
+		{fmt.Errorf("outer: %w", statusErr(http.StatusTeapot)), 418, `{"error":"status 418"}`},
+		{fmt.Errorf("outer: %w", statusErr(http.StatusServiceUnavailable)), 503, `{"error":"an internal server error occurred"}`},
+		{errors.Join(errors.New("a"), Conflict("taken")), 409, `{"error":"taken"}`},
+		{fmt.Errorf("outer: %w", MultiErr{{Status: 400, Err: errors.New("bad")}}), 400, `{"errors":[{"status":400,"error":"bad"}]}`},
+		{errors.New("plain"), 500, `{"error":"an internal server error occurred"}`},
+	}
+
+	for i, test := range tests {
+		if got := StatusOf(test.err); got != test.status {
+			t.Errorf("Test %d: expected StatusOf to be %d, got: %d", i, test.status, got)
+		}
+
+		err := test.err
+		h := Handler(func(r *http.Request) (*testType, error) {
+			return nil, err
+		})
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+
+	if !errors.Is(wrapped, sql.ErrNoRows) {
+		t.Error("expected the cause to be wrapped")
+	}
+	if WrapStatus(nil, http.StatusNotFound, "") != nil {
+		t.Error("expected WrapStatus of nil to be nil")
+	}
+	if StatusOf(nil) != http.StatusOK {
+		t.Error("expected StatusOf nil to be 200")
+	}
+}
@@ -0,0 +1,158 @@
+package jsonware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"sync"
+)
+
+// ETagStore keeps the etags of the responses of handlers using ETags, by the
+// Group of the handler and the resource the response is for. It's shared by
+// every server so that any of them can answer a conditional request for a
+// response another one sent, and is usually backed by something like redis.
+type ETagStore interface {
+	// Get finds the etag of key in group, it's empty when there's none.
+	Get(ctx context.Context, group, key string) (string, error)
+	// Set stores the etag of key in group.
+	Set(ctx context.Context, group, key, etag string) error
+	// Invalidate forgets every etag in group.
+	Invalidate(ctx context.Context, group string) error
+}
+
+// MemoryETagStore is an ETagStore that keeps etags in memory, it's only
+// suitable for a single server.
+type MemoryETagStore struct {
+	mut   sync.Mutex
+	etags map[string]map[string]string
+}
+
+// Get finds the etag of key in group.
+func (m *MemoryETagStore) Get(ctx context.Context, group, key string) (string, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return m.etags[group][key], nil
+}
+
+// Set stores the etag of key in group.
+func (m *MemoryETagStore) Set(ctx context.Context, group, key, etag string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if m.etags == nil {
+		m.etags = make(map[string]map[string]string)
+	}
+	if m.etags[group] == nil {
+		m.etags[group] = make(map[string]string)
+	}
+	m.etags[group][key] = etag
+	return nil
+}
+
+// Invalidate forgets every etag in group.
+func (m *MemoryETagStore) Invalidate(ctx context.Context, group string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	delete(m.etags, group)
+	return nil
+}
+
+/*
+ETags sends the JSONHandler's successful responses to GET and HEAD requests
+with an ETag, the hash of the body as it's sent, and keeps it in store under
+the request's key, which is the resource the response is for. A conditional
+request whose If-None-Match matches the stored etag gets a 304 Not Modified
+without the handler being called, so repeated GETs, to any server sharing
+the store, cost only a lookup. Others get a 304 once the body has been
+produced when it hasn't changed.
+
+Successful POST, PUT, PATCH and DELETE requests to a handler using ETags
+invalidate every etag stored for handlers in its Group, so the handlers that
+change resources need the same Group and store as the ones that get them.
+
+	etags := &jsonware.MemoryETagStore{}
+	http.Handle("GET /orders/{id}", jsonware.Handler(getOrder).Group("orders").ETags(etags, nil))
+	http.Handle("PUT /orders/{id}", jsonware.Handler(putOrder).Group("orders").ETags(etags, nil))
+
+key is the request's path and query when it's nil. It has to tell apart
+every response the handler can send, so responses that depend on more than
+the url, like ones pruned for the Subject by FilterResponse, need a key that
+includes it. If the store fails the failure is logged and the request is
+served as though there was no etag. Handlers with a Version use it instead.
+*/
+func (j *JSONHandler) ETags(store ETagStore, key func(r *http.Request) string) *JSONHandler {
+	if key == nil {
+		key = func(r *http.Request) string { return r.URL.RequestURI() }
+	}
+	j.etags = &etagStore{store: store, key: key}
+	return j
+}
+
+// etagStore is the ETagStore of a handler and how it finds the keys of
+// requests.
+type etagStore struct {
+	store ETagStore
+	key   func(r *http.Request) string
+}
+
+// checkStoredETag writes a 304 when the client's copy of the response has
+// the etag that's stored for it, returning true when it did.
+func (j JSONHandler) checkStoredETag(w http.ResponseWriter, r *http.Request) bool {
+	match := r.Header.Get("If-None-Match")
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || len(match) == 0 {
+		return false
+	}
+	etag, err := j.etags.store.Get(r.Context(), j.group, j.etags.key(r))
+	if err != nil {
+		logf(j.logger, "failed to get etag of %s %s: %v", r.Method, r.URL.Path, err)
+		return false
+	}
+	if len(etag) == 0 || !etagMatches(match, etag) {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	j.notModified(w)
+	return true
+}
+
+// storeETag sets the ETag of the response body b and stores it, writing a
+// 304 and returning true when the client already has it.
+func (j JSONHandler) storeETag(w http.ResponseWriter, r *http.Request, b []byte) bool {
+	sum := sha256.Sum256(b)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if err := j.etags.store.Set(r.Context(), j.group, j.etags.key(r), etag); err != nil {
+		logf(j.logger, "failed to store etag of %s %s: %v", r.Method, r.URL.Path, err)
+	}
+
+	w.Header().Set("ETag", etag)
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	j.notModified(w)
+	return true
+}
+
+// invalidateETags forgets the etags of the handler's group after a request
+// changed its resources.
+func (j JSONHandler) invalidateETags(r *http.Request) {
+	if err := j.etags.store.Invalidate(r.Context(), j.group); err != nil {
+		logf(j.logger, "failed to invalidate etags of group %q: %v", j.group, err)
+	}
+}
+
+// storesETag checks the response v to r is one whose etag is stored, a 200
+// to a GET or HEAD.
+func storesETag(r *http.Request, v interface{}) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	status := 0
+	if env, ok := v.(envelope); ok {
+		v, _, status = env.envelope()
+	}
+	if sc, ok := v.(StatusCoder); ok && status == 0 {
+		status = sc.StatusCode()
+	}
+	return status == 0 || status == http.StatusOK
+}
@@ -0,0 +1,118 @@
+package jsonware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type brokenETagStore struct{}
+
+func (brokenETagStore) Get(ctx context.Context, group, key string) (string, error) {
+	return "", errors.New("store is down")
+}
+
+func (brokenETagStore) Set(ctx context.Context, group, key, etag string) error {
+	return errors.New("store is down")
+}
+
+func (brokenETagStore) Invalidate(ctx context.Context, group string) error {
+	return errors.New("store is down")
+}
+
+func TestETags(t *testing.T) {
+	t.Parallel()
+
+	var name atomic.Value
+	name.Store("bob")
+	var calls int32
+	store := &MemoryETagStore{}
+
+	get := Handler(func(r *http.Request) (*testType, error) {
+		atomic.AddInt32(&calls, 1)
+		return &testType{Name: name.Load().(string)}, nil
+	}).Group("users").ETags(store, nil)
+	put := Handler(func(r *http.Request, in *testType) error {
+		name.Store(in.Name)
+		return nil
+	}).Group("users").ETags(store, nil)
+	other := Handler(func(r *http.Request) error { return nil }).Group("orders").ETags(store, nil)
+	created := Handler(func(r *http.Request) (*createdType, error) { return &createdType{}, nil }).ETags(store, nil)
+
+	send := func(h *JSONHandler, method, body, etag string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/users/1", strings.NewReader(body))
+		req.Header.Set("Accept", "application/json")
+		if len(body) != 0 {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if len(etag) != 0 {
+			req.Header.Set("If-None-Match", etag)
+		}
+		h.ServeHTTP(res, req)
+		return res
+	}
+
+	res := send(get, "GET", "", "")
+	etag := res.Header().Get("ETag")
+	if res.Code != 200 || len(etag) == 0 {
+		t.Fatalf("expected a 200 with an etag, got: %d %q", res.Code, etag)
+	}
+
+	if res := send(get, "GET", "", etag); res.Code != 304 || res.Body.Len() != 0 || res.Header().Get("ETag") != etag {
+		t.Errorf("expected a 304 with the etag, got: %d %q", res.Code, res.Header().Get("ETag"))
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected the stored etag to answer without calling the handler, it was called %d times", n)
+	}
+
+	send(other, "DELETE", "", "")
+	send(get, "GET", "", etag)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected another group's changes to leave the etag alone, the handler was called %d times", n)
+	}
+
+	send(put, "PUT", `{"name":"bob"}`, "")
+	if res := send(get, "GET", "", etag); res.Code != 304 {
+		t.Errorf("expected a 304 for an unchanged body, got: %d", res.Code)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("expected a change to invalidate the etag, the handler was called %d times", n)
+	}
+
+	send(put, "PUT", `{"name":"ann"}`, "")
+	res = send(get, "GET", "", etag)
+	if res.Code != 200 || strings.TrimSpace(res.Body.String()) != `{"name":"ann"}` || res.Header().Get("ETag") == etag {
+		t.Errorf("expected the changed body with a new etag, got: %d %s %q", res.Code, res.Body.String(), res.Header().Get("ETag"))
+	}
+
+	if res := send(created, "GET", "", ""); res.Code != 201 || len(res.Header().Get("ETag")) != 0 {
+		t.Errorf("expected no etag for a 201, got: %d %q", res.Code, res.Header().Get("ETag"))
+	}
+}
+
+func TestETagsBrokenStore(t *testing.T) {
+	t.Parallel()
+
+	logs := &strings.Builder{}
+	h := Handler(func(r *http.Request) (*testType, error) {
+		return &testType{Name: "bob"}, nil
+	}).Log(logs).ETags(brokenETagStore{}, nil)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("If-None-Match", `"abc"`)
+	h.ServeHTTP(res, req)
+
+	if res.Code != 200 || strings.TrimSpace(res.Body.String()) != `{"name":"bob"}` {
+		t.Errorf("expected the response to be served, got: %d %s", res.Code, res.Body.String())
+	}
+	if !strings.Contains(logs.String(), "store is down") {
+		t.Errorf("expected the store's failure to be logged, got: %s", logs.String())
+	}
+}
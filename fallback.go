@@ -0,0 +1,207 @@
+package jsonware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+/*
+FallbackHandler serves requests with a primary handler and falls back to a
+secondary one when the primary fails with a 5xx or takes too long, like a
+cached or stale data path standing in for a live one. It's made by Fallback
+or Hedge.
+
+The request body is read up front so that both handlers can be given it,
+bodies larger than MaxBodySize get a 413. Both handlers may run for the same
+request, so the secondary should be safe to call after the primary has done
+some or all of its work. A handler that panics is treated as one that
+responded with a 500.
+*/
+type FallbackHandler struct {
+	primary   http.Handler
+	secondary http.Handler
+	timeout   time.Duration
+	hedge     time.Duration
+
+	// MaxBodySize is the largest request body that's read, MaxDecompressedSize
+	// is used when it's zero.
+	MaxBodySize int64
+
+	// Log is where errors are reported, the global logger is used when it's
+	// nil.
+	Log io.Writer
+}
+
+/*
+Fallback serves requests with primary, and with secondary when primary
+responds with a 5xx or takes longer than the FallbackHandler's Timeout. The
+response of the secondary is sent even when it's a failure too.
+
+	h := jsonware.Fallback(
+		jsonware.Handler(liveRates),
+		jsonware.Handler(cachedRates),
+	).Timeout(500 * time.Millisecond)
+*/
+func Fallback(primary, secondary *JSONHandler) *FallbackHandler {
+	return &FallbackHandler{primary: primary, secondary: secondary}
+}
+
+/*
+Hedge is like Fallback, except that GET and HEAD requests that primary hasn't
+answered within delay are sent to secondary as well, and whichever handler
+succeeds first answers the request. Other requests aren't hedged since they
+may not be safe to repeat.
+*/
+func Hedge(primary, secondary *JSONHandler, delay time.Duration) *FallbackHandler {
+	return &FallbackHandler{primary: primary, secondary: secondary, hedge: delay}
+}
+
+// Timeout sets how long the primary handler has to respond before the
+// secondary is used, the primary's request context is canceled then. There's
+// no limit when it's zero.
+func (f *FallbackHandler) Timeout(d time.Duration) *FallbackHandler {
+	f.timeout = d
+	return f
+}
+
+// captured is a response held on to until it's decided which handler's is
+// sent.
+type captured struct {
+	header    http.Header
+	status    int
+	body      bytes.Buffer
+	secondary bool
+}
+
+func (c *captured) Header() http.Header { return c.header }
+
+func (c *captured) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+func (c *captured) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	return c.body.Write(p)
+}
+
+func (c *captured) send(w http.ResponseWriter) {
+	for k, v := range c.header {
+		w.Header()[k] = v
+	}
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+	}
+	c.body.WriteTo(w)
+}
+
+func (f *FallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		limit := f.MaxBodySize
+		if limit <= 0 {
+			limit = MaxDecompressedSize
+		}
+		var err error
+		body, err = io.ReadAll(&limitedBody{ReadCloser: r.Body, left: limit})
+		if isBodyTooLarge(err) {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, f.Log, Err{Status: http.StatusRequestEntityTooLarge, Err: err})
+			return
+		} else if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, f.Log, Err{Status: http.StatusBadRequest, Err: errors.New("could not read request body")})
+			return
+		}
+	}
+
+	results := make(chan *captured, 2)
+	serve := func(ctx context.Context, h http.Handler, secondary bool) {
+		c := &captured{header: make(http.Header), secondary: secondary}
+		// The handlers run in their own goroutines, a panic would take the
+		// whole server down.
+		defer func() {
+			if p := recover(); p != nil {
+				c = &captured{header: make(http.Header), secondary: secondary}
+				c.header.Set("Content-Type", "application/json")
+				writeError(c, f.Log, fmt.Errorf("panic: %v\n%s", p, debug.Stack()))
+			}
+			results <- c
+		}()
+
+		req := r.Clone(ctx)
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		h.ServeHTTP(c, req)
+	}
+
+	primaryCtx, cancelPrimary := context.WithCancel(r.Context())
+	defer cancelPrimary()
+	secondaryCtx, cancelSecondary := context.WithCancel(r.Context())
+	defer cancelSecondary()
+
+	go serve(primaryCtx, f.primary, false)
+	pending := 1
+
+	var timeout, hedge <-chan time.Time
+	if f.timeout > 0 {
+		t := time.NewTimer(f.timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	if f.hedge > 0 && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		t := time.NewTimer(f.hedge)
+		defer t.Stop()
+		hedge = t.C
+	}
+
+	started, abandoned := false, false
+	startSecondary := func() {
+		if !started {
+			started = true
+			pending++
+			go serve(secondaryCtx, f.secondary, true)
+		}
+	}
+
+	var failed *captured
+	for pending > 0 {
+		select {
+		case c := <-results:
+			if !c.secondary && abandoned {
+				continue
+			}
+			pending--
+			if c.status < 500 {
+				c.send(w)
+				return
+			}
+			if !c.secondary {
+				timeout, hedge = nil, nil
+			}
+			failed = c
+			startSecondary()
+		case <-timeout:
+			timeout, hedge = nil, nil
+			abandoned = true
+			pending--
+			cancelPrimary()
+			startSecondary()
+		case <-hedge:
+			hedge = nil
+			startSecondary()
+		}
+	}
+
+	failed.send(w)
+}
@@ -0,0 +1,192 @@
+package jsonware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fallbackHandler responds with name after delay, or fails with err.
+func fallbackHandler(name string, delay time.Duration, err error, calls *int32) *JSONHandler {
+	return Handler(func(r *http.Request) (*testType, error) {
+		atomic.AddInt32(calls, 1)
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &testType{Name: name}, nil
+	}).Log(&bytes.Buffer{})
+}
+
+func TestFallback(t *testing.T) {
+	t.Parallel()
+
+	down := errors.New("down")
+	notFound := Err{Status: http.StatusNotFound, Err: errors.New("not found")}
+
+	var tests = []struct {
+		primaryDelay  time.Duration
+		primaryErr    error
+		secondaryErr  error
+		timeout       time.Duration
+		status        int
+		want          string
+		wantSecondary bool
+	}{
+		{0, nil, nil, 0, 200, "primary", false},
+		{0, down, nil, 0, 200, "secondary", true},
+		{0, notFound, nil, 0, 404, "not found", false},
+		{0, down, down, 0, 500, "an internal server error occurred", true},
+		{time.Second, nil, nil, 20 * time.Millisecond, 200, "secondary", true},
+	}
+
+	for i, test := range tests {
+		var primaryCalls, secondaryCalls int32
+		h := Fallback(
+			fallbackHandler("primary", test.primaryDelay, test.primaryErr, &primaryCalls),
+			fallbackHandler("secondary", 0, test.secondaryErr, &secondaryCalls),
+		).Timeout(test.timeout)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if !strings.Contains(res.Body.String(), test.want) {
+			t.Errorf("Test %d: expected %s in the body, got: %s", i, test.want, res.Body.String())
+		}
+		if got := atomic.LoadInt32(&secondaryCalls) != 0; got != test.wantSecondary {
+			t.Errorf("Test %d: expected secondary called %t, got: %t", i, test.wantSecondary, got)
+		}
+		if ct := res.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Test %d: expected the content type to be copied, got: %q", i, ct)
+		}
+	}
+}
+
+func TestFallbackBody(t *testing.T) {
+	t.Parallel()
+
+	var bodies []string
+	handler := func(fail bool) *JSONHandler {
+		return Handler(func(r *http.Request, in *testType) (*testType, error) {
+			bodies = append(bodies, in.Name)
+			if fail {
+				return nil, errors.New("down")
+			}
+			return in, nil
+		}).Log(&bytes.Buffer{})
+	}
+
+	h := Fallback(handler(true), handler(false))
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"name":"a"}`))
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK || len(bodies) != 2 || bodies[0] != "a" || bodies[1] != "a" {
+		t.Errorf("expected both handlers to get the body, got: %d %v", res.Code, bodies)
+	}
+}
+
+func TestFallbackTooLarge(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	h := Fallback(fallbackHandler("a", 0, nil, &calls), fallbackHandler("b", 0, nil, &calls))
+	h.MaxBodySize = 4
+	h.Log = &bytes.Buffer{}
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"name":"a"}`))
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(res, req)
+
+	if res.Code != http.StatusRequestEntityTooLarge || calls != 0 {
+		t.Errorf("expected a 413 without calling the handlers, got: %d %d", res.Code, calls)
+	}
+}
+
+func TestFallbackPanic(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+	panics := Handler(func(r *http.Request) (*testType, error) { panic("boom") })
+
+	var tests = []struct {
+		h      *FallbackHandler
+		status int
+		body   string
+	}{
+		{Fallback(panics, fallbackHandler("b", 0, nil, &calls)), 200, `{"name":"b"}`},
+		{Fallback(fallbackHandler("a", 0, errors.New("down"), &calls), panics), 500, `{"error":"an internal server error occurred"}`},
+	}
+
+	for i, test := range tests {
+		log := &bytes.Buffer{}
+		test.h.Log = log
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		test.h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.body {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.body, b)
+		}
+		if !strings.Contains(log.String(), "panic: boom") {
+			t.Errorf("Test %d: expected the panic to be logged, got: %s", i, log.String())
+		}
+	}
+}
+
+func TestHedge(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		method        string
+		primaryDelay  time.Duration
+		want          string
+		wantSecondary bool
+	}{
+		{"GET", 0, "primary", false},
+		{"GET", time.Second, "secondary", true},
+		{"DELETE", 50 * time.Millisecond, "primary", false},
+	}
+
+	for i, test := range tests {
+		var primaryCalls, secondaryCalls int32
+		h := Hedge(
+			fallbackHandler("primary", test.primaryDelay, nil, &primaryCalls),
+			fallbackHandler("secondary", 0, nil, &secondaryCalls),
+			10*time.Millisecond,
+		)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK || !strings.Contains(res.Body.String(), test.want) {
+			t.Errorf("Test %d: expected a 200 from the %s, got: %d %s", i, test.want, res.Code, res.Body.String())
+		}
+		if got := atomic.LoadInt32(&secondaryCalls) != 0; got != test.wantSecondary {
+			t.Errorf("Test %d: expected secondary called %t, got: %t", i, test.wantSecondary, got)
+		}
+	}
+}
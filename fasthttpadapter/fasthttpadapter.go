@@ -0,0 +1,35 @@
+/*
+Package fasthttpadapter lets jsonware handlers be served by fasthttp.
+
+Requests are translated into net/http requests so the handlers, their
+decoding and their error handling are exactly the same as everywhere else.
+String user values set on the RequestCtx, such as the parameters matched by
+fasthttp/router, are bound to the path tagged fields of the handlers' request
+objects.
+
+	r := router.New()
+	r.GET("/users/{id}", fasthttpadapter.Handler(jsonware.Handler(getUser)))
+	fasthttp.ListenAndServe(":8080", r.Handler)
+*/
+package fasthttpadapter
+
+import (
+	"net/http"
+
+	"github.com/aarondl/jsonware"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// Handler converts h, typically a JSONHandler, into a fasthttp handler.
+func Handler(h http.Handler) fasthttp.RequestHandler {
+	return fasthttpadaptor.NewFastHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The adaptor serves requests with the RequestCtx as their context,
+		// whose values are its user values.
+		ctx := r.Context()
+		h.ServeHTTP(w, jsonware.WithPathParams(r, func(name string) string {
+			s, _ := ctx.Value(name).(string)
+			return s
+		}))
+	}))
+}
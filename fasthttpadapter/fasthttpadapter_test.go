@@ -0,0 +1,67 @@
+package fasthttpadapter
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aarondl/jsonware"
+	"github.com/valyala/fasthttp"
+)
+
+type user struct {
+	ID   int    `path:"id" json:"id"`
+	Name string `json:"name"`
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(jsonware.Handler(func(r *http.Request, in *user) (*user, error) {
+		if in.Name == "error" {
+			return nil, errors.New("kaboom")
+		}
+		return in, nil
+	}).Log(&strings.Builder{}))
+
+	var tests = []struct {
+		method  string
+		id      string
+		reqbody string
+		status  int
+		resbody string
+	}{
+		{"GET", "5", "", 200, `{"id":5,"name":""}`},
+		{"GET", "x", "", 400, `{"error":"invalid path parameter id"}`},
+		{"PUT", "5", `{"name":"bob"}`, 200, `{"id":5,"name":"bob"}`},
+		{"PUT", "5", `{"name":"error"}`, 500, `{"error":"an internal server error occurred"}`},
+		{"PUT", "5", `{"name"`, 400, `{"error":"could not deserialize json request body"}`},
+	}
+
+	for i, test := range tests {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(test.method)
+		ctx.Request.SetRequestURI("/users/" + test.id)
+		ctx.Request.Header.Set("Accept", "application/json")
+		ctx.Request.SetBodyString(test.reqbody)
+		ctx.SetUserValue("id", test.id)
+
+		h(ctx)
+
+		if code := ctx.Response.StatusCode(); code != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, code)
+		}
+
+		if ct := string(ctx.Response.Header.ContentType()); ct != "application/json" {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected json content type, got: %s", ct)
+		}
+
+		if b := strings.TrimSpace(string(ctx.Response.Body())); b != test.resbody {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package jsonware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	errFlagNotFound = errors.New("not found")
+	errFlagOff      = errors.New("feature is not enabled")
+)
+
+// FlagTarget is who a request is made by, as far as feature flags are
+// concerned. Fields are empty when the request doesn't have them.
+type FlagTarget struct {
+	// Tenant is the id of the request's Tenant.
+	Tenant string
+	// Principal is the common name of the request's client certificate.
+	Principal string
+	// Owner is who the request's APIKey belongs to.
+	Owner string
+}
+
+// FlagProvider decides if a feature flag is on for a target.
+type FlagProvider interface {
+	FlagEnabled(ctx context.Context, flag string, target FlagTarget) (bool, error)
+}
+
+// FlagProviderFunc is a function that's a FlagProvider.
+type FlagProviderFunc func(ctx context.Context, flag string, target FlagTarget) (bool, error)
+
+// FlagEnabled calls fn.
+func (fn FlagProviderFunc) FlagEnabled(ctx context.Context, flag string, target FlagTarget) (bool, error) {
+	return fn(ctx, flag, target)
+}
+
+/*
+FeatureFlag only serves requests when provider says flag is on for whoever
+is making them, so new endpoints can be dark launched. Requests get a
+response with offStatus when it's off, which is a 404 Not Found as if the
+endpoint didn't exist when it's zero, or usually a 403 Forbidden otherwise.
+Errors from provider are cloaked. The Config of the handler's group can turn
+the flag on or off in place of provider, which may be nil to only use the
+Config.
+
+The flag is checked after the client has been authenticated, so put the
+Tenancy, APIKeys or client certificate checks that identify it in front.
+
+	h := jsonware.Handler(exportReport).FeatureFlag("report-export", flags, 0)
+*/
+func (j *JSONHandler) FeatureFlag(flag string, provider FlagProvider, offStatus int) *JSONHandler {
+	if offStatus == 0 {
+		offStatus = http.StatusNotFound
+	}
+	j.flag = flag
+	j.flagProvider = provider
+	j.flagOffStatus = offStatus
+	return j
+}
+
+// checkFlag checks that the handler's feature flag is on for r, cfg is the
+// Config of the handler's group.
+func (j JSONHandler) checkFlag(r *http.Request, cfg Config) error {
+	on, set := cfg.Flags[j.flag]
+	if !set && j.flagProvider != nil {
+		var err error
+		if on, err = j.flagProvider.FlagEnabled(r.Context(), j.flag, flagTarget(r)); err != nil {
+			return fmt.Errorf("failed to check feature flag %s: %w", j.flag, err)
+		}
+	}
+	if on {
+		return nil
+	}
+	if j.flagOffStatus == http.StatusNotFound {
+		return Err{Status: http.StatusNotFound, Err: errFlagNotFound}
+	}
+	return Err{Status: j.flagOffStatus, Err: errFlagOff}
+}
+
+// flagTarget finds who's making a request.
+func flagTarget(r *http.Request) FlagTarget {
+	var target FlagTarget
+	if t, ok := TenantFrom(r.Context()); ok {
+		target.Tenant = t.ID
+	}
+	if p, ok := PrincipalFrom(r.Context()); ok {
+		target.Principal = p.CommonName
+	}
+	if k, ok := APIKeyFrom(r.Context()); ok {
+		target.Owner = k.Owner
+	}
+	return target
+}
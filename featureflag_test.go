@@ -0,0 +1,67 @@
+package jsonware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFeatureFlag(t *testing.T) {
+	t.Parallel()
+
+	flags := FlagProviderFunc(func(ctx context.Context, flag string, target FlagTarget) (bool, error) {
+		if flag != "beta" {
+			t.Errorf("expected the beta flag, got: %s", flag)
+		}
+		switch target.Tenant {
+		case "broken":
+			return false, errors.New("flag service down")
+		case "acme":
+			return target.Owner == "alice", nil
+		}
+		return false, nil
+	})
+
+	var tests = []struct {
+		tenant    string
+		owner     string
+		offStatus int
+		status    int
+		want      string
+	}{
+		{"acme", "alice", 0, 200, `{"name":"beta"}`},
+		{"acme", "bob", 0, 404, `{"error":"not found"}`},
+		{"acme", "bob", 403, 403, `{"error":"feature is not enabled"}`},
+		{"", "", 0, 404, `{"error":"not found"}`},
+		{"broken", "", 0, 500, `{"error":"an internal server error occurred"}`},
+	}
+
+	for i, test := range tests {
+		h := Handler(func(r *http.Request) *testType { return &testType{Name: "beta"} }).
+			Log(&bytes.Buffer{}).
+			FeatureFlag("beta", flags, test.offStatus)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		ctx := req.Context()
+		if len(test.tenant) != 0 {
+			ctx = WithTenant(ctx, Tenant{ID: test.tenant})
+		}
+		if len(test.owner) != 0 {
+			ctx = WithAPIKey(ctx, &APIKey{ID: "k", Owner: test.owner})
+		}
+		h.ServeHTTP(res, req.WithContext(ctx))
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package jsonware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	errMissingFields = errors.New("missing required fields")
+	errNotInEnum     = errors.New("fields have values that are not allowed")
+	errTooLarge      = errors.New("fields are too large")
+	errTooLong       = errors.New("fields are too long")
+)
+
+// ruleField is a field of a request object that has a default value, is
+// required or is restricted to an enum.
+type ruleField struct {
+	index int
+	// name is the field's json key, for reporting it to the client.
+	name string
+	// value is the default.
+	value string
+	// enum is the values the field may have.
+	enum []string
+	// maxSize is the most bytes the field may have.
+	maxSize int
+	// maxLen is the most characters or elements the field may have, and
+	// maxBytes the most bytes. Zero is unlimited.
+	maxLen   int
+	maxBytes int
+}
+
+// fieldRules are the rules for the fields of a request object that are
+// tagged with default, required:"true", enum, maxsize, maxlen or maxbytes.
+type fieldRules struct {
+	defaults []ruleField
+	required []ruleField
+	enums    []ruleField
+	sizes    []ruleField
+	limits   []ruleField
+}
+
+// ruleFields finds the rules for the fields of typ, a request object type.
+// It's nil when there are none. It panics if a default can't be parsed into
+// its field or an enum can't be applied to it.
+func ruleFields(typ reflect.Type) *fieldRules {
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	typ = typ.Elem()
+
+	rules := &fieldRules{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if len(f.PkgPath) != 0 {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if len(name) == 0 || name == "-" {
+			name = f.Name
+		}
+
+		if f.Tag.Get("required") == "true" {
+			rules.required = append(rules.required, ruleField{index: i, name: name})
+		}
+		if value, ok := f.Tag.Lookup("default"); ok {
+			if err := setDefault(reflect.New(f.Type).Elem(), value); err != nil {
+				panic(fmt.Sprintf("Field %s.%s cannot have default %s: %v", typ.Name(), f.Name, value, err))
+			}
+			rules.defaults = append(rules.defaults, ruleField{index: i, name: name, value: value})
+		}
+		if tag, ok := f.Tag.Lookup("enum"); ok {
+			enum := strings.Split(tag, ",")
+			for k := range enum {
+				enum[k] = strings.TrimSpace(enum[k])
+				if err := setEnum(reflect.New(f.Type).Elem(), enum[k]); err != nil {
+					panic(fmt.Sprintf("Field %s.%s cannot have enum %s: %v", typ.Name(), f.Name, tag, err))
+				}
+			}
+			rules.enums = append(rules.enums, ruleField{index: i, name: name, enum: enum})
+		}
+		if tag, ok := f.Tag.Lookup("maxsize"); ok {
+			size, err := strconv.Atoi(tag)
+			if err != nil || size < 0 || f.Type != bytesType {
+				panic(fmt.Sprintf("Field %s.%s cannot have maxsize %s, it must be a number on a Bytes field", typ.Name(), f.Name, tag))
+			}
+			rules.sizes = append(rules.sizes, ruleField{index: i, name: name, maxSize: size})
+		}
+
+		limit := ruleField{index: i, name: name}
+		if tag, ok := f.Tag.Lookup("maxlen"); ok {
+			var err error
+			limit.maxLen, err = strconv.Atoi(tag)
+			if err != nil || limit.maxLen <= 0 || !hasLen(f.Type) {
+				panic(fmt.Sprintf("Field %s.%s cannot have maxlen %s, it must be a number on a string, slice or map field", typ.Name(), f.Name, tag))
+			}
+		}
+		if tag, ok := f.Tag.Lookup("maxbytes"); ok {
+			var err error
+			limit.maxBytes, err = strconv.Atoi(tag)
+			if err != nil || limit.maxBytes <= 0 {
+				panic(fmt.Sprintf("Field %s.%s cannot have maxbytes %s, it must be a number", typ.Name(), f.Name, tag))
+			}
+		}
+		if limit.maxLen != 0 || limit.maxBytes != 0 {
+			rules.limits = append(rules.limits, limit)
+		}
+	}
+
+	if len(rules.defaults) == 0 && len(rules.required) == 0 && len(rules.enums) == 0 &&
+		len(rules.sizes) == 0 && len(rules.limits) == 0 {
+		return nil
+	}
+	return rules
+}
+
+// setDefault parses a default tag into v.
+func setDefault(v reflect.Value, value string) error {
+	if isBindable(v.Type()) {
+		return setString(v, value)
+	}
+	return json.Unmarshal([]byte(value), v.Addr().Interface())
+}
+
+// setEnum checks an enum value can be compared with a field by parsing it
+// into v, enums only apply to string and integer fields.
+func setEnum(v reflect.Value, value string) error {
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return setString(v, value)
+	}
+	return fmt.Errorf("enums only apply to strings and integers")
+}
+
+// enumString formats a string or integer field for comparing with its enum.
+func enumString(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	}
+	return strconv.FormatUint(v.Uint(), 10)
+}
+
+// hasLen checks if maxlen applies to fields of typ.
+func hasLen(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// fieldLen is how many characters or elements are in a field.
+func fieldLen(v reflect.Value) int {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len()
+	}
+	return 0
+}
+
+// fieldBytes is how many bytes are in a field, strings and byte slices are
+// counted as they are and everything else as json.
+func fieldBytes(v reflect.Value, codec *Codec) int {
+	switch {
+	case !v.IsValid(), v.Kind() == reflect.Ptr:
+		return 0
+	case v.Kind() == reflect.String:
+		return v.Len()
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return v.Len()
+	}
+
+	b, err := codec.Marshal(v.Interface())
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+/*
+apply checks obj, a pointer to a struct, against the rules. Fields that are
+still zero are set to their defaults, then none of the required fields may
+be zero, fields with an enum must have one of its values, fields can't be
+longer than their maxlen or maxbytes, and Bytes fields can't be larger than
+their maxsize. Zero values are left to required, so
+optional enum fields may be left out.
+*/
+func (rules *fieldRules) apply(obj reflect.Value, codec *Codec) error {
+	obj = obj.Elem()
+	for _, f := range rules.defaults {
+		if field := obj.Field(f.index); field.IsZero() {
+			// Parsed again every time so that requests don't share
+			// pointers and slices.
+			if err := setDefault(field, f.value); err != nil {
+				return err
+			}
+		}
+	}
+
+	var missing []string
+	for _, f := range rules.required {
+		if obj.Field(f.index).IsZero() {
+			missing = append(missing, codec.fieldKey(f.name))
+		}
+	}
+	if len(missing) != 0 {
+		return Err{Status: http.StatusUnprocessableEntity, Err: errMissingFields, Reason: missing}
+	}
+
+	var invalid map[string][]string
+	for _, f := range rules.enums {
+		field := obj.Field(f.index)
+		if field.IsZero() {
+			continue
+		}
+		if field.Kind() == reflect.Ptr {
+			field = field.Elem()
+		}
+
+		value, allowed := enumString(field), false
+		for _, e := range f.enum {
+			if e == value {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			if invalid == nil {
+				invalid = make(map[string][]string)
+			}
+			invalid[codec.fieldKey(f.name)] = f.enum
+		}
+	}
+	if len(invalid) != 0 {
+		return Err{Status: http.StatusUnprocessableEntity, Err: errNotInEnum, Reason: invalid}
+	}
+
+	var tooLong map[string]string
+	for _, f := range rules.limits {
+		field := obj.Field(f.index)
+		for field.Kind() == reflect.Ptr && !field.IsNil() {
+			field = field.Elem()
+		}
+
+		var problem string
+		if f.maxLen != 0 && fieldLen(field) > f.maxLen {
+			unit := "elements"
+			if field.Kind() == reflect.String {
+				unit = "characters"
+			}
+			problem = fmt.Sprintf("longer than %d %s", f.maxLen, unit)
+		} else if f.maxBytes != 0 && fieldBytes(field, codec) > f.maxBytes {
+			problem = fmt.Sprintf("larger than %d bytes", f.maxBytes)
+		}
+		if len(problem) == 0 {
+			continue
+		}
+
+		if tooLong == nil {
+			tooLong = make(map[string]string)
+		}
+		tooLong[codec.fieldKey(f.name)] = problem
+	}
+	if len(tooLong) != 0 {
+		return Err{Status: http.StatusUnprocessableEntity, Err: errTooLong, Reason: tooLong}
+	}
+
+	var tooLarge map[string]int
+	for _, f := range rules.sizes {
+		if obj.Field(f.index).Len() > f.maxSize {
+			if tooLarge == nil {
+				tooLarge = make(map[string]int)
+			}
+			tooLarge[codec.fieldKey(f.name)] = f.maxSize
+		}
+	}
+	if len(tooLarge) != 0 {
+		return Err{Status: http.StatusRequestEntityTooLarge, Err: errTooLarge, Reason: tooLarge}
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type defaultsType struct {
+	Name  string   `json:"name" required:"true"`
+	Email string   `json:"email" required:"true"`
+	Role  string   `json:"role" default:"member"`
+	Limit *int     `json:"limit" default:"10"`
+	Tags  []string `json:"tags" default:"[\"new\"]"`
+	ID    int      `path:"id" required:"true"`
+}
+
+func TestDefaults(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		method string
+		path   string
+		body   string
+		codec  *Codec
+		status int
+		want   string
+	}{
+		{"POST", "/users/5", `{"name":"bob","email":"b@b.com"}`, nil, 200,
+			`{"name":"bob","email":"b@b.com","role":"member","limit":10,"tags":["new"],"ID":5}`},
+		{"POST", "/users/5", `{"name":"bob","email":"b@b.com","role":"admin","limit":0,"tags":[]}`, nil, 200,
+			`{"name":"bob","email":"b@b.com","role":"admin","limit":0,"tags":[],"ID":5}`},
+		{"POST", "/users/5", `{"name":"bob"}`, nil, 422,
+			`{"error":"missing required fields","reason":["email"]}`},
+		{"POST", "/users/0", `{}`, nil, 422,
+			`{"error":"missing required fields","reason":["name","email","ID"]}`},
+		{"POST", "/users/0", `{"name":"bob","email":"b@b.com"}`, &Codec{Keys: SnakeCase}, 422,
+			`{"error":"missing required fields","reason":["id"]}`},
+		{"GET", "/users/5", ``, nil, 422,
+			`{"error":"missing required fields","reason":["name","email"]}`},
+	}
+
+	for i, test := range tests {
+		mux := http.NewServeMux()
+		h := Handler(func(r *http.Request, in *defaultsType) *defaultsType { return in })
+		if test.codec != nil {
+			h.Codec(test.codec)
+		}
+		mux.Handle("/users/{id}", h)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		mux.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
+
+func TestDefaultsNotShared(t *testing.T) {
+	t.Parallel()
+
+	var seen [][]string
+	h := Handler(func(r *http.Request, in *defaultsType) *defaultsType {
+		in.Tags[0] = "changed"
+		seen = append(seen, in.Tags)
+		return in
+	})
+
+	for i := 0; i < 2; i++ {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"name":"bob","email":"b@b.com"}`))
+		req.Header.Set("Accept", "application/json")
+		req = WithPathParams(req, func(string) string { return "1" })
+		h.ServeHTTP(res, req)
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got: %d %s", res.Code, res.Body.String())
+		}
+	}
+
+	if &seen[0][0] == &seen[1][0] {
+		t.Error("expected each request to get its own default")
+	}
+}
+
+func TestDefaultsPanic(t *testing.T) {
+	t.Parallel()
+
+	type bad struct {
+		Limit int `json:"limit" default:"ten"`
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	Handler(func(r *http.Request, in *bad) *bad { return in })
+}
+
+func TestEnums(t *testing.T) {
+	t.Parallel()
+
+	type enumType struct {
+		Role     string `json:"role" enum:"member, admin"`
+		Level    *int   `json:"level" enum:"1,2,3"`
+		Priority uint8  `json:"priority_level" enum:"5"`
+	}
+
+	var tests = []struct {
+		body   string
+		codec  *Codec
+		status int
+		want   string
+	}{
+		{`{}`, nil, 200, ``},
+		{`{"role":"admin","level":2,"priority_level":5}`, nil, 200, ``},
+		{`{"role":"owner"}`, nil, 422, `{"error":"fields have values that are not allowed","reason":{"role":["member","admin"]}}`},
+		{`{"role":"member","level":0,"priority_level":4}`, nil, 422,
+			`{"error":"fields have values that are not allowed","reason":{"level":["1","2","3"],"priority_level":["5"]}}`},
+		{`{"priorityLevel":4}`, &Codec{Keys: CamelCase}, 422,
+			`{"error":"fields have values that are not allowed","reason":{"priorityLevel":["5"]}}`},
+	}
+
+	for i, test := range tests {
+		h := Handler(func(r *http.Request, in *enumType) *enumType { return in })
+		if test.codec != nil {
+			h.Codec(test.codec)
+		}
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); len(test.want) != 0 && b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
+
+func TestEnumsPanic(t *testing.T) {
+	t.Parallel()
+
+	var tests = []interface{}{
+		func(r *http.Request, in *struct {
+			Level int `enum:"1,two"`
+		}) error {
+			return nil
+		},
+		func(r *http.Request, in *struct {
+			Ratio float64 `enum:"1.5"`
+		}) error {
+			return nil
+		},
+	}
+
+	for i, test := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("Test %d: expected a panic", i)
+				}
+			}()
+			Handler(test)
+		}()
+	}
+}
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+
+	type limitType struct {
+		Name  string            `json:"name" maxlen:"5"`
+		Bio   *string           `json:"bio" maxbytes:"8"`
+		Tags  []string          `json:"tags" maxlen:"2"`
+		Attrs map[string]string `json:"attrs" maxbytes:"20"`
+	}
+
+	var tests = []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{`{}`, 200, ``},
+		{`{"name":"héllo","bio":"12345678","tags":["a","b"],"attrs":{"a":"b"}}`, 200, ``},
+		{`{"name":"hello!"}`, 422, `{"error":"fields are too long","reason":{"name":"longer than 5 characters"}}`},
+		{`{"bio":"héllo!!!","tags":["a","b","c"]}`, 422,
+			`{"error":"fields are too long","reason":{"bio":"larger than 8 bytes","tags":"longer than 2 elements"}}`},
+		{`{"attrs":{"key":"a much longer value"}}`, 422, `{"error":"fields are too long","reason":{"attrs":"larger than 20 bytes"}}`},
+	}
+
+	for i, test := range tests {
+		h := Handler(func(r *http.Request, in *limitType) *limitType { return in })
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); len(test.want) != 0 && b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
+
+func TestLimitsPanic(t *testing.T) {
+	t.Parallel()
+
+	var tests = []interface{}{
+		func(r *http.Request, in *struct {
+			Count int `maxlen:"5"`
+		}) error {
+			return nil
+		},
+		func(r *http.Request, in *struct {
+			Name string `maxbytes:"many"`
+		}) error {
+			return nil
+		},
+	}
+
+	for i, test := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("Test %d: expected a panic", i)
+				}
+			}()
+			Handler(test)
+		}()
+	}
+}
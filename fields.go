@@ -0,0 +1,309 @@
+package jsonware
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldsParam is the query parameter clients pick the fields of responses
+// with.
+const FieldsParam = "fields"
+
+/*
+Selectable lets clients pick the fields of the JSONHandler's responses with
+the fields query parameter, as json names separated by commas and nested ones
+separated by dots. The fields that aren't picked are zeroed, so they should
+also be tagged omitempty to be left out entirely. Picking a field picks all of
+its own, and the fields of list items are picked the same way as the fields
+of the response.
+
+	?fields=id,total,customer.name,items.sku
+
+maxDepth limits how deeply nested a field can be, customer.name is 2 deep,
+and maxCost limits how many fields a request can pick, counting each field
+on the way to a nested one once, so the fields above cost 6. Requests that go
+over either limit get a 400, so that clients can't ask for pathological
+selections. A limit of 0 is no limit. Fields that aren't in the response type
+get a 400 too, when the handler's response type is known before it's called.
+
+Handlers get the fields with FieldsOf, to only load what's asked for.
+
+	jsonware.Handler(getOrder).Selectable(3, 20)
+
+Streamed responses can't be selected from.
+*/
+func (j *JSONHandler) Selectable(maxDepth, maxCost int) *JSONHandler {
+	j.selectable = true
+	j.maxFieldDepth = maxDepth
+	j.maxFieldCost = maxCost
+	return j
+}
+
+// FieldsOf is the fields the request picks in its fields query parameter, in
+// the order they were asked for, none when it picks every field.
+func FieldsOf(r *http.Request) []string {
+	var fields []string
+	for _, param := range r.URL.Query()[FieldsParam] {
+		for _, field := range strings.Split(param, ",") {
+			if field = strings.TrimSpace(field); len(field) != 0 {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// selection is a tree of the fields picked by a request, a nil selection
+// picks every field.
+type selection map[string]selection
+
+// add picks the field at path.
+func (s selection) add(path []string) {
+	child, ok := s[path[0]]
+	switch {
+	case len(path) == 1:
+		s[path[0]] = nil
+	case ok && child == nil:
+		// The whole field was picked already.
+	default:
+		if child == nil {
+			child = make(selection)
+			s[path[0]] = child
+		}
+		child.add(path[1:])
+	}
+}
+
+// cost counts the fields in the selection.
+func (s selection) cost() int {
+	n := 0
+	for _, child := range s {
+		n += 1 + child.cost()
+	}
+	return n
+}
+
+// selectionOf reads the selection of a request, nil when it picks every
+// field.
+func selectionOf(r *http.Request) (selection, error) {
+	fields := FieldsOf(r)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	sel := make(selection)
+	for _, field := range fields {
+		path := strings.Split(field, ".")
+		for _, name := range path {
+			if len(name) == 0 {
+				return nil, fmt.Errorf("invalid field: %s", field)
+			}
+		}
+		sel.add(path)
+	}
+	return sel, nil
+}
+
+// checkFields checks the fields the request picks exist and are within the
+// handler's limits.
+func (j JSONHandler) checkFields(r *http.Request) error {
+	fields := FieldsOf(r)
+	sel, err := selectionOf(r)
+	if err != nil {
+		return Err{Status: http.StatusBadRequest, Err: err}
+	}
+
+	for _, field := range fields {
+		if depth := strings.Count(field, ".") + 1; j.maxFieldDepth > 0 && depth > j.maxFieldDepth {
+			return Err{Status: http.StatusBadRequest, Err: fmt.Errorf("field is nested too deeply: %s", field), Reason: fmt.Sprintf("at most %d deep", j.maxFieldDepth)}
+		}
+	}
+	if cost := sel.cost(); j.maxFieldCost > 0 && cost > j.maxFieldCost {
+		return Err{Status: http.StatusBadRequest, Err: fmt.Errorf("too many fields: %d", cost), Reason: fmt.Sprintf("at most %d", j.maxFieldCost)}
+	}
+
+	if j.out == nil || j.out.Kind() == reflect.Interface || j.stream != streamNone {
+		return nil
+	}
+	for _, field := range fields {
+		if !hasField(itemType(j.out), strings.Split(field, ".")) {
+			return Err{Status: http.StatusBadRequest, Err: fmt.Errorf("unknown field: %s", field), Reason: fieldNames(itemType(j.out))}
+		}
+	}
+	return nil
+}
+
+// hasField checks the field at path is in typ, fields behind an interface
+// could be anything so they're assumed to be.
+func hasField(typ reflect.Type, path []string) bool {
+	for _, name := range path {
+		typ = elemType(typ)
+		if typ.Kind() == reflect.Interface {
+			return true
+		}
+		if typ.Kind() != reflect.Struct {
+			return false
+		}
+		f, ok := fieldByJSONName(typ, name)
+		if !ok {
+			return false
+		}
+		typ = f.Type
+	}
+	return true
+}
+
+// elemType is the type of the values in pointers, lists and maps of typ.
+func elemType(typ reflect.Type) reflect.Type {
+	for {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			typ = typ.Elem()
+		default:
+			return typ
+		}
+	}
+}
+
+// fieldByJSONName finds the field of the struct type typ that's encoded as
+// name, including the ones promoted from embedded structs.
+func fieldByJSONName(typ reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		own, embedded := jsonFieldName(f)
+		if embedded {
+			if f, ok := fieldByJSONName(elemType(f.Type), name); ok {
+				return f, true
+			}
+			continue
+		}
+		if len(own) != 0 && own == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// fieldNames is the sorted json names of the fields of typ, for telling
+// clients what they can pick.
+func fieldNames(typ reflect.Type) []string {
+	typ = elemType(typ)
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, embedded := jsonFieldName(f)
+		if embedded {
+			names = append(names, fieldNames(f.Type)...)
+		} else if len(name) != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// jsonFieldName is the name encoding/json gives a struct field, empty when
+// it's left out, or whether the field is an embedded struct whose fields are
+// promoted in its place.
+func jsonFieldName(f reflect.StructField) (string, bool) {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return "", false
+	}
+	if f.Anonymous && len(name) == 0 && elemType(f.Type).Kind() == reflect.Struct {
+		return "", true
+	}
+	if len(f.PkgPath) != 0 {
+		return "", false
+	}
+	if len(name) == 0 {
+		name = f.Name
+	}
+	return name, false
+}
+
+// selectFields returns a copy of v with only the fields in sel set.
+func selectFields(v reflect.Value, sel selection) reflect.Value {
+	if sel == nil {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(selectFields(v.Elem(), sel))
+		return p
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		i := reflect.New(v.Type()).Elem()
+		i.Set(selectFields(v.Elem(), sel))
+		return i
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s.Index(i).Set(selectFields(v.Index(i), sel))
+		}
+		return s
+	case reflect.Array:
+		a := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			a.Index(i).Set(selectFields(v.Index(i), sel))
+		}
+		return a
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), selectFields(iter.Value(), sel))
+		}
+		return m
+	case reflect.Struct:
+		s := reflect.New(v.Type()).Elem()
+		s.Set(v)
+		selectStructFields(s, sel)
+		return s
+	}
+	return v
+}
+
+// selectStructFields zeroes the fields of the settable struct s that aren't
+// in sel. The exported fields of embedded structs are settable even when the
+// embedded struct isn't, so they're pruned in place.
+func selectStructFields(s reflect.Value, sel selection) {
+	typ := s.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		f := s.Field(i)
+		name, embedded := jsonFieldName(typ.Field(i))
+		switch {
+		case embedded && f.Kind() == reflect.Struct:
+			selectStructFields(f, sel)
+		case !f.CanSet():
+		case embedded:
+			f.Set(selectFields(f, sel))
+		case len(name) == 0:
+		default:
+			if child, ok := sel[name]; ok {
+				f.Set(selectFields(f, child))
+			} else {
+				f.Set(reflect.Zero(f.Type()))
+			}
+		}
+	}
+}
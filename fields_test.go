@@ -0,0 +1,95 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fieldsBase struct {
+	Created string `json:"created,omitempty"`
+}
+
+type fieldsOrder struct {
+	fieldsBase
+	ID       string          `json:"id,omitempty"`
+	Total    int             `json:"total,omitempty"`
+	Customer *fieldsCustomer `json:"customer,omitempty"`
+	Items    []fieldsItem    `json:"items,omitempty"`
+	Meta     interface{}     `json:"meta,omitempty"`
+}
+
+type fieldsCustomer struct {
+	Name    string          `json:"name,omitempty"`
+	Email   string          `json:"email,omitempty"`
+	Address *fieldsCustomer `json:"address,omitempty"`
+}
+
+type fieldsItem struct {
+	SKU   string `json:"sku,omitempty"`
+	Price int    `json:"price,omitempty"`
+}
+
+func TestSelectable(t *testing.T) {
+	t.Parallel()
+
+	order := &fieldsOrder{
+		fieldsBase: fieldsBase{Created: "today"},
+		ID:         "o1",
+		Total:      7,
+		Customer:   &fieldsCustomer{Name: "ann", Email: "ann@example.com", Address: &fieldsCustomer{Name: "home"}},
+		Items:      []fieldsItem{{SKU: "a", Price: 3}, {SKU: "b", Price: 4}},
+		Meta:       fieldsItem{SKU: "m", Price: 1},
+	}
+	one := Handler(func(r *http.Request) (*fieldsOrder, error) { return order, nil }).Selectable(2, 5)
+	list := Handler(func(r *http.Request) ([]*fieldsOrder, error) { return []*fieldsOrder{order}, nil }).Selectable(0, 0)
+
+	var tests = []struct {
+		handler *JSONHandler
+		query   string
+		status  int
+		want    string
+	}{
+		{one, "?fields=id,total", 200, `{"id":"o1","total":7}`},
+		{one, "?fields=id&fields=customer.name", 200, `{"id":"o1","customer":{"name":"ann"}}`},
+		{one, "?fields=customer.name,customer", 200, `{"customer":{"name":"ann","email":"ann@example.com","address":{"name":"home"}}}`},
+		{one, "?fields=items.sku,created", 200, `{"created":"today","items":[{"sku":"a"},{"sku":"b"}]}`},
+		{one, "?fields=meta.price", 200, `{"meta":{"price":1}}`},
+		{one, "?fields=customer.address.name", 400, `{"error":"field is nested too deeply: customer.address.name","reason":"at most 2 deep"}`},
+		{one, "?fields=id,total,created,customer.name,customer.email", 400, `{"error":"too many fields: 6","reason":"at most 5"}`},
+		{one, "?fields=secret", 400, `{"error":"unknown field: secret","reason":["created","customer","id","items","meta","total"]}`},
+		{one, "?fields=customer..name", 400, `{"error":"invalid field: customer..name"}`},
+		{list, "?fields=customer.address.name,id", 200, `[{"id":"o1","customer":{"address":{"name":"home"}}}]`},
+		{list, "", 200, `[{"created":"today","id":"o1","total":7,"customer":{"name":"ann","email":"ann@example.com","address":{"name":"home"}},"items":[{"sku":"a","price":3},{"sku":"b","price":4}],"meta":{"sku":"m","price":1}}]`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/orders"+test.query, nil)
+		req.Header.Set("Accept", "application/json")
+		test.handler.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+
+	if order.Total != 7 || len(order.Customer.Email) == 0 || order.Items[0].Price != 3 {
+		t.Error("expected the handler's value to be left alone")
+	}
+}
+
+func TestFieldsOf(t *testing.T) {
+	t.Parallel()
+
+	req, _ := http.NewRequest("GET", "/?fields=id,+customer.name,&fields=total", nil)
+	want := []string{"id", "customer.name", "total"}
+	if got := FieldsOf(req); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got: %v", want, got)
+	}
+}
@@ -0,0 +1,333 @@
+package jsonware
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldParseError is the error of a UUID, URL, Duration or Bytes that
+// couldn't be parsed, JSONHandlers report it along with the field it was in.
+type fieldParseError struct {
+	kind  string
+	value string
+	// status is 400 when it's zero.
+	status int
+	// reason replaces the default message.
+	reason string
+}
+
+func (e *fieldParseError) Error() string {
+	if len(e.reason) != 0 {
+		return e.reason
+	}
+	return fmt.Sprintf("not a valid %s: %q", e.kind, e.value)
+}
+
+/*
+UUID is a request object field that holds a uuid, in the canonical form
+5f2c1e8a-7d4b-4c3e-9a1f-0b6d2e8c4a7f or without the hyphens. Requests with
+a UUID that can't be parsed get a 400 naming the field, as they do for URL
+and Duration fields.
+
+	type GetOrder struct {
+		ID jsonware.UUID `path:"id"`
+	}
+*/
+type UUID [16]byte
+
+// ParseUUID parses a uuid in the canonical form or without the hyphens.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	hexits := s
+	if len(s) == 36 {
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return u, &fieldParseError{kind: "uuid", value: s}
+		}
+		hexits = s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	}
+	if len(hexits) != 32 {
+		return u, &fieldParseError{kind: "uuid", value: s}
+	}
+	if _, err := hex.Decode(u[:], []byte(hexits)); err != nil {
+		return u, &fieldParseError{kind: "uuid", value: s}
+	}
+	return u, nil
+}
+
+// String formats the uuid in the canonical form.
+func (u UUID) String() string {
+	h := hex.EncodeToString(u[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// MarshalText formats the uuid in the canonical form.
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText parses a uuid.
+func (u *UUID) UnmarshalText(text []byte) error {
+	parsed, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// URL is a request object field that holds an absolute url, like
+// https://example.com/hook.
+type URL struct {
+	url.URL
+}
+
+// MarshalText formats the url.
+func (u URL) MarshalText() ([]byte, error) {
+	return []byte(u.URL.String()), nil
+}
+
+// UnmarshalText parses an absolute url.
+func (u *URL) UnmarshalText(text []byte) error {
+	parsed, err := url.Parse(string(text))
+	if err != nil || !parsed.IsAbs() || len(parsed.Host) == 0 {
+		return &fieldParseError{kind: "url", value: string(text)}
+	}
+	u.URL = *parsed
+	return nil
+}
+
+// Duration is a request object field that holds a duration. It's written as
+// a string like "1h30m", the same as time.ParseDuration takes, and may be
+// sent as a number of seconds too.
+type Duration time.Duration
+
+// MarshalText formats the duration like time.Duration does.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText parses a duration like time.ParseDuration does.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return &fieldParseError{kind: "duration", value: string(text)}
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// UnmarshalJSON parses a duration string or a number of seconds.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	if len(b) != 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		return d.UnmarshalText([]byte(s))
+	}
+
+	seconds, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return &fieldParseError{kind: "duration", value: string(b)}
+	}
+	*d = Duration(seconds * float64(time.Second))
+	return nil
+}
+
+// MaxBytesSize is the most bytes a Bytes field may decode to, strings that
+// would decode to more are rejected with a 413 before they're decoded.
+var MaxBytesSize = 1 << 20
+
+/*
+Bytes is a request object field that holds binary data sent as a base64
+string. Both the standard and url safe alphabets are accepted, with or
+without padding. Unlike a plain []byte it refuses strings that would decode
+to more than MaxBytesSize before decoding them, and fields can have a
+tighter limit with a maxsize tag:
+
+	type SetAvatar struct {
+		Image jsonware.Bytes `json:"image" maxsize:"65536"`
+	}
+
+It's written back out with the standard alphabet.
+*/
+type Bytes []byte
+
+// MarshalJSON writes the bytes as a standard base64 string.
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	if b == nil {
+		return []byte("null"), nil
+	}
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(b))+2)
+	out[0], out[len(out)-1] = '"', '"'
+	base64.StdEncoding.Encode(out[1:], b)
+	return out, nil
+}
+
+// UnmarshalJSON decodes a base64 string.
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return &fieldParseError{kind: "base64 string", reason: "not a base64 string"}
+	}
+
+	encoded := data[1 : len(data)-1]
+	if bytes.IndexByte(encoded, '\\') >= 0 {
+		// Escapes are rare enough to not be worth avoiding the copy for.
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		encoded = []byte(s)
+	}
+
+	enc := base64.StdEncoding
+	if bytes.ContainsAny(encoded, "-_") {
+		enc = base64.URLEncoding
+	}
+	if len(encoded)%4 != 0 {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+
+	if n := enc.DecodedLen(len(encoded)); n > MaxBytesSize+2 {
+		return &fieldParseError{
+			kind:   "base64 string",
+			status: http.StatusRequestEntityTooLarge,
+			reason: fmt.Sprintf("larger than %d bytes", MaxBytesSize),
+		}
+	}
+
+	decoded := make([]byte, enc.DecodedLen(len(encoded)))
+	n, err := enc.Decode(decoded, encoded)
+	if err != nil {
+		return &fieldParseError{kind: "base64 string", reason: "not a valid base64 string"}
+	}
+	if n > MaxBytesSize {
+		return &fieldParseError{
+			kind:   "base64 string",
+			status: http.StatusRequestEntityTooLarge,
+			reason: fmt.Sprintf("larger than %d bytes", MaxBytesSize),
+		}
+	}
+	*b = decoded[:n]
+	return nil
+}
+
+var (
+	uuidType     = reflect.TypeOf(UUID{})
+	urlType      = reflect.TypeOf(URL{})
+	durationType = reflect.TypeOf(Duration(0))
+	bytesType    = reflect.TypeOf(Bytes(nil))
+)
+
+// hasFieldTypes checks if a UUID, URL, Duration or Bytes can be decoded somewhere
+// in typ.
+func hasFieldTypes(typ reflect.Type, seen map[reflect.Type]bool) bool {
+	if typ == nil || seen[typ] {
+		return false
+	}
+	seen[typ] = true
+
+	switch typ {
+	case uuidType, urlType, durationType, bytesType:
+		return true
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return hasFieldTypes(typ.Elem(), seen)
+	case reflect.Struct:
+		for _, f := range codecFields(typ) {
+			if hasFieldTypes(f.typ, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// locateField finds the path to the field of body, as decoded into typ,
+// that fails to parse, like items[2].id.
+func locateField(body []byte, typ reflect.Type, codec *Codec) (string, bool) {
+	var raw interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return "", false
+	}
+	return locate(raw, typ, codec)
+}
+
+func locate(raw interface{}, typ reflect.Type, codec *Codec) (string, bool) {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil {
+		return "", false
+	}
+
+	switch typ {
+	case uuidType, urlType, durationType, bytesType:
+		b, _ := json.Marshal(raw)
+		if json.Unmarshal(b, reflect.New(typ).Interface()) != nil {
+			return "", true
+		}
+		return "", false
+	}
+
+	switch val := raw.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		// Sorted so that the same field is reported every time.
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			var elemType reflect.Type
+			switch typ.Kind() {
+			case reflect.Map:
+				elemType = typ.Elem()
+			case reflect.Struct:
+				for _, f := range codecFields(typ) {
+					if codec.fieldKey(f.name) == k || strings.EqualFold(f.name, k) {
+						elemType = f.typ
+						break
+					}
+				}
+			}
+			if path, ok := locate(val[k], elemType, codec); ok {
+				if len(path) != 0 && path[0] != '[' {
+					path = "." + path
+				}
+				return k + path, true
+			}
+		}
+	case []interface{}:
+		if typ.Kind() != reflect.Slice && typ.Kind() != reflect.Array {
+			return "", false
+		}
+		for i, elem := range val {
+			if path, ok := locate(elem, typ.Elem(), codec); ok {
+				if len(path) != 0 && path[0] != '[' {
+					path = "." + path
+				}
+				return "[" + strconv.Itoa(i) + "]" + path, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,233 @@
+package jsonware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseUUID(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{"5f2c1e8a-7d4b-4c3e-9a1f-0b6d2e8c4a7f", "5f2c1e8a-7d4b-4c3e-9a1f-0b6d2e8c4a7f", false},
+		{"5F2C1E8A-7D4B-4C3E-9A1F-0B6D2E8C4A7F", "5f2c1e8a-7d4b-4c3e-9a1f-0b6d2e8c4a7f", false},
+		{"5f2c1e8a7d4b4c3e9a1f0b6d2e8c4a7f", "5f2c1e8a-7d4b-4c3e-9a1f-0b6d2e8c4a7f", false},
+		{"5f2c1e8a_7d4b-4c3e-9a1f-0b6d2e8c4a7f", "", true},
+		{"5f2c1e8a-7d4b-4c3e-9a1f-0b6d2e8c4a7", "", true},
+		{"zf2c1e8a-7d4b-4c3e-9a1f-0b6d2e8c4a7f", "", true},
+		{"", "", true},
+	}
+
+	for i, test := range tests {
+		u, err := ParseUUID(test.in)
+		if test.err != (err != nil) {
+			t.Errorf("Test %d: expected error %t, got: %v", i, test.err, err)
+		}
+		if !test.err && u.String() != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, u)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{`"1h30m"`, 90 * time.Minute, false},
+		{`"250ms"`, 250 * time.Millisecond, false},
+		{`90`, 90 * time.Second, false},
+		{`1.5`, 1500 * time.Millisecond, false},
+		{`"soon"`, 0, true},
+		{`true`, 0, true},
+	}
+
+	for i, test := range tests {
+		var d Duration
+		err := json.Unmarshal([]byte(test.in), &d)
+		if test.err != (err != nil) {
+			t.Errorf("Test %d: expected error %t, got: %v", i, test.err, err)
+		}
+		if time.Duration(d) != test.want {
+			t.Errorf("Test %d: expected %v, got: %v", i, test.want, time.Duration(d))
+		}
+	}
+
+	if b, _ := json.Marshal(Duration(90 * time.Minute)); string(b) != `"1h30m0s"` {
+		t.Errorf("expected duration to marshal as a string, got: %s", b)
+	}
+}
+
+type fieldTypesHook struct {
+	ID   UUID `json:"id"`
+	URL  URL  `json:"url"`
+	Wait *Duration
+}
+
+type fieldTypesType struct {
+	Owner UUID              `json:"owner_id"`
+	Hooks []fieldTypesHook  `json:"hooks"`
+	Named map[string]UUID   `json:"named"`
+	Every Duration          `json:"every"`
+	Extra map[string]string `json:"extra"`
+}
+
+func TestFieldTypes(t *testing.T) {
+	t.Parallel()
+
+	const id = "5f2c1e8a-7d4b-4c3e-9a1f-0b6d2e8c4a7f"
+
+	var tests = []struct {
+		body   string
+		codec  *Codec
+		status int
+		want   string
+	}{
+		{`{"owner_id":"` + id + `","hooks":[{"id":"` + id + `","url":"https://example.com/h?a=1","Wait":"2s"}],"every":60}`, nil, 200,
+			`{"owner_id":"` + id + `","hooks":[{"id":"` + id + `","url":"https://example.com/h?a=1","Wait":"2s"}],"named":null,"every":"1m0s","extra":null}`},
+		{`{"owner_id":"nope"}`, nil, 400, `{"error":"invalid field owner_id","reason":"not a valid uuid: \"nope\""}`},
+		{`{"hooks":[{"id":"` + id + `","url":"https://example.com"},{"url":"/relative"}]}`, nil, 400,
+			`{"error":"invalid field hooks[1].url","reason":"not a valid url: \"/relative\""}`},
+		{`{"hooks":[{"wait":"soon"}]}`, nil, 400, `{"error":"invalid field hooks[0].wait","reason":"not a valid duration: \"soon\""}`},
+		{`{"named":{"a":"` + id + `","b":"x"}}`, nil, 400, `{"error":"invalid field named.b","reason":"not a valid uuid: \"x\""}`},
+		{`{"ownerId":"x"}`, &Codec{Keys: CamelCase}, 400, `{"error":"invalid field ownerId","reason":"not a valid uuid: \"x\""}`},
+		{`{"extra":{"a":1}}`, nil, 400, `{"error":"could not deserialize json request body"}`},
+	}
+
+	for i, test := range tests {
+		h := Handler(func(r *http.Request, in *fieldTypesType) *fieldTypesType { return in })
+		if test.codec != nil {
+			h.Codec(test.codec)
+		}
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
+
+func TestFieldTypesPath(t *testing.T) {
+	t.Parallel()
+
+	type getOrder struct {
+		ID UUID `path:"id"`
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/orders/{id}", Handler(func(r *http.Request, in *getOrder) *getOrder { return in }))
+
+	var tests = []struct {
+		path   string
+		status int
+	}{
+		{"/orders/5f2c1e8a7d4b4c3e9a1f0b6d2e8c4a7f", 200},
+		{"/orders/5", 400},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", test.path, nil)
+		req.Header.Set("Accept", "application/json")
+		mux.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d %s", i, test.status, res.Code, res.Body.String())
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		in   string
+		want string
+		err  string
+	}{
+		{`"aGVsbG8/Pz4+"`, "hello??>>", ""},
+		{`"aGVsbG8_Pz4-"`, "hello??>>", ""},
+		{`"aGVsbG8\/Pz4+"`, "hello??>>", ""},
+		{`"aGk="`, "hi", ""},
+		{`"aGk"`, "hi", ""},
+		{`null`, "", ""},
+		{`"!!!!"`, "", "not a valid base64 string"},
+		{`5`, "", "not a base64 string"},
+		{`"` + strings.Repeat("A", (MaxBytesSize/3+1)*4) + `"`, "", "larger than"},
+	}
+
+	for i, test := range tests {
+		var b Bytes
+		err := b.UnmarshalJSON([]byte(test.in))
+		if len(test.err) == 0 && err != nil {
+			t.Errorf("Test %d: unexpected error: %v", i, err)
+		} else if len(test.err) != 0 && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("Test %d: expected error %q, got: %v", i, test.err, err)
+		}
+		if string(b) != test.want {
+			t.Errorf("Test %d: expected %q, got: %q", i, test.want, b)
+		}
+	}
+
+	if b, _ := json.Marshal(struct{ B Bytes }{Bytes("hi")}); string(b) != `{"B":"aGk="}` {
+		t.Errorf("expected bytes to marshal as base64, got: %s", b)
+	}
+	if b, _ := json.Marshal(struct{ B Bytes }{}); string(b) != `{"B":null}` {
+		t.Errorf("expected nil bytes to marshal as null, got: %s", b)
+	}
+}
+
+func TestBytesHandler(t *testing.T) {
+	t.Parallel()
+
+	type avatar struct {
+		Image Bytes `json:"image" maxsize:"4"`
+		Thumb Bytes `json:"thumb"`
+	}
+
+	var tests = []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{`{"image":"aGk="}`, 200, `{"image":"aGk=","thumb":null}`},
+		{`{"image":"aGVsbG8="}`, 413, `{"error":"fields are too large","reason":{"image":4}}`},
+		{`{"thumb":"%%"}`, 400, `{"error":"invalid field thumb","reason":"not a valid base64 string"}`},
+		{`{"thumb":"` + strings.Repeat("A", (MaxBytesSize/3+1)*4) + `"}`, 413,
+			`{"error":"invalid field thumb","reason":"larger than 1048576 bytes"}`},
+	}
+
+	for i, test := range tests {
+		h := Handler(func(r *http.Request, in *avatar) *avatar { return in })
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
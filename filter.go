@@ -0,0 +1,223 @@
+package jsonware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// FilterParam is the query parameter clients filter lists with.
+const FilterParam = "filter"
+
+// Filter ops.
+const (
+	FilterEq       = "eq"
+	FilterNe       = "ne"
+	FilterLt       = "lt"
+	FilterLte      = "lte"
+	FilterGt       = "gt"
+	FilterGte      = "gte"
+	FilterIn       = "in"
+	FilterContains = "contains"
+)
+
+// filterOps are the sql operators of the filter ops.
+var filterOps = map[string]string{
+	FilterEq:       "=",
+	FilterNe:       "<>",
+	FilterLt:       "<",
+	FilterLte:      "<=",
+	FilterGt:       ">",
+	FilterGte:      ">=",
+	FilterIn:       "IN",
+	FilterContains: "LIKE",
+}
+
+var errBadFilter = errors.New("invalid filter")
+
+// Filter is a condition the items of a list must meet, one of the filters a
+// request asks for.
+type Filter struct {
+	// Field is the json name of the field.
+	Field string
+	Op    string
+	// Values is what the field is compared with, it's only ever more than
+	// one value for FilterIn.
+	Values []string
+}
+
+/*
+Filterable makes the JSONHandler check the filters clients ask for with
+filter query parameters against the fields of its response type, the same
+way Sortable does for sorting. Each filter is a field, an op and a value
+separated by colons, and the items of the list must match all of them:
+
+	?filter=status:eq:open&filter=total:gte:100&filter=region:in:eu|us
+
+The ops are eq, ne, lt, lte, gt, gte, in, whose values are separated by |,
+and contains. Asking for a field that can't be filtered by or an op that
+doesn't exist gets the client a 400. When any field is tagged with
+filterable:"true" only the ones that are can be filtered by. Fields tagged
+with visible can only be filtered by subjects that can see them, or clients
+could find out their values one guess at a time. Filterable panics if the
+response type has no fields.
+
+Handlers get the filters with FiltersOf, and Where turns them into sql.
+
+	jsonware.Handler(listOrders).Filterable()
+
+	func listOrders(r *http.Request) ([]*Order, error) {
+		where, args, err := jsonware.Where(jsonware.FiltersOf(r), nil, jsonware.Dollar)
+		...
+		rows, err := db.QueryContext(r.Context(), "SELECT * FROM orders WHERE "+where, args...)
+	}
+*/
+func (j *JSONHandler) Filterable() *JSONHandler {
+	fields := jsonFields(j.out, "filterable")
+	if len(fields) == 0 {
+		panic(fmt.Sprintf("Filterable needs a response type with fields, %v has none", j.out))
+	}
+	j.filterable = fields
+	return j
+}
+
+// filtersKey is the context key of the filters a Filterable handler checked.
+type filtersKey struct{}
+
+// FiltersOf is the filters the request asks for in its filter query
+// parameters, once a Filterable handler has checked them. It's nil for
+// requests to handlers that aren't Filterable, so that fields which were
+// never checked can't end up in a query.
+func FiltersOf(r *http.Request) []Filter {
+	filters, _ := r.Context().Value(filtersKey{}).([]Filter)
+	return filters
+}
+
+// parseFilter parses a filter query parameter like total:gte:100.
+func parseFilter(param string) (Filter, error) {
+	parts := strings.SplitN(param, ":", 3)
+	if len(parts) != 3 || len(parts[0]) == 0 {
+		return Filter{}, fmt.Errorf("filter must be field:op:value: %s", param)
+	}
+	f := Filter{Field: parts[0], Op: parts[1], Values: []string{parts[2]}}
+	if _, ok := filterOps[f.Op]; !ok {
+		return Filter{}, fmt.Errorf("unknown filter op: %s", f.Op)
+	}
+	if f.Op == FilterIn {
+		f.Values = strings.Split(parts[2], "|")
+	}
+	return f, nil
+}
+
+// checkFilters checks the request only asks for filters on fields that can
+// be filtered by, returning a copy of it that FiltersOf gets them from.
+func (j JSONHandler) checkFilters(r *http.Request) (*http.Request, error) {
+	subject, _ := subjectOf(r)
+	var filters []Filter
+	for _, param := range r.URL.Query()[FilterParam] {
+		f, err := parseFilter(param)
+		if err != nil {
+			return nil, Err{Status: http.StatusBadRequest, Err: err}
+		}
+		if !j.filterable.allows(f.Field, subject.Roles) {
+			return nil, Err{Status: http.StatusBadRequest, Err: fmt.Errorf("cannot filter by: %s", f.Field), Reason: j.filterable.allowed(subject.Roles)}
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 0 {
+		return r, nil
+	}
+	return r.WithContext(context.WithValue(r.Context(), filtersKey{}, filters)), nil
+}
+
+// Placeholder writes the placeholder of the nth parameter of a query,
+// counting from 1.
+type Placeholder func(n int) string
+
+// QuestionMark is the ? placeholder of MySQL and SQLite.
+func QuestionMark(n int) string { return "?" }
+
+// Dollar is the $1 placeholder of PostgreSQL.
+func Dollar(n int) string { return "$" + strconv.Itoa(n) }
+
+/*
+Where compiles filters into the condition of a sql WHERE clause, with the
+values as parameters rather than in the sql. columns maps the json names of
+fields to the columns they're stored in, fields that aren't in it are their
+own column as long as they're plain identifiers, so that nothing a client
+sends can end up in the sql. The condition of no filters is 1 = 1.
+
+	where, args, err := jsonware.Where(filters, map[string]string{"total": "total_cents"}, jsonware.Dollar)
+	// status = $1 AND total_cents >= $2, ["open", "100"]
+*/
+func Where(filters []Filter, columns map[string]string, placeholder Placeholder) (string, []interface{}, error) {
+	if len(filters) == 0 {
+		return "1 = 1", nil, nil
+	}
+
+	var b strings.Builder
+	var args []interface{}
+	for i, f := range filters {
+		op, ok := filterOps[f.Op]
+		if !ok || len(f.Values) == 0 {
+			return "", nil, errBadFilter
+		}
+		column, ok := columns[f.Field]
+		if !ok {
+			if !isIdentifier(f.Field) {
+				return "", nil, fmt.Errorf("filter field is not a column: %s", f.Field)
+			}
+			column = f.Field
+		}
+
+		if i > 0 {
+			b.WriteString(" AND ")
+		}
+		b.WriteString(column + " " + op + " ")
+		switch f.Op {
+		case FilterIn:
+			b.WriteByte('(')
+			for k, v := range f.Values {
+				if k > 0 {
+					b.WriteString(", ")
+				}
+				args = append(args, v)
+				b.WriteString(placeholder(len(args)))
+			}
+			b.WriteByte(')')
+		case FilterContains:
+			args = append(args, "%"+escapeLike(f.Values[0])+"%")
+			b.WriteString(placeholder(len(args)))
+			b.WriteString(" ESCAPE '!'")
+		default:
+			args = append(args, f.Values[0])
+			b.WriteString(placeholder(len(args)))
+		}
+	}
+	return b.String(), args, nil
+}
+
+// isIdentifier checks s is a plain sql identifier.
+func isIdentifier(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern with !, which unlike \
+// means the same in the string literals of every database.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
@@ -0,0 +1,141 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type filterOrder struct {
+	Status string `json:"status" filterable:"true"`
+	Total  int    `json:"total" filterable:"true"`
+	Notes  string `json:"notes"`
+}
+
+func TestFilterable(t *testing.T) {
+	t.Parallel()
+
+	var got []Filter
+	h := Handler(func(r *http.Request) ([]filterOrder, error) {
+		got = FiltersOf(r)
+		return nil, nil
+	}).Filterable()
+	unchecked := Handler(func(r *http.Request) ([]filterOrder, error) {
+		got = FiltersOf(r)
+		return nil, nil
+	})
+
+	var tests = []struct {
+		handler *JSONHandler
+		query   string
+		status  int
+		want    []Filter
+		body    string
+	}{
+		{h, "", 200, nil, ``},
+		{h, "?filter=status:eq:open&filter=total:gte:100", 200, []Filter{{Field: "status", Op: "eq", Values: []string{"open"}}, {Field: "total", Op: "gte", Values: []string{"100"}}}, ``},
+		{h, "?filter=status:in:open|held", 200, []Filter{{Field: "status", Op: "in", Values: []string{"open", "held"}}}, ``},
+		{h, "?filter=status:eq:a:b", 200, []Filter{{Field: "status", Op: "eq", Values: []string{"a:b"}}}, ``},
+		{h, "?filter=notes:eq:x", 400, nil, `{"error":"cannot filter by: notes","reason":["status","total"]}`},
+		{h, "?filter=status:like:x", 400, nil, `{"error":"unknown filter op: like"}`},
+		{h, "?filter=status", 400, nil, `{"error":"filter must be field:op:value: status"}`},
+		// Handlers that aren't Filterable get no filters.
+		{unchecked, "?filter=notes:eq:x", 200, nil, ``},
+	}
+
+	for i, test := range tests {
+		got = nil
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/orders"+test.query, nil)
+		req.Header.Set("Accept", "application/json")
+		test.handler.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Test %d: expected filters %v, got: %v", i, test.want, got)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.body {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.body, b)
+		}
+	}
+}
+
+func TestFilterableVisible(t *testing.T) {
+	t.Parallel()
+
+	type account struct {
+		ID    int    `json:"id"`
+		Email string `json:"email,omitempty" visible:"admin"`
+	}
+	h := Handler(func(r *http.Request) ([]account, error) { return nil, nil }).Filterable()
+
+	var tests = []struct {
+		subject *Subject
+		query   string
+		status  int
+		body    string
+	}{
+		{nil, "?filter=id:eq:1", 200, ``},
+		{nil, "?filter=email:contains:a", 400, `{"error":"cannot filter by: email","reason":["id"]}`},
+		{&Subject{ID: "bob", Roles: []string{"support"}}, "?filter=email:contains:a", 400, `{"error":"cannot filter by: email","reason":["id"]}`},
+		{&Subject{ID: "ann", Roles: []string{"admin"}}, "?filter=email:contains:a", 200, ``},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/accounts"+test.query, nil)
+		req.Header.Set("Accept", "application/json")
+		if test.subject != nil {
+			req = req.WithContext(WithSubject(req.Context(), *test.subject))
+		}
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.body {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.body, b)
+		}
+	}
+}
+
+func TestWhere(t *testing.T) {
+	t.Parallel()
+
+	columns := map[string]string{"total": "total_cents", "order": `"order"`}
+
+	var tests = []struct {
+		filters     []Filter
+		placeholder Placeholder
+		where       string
+		args        []interface{}
+		err         bool
+	}{
+		{nil, Dollar, "1 = 1", nil, false},
+		{[]Filter{{"status", FilterEq, []string{"open"}}, {"total", FilterGte, []string{"100"}}}, Dollar, "status = $1 AND total_cents >= $2", []interface{}{"open", "100"}, false},
+		{[]Filter{{"status", FilterNe, []string{"open"}}, {"total", FilterLt, []string{"5"}}}, QuestionMark, "status <> ? AND total_cents < ?", []interface{}{"open", "5"}, false},
+		{[]Filter{{"id", FilterGt, []string{"1"}}, {"region", FilterIn, []string{"eu", "us"}}}, Dollar, "id > $1 AND region IN ($2, $3)", []interface{}{"1", "eu", "us"}, false},
+		{[]Filter{{"name", FilterContains, []string{"50%_off!"}}}, Dollar, "name LIKE $1 ESCAPE '!'", []interface{}{"%50!%!_off!!%"}, false},
+		{[]Filter{{"order", FilterLte, []string{"3"}}}, Dollar, `"order" <= $1`, []interface{}{"3"}, false},
+		{[]Filter{{"name; DROP TABLE x", FilterEq, []string{"a"}}}, Dollar, "", nil, true},
+		{[]Filter{{"name", "like", []string{"a"}}}, Dollar, "", nil, true},
+		{[]Filter{{"name", FilterEq, nil}}, Dollar, "", nil, true},
+	}
+
+	for i, test := range tests {
+		where, args, err := Where(test.filters, columns, test.placeholder)
+		if (err != nil) != test.err {
+			t.Errorf("Test %d: expected error %t, got: %v", i, test.err, err)
+		}
+		if where != test.where {
+			t.Errorf("Test %d: expected %q, got: %q", i, test.where, where)
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("Test %d: expected args %v, got: %v", i, test.args, args)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package jsonware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFlightHeaders are the request headers a FlightRecorder keeps when
+// its Headers field is nil.
+var DefaultFlightHeaders = []string{
+	"Accept",
+	"Content-Type",
+	"Content-Length",
+	"User-Agent",
+	"X-Request-Id",
+}
+
+// Flight is a failed request kept by a FlightRecorder.
+type Flight struct {
+	Time   time.Time   `json:"time"`
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Header http.Header `json:"header,omitempty"`
+	// Body is the start of the request body, as much of it as the handler
+	// read up to the FlightRecorder's BodyLimit.
+	Body   string `json:"body,omitempty"`
+	Status int    `json:"status"`
+	// Error is the error the response was written for, and Stack where it
+	// came from when the error carries a stack or the handler panicked.
+	Error string `json:"error,omitempty"`
+	Stack string `json:"stack,omitempty"`
+}
+
+/*
+FlightRecorder keeps the last few requests that failed in the handlers it
+wraps, so that a failure that happened once in the middle of the night can be
+looked into after the fact. It's a ring buffer in memory, the oldest failure
+is dropped when a new one doesn't fit.
+
+Only what's safe to keep is kept: the path without its query, the request
+headers listed in Headers, DefaultFlightHeaders when it's nil, and the start
+of the body. Sanitize can be set to scrub anything else. Errors and their
+stacks come from the errors written by JSONHandlers, so cloaked errors are
+kept in full, and a handler that panics is recorded with the panic's stack
+before the panic carries on.
+
+	flights := &jsonware.FlightRecorder{Size: 50}
+	http.Handle("/", flights.Wrap(mux))
+	debugMux.Handle("/debug/flights", flights.Handler(isStaff))
+*/
+type FlightRecorder struct {
+	// Size is how many failed requests are kept, 100 when it's zero.
+	Size int
+	// MinStatus is the lowest status that counts as a failure, 500 when
+	// it's zero.
+	MinStatus int
+	// BodyLimit is how many bytes of request bodies are kept, 1024 when it's
+	// zero and none when it's negative.
+	BodyLimit int
+	Headers   []string
+	Sanitize  func(*Flight)
+
+	mut     sync.Mutex
+	flights []Flight
+	next    int
+}
+
+// Wrap returns a handler that serves requests with h and records the ones
+// that fail.
+func (fr *FlightRecorder) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fw := &flightWriter{ResponseWriter: w}
+		var body *snippetReader
+		if r.Body != nil && r.Body != http.NoBody && fr.bodyLimit() > 0 {
+			body = &snippetReader{ReadCloser: r.Body, limit: fr.bodyLimit()}
+			r2 := *r
+			r2.Body = body
+			r = &r2
+		}
+
+		defer func() {
+			if p := recover(); p != nil {
+				if p != http.ErrAbortHandler {
+					fw.err = fmt.Sprintf("panic: %v", p)
+					fw.stack = string(debug.Stack())
+					fw.status = http.StatusInternalServerError
+					fr.record(r, fw, body)
+				}
+				panic(p)
+			}
+		}()
+		h.ServeHTTP(fw, r)
+
+		status := fw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		minStatus := fr.MinStatus
+		if minStatus == 0 {
+			minStatus = http.StatusInternalServerError
+		}
+		if status >= minStatus {
+			fw.status = status
+			fr.record(r, fw, body)
+		}
+	})
+}
+
+// Flights returns the failed requests that are kept, the most recent first.
+func (fr *FlightRecorder) Flights() []Flight {
+	fr.mut.Lock()
+	defer fr.mut.Unlock()
+
+	flights := make([]Flight, 0, len(fr.flights))
+	for i := 0; i < len(fr.flights); i++ {
+		at := (fr.next - 1 - i + len(fr.flights)) % len(fr.flights)
+		flights = append(flights, fr.flights[at])
+	}
+	return flights
+}
+
+// Handler returns a handler that dumps the failed requests that are kept
+// as json, the most recent first. It shows errors that are otherwise
+// cloaked, so requests are only answered when authorize allows them, everyone
+// else gets a 403, and so does everyone when authorize is nil.
+func (fr *FlightRecorder) Handler(authorize func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if authorize == nil || !authorize(r) {
+			writeError(w, nil, Err{Status: http.StatusForbidden, Err: errForbidden})
+			return
+		}
+
+		b, err := json.Marshal(fr.Flights())
+		if err != nil {
+			writeError(w, nil, err)
+			return
+		}
+		w.Write(b)
+	})
+}
+
+func (fr *FlightRecorder) bodyLimit() int {
+	if fr.BodyLimit == 0 {
+		return 1024
+	}
+	return fr.BodyLimit
+}
+
+func (fr *FlightRecorder) record(r *http.Request, fw *flightWriter, body *snippetReader) {
+	names := fr.Headers
+	if names == nil {
+		names = DefaultFlightHeaders
+	}
+	header := make(http.Header)
+	for _, name := range names {
+		if v := r.Header.Values(name); len(v) != 0 {
+			header[http.CanonicalHeaderKey(name)] = append([]string(nil), v...)
+		}
+	}
+
+	f := Flight{
+		Time:   time.Now(),
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: header,
+		Status: fw.status,
+		Error:  fw.err,
+		Stack:  fw.stack,
+	}
+	if body != nil {
+		f.Body = body.snippet.String()
+	}
+	if fr.Sanitize != nil {
+		fr.Sanitize(&f)
+	}
+
+	size := fr.Size
+	if size <= 0 {
+		size = 100
+	}
+
+	fr.mut.Lock()
+	defer fr.mut.Unlock()
+	if len(fr.flights) < size {
+		fr.flights = append(fr.flights, f)
+		fr.next = len(fr.flights) % size
+	} else {
+		fr.flights[fr.next] = f
+		fr.next = (fr.next + 1) % size
+	}
+}
+
+// flightWriter keeps the status and error of a response.
+type flightWriter struct {
+	http.ResponseWriter
+
+	status int
+	err    string
+	stack  string
+}
+
+func (f *flightWriter) WriteHeader(status int) {
+	if f.status == 0 {
+		f.status = status
+	}
+	f.ResponseWriter.WriteHeader(status)
+}
+
+func (f *flightWriter) Write(b []byte) (int, error) {
+	if f.status == 0 {
+		f.status = http.StatusOK
+	}
+	return f.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (f *flightWriter) Unwrap() http.ResponseWriter { return f.ResponseWriter }
+
+// noteError keeps the error a response is written for, the first one wins.
+func (f *flightWriter) noteError(err error) {
+	if len(f.err) != 0 {
+		return
+	}
+	f.err = err.Error()
+	if full := formatErr(err); full != f.err {
+		f.stack = strings.TrimPrefix(strings.TrimPrefix(full, f.err), "\n")
+	}
+}
+
+// noteError tells a FlightRecorder wrapping w about the error a response is
+// written for.
+func noteError(w http.ResponseWriter, err error) {
+	for w != nil {
+		if fw, ok := w.(*flightWriter); ok {
+			fw.noteError(err)
+			return
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = u.Unwrap()
+	}
+}
+
+// snippetReader keeps the first limit bytes read from a request body.
+type snippetReader struct {
+	io.ReadCloser
+	limit   int
+	snippet bytes.Buffer
+}
+
+func (s *snippetReader) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+	if room := s.limit - s.snippet.Len(); room > 0 {
+		if room > n {
+			room = n
+		}
+		s.snippet.Write(p[:room])
+	}
+	return n, err
+}
@@ -0,0 +1,113 @@
+package jsonware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	t.Parallel()
+
+	type input struct {
+		Name string `json:"name"`
+	}
+	fr := &FlightRecorder{Size: 2, BodyLimit: 8}
+	mux := http.NewServeMux()
+	mux.Handle("/ok", Handler(func(r *http.Request, in *input) (*input, error) { return in, nil }))
+	mux.Handle("/bad", Handler(func(r *http.Request, in *input) (*input, error) {
+		return nil, Err{Status: http.StatusConflict, Err: errors.New("conflict")}
+	}))
+	mux.Handle("/fail", Handler(func(r *http.Request, in *input) (*input, error) {
+		return nil, WithStack(errors.New("db is down"))
+	}).Log(&strings.Builder{}))
+	mux.Handle("/panic", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	h := fr.Wrap(mux)
+
+	serve := func(path string) {
+		defer func() { recover() }()
+		req, _ := http.NewRequest("POST", path+"?token=secret", strings.NewReader(`{"name":"alexander"}`))
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("X-Request-Id", "abc")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	serve("/ok")
+	serve("/bad")
+	serve("/fail")
+	flights := fr.Flights()
+	if len(flights) != 1 {
+		t.Fatalf("expected 1 flight, got: %#v", flights)
+	}
+	f := flights[0]
+	if f.Method != "POST" || f.Path != "/fail" || f.Status != 500 || f.Error != "db is down" {
+		t.Errorf("expected the failed request, got: %#v", f)
+	}
+	if f.Body != `{"name":` {
+		t.Errorf("expected the start of the body, got: %q", f.Body)
+	}
+	if len(f.Header) != 2 || f.Header.Get("X-Request-Id") != "abc" || f.Header.Get("Authorization") != "" {
+		t.Errorf("expected only the safe headers, got: %v", f.Header)
+	}
+	if !strings.Contains(f.Stack, "TestFlightRecorder") {
+		t.Errorf("expected the error's stack, got: %s", f.Stack)
+	}
+
+	serve("/panic")
+	serve("/fail")
+	flights = fr.Flights()
+	if len(flights) != 2 {
+		t.Fatalf("expected 2 flights, got: %d", len(flights))
+	}
+	if flights[0].Path != "/fail" || flights[1].Path != "/panic" {
+		t.Errorf("expected the most recent flights first, got: %s, %s", flights[0].Path, flights[1].Path)
+	}
+	if flights[1].Error != "panic: boom" || !strings.Contains(flights[1].Stack, "TestFlightRecorder") {
+		t.Errorf("expected the panic and its stack, got: %s\n%s", flights[1].Error, flights[1].Stack)
+	}
+
+	for i, authorize := range []func(*http.Request) bool{nil, func(*http.Request) bool { return false }} {
+		res := httptest.NewRecorder()
+		fr.Handler(authorize).ServeHTTP(res, httptest.NewRequest("GET", "/debug/flights", nil))
+		if res.Code != http.StatusForbidden {
+			t.Errorf("Test %d: expected a 403, got: %d", i, res.Code)
+		}
+	}
+
+	res := httptest.NewRecorder()
+	fr.Handler(func(*http.Request) bool { return true }).ServeHTTP(res, httptest.NewRequest("GET", "/debug/flights", nil))
+	var dumped []Flight
+	if err := json.Unmarshal(res.Body.Bytes(), &dumped); err != nil {
+		t.Fatal(err)
+	}
+	if len(dumped) != 2 || dumped[0].Path != "/fail" {
+		t.Errorf("expected the flights to be dumped, got: %s", res.Body.String())
+	}
+}
+
+func TestFlightRecorderMinStatus(t *testing.T) {
+	t.Parallel()
+
+	fr := &FlightRecorder{MinStatus: 400, BodyLimit: -1, Sanitize: func(f *Flight) { f.Path = "/redacted" }}
+	h := fr.Wrap(Handler(func(r *http.Request) (*testType, error) {
+		return nil, Err{Status: http.StatusNotFound, Err: errors.New("no such user")}
+	}))
+
+	req, _ := http.NewRequest("GET", "/users/5", nil)
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	flights := fr.Flights()
+	if len(flights) != 1 {
+		t.Fatalf("expected 1 flight, got: %d", len(flights))
+	}
+	if f := flights[0]; f.Status != 404 || f.Error != "no such user" || f.Path != "/redacted" || len(f.Stack) != 0 {
+		t.Errorf("expected the sanitized 404, got: %#v", f)
+	}
+}
@@ -0,0 +1,25 @@
+/*
+Package ginadapter lets jsonware handlers be served by gin.
+
+The path parameters matched by gin are bound to the path tagged fields of the
+handlers' request objects, and responses and errors are written by jsonware as
+usual.
+
+	r := gin.New()
+	r.GET("/users/:id", ginadapter.Handler(jsonware.Handler(getUser)))
+*/
+package ginadapter
+
+import (
+	"net/http"
+
+	"github.com/aarondl/jsonware"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler converts h, typically a JSONHandler, into a gin.HandlerFunc.
+func Handler(h http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.ServeHTTP(c.Writer, jsonware.WithPathParams(c.Request, c.Param))
+	}
+}
@@ -0,0 +1,38 @@
+package jsonware
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+var errGone = errors.New("resource has been deleted")
+
+// Tombstone is what's left of a deleted resource, sent to clients that ask
+// for it after it's gone.
+type Tombstone struct {
+	DeletedAt time.Time `json:"deleted_at"`
+	// SupersededBy is the url of the resource that replaced the deleted one,
+	// if there is one.
+	SupersededBy string `json:"superseded_by,omitempty"`
+}
+
+/*
+Gone is the error handlers return for resources that have been deleted, so
+that clients of every service can tell a deleted resource from one that never
+existed. It's sent as a 410 Gone with the tombstone as the error's reason.
+
+	if order.DeletedAt != nil {
+		return nil, jsonware.Gone(jsonware.Tombstone{
+			DeletedAt:    *order.DeletedAt,
+			SupersededBy: "/orders/" + order.ReplacedBy,
+		})
+	}
+
+Responds with:
+
+	{"error":"resource has been deleted","reason":{"deleted_at":"2024-05-01T10:00:00Z","superseded_by":"/orders/42"}}
+*/
+func Gone(info Tombstone) error {
+	return Err{Status: http.StatusGone, Err: errGone, Reason: info}
+}
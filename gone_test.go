@@ -0,0 +1,47 @@
+package jsonware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGone(t *testing.T) {
+	t.Parallel()
+
+	deleted := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		info Tombstone
+		want string
+	}{
+		{Tombstone{DeletedAt: deleted}, `{"error":"resource has been deleted","reason":{"deleted_at":"2024-05-01T10:00:00Z"}}`},
+		{Tombstone{DeletedAt: deleted, SupersededBy: "/orders/42"}, `{"error":"resource has been deleted","reason":{"deleted_at":"2024-05-01T10:00:00Z","superseded_by":"/orders/42"}}`},
+	}
+
+	for i, test := range tests {
+		info := test.info
+		h := Handler(func(r *http.Request) (*testType, error) {
+			return nil, Gone(info)
+		})
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/orders/41", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusGone {
+			t.Errorf("Test %d: expected status %d, got: %d", i, http.StatusGone, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+
+	if !errors.Is(Gone(Tombstone{}), errGone) {
+		t.Error("expected Gone to wrap errGone")
+	}
+}
@@ -0,0 +1,108 @@
+package jsonware
+
+import (
+	"io"
+	"net/http"
+)
+
+/*
+BeforeDecode adds a hook that's given the body of a request before it's
+decoded, and returns the reader the request object is decoded from in its
+place. It lets integrations unwrap envelopes, like the notifications of a
+push service, so the handler can take the message inside as its request
+object. Hooks run in the order they were added, after the body has been
+decompressed and transcoded to utf-8.
+
+Errors are reported like the handler's own, so hooks should return an Err
+with a 400 for bodies that aren't what they expect.
+
+	h.BeforeDecode(func(r *http.Request, body io.Reader) (io.Reader, error) {
+		var push struct {
+			Message struct {
+				Data []byte `json:"data"`
+			} `json:"message"`
+		}
+		if err := json.NewDecoder(body).Decode(&push); err != nil {
+			return nil, jsonware.Err{Status: http.StatusBadRequest, Err: err}
+		}
+		return bytes.NewReader(push.Message.Data), nil
+	})
+*/
+func (j *JSONHandler) BeforeDecode(hook func(r *http.Request, body io.Reader) (io.Reader, error)) *JSONHandler {
+	j.beforeDecode = append(j.beforeDecode, hook)
+	return j
+}
+
+// runBeforeDecode returns a copy of the request whose body is what the
+// BeforeDecode hooks return.
+func (j JSONHandler) runBeforeDecode(r *http.Request) (*http.Request, error) {
+	if len(j.beforeDecode) == 0 || r.Body == nil || r.Body == http.NoBody {
+		return r, nil
+	}
+
+	var body io.Reader = r.Body
+	for _, hook := range j.beforeDecode {
+		var err error
+		if body, err = hook(r, body); err != nil {
+			return nil, err
+		}
+	}
+	r2 := *r
+	r2.Body = hookedBody{Reader: body, orig: r.Body}
+	return &r2, nil
+}
+
+// hookedBody is a body replaced by BeforeDecode hooks, closing it closes the
+// original.
+type hookedBody struct {
+	io.Reader
+	orig io.Closer
+}
+
+func (h hookedBody) Close() error {
+	return h.orig.Close()
+}
+
+/*
+BeforeEncode adds a hook that's given the value a handler returned before
+it's encoded, and returns the value to encode in its place. Hooks run in the
+order they were added, each given what the one before returned. The
+StatusCoder, SelfURLer and CookieSetter of the handler's own value still
+decide the status and headers of the response.
+
+	h.BeforeEncode(func(r *http.Request, v interface{}) interface{} {
+		if u, ok := v.(*User); ok {
+			redacted := *u
+			redacted.PasswordHash = ""
+			return &redacted
+		}
+		return v
+	})
+
+Streamed responses aren't passed to encode hooks.
+*/
+func (j *JSONHandler) BeforeEncode(hook func(r *http.Request, v interface{}) interface{}) *JSONHandler {
+	j.beforeEncode = append(j.beforeEncode, hook)
+	return j
+}
+
+/*
+AfterEncode adds a hook that's given the encoded body of a response, along
+with the value it was encoded from, and returns the body to send in its
+place. Hooks run in the order they were added, after the BeforeEncode hooks
+and before the response is checked, signed or digested.
+
+	h.AfterEncode(func(r *http.Request, v interface{}, body []byte) []byte {
+		var obj map[string]interface{}
+		if json.Unmarshal(body, &obj) != nil {
+			return body
+		}
+		obj["server_time"] = time.Now().Unix()
+		b, _ := json.Marshal(obj)
+		return b
+	})
+*/
+func (j *JSONHandler) AfterEncode(hook func(r *http.Request, v interface{}, body []byte) []byte) *JSONHandler {
+	j.afterEncode = append(j.afterEncode, hook)
+	return j
+}
@@ -0,0 +1,149 @@
+package jsonware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type hookedType struct {
+	Name   string `json:"name"`
+	Secret string `json:"secret,omitempty"`
+}
+
+func (h *hookedType) StatusCode() int { return http.StatusAccepted }
+
+func TestEncodeHooks(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		before []func(r *http.Request, v interface{}) interface{}
+		after  []func(r *http.Request, v interface{}, body []byte) []byte
+		want   string
+	}{
+		{nil, nil, `{"name":"a","secret":"s"}`},
+		{
+			[]func(r *http.Request, v interface{}) interface{}{
+				func(r *http.Request, v interface{}) interface{} {
+					h := *v.(*hookedType)
+					h.Secret = ""
+					return &h
+				},
+			},
+			nil,
+			`{"name":"a"}`,
+		},
+		{
+			[]func(r *http.Request, v interface{}) interface{}{
+				func(r *http.Request, v interface{}) interface{} {
+					return map[string]interface{}{"data": v, "path": r.URL.Path}
+				},
+			},
+			[]func(r *http.Request, v interface{}, body []byte) []byte{
+				func(r *http.Request, v interface{}, body []byte) []byte {
+					if _, ok := v.(map[string]interface{}); !ok {
+						t.Errorf("expected the hooked value, got: %T", v)
+					}
+					return bytes.TrimSpace(body)
+				},
+				func(r *http.Request, v interface{}, body []byte) []byte {
+					return append(body[:len(body)-1], `,"server_time":1}`...)
+				},
+			},
+			`{"data":{"name":"a","secret":"s"},"path":"/hooks","server_time":1}`,
+		},
+	}
+
+	for i, test := range tests {
+		h := Handler(func(r *http.Request) *hookedType { return &hookedType{Name: "a", Secret: "s"} })
+		for _, hook := range test.before {
+			h.BeforeEncode(hook)
+		}
+		for _, hook := range test.after {
+			h.AfterEncode(hook)
+		}
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/hooks", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusAccepted {
+			t.Errorf("Test %d: expected status 202, got: %d", i, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
+
+func TestBeforeDecode(t *testing.T) {
+	t.Parallel()
+
+	unwrap := func(r *http.Request, body io.Reader) (io.Reader, error) {
+		var envelope struct {
+			Message json.RawMessage `json:"message"`
+		}
+		if err := json.NewDecoder(body).Decode(&envelope); err != nil || envelope.Message == nil {
+			return nil, Err{Status: http.StatusBadRequest, Err: errors.New("not an envelope")}
+		}
+		return bytes.NewReader(envelope.Message), nil
+	}
+
+	var tests = []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{`{"message":{"name":"a"}}`, 200, `{"name":"a"}`},
+		{`{"message":{"message":{"name":"b"}}}`, 200, `{"name":"b"}`},
+		{`{"name":"c"}`, 400, `{"error":"not an envelope"}`},
+	}
+
+	for i, test := range tests {
+		h := Handler(func(r *http.Request, in *testType) *testType { return in }).BeforeDecode(unwrap)
+		if i == 1 {
+			h.BeforeDecode(unwrap)
+		}
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
+
+func TestBeforeDecodeCopies(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(func(r *http.Request, in *testType) *testType { return in }).
+		BeforeDecode(func(r *http.Request, body io.Reader) (io.Reader, error) {
+			return strings.NewReader(`{"name":"hooked"}`), nil
+		})
+
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"name":"a"}`))
+	orig := req.Body
+
+	r, err := h.runBeforeDecode(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Body != orig {
+		t.Error("expected the caller's request body to be left alone")
+	}
+	if b, _ := io.ReadAll(r.Body); string(b) != `{"name":"hooked"}` {
+		t.Errorf("expected the hooked body, got: %s", b)
+	}
+}
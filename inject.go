@@ -0,0 +1,116 @@
+package jsonware
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+var providers = make(map[reflect.Type]reflect.Value)
+
+/*
+Provide registers value to be passed to every handler that declares an
+argument of value's type. This lets handlers take their dependencies as
+arguments instead of closing over global singletons, which makes them
+trivially testable by calling them with fakes.
+
+Provided arguments are resolved when Handler is called, so values must be
+provided before the handlers that need them are created. Not safe for use by
+multiple goroutines, do this before your http server has been started.
+
+	jsonware.Provide(userService)
+
+	func createUser(w http.ResponseWriter, r *http.Request, u *User, users *UserService) (*User, error) {
+		return users.Create(u)
+	}
+
+	http.Handle("/users", jsonware.Handler(createUser))
+*/
+func Provide(value interface{}) {
+	if value == nil {
+		panic("Cannot provide nil, use ProvideAs to provide a nil of a specific type")
+	}
+	providers[reflect.TypeOf(value)] = reflect.ValueOf(value)
+}
+
+/*
+ProvideAs registers value to be passed to every handler that declares an
+argument of the type pointed to by typ. It's used to provide interfaces, which
+Provide cannot infer from a value.
+
+	jsonware.ProvideAs((*UserStore)(nil), postgresStore)
+*/
+func ProvideAs(typ interface{}, value interface{}) {
+	t := reflect.TypeOf(typ)
+	if t == nil || t.Kind() != reflect.Ptr {
+		panic("ProvideAs requires a pointer to the provided type, eg. (*MyInterface)(nil)")
+	}
+	t = t.Elem()
+
+	if value == nil {
+		providers[t] = reflect.Zero(t)
+		return
+	}
+
+	v := reflect.ValueOf(value)
+	if !v.Type().AssignableTo(t) {
+		panic(fmt.Sprintf("Cannot provide %s as %s", v.Type(), t))
+	}
+
+	// Store the value as the provided type so that it's passed as exactly
+	// that type to handlers.
+	pv := reflect.New(t).Elem()
+	pv.Set(v)
+	providers[t] = pv
+}
+
+var extractors = make(map[reflect.Type]reflect.Value)
+
+/*
+RegisterExtractor registers a function that produces a value from each
+request, such as the current user or tenant, to be passed to every handler
+that declares an argument of the type it returns. fn must have the form:
+
+	func(r *http.Request) (T, error)
+
+Extractors run before the request body is deserialized. If one fails the
+handler is not called and the client gets a 401 Unauthorized with the
+extractor's error message, unless the extractor returned an Err in which case
+that is used as is.
+
+	jsonware.RegisterExtractor(func(r *http.Request) (*User, error) {
+		return sessions.User(r)
+	})
+
+	func getProfile(w http.ResponseWriter, r *http.Request, user *User) (*Profile, error) {
+		return user.Profile, nil
+	}
+
+Extractors are resolved when Handler is called, so they must be registered
+before the handlers that need them are created. Not safe for use by multiple
+goroutines, do this before your http server has been started.
+*/
+func RegisterExtractor(fn interface{}) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		panic("Can only register functions as extractors.")
+	}
+
+	typ := v.Type()
+	if typ.NumIn() != 1 || "*http.Request" != typ.In(0).String() {
+		panic("Extractor must have a single *http.Request argument")
+	}
+	if typ.NumOut() != 2 || "error" != typ.Out(1).String() {
+		panic("Extractor must have two returns: value, error")
+	}
+
+	extractors[typ.Out(0)] = v
+}
+
+// extractErr turns an extractor's error into the error sent to the client.
+func extractErr(err error) error {
+	if e, ok := asErr(err); ok {
+		return e
+	}
+	return Err{Status: http.StatusUnauthorized, Err: err}
+}
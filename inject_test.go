@@ -0,0 +1,187 @@
+package jsonware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type greeter struct {
+	greeting string
+}
+
+type namer interface {
+	Name() string
+}
+
+type fixedNamer string
+
+func (f fixedNamer) Name() string { return string(f) }
+
+func init() {
+	// Providers must be registered before handlers are created, which in
+	// tests means before any test runs.
+	Provide(&greeter{greeting: "hello"})
+	ProvideAs((*namer)(nil), fixedNamer("world"))
+}
+
+func injectHandler1(w http.ResponseWriter, r *http.Request, g *greeter, n namer) (*testType, error) {
+	return &testType{Name: g.greeting + " " + n.Name()}, nil
+}
+
+func injectHandler2(w http.ResponseWriter, r *http.Request, n namer, t *testType, g *greeter) (*testType, error) {
+	return &testType{Name: g.greeting + " " + t.Name + " and " + n.Name()}, nil
+}
+
+func TestProvide(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		handler interface{}
+		method  string
+		reqbody string
+		resbody string
+	}{
+		{injectHandler1, "GET", "", `{"name":"hello world"}`},
+		{injectHandler2, "POST", `{"name":"bob"}`, `{"name":"hello bob and world"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/", bytes.NewBufferString(test.reqbody))
+		req.Header = http.Header{"Accept": []string{"*/*"}}
+
+		Handler(test.handler).ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", http.StatusOK, res.Code)
+		}
+
+		if b := strings.TrimSpace(res.Body.String()); b != test.resbody {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+	}
+}
+
+func TestProvidePanics(t *testing.T) {
+	t.Parallel()
+
+	var tests = []func(){
+		func() { Provide(nil) },
+		func() { ProvideAs(5, 5) },
+		func() { ProvideAs((*namer)(nil), 5) },
+		// Two bodies, neither provided.
+		func() {
+			Handler(func(w http.ResponseWriter, r *http.Request, a *testType, b *testType) (interface{}, error) {
+				return nil, nil
+			})
+		},
+		// Not provided and can't be a body.
+		func() {
+			Handler(func(w http.ResponseWriter, r *http.Request, g greeter) (interface{}, error) {
+				return nil, nil
+			})
+		},
+	}
+
+	for i, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Test %d: expected a panic", i)
+				}
+			}()
+			test()
+		}()
+	}
+}
+
+type extractedUser struct {
+	name string
+}
+
+type extractedTenant string
+
+func init() {
+	RegisterExtractor(func(r *http.Request) (*extractedUser, error) {
+		name := r.Header.Get("X-User")
+		if name == "" {
+			return nil, errors.New("no user")
+		}
+		return &extractedUser{name: name}, nil
+	})
+	RegisterExtractor(func(r *http.Request) (extractedTenant, error) {
+		if r.Header.Get("X-Tenant") == "banned" {
+			return "", Err{Status: http.StatusForbidden, Err: errors.New("tenant is banned")}
+		}
+		return extractedTenant(r.Header.Get("X-Tenant")), nil
+	})
+}
+
+func extractHandler(w http.ResponseWriter, r *http.Request, t *testType, u *extractedUser, tenant extractedTenant) (*testType, error) {
+	return &testType{Name: t.Name + " " + u.name + "@" + string(tenant)}, nil
+}
+
+func TestRegisterExtractor(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		user    string
+		tenant  string
+		status  int
+		resbody string
+	}{
+		{"bob", "acme", 200, `{"name":"hi bob@acme"}`},
+		{"", "acme", 401, `{"error":"no user"}`},
+		{"bob", "banned", 403, `{"error":"tenant is banned"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", bytes.NewBufferString(`{"name":"hi"}`))
+		req.Header = http.Header{
+			"Accept":   []string{"*/*"},
+			"X-User":   []string{test.user},
+			"X-Tenant": []string{test.tenant},
+		}
+
+		Handler(extractHandler).ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, res.Code)
+		}
+
+		if b := strings.TrimSpace(res.Body.String()); b != test.resbody {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+	}
+}
+
+func TestRegisterExtractorPanics(t *testing.T) {
+	t.Parallel()
+
+	var tests = []interface{}{
+		5,
+		func() (*testType, error) { return nil, nil },
+		func(r *http.Request) *testType { return nil },
+		func(r *http.Request) (*testType, int) { return nil, 0 },
+	}
+
+	for i, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Test %d: expected a panic", i)
+				}
+			}()
+			RegisterExtractor(test)
+		}()
+	}
+}
@@ -0,0 +1,118 @@
+package jsonware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+var itemsType = reflect.TypeOf((*Items)(nil))
+
+/*
+Items is a request body that is a json array, decoded one element at a time
+as the handler asks for them instead of all at once. This lets handlers
+process huge bulk uploads without holding them in memory. A handler receives
+one by declaring an *Items argument in place of a request object.
+
+	func importUsers(r *http.Request, items *jsonware.Items) (*Summary, error) {
+		var n int
+		for {
+			var u User
+			if !items.Next(&u) {
+				break
+			}
+			if err := db.Insert(&u); err != nil {
+				return nil, err
+			}
+			n++
+		}
+		if err := items.Err(); err != nil {
+			return nil, err
+		}
+		return &Summary{Imported: n}, nil
+	}
+
+Contracts are not checked against streamed request bodies.
+*/
+type Items struct {
+	dec *json.Decoder
+	n   int
+
+	started bool
+	done    bool
+	err     error
+}
+
+func newItems(body io.Reader) *Items {
+	return &Items{dec: json.NewDecoder(body)}
+}
+
+// Next decodes the next element of the array into v, it returns false when
+// there are no more elements or something went wrong, see Err. Elements that
+// are Validators are validated.
+func (it *Items) Next(v interface{}) bool {
+	if it.done || it.err != nil {
+		return false
+	}
+
+	if !it.started {
+		it.started = true
+		if tok, err := it.dec.Token(); err != nil || tok != json.Delim('[') {
+			it.err = Err{
+				Status: http.StatusBadRequest,
+				Err:    fmt.Errorf("could not deserialize json request body"),
+			}
+			return false
+		}
+	}
+
+	if !it.dec.More() {
+		it.done = true
+		if _, err := it.dec.Token(); err != nil {
+			it.err = Err{
+				Status: http.StatusBadRequest,
+				Err:    fmt.Errorf("could not deserialize json request body"),
+			}
+		}
+		return false
+	}
+
+	if err := it.dec.Decode(v); isBodyTooLarge(err) {
+		it.err = Err{Status: http.StatusRequestEntityTooLarge, Err: err}
+		return false
+	} else if err != nil {
+		it.err = Err{
+			Status: http.StatusBadRequest,
+			Err:    fmt.Errorf("could not deserialize item %d of json request body", it.n),
+		}
+		return false
+	}
+
+	if val, ok := v.(Validator); ok {
+		if err := val.Validate(); err != nil {
+			err = invalidErr(err)
+			if e, ok := err.(Err); ok {
+				e.Err = fmt.Errorf("item %d: %v", it.n, e.Err)
+				err = e
+			}
+			it.err = err
+			return false
+		}
+	}
+
+	it.n++
+	return true
+}
+
+// Err returns the error that stopped Next, if any. It's an Err describing
+// the problem to the client so it can be returned from the handler as is.
+func (it *Items) Err() error {
+	return it.err
+}
+
+// Count returns how many elements have been decoded so far.
+func (it *Items) Count() int {
+	return it.n
+}
@@ -0,0 +1,62 @@
+package jsonware
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func itemsHandler(r *http.Request, items *Items) (*testType, error) {
+	var names []string
+	for {
+		var v validType
+		if !items.Next(&v) {
+			break
+		}
+		names = append(names, v.Name)
+	}
+	if err := items.Err(); err != nil {
+		return nil, err
+	}
+	return &testType{Name: fmt.Sprintf("%d:%s", items.Count(), strings.Join(names, ","))}, nil
+}
+
+func TestItems(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		method  string
+		reqbody string
+		status  int
+		resbody string
+	}{
+		{"POST", `[{"name":"a"},{"name":"b"},{"name":"c"}]`, 200, `{"name":"3:a,b,c"}`},
+		{"POST", `[]`, 200, `{"name":"0:"}`},
+		{"POST", `{"name":"a"}`, 400, `{"error":"could not deserialize json request body"}`},
+		{"POST", `[{"name":"a"},{"name":5}]`, 400, `{"error":"could not deserialize item 1 of json request body"}`},
+		{"POST", `[{"name":"a"},{"name":""}]`, 422, `{"error":"item 1: name is required"}`},
+		{"POST", `[{"name":"a"}`, 400, `{"error":"could not deserialize item 1 of json request body"}`},
+		{"GET", ``, 400, `{"error":"invalid http method to this endpoint: GET"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/", bytes.NewBufferString(test.reqbody))
+		req.Header = http.Header{"Accept": []string{"*/*"}}
+
+		Handler(itemsHandler).ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, res.Code)
+		}
+
+		if b := strings.TrimSpace(res.Body.String()); b != test.resbody {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+	}
+}
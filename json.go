@@ -8,11 +8,14 @@ package jsonware
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var globalLogger io.Writer
@@ -39,9 +42,77 @@ on each individual handler as an override by the .Log() function there.
 	http.Handle("/", Handler(myHandler).Log(myLogger))
 */
 type JSONHandler struct {
-	logger io.Writer
-	fn     reflect.Value
-	in     reflect.Type
+	logger  io.Writer
+	fn      reflect.Value
+	args    []handlerArg
+	in      reflect.Type
+	out     reflect.Type
+	errs    bool
+	paths   []pathField
+	cookies []pathField
+	rules   *fieldRules
+	// fieldTypes is set when the request object has UUID, URL, Duration or
+	// Bytes fields.
+	fieldTypes bool
+	// uploads is set when the handler takes uploaded files.
+	uploads      bool
+	uploadLimits *UploadLimits
+
+	stream        streamKind
+	flushItems    int
+	flushInterval time.Duration
+	heartbeat     time.Duration
+
+	digest   string
+	jws      *JWS
+	codec    *Codec
+	encoders *adaptiveEncoders
+
+	strictMethods bool
+
+	allowClients []string
+	scopes       []string
+	roles        []string
+	policy       Policy
+	inputPolicy  func(r *http.Request, s Subject, in interface{}) (bool, error)
+	filters      []func(r *http.Request, s Subject, out interface{}) (interface{}, error)
+	basicRealm   string
+	basicVerify  func(user, pass string) bool
+
+	group         string
+	flag          string
+	flagProvider  FlagProvider
+	flagOffStatus int
+
+	requestSchema  *Schema
+	responseSchema *Schema
+	responseCheck  *ResponseCheck
+
+	beforeDecode []func(r *http.Request, body io.Reader) (io.Reader, error)
+	beforeEncode []func(r *http.Request, v interface{}) interface{}
+	afterEncode  []func(r *http.Request, v interface{}, body []byte) []byte
+	emitter      *Emitter
+
+	breaker *breaker
+
+	responseLimit *responseLimit
+	chunking      *Chunking
+
+	watchdog     time.Duration
+	version      func(r *http.Request) (string, error)
+	etags        *etagStore
+	cacheControl string
+
+	view       string
+	viewParam  string
+	sortable   fieldRoles
+	filterable fieldRoles
+
+	selectable    bool
+	maxFieldDepth int
+	maxFieldCost  int
+
+	countBytes func(r *http.Request, read, written int64)
 }
 
 // Log sets the JSONHandler's logging io.Writer for writing out cloaked errors.
@@ -50,6 +121,17 @@ func (j *JSONHandler) Log(logger io.Writer) *JSONHandler {
 	return j
 }
 
+/*
+StrictMethods makes the JSONHandler reject requests made with the wrong http
+method with a 405 Method Not Allowed and an Allow header listing the methods
+it does take, instead of the default 400 Bad Request. Clients that decide
+whether to retry by the class of the status treat a 405 correctly.
+*/
+func (j *JSONHandler) StrictMethods() *JSONHandler {
+	j.strictMethods = true
+	return j
+}
+
 /*
 Err can be used in a JSONHandler to override the error mechanism in
 JSONHandler's ServeHTTP method. If a status is set it will obey it,
@@ -92,11 +174,99 @@ func (e Err) Error() string {
 	return e.Err.Error()
 }
 
+// Unwrap returns the internal error.
+func (e Err) Unwrap() error {
+	return e.Err
+}
+
+/*
+StatusCoder is implemented by errors and responses that know which http
+status they should be sent with, as errors from many libraries already do.
+
+When a handler returns an error that is a StatusCoder, a 4xx status is sent
+along with the error's message like an Err would be, and a 5xx status is sent
+with the usual cloaked error. When a handler's response is a StatusCoder it's
+sent with that status instead of 200 OK.
+*/
+type StatusCoder interface {
+	StatusCode() int
+}
+
+/*
+SelfURLer is implemented by resources that know their own url. When a POST
+handler responds with one it's sent as 201 Created with a Location header
+pointing at the resource, which is the convention for creating resources.
+Created does the same for responses that aren't SelfURLers.
+
+	func (o *Order) SelfURL() string { return "/orders/" + o.ID }
+*/
+type SelfURLer interface {
+	SelfURL() string
+}
+
+/*
+Created wraps the value a handler responds with to send it as 201 Created
+with a Location header, whatever the method. Only Value is serialized, and it
+goes through the handler's Codec, filters and views like an unwrapped value.
+
+	return &jsonware.Created{Value: job, Location: "/jobs/" + job.ID}, nil
+*/
+type Created struct {
+	Value    interface{}
+	Location string
+}
+
+// StatusCode is always 201 Created.
+func (c Created) StatusCode() int { return http.StatusCreated }
+
+// SelfURL is the Location.
+func (c Created) SelfURL() string { return c.Location }
+
+// MarshalJSON serializes the wrapped value.
+func (c Created) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Value)
+}
+
+func (c Created) envelope() (interface{}, http.Header, int) {
+	var header http.Header
+	if len(c.Location) != 0 {
+		header = http.Header{"Location": {c.Location}}
+	}
+	return c.Value, header, http.StatusCreated
+}
+
+/*
+Validator is implemented by request objects that can check themselves once
+they've been deserialized. The handler is only called if Validate returns
+nil, otherwise the client gets a 422 Unprocessable Entity with the error's
+message, unless it's an Err or MultiErr in which case that is used as is.
+*/
+type Validator interface {
+	Validate() error
+}
+
+// invalidErr turns an error from a Validator into the error sent to the
+// client.
+func invalidErr(err error) error {
+	switch e := err.(type) {
+	case Err, MultiErr:
+		return e
+	}
+	return Err{Status: http.StatusUnprocessableEntity, Err: err}
+}
+
 // ServeHTTP serves an http response, see JSONHandler documentation for details.
 func (j JSONHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	j.logger = tagLogger(r, j.logger)
+	if j.countBytes != nil {
+		var report func()
+		w, r, report = j.counted(w, r)
+		defer report()
+	}
+
 	// Ensure request accepts json
 	ah := r.Header.Get("Accept")
-	if !strings.Contains(ah, "*/*") && !strings.Contains(ah, "application/json") {
+	if !strings.Contains(ah, "*/*") && !strings.Contains(ah, "application/json") && (j.stream == streamNone || !acceptsStream(ah)) {
 		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(http.StatusBadRequest)
 		io.WriteString(w, "this endpoint only responds to json-accepting clients")
@@ -105,40 +275,251 @@ func (j JSONHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
+	if m := currentMaintenance(j.group); m != nil {
+		writeError(w, j.logger, maintenanceErr(w, m, time.Now()))
+		return
+	}
+	cfg, configured := groupConfig(j.group)
+	if configured {
+		r, j.logger = cfg.apply(r, j.logger)
+	}
+
+	// HEAD requests are served like GET requests without the body.
+	if r.Method == http.MethodHead {
+		w = headWriter{w}
+	}
+
+	r = withCertPrincipal(r)
+	if len(j.allowClients) != 0 {
+		if err := j.checkClient(r); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+	}
+	if j.basicVerify != nil {
+		if err := j.checkBasicAuth(w, r); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+	}
+	if len(j.scopes) != 0 && j.policy == nil && DefaultPolicy == nil {
+		if err := j.checkScopes(r); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+	}
+	if j.authorizes() {
+		if err := j.authorize(r); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+	}
+	if len(j.flag) != 0 {
+		if err := j.checkFlag(r, cfg); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+	}
+
 	// Ensure request follows REST principles.
-	deserialize := j.fn.Type().NumIn() == 3
+	// Request objects that are bound to the path can be used without a body.
+	// Uploads are multipart and need a body too.
+	deserialize := j.in != nil && j.in != itemsType && isDataMethod(r.Method) && !j.uploads
 	switch {
-	case deserialize && !isDataMethod(r.Method):
+	case j.uploads && !isDataMethod(r.Method):
 		fallthrough
-	case !deserialize && isDataMethod(r.Method):
+	case j.in != nil && !isDataMethod(r.Method) && len(j.paths) == 0 && len(j.cookies) == 0:
+		fallthrough
+	case j.in == nil && !j.uploads && isDataMethod(r.Method):
+		status := http.StatusBadRequest
+		if j.strictMethods {
+			status = http.StatusMethodNotAllowed
+			w.Header().Set("Allow", j.allowedMethods())
+		}
 		writeError(w, j.logger, Err{
-			Status: http.StatusBadRequest,
+			Status: status,
 			Err:    fmt.Errorf("invalid http method to this endpoint: %s", r.Method),
 		})
 		return
 	}
 
-	// Set up arguments for handler call.
-	in := []reflect.Value{
-		reflect.ValueOf(w), reflect.ValueOf(r),
+	if len(j.viewParam) != 0 {
+		if err := j.checkView(r); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+	}
+
+	if j.sortable != nil {
+		if err := j.checkSort(r); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+	}
+
+	if j.filterable != nil {
+		var err error
+		if r, err = j.checkFilters(r); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
 	}
+
+	if j.selectable {
+		if err := j.checkFields(r); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+	}
+
+	if j.version != nil {
+		current, err := j.checkVersion(w, r)
+		if err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+		if current {
+			return
+		}
+	} else if j.etags != nil && j.checkStoredETag(w, r) {
+		return
+	}
+
+	// handlerErr is the error the handler returned, if it was called.
+	var handlerErr error
+	var called bool
+	if j.breaker != nil {
+		var done func(bool, error)
+		if r, done = j.breaker.guard(w, r, j.logger); r == nil {
+			return
+		}
+		defer func() {
+			if p := recover(); p != nil {
+				done(true, errPanicked)
+				panic(p)
+			}
+			done(called, handlerErr)
+		}()
+	}
+
+	if isDataMethod(r.Method) {
+		var err error
+		if r, err = verifyDigest(r); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+		if r, err = decompressBody(r); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+		if r, err = verifyReprDigest(r); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+		if r, err = decodeCharset(r); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+		if r, err = j.runBeforeDecode(r); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+	}
+
+	// Set up arguments for handler call.
+	in := make([]reflect.Value, len(j.args))
 	var deserializeTo reflect.Value
-	if deserialize {
-		switch j.in.Kind() {
-		case reflect.Slice, reflect.Map:
-			deserializeTo = reflect.New(j.in)
-			in = append(in, deserializeTo.Elem())
-		case reflect.Ptr:
-			deserializeTo = reflect.New(j.in.Elem())
-			in = append(in, deserializeTo)
+	filesArg := -1
+	for i, a := range j.args {
+		switch a.kind {
+		case argWriter:
+			in[i] = reflect.ValueOf(w)
+		case argRequest:
+			in[i] = reflect.ValueOf(r)
+		case argContext:
+			in[i] = reflect.ValueOf(r.Context())
+		case argBody:
+			if j.in == itemsType {
+				in[i] = reflect.ValueOf(newItems(r.Body))
+				continue
+			}
+			deserializeTo, in[i] = allocate(j.in)
+		case argFiles:
+			filesArg = i
+		case argProvided:
+			in[i] = a.value
+		case argExtracted:
+			out := a.value.Call([]reflect.Value{reflect.ValueOf(r)})
+			if err, _ := out[1].Interface().(error); err != nil {
+				writeError(w, j.logger, extractErr(err))
+				return
+			}
+			in[i] = out[0]
+		}
+	}
+
+	if j.uploads {
+		files, err := j.readMultipart(r, deserializeTo)
+		defer removeFiles(files, j.logger)
+		if err != nil {
+			writeError(w, j.logger, err)
+			return
 		}
+		in[filesArg] = reflect.ValueOf(files)
 	}
 
 	// Do json deserialization of body.
 	if deserialize {
-		dec := json.NewDecoder(r.Body)
+		var body io.Reader = r.Body
+		if j.requestSchema != nil {
+			raw, err := io.ReadAll(r.Body)
+			if isBodyTooLarge(err) {
+				writeError(w, j.logger, Err{Status: http.StatusRequestEntityTooLarge, Err: err})
+				return
+			} else if err != nil {
+				writeError(w, j.logger, Err{
+					Status: http.StatusBadRequest,
+					Err:    fmt.Errorf("could not read request body"),
+				})
+				return
+			}
+			// Malformed json is the client's fault, leave it for the
+			// decoder to report.
+			if json.Valid(raw) {
+				if err = j.requestSchema.Validate(raw); err != nil {
+					writeError(w, j.logger, fmt.Errorf("request violates contract: %v", err))
+					return
+				}
+			}
+			body = bytes.NewReader(raw)
+		}
+
+		// The body is kept around to find the field that failed to parse
+		// should one of the field types fail.
+		var seen *bytes.Buffer
+		if j.fieldTypes {
+			seen = &bytes.Buffer{}
+			body = io.TeeReader(body, seen)
+		}
+		dec := json.NewDecoder(body)
 
-		if err := dec.Decode(deserializeTo.Interface()); err != nil {
+		var perr *fieldParseError
+		if err := j.jsonCodec().decode(dec, deserializeTo.Interface()); isBodyTooLarge(err) {
+			writeError(w, j.logger, Err{Status: http.StatusRequestEntityTooLarge, Err: err})
+			return
+		} else if seen != nil && errors.As(err, &perr) {
+			io.Copy(io.Discard, body)
+			status := http.StatusBadRequest
+			if perr.status != 0 {
+				status = perr.status
+			}
+			e := Err{Status: status, Err: perr}
+			if field, ok := locateField(seen.Bytes(), j.in, j.jsonCodec()); ok {
+				e = Err{Status: status, Err: fmt.Errorf("invalid field %s", field), Reason: perr.Error()}
+			}
+			writeError(w, j.logger, e)
+			return
+		} else if err != nil {
 			writeError(w, j.logger, Err{
 				Status: http.StatusBadRequest,
 				Err:    fmt.Errorf("could not deserialize json request body"),
@@ -148,69 +529,385 @@ func (j JSONHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		r.Body.Close()
 	}
 
+	if len(j.paths) != 0 {
+		if err := bindPath(r, deserializeTo, j.paths); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+	}
+	if len(j.cookies) != 0 {
+		if err := bindCookies(r, deserializeTo, j.cookies); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+	}
+
+	if j.rules != nil {
+		if err := j.rules.apply(deserializeTo, j.jsonCodec()); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+	}
+
+	if deserializeTo.IsValid() {
+		if v, ok := deserializeTo.Interface().(Validator); ok {
+			if err := v.Validate(); err != nil {
+				writeError(w, j.logger, invalidErr(err))
+				return
+			}
+		}
+	}
+
+	if j.inputPolicy != nil {
+		if err := j.authorizeInput(r, deserializeTo); err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+	}
+
+	capture := captureFrom(r.Context())
+	if capture != nil {
+		capture.called = time.Now()
+	}
+	if j.watchdog > 0 {
+		defer j.watch(r)()
+	}
+	called = true
 	out := j.fn.Call(in)
+	if capture != nil {
+		capture.returned = time.Now()
+	}
 
 	// Handle error return value
-	if !out[1].IsNil() {
-		writeError(w, j.logger, out[1].Interface().(error))
+	if j.errs {
+		if errOut := out[len(out)-1]; !errOut.IsNil() {
+			handlerErr = errOut.Interface().(error)
+			writeError(w, j.logger, handlerErr)
+			return
+		}
+	}
+
+	if j.etags != nil && (isDataMethod(r.Method) || r.Method == http.MethodDelete) {
+		j.invalidateETags(r)
+	}
+
+	// Handlers that only return an error write their own responses.
+	if j.out == nil {
+		return
+	}
+
+	if j.stream != streamNone {
+		j.writeStream(w, r, out[0])
 		return
 	}
 
 	// Serialize the interface{} return value
 	if !out[0].IsNil() {
-		enc := json.NewEncoder(w)
-		if err := enc.Encode(out[0].Interface()); err != nil {
+		body, ok := bodyOf(out[0].Interface())
+		if !ok {
+			j.writeHeaders(w, r, out[0].Interface())
+			return
+		}
+		resp, err := j.filterResponse(r, body)
+		if err != nil {
+			writeError(w, j.logger, err)
+			return
+		}
+		if p, ok := resp.(*Partial); ok {
+			p.logErrors(j.logger, r)
+		}
+		for _, hook := range j.beforeEncode {
+			resp = hook(r, resp)
+		}
+
+		var violations []string
+		if j.responseCheck != nil {
+			violations = j.responseCheck.inspect(reflect.ValueOf(resp), j.jsonCodec())
+		}
+
+		if j.chunking != nil && j.chunking.chunks(j, resp) {
+			j.writeHeaders(w, r, out[0].Interface())
+			fw := &flushWriter{rc: http.NewResponseController(w), w: w}
+			if err := encodeTo(fw, resp, j.jsonCodec()); err != nil {
+				logf(j.logger, "failed to send response: %v", err)
+			}
+			if j.chunking.Metrics != nil {
+				j.chunking.Metrics(r, true, fw.written)
+			}
+			return
+		}
+
+		buf := &bytes.Buffer{}
+		if raw, ok := resp.(Raw); ok {
+			buf, err = j.rawBody(raw)
+		} else if j.responseLimit != nil {
+			var truncated bool
+			truncated, err = j.responseLimit.encode(buf, resp, j.jsonCodec())
+			if truncated {
+				logf(j.logger, "response to %s %s is larger than %d bytes, it was truncated", r.Method, r.URL.Path, j.responseLimit.size)
+			}
+			if err == errResponseTooLarge && j.responseLimit.streams(j) {
+				logf(j.logger, "response to %s %s is larger than %d bytes, it was streamed", r.Method, r.URL.Path, j.responseLimit.size)
+				j.writeHeaders(w, r, out[0].Interface())
+				if err := encodeTo(w, resp, j.jsonCodec()); err != nil {
+					logf(j.logger, "failed to send response: %v", err)
+				}
+				return
+			}
+		} else if j.encoders != nil {
+			var b []byte
+			if b, err = j.encoders.marshal(resp); err == nil {
+				buf.Write(b)
+				buf.WriteByte('\n')
+			}
+		} else if c := j.jsonCodec(); c != nil {
+			var b []byte
+			if b, err = c.Marshal(resp); err == nil {
+				buf.Write(b)
+				buf.WriteByte('\n')
+			}
+		} else {
+			err = json.NewEncoder(buf).Encode(resp)
+		}
+		if err != nil {
+			if j.responseCheck != nil {
+				j.responseCheck.report(r, j.logger, violations)
+			}
+			logf(j.logger, "failed to encode response to %s %s: %v", r.Method, r.URL.Path, err)
 			writeError(w, j.logger, Err{
 				Status: http.StatusInternalServerError,
 				Err:    fmt.Errorf("problem preparing response"),
 			})
 			return
 		}
+
+		for _, hook := range j.afterEncode {
+			b := hook(r, resp, buf.Bytes())
+			buf = bytes.NewBuffer(b)
+		}
+
+		if j.responseCheck != nil {
+			if s := j.responseCheck.Schema; s != nil {
+				if err := s.Validate(buf.Bytes()); err != nil {
+					violations = append(violations, err.Error())
+				}
+			}
+			if j.responseCheck.report(r, j.logger, violations) {
+				writeError(w, j.logger, fmt.Errorf("response violates invariants: %s", strings.Join(violations, "; ")))
+				return
+			}
+		}
+
+		if j.responseSchema != nil {
+			if err := j.responseSchema.Validate(buf.Bytes()); err != nil {
+				writeError(w, j.logger, fmt.Errorf("response violates contract: %v", err))
+				return
+			}
+		}
+
+		// Signing can replace the body, the event is what was encoded.
+		var encoded []byte
+		if j.emitter != nil {
+			encoded = append(encoded, bytes.TrimSpace(buf.Bytes())...)
+		}
+
+		if j.jws != nil {
+			token, err := j.jws.sign(buf.Bytes())
+			if err != nil {
+				writeError(w, j.logger, fmt.Errorf("failed to sign response: %v", err))
+				return
+			}
+			if j.jws.Detached {
+				w.Header().Set(JWSHeader, token)
+			} else {
+				w.Header().Set("Content-Type", joseContentType)
+				buf = bytes.NewBufferString(token)
+			}
+		}
+		if len(j.digest) != 0 {
+			w.Header().Set("Content-Digest", digestValue(j.digest, buf.Bytes()))
+		}
+		if j.etags != nil && storesETag(r, out[0].Interface()) && j.storeETag(w, r, buf.Bytes()) {
+			return
+		}
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		}
+		if j.chunking != nil && j.chunking.Metrics != nil {
+			j.chunking.Metrics(r, false, buf.Len())
+		}
+		status := j.writeHeaders(w, r, out[0].Interface())
+		if _, err := buf.WriteTo(w); err != nil {
+			logf(j.logger, "failed to send response: %v", err)
+		}
+
+		mutated := isDataMethod(r.Method) || r.Method == http.MethodDelete
+		if j.emitter != nil && mutated && (status == 0 || status < 400) {
+			j.emit(r, resp, encoded)
+		}
+	}
+}
+
+// writeHeaders sets the headers, cookies, Location and Cache-Control of the
+// response v and writes its status, it returns the status or 0 when it's the
+// default.
+func (j JSONHandler) writeHeaders(w http.ResponseWriter, r *http.Request, v interface{}) int {
+	status := 0
+	if env, ok := v.(envelope); ok {
+		var header http.Header
+		v, header, status = env.envelope()
+		for name, values := range header {
+			for _, value := range values {
+				w.Header().Add(name, value)
+			}
+		}
+	}
+
+	if cs, ok := v.(CookieSetter); ok {
+		for _, c := range cs.Cookies() {
+			http.SetCookie(w, c)
+		}
+	}
+	self, isSelf := v.(SelfURLer)
+	if status == 0 && isSelf && r.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+	if sc, ok := v.(StatusCoder); ok && status == 0 {
+		status = sc.StatusCode()
+	}
+	if len(j.cacheControl) != 0 && status < 400 {
+		w.Header().Set("Cache-Control", j.cacheControl)
+	}
+	if status == http.StatusCreated && isSelf && len(w.Header().Get("Location")) == 0 {
+		if loc := self.SelfURL(); len(loc) != 0 {
+			w.Header().Set("Location", loc)
+		}
+	}
+	if status != 0 {
+		w.WriteHeader(status)
+	}
+	return status
+}
+
+// argKind says where the value for one of a handler's arguments comes from.
+type argKind int
+
+const (
+	argWriter argKind = iota
+	argRequest
+	argContext
+	argBody
+	argFiles
+	argProvided
+	argExtracted
+)
+
+// handlerArg describes how to fill in one of a handler's arguments.
+type handlerArg struct {
+	kind argKind
+	// value is the value of a provided argument, or the extractor function
+	// of an extracted argument.
+	value reflect.Value
+}
+
+// allocate creates storage for deserializing json into typ, which must be a
+// pointer, slice or map. It returns a pointer suitable for passing to a
+// decoder as well as the value to hand to the function that wanted typ.
+func allocate(typ reflect.Type) (ptr reflect.Value, arg reflect.Value) {
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Map:
+		ptr = reflect.New(typ)
+		arg = ptr.Elem()
+	case reflect.Ptr:
+		ptr = reflect.New(typ.Elem())
+		arg = ptr
 	}
+	return ptr, arg
+}
+
+// isResponseType checks that typ can be returned from a handler as the
+// response.
+func isResponseType(typ reflect.Type) bool {
+	return "interface {}" == typ.String() || typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map ||
+		streamKindOf(typ) != streamNone
+}
+
+// allowedMethods lists the http methods the handler takes for the Allow
+// header.
+func (j JSONHandler) allowedMethods() string {
+	if j.uploads || (j.in != nil && len(j.paths) == 0 && len(j.cookies) == 0) {
+		return "POST, PUT, PATCH"
+	}
+	if j.in != nil {
+		return "GET, HEAD, DELETE, POST, PUT, PATCH"
+	}
+	return "GET, HEAD, DELETE"
 }
 
 func isDataMethod(method string) bool {
-	return method != "GET" && method != "DELETE"
+	return method != "GET" && method != "HEAD" && method != "DELETE"
+}
+
+// headWriter throws away the body of a response to a HEAD request.
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (h headWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (h headWriter) Unwrap() http.ResponseWriter { return h.ResponseWriter }
+
+// logf writes to logger, or the global logger if logger is nil.
+func logf(logger io.Writer, format string, args ...interface{}) {
+	if logger != nil {
+		fmt.Fprintf(logger, format, args...)
+	} else if globalLogger != nil {
+		fmt.Fprintf(globalLogger, format, args...)
+	}
 }
 
 // writeError writes an error out to the response.
 func writeError(w http.ResponseWriter, logger io.Writer, err error) {
 	logit := func(format string, args ...interface{}) {
-		if logger != nil {
-			fmt.Fprintf(logger, format, args...)
-		} else if globalLogger != nil {
-			fmt.Fprintf(globalLogger, format, args...)
-		}
+		logf(logger, format, args...)
 	}
+	noteError(w, err)
 
-	switch e := err.(type) {
-	case Err:
-		toJSON := map[string]interface{}{
-			"error": e.Err.Error(),
-		}
-		if e.Reason != nil {
-			toJSON["reason"] = e.Reason
-		}
+	if m, ok := asMultiErr(err); ok {
+		writeMultiErr(w, logger, m)
+		return
+	}
 
-		buf := &bytes.Buffer{}
-		enc := json.NewEncoder(buf)
-		if err = enc.Encode(toJSON); err != nil {
-			logit("failed to serialize err: %v", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			io.WriteString(w, `{"error":"an internal server error occurred"}`)
-			return
-		}
+	e, ok := asErr(err)
+	if !ok {
+		logit("internal error: %s", formatErr(err))
+		w.WriteHeader(cloakedStatus(err))
+		io.WriteString(w, `{"error":"an internal server error occurred"}`)
+		return
+	}
 
-		if e.Status != 0 {
-			w.WriteHeader(e.Status)
-		}
-		if _, err = io.Copy(w, buf); err != nil {
-			logit("failed to send response: %v", err)
-		}
-	default:
-		logit("internal error: %v", err)
+	toJSON := map[string]interface{}{
+		"error": e.Err.Error(),
+	}
+	if e.Reason != nil {
+		toJSON["reason"] = e.Reason
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	if err = enc.Encode(toJSON); err != nil {
+		logit("failed to serialize err: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		io.WriteString(w, `{"error":"an internal server error occurred"}`)
+		return
+	}
+
+	if e.Status != 0 {
+		w.WriteHeader(e.Status)
+	}
+	if _, err = io.Copy(w, buf); err != nil {
+		logit("failed to send response: %v", err)
 	}
 }
 
@@ -218,7 +915,7 @@ func writeError(w http.ResponseWriter, logger io.Writer, err error) {
 Handler changes a function into a JSONHandler.
 Acceptable forms of the input function:
 
-	GET/DELETE (Note: all variant return types also work with POST/PUT/PATCH)
+	GET/HEAD/DELETE (Note: all variant return types also work with POST/PUT/PATCH)
 	func Fn(w http.ResponseWriter, r *http.Request) (interface{}, error)
 	func Fn(w http.ResponseWriter, r *http.Request) (*MyStruct, error)
 	func Fn(w http.ResponseWriter, r *http.Request) ([]*MyStruct, error)
@@ -228,6 +925,67 @@ Acceptable forms of the input function:
 	func Fn(w http.ResponseWriter, r *http.Request, m *MyStruct) (interface{}, error)
 	func Fn(w http.ResponseWriter, r *http.Request, m []*MyStruct) (interface{}, error)
 	func Fn(w http.ResponseWriter, r *http.Request, m map[string]*MyStruct) (interface{}, error)
+
+Handlers that take a []jsonware.File accept multipart/form-data uploads
+instead, with the request object deserialized from the part named metadata.
+See File.
+
+	func Fn(r *http.Request, m *MyStruct, files []jsonware.File) (interface{}, error)
+
+Handlers that write their own responses, for example to stream them, may
+return only an error. jsonware still deserializes their request body and
+writes out their errors but leaves the response alone otherwise.
+
+	func Fn(w http.ResponseWriter, r *http.Request, m *MyStruct) error
+
+Handlers that cannot fail, such as pure lookups, may return only the
+response.
+
+	func Fn(r *http.Request) *MyStruct
+
+HEAD requests are served by running the handler like a GET and sending its
+headers and the Content-Length of its body without the body itself.
+
+The arguments may come in any order, and only the ones the handler needs have
+to be declared, as long as it takes either the *http.Request or a
+context.Context, which is the request's context. Any number of arguments of
+types registered with Provide or RegisterExtractor may also be declared and
+are filled in with the provided or extracted values. See Provide and
+RegisterExtractor.
+
+	func Fn(ctx context.Context, m *MyStruct) *OtherStruct
+
+	func Fn(w http.ResponseWriter, r *http.Request, m *MyStruct, users *UserService) (interface{}, error)
+
+Fields of the request object tagged with default are set to it when they're
+missing, and requests missing fields tagged with required:"true" get a 422
+Unprocessable Entity listing them in its reason. Fields count as missing when
+they're still zero once the body has been deserialized and the path and
+cookies bound, so use a pointer where zero is a valid value. Defaults of
+fields that can be bound to a path parameter are written the same way, for
+everything else they are json. Handler panics if a default can't be
+deserialized into its field.
+
+Fields tagged with enum, a comma separated list of values, must have one of
+them or the request gets a 422 whose reason lists the allowed values of each
+field that doesn't. Enums apply to string and integer fields, and leave zero
+values to required.
+
+Fields tagged with maxlen can have at most that many characters, or elements
+for slices and maps, and fields tagged with maxbytes at most that many bytes,
+counting anything other than a string as json. Requests with longer fields
+get a 422 whose reason says which limit each field broke.
+
+Handler panics if the response type, or the item type of a streaming
+response, has fields that can't be serialized, like channels, functions, maps
+whose keys can't be strings, or structs with no exported fields.
+
+	type CreateUser struct {
+		Name  string   `json:"name" required:"true" maxlen:"64"`
+		Role  string   `json:"role" default:"member" enum:"member,admin"`
+		Limit *int     `json:"limit" default:"10"`
+		Tags  []string `json:"tags" default:"[\"new\"]"`
+	}
 */
 func Handler(fn interface{}) *JSONHandler {
 	typ := reflect.TypeOf(fn)
@@ -235,42 +993,87 @@ func Handler(fn interface{}) *JSONHandler {
 		panic("Can only register functions.")
 	}
 
-	var p1, p2, p3 reflect.Type
+	var args []handlerArg
+	var body reflect.Type
+	hasRequest := false
 
-	switch typ.NumIn() {
-	case 3:
-		p3 = typ.In(2)
-		if p3.Kind() != reflect.Ptr && p3.Kind() != reflect.Map && p3.Kind() != reflect.Slice {
-			panic("Third argument must be an *object, map, or slice")
+	for i := 0; i < typ.NumIn(); i++ {
+		p := typ.In(i)
+		switch p.String() {
+		case "http.ResponseWriter":
+			args = append(args, handlerArg{kind: argWriter})
+			continue
+		case "*http.Request":
+			hasRequest = true
+			args = append(args, handlerArg{kind: argRequest})
+			continue
+		case "context.Context":
+			hasRequest = true
+			args = append(args, handlerArg{kind: argContext})
+			continue
+		}
+		if p == filesType {
+			args = append(args, handlerArg{kind: argFiles})
+			continue
 		}
 
-		fallthrough
-	case 2:
-		p1, p2 = typ.In(0), typ.In(1)
-		if "http.ResponseWriter" != p1.String() {
-			panic("First argument must be an http.ResponseWriter")
+		if v, ok := providers[p]; ok {
+			args = append(args, handlerArg{kind: argProvided, value: v})
+			continue
+		}
+		if fn, ok := extractors[p]; ok {
+			args = append(args, handlerArg{kind: argExtracted, value: fn})
+			continue
 		}
 
-		if "*http.Request" != p2.String() {
-			panic("Second argument must be a *http.Request")
+		if body != nil {
+			panic(fmt.Sprintf("Argument %d (%s) has no provider, only one argument may be the request body", i+1, p))
 		}
-	default:
-		panic("Handler must have 2-3 arguments: ResponseWriter, Request, [Object]")
+		if p.Kind() != reflect.Ptr && p.Kind() != reflect.Map && p.Kind() != reflect.Slice {
+			panic(fmt.Sprintf("Argument %d (%s) must be an *object, map, slice, or a provided type", i+1, p))
+		}
+
+		body = p
+		args = append(args, handlerArg{kind: argBody})
 	}
 
-	if typ.NumOut() != 2 {
-		panic("Handler must have two returns: *object or interface{}, and error")
+	if !hasRequest {
+		panic("Handler must take a *http.Request or a context.Context")
 	}
 
-	o1, o2 := typ.Out(0), typ.Out(1)
+	var out reflect.Type
+	errs := true
+	switch typ.NumOut() {
+	case 1:
+		if "error" == typ.Out(0).String() {
+			break
+		}
+		out, errs = typ.Out(0), false
+		if !isResponseType(out) {
+			panic("Single return must be an error, or an empty *object, map, slice or interface{}")
+		}
+	case 2:
+		out = typ.Out(0)
+		if !isResponseType(out) {
+			panic("First return must be an empty *object, map, slice or interface{}")
+		}
 
-	if "interface {}" != o1.String() && o1.Kind() != reflect.Ptr && o1.Kind() != reflect.Slice && o1.Kind() != reflect.Map {
-		panic("First return must be an empty *object, map, slice or interface{}")
+		if "error" != typ.Out(1).String() {
+			panic("Second return must be an error")
+		}
+	default:
+		panic("Handler must have one or two returns: [*object or interface{}], [error]")
 	}
 
-	if "error" != o2.String() {
-		panic("Second return must be an error")
+	j := &JSONHandler{fn: reflect.ValueOf(fn), args: args, in: body, out: out, errs: errs, paths: pathFields(body), cookies: cookieFields(body)}
+	j.rules = ruleFields(body)
+	for _, a := range args {
+		j.uploads = j.uploads || a.kind == argFiles
 	}
-
-	return &JSONHandler{fn: reflect.ValueOf(fn), in: p3}
+	j.fieldTypes = hasFieldTypes(body, map[reflect.Type]bool{})
+	if out != nil {
+		j.stream = streamKindOf(out)
+		checkResponseType(out, j.stream)
+	}
+	return j
 }
@@ -2,7 +2,10 @@ package jsonware
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -54,14 +57,63 @@ func testHandler7(w http.ResponseWriter, r *http.Request) (map[string]*testType,
 
 // return unserializable
 func testHandler8(w http.ResponseWriter, r *http.Request) (interface{}, error) {
-	return map[int]string{}, nil
+	return make(chan int), nil
+}
+
+// return error only
+func testHandler9(w http.ResponseWriter, r *http.Request, t *testType) error {
+	w.WriteHeader(http.StatusAccepted)
+	_, err := io.WriteString(w, `{"custom":"`+t.Name+`"}`)
+	return err
+}
+
+// return value only
+func testHandler10(r *http.Request) *testType {
+	return &testType{r.URL.Query().Get("name")}
+}
+
+// context and param only, return value only
+func testHandler11(ctx context.Context, t *testType) *testType {
+	return &testType{t.Name + " " + ctx.Value(testCtxKey{}).(string)}
+}
+
+type testCtxKey struct{}
+
+type createdType struct {
+	Name string `json:"name"`
+}
+
+func (c *createdType) StatusCode() int { return http.StatusCreated }
+
+type validType struct {
+	Name string `json:"name"`
+}
+
+func (v *validType) Validate() error {
+	switch v.Name {
+	case "":
+		return errors.New("name is required")
+	case "admin":
+		return Err{Status: http.StatusForbidden, Err: errors.New("name is reserved")}
+	}
+	return nil
+}
+
+// param that validates itself
+func testHandler13(r *http.Request, v *validType) *validType { return v }
+
+// return value with its own status
+func testHandler12(w http.ResponseWriter, r *http.Request, t *testType) (*createdType, error) {
+	return &createdType{t.Name}, nil
 }
 
 // Params Arity
 func badHandler1() (interface{}, error) { return nil, nil }
 
 // Return arity
-func badHandler2(w http.ResponseWriter, r *http.Request) error { return nil }
+func badHandler2(w http.ResponseWriter, r *http.Request) (interface{}, error, error) {
+	return nil, nil, nil
+}
 
 // 1st arg
 func badHandler3(w int, r *http.Request) (interface{}, error) { return nil, nil }
@@ -74,6 +126,9 @@ func badHandler5(w http.ResponseWriter, r *http.Request, t testType) (interface{
 	return nil, nil
 }
 
+// value only return
+func badHandler8(r *http.Request) testType { return testType{} }
+
 // 1st return
 func badHandler6(w http.ResponseWriter, r *http.Request) (testType, error) { return testType{}, nil }
 
@@ -95,6 +150,26 @@ func errHandler3(w http.ResponseWriter, r *http.Request) (interface{}, error) {
 	return nil, Err{Status: http.StatusBadRequest, Err: errors.New("ugly request")}
 }
 
+// handled json error from error only handler
+func errHandler5(w http.ResponseWriter, r *http.Request) error {
+	return Err{Status: http.StatusNotFound, Err: errors.New("no such thing")}
+}
+
+type statusErr int
+
+func (s statusErr) Error() string   { return fmt.Sprintf("status %d", int(s)) }
+func (s statusErr) StatusCode() int { return int(s) }
+
+// client error carrying its own status
+func errHandler6(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	return nil, statusErr(http.StatusUnauthorized)
+}
+
+// server error carrying its own status
+func errHandler7(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	return nil, statusErr(http.StatusServiceUnavailable)
+}
+
 // handled json error with serialized reason
 func errHandler4(w http.ResponseWriter, r *http.Request) (interface{}, error) {
 	return nil, Err{
@@ -123,12 +198,21 @@ func TestSerializing(t *testing.T) {
 		{testHandler6, "GET", 200, ``, `[{"name":"hi"}]`},
 		{testHandler7, "GET", 200, ``, `{"hi":{"name":"hi"}}`},
 		{testHandler8, "GET", 500, ``, `{"error":"problem preparing response"}`},
+		{testHandler9, "POST", 202, `{ "name": "hi" }`, `{"custom":"hi"}`},
+		{testHandler9, "POST", 400, `{ "name"`, "could not deserialize"},
+		{testHandler10, "GET", 200, ``, `{"name":"bob"}`},
+		{testHandler11, "POST", 200, `{ "name": "hi" }`, `{"name":"hi there"}`},
+		{testHandler12, "POST", 201, `{ "name": "hi" }`, `{"name":"hi"}`},
+		{testHandler13, "POST", 200, `{ "name": "hi" }`, `{"name":"hi"}`},
+		{testHandler13, "POST", 422, `{}`, `{"error":"name is required"}`},
+		{testHandler13, "POST", 403, `{ "name": "admin" }`, `{"error":"name is reserved"}`},
 	}
 
 	for i, test := range tests {
 		res := httptest.NewRecorder()
-		req, _ := http.NewRequest(test.method, "/", bytes.NewBufferString(test.reqbody))
+		req, _ := http.NewRequest(test.method, "/?name=bob", bytes.NewBufferString(test.reqbody))
 		req.Header = http.Header{"Accept": []string{"*/*"}}
+		req = req.WithContext(context.WithValue(req.Context(), testCtxKey{}, "there"))
 
 		j := Handler(test.handler)
 		j.ServeHTTP(res, req)
@@ -209,6 +293,9 @@ func TestErrors(t *testing.T) {
 		{errHandler2, 200, "validation error", ""},
 		{errHandler3, 400, "ugly request", ""},
 		{errHandler4, 400, `{"error":"ugly request","reason":{"problem":"occurred"}`, ""},
+		{errHandler5, 404, `{"error":"no such thing"}`, ""},
+		{errHandler6, 401, `{"error":"status 401"}`, ""},
+		{errHandler7, 503, "an internal server error", "internal error: status 503"},
 	}
 
 	log := &bytes.Buffer{}
@@ -260,11 +347,11 @@ func TestErrSerializeErr(t *testing.T) {
 	defer Log(nil)
 
 	j := Handler(func(_ http.ResponseWriter, r *http.Request) (interface{}, error) {
-		return nil, Err{Status: 400, Err: errors.New("something"), Reason: map[int]string{}}
+		return nil, Err{Status: 400, Err: errors.New("something"), Reason: make(chan int)}
 	})
 	j.ServeHTTP(res, req)
 
-	if l := log.String(); !strings.Contains(l, "failed to serialize err: json: unsupported type: map[int]string") {
+	if l := log.String(); !strings.Contains(l, "failed to serialize err: json: unsupported type: chan int") {
 		t.Error("Log was wrong:", l)
 	}
 
@@ -304,6 +391,10 @@ func TestHandler(t *testing.T) {
 		{testHandler5, false},
 		{testHandler6, false},
 		{testHandler7, false},
+		{testHandler9, false},
+		{errHandler5, false},
+		{testHandler10, false},
+		{testHandler11, false},
 		{badHandler1, true},
 		{badHandler2, true},
 		{badHandler3, true},
@@ -311,6 +402,7 @@ func TestHandler(t *testing.T) {
 		{badHandler5, true},
 		{badHandler6, true},
 		{badHandler7, true},
+		{badHandler8, true},
 		{5, true},
 	}
 
@@ -337,3 +429,134 @@ func testPanic(handler interface{}) (didPanic bool, msg string) {
 	Handler(handler)
 	return
 }
+
+type orderType struct {
+	ID string `json:"id"`
+}
+
+func (o *orderType) SelfURL() string { return "/orders/" + o.ID }
+
+func TestCreated(t *testing.T) {
+	t.Parallel()
+
+	order := func(r *http.Request, in *orderType) *orderType { return in }
+	wrapped := func(r *http.Request) *Created { return &Created{Value: &testType{"job"}, Location: "/jobs/5"} }
+
+	var tests = []struct {
+		handler  interface{}
+		method   string
+		reqbody  string
+		status   int
+		location string
+		resbody  string
+	}{
+		{order, "POST", `{"id":"5"}`, 201, "/orders/5", `{"id":"5"}`},
+		{order, "PUT", `{"id":"5"}`, 200, "", `{"id":"5"}`},
+		{wrapped, "GET", "", 201, "/jobs/5", `{"name":"job"}`},
+		{func(r *http.Request) *Created { return &Created{Value: &orderType{"5"}, Location: "/jobs/5"} }, "GET", "", 201, "/jobs/5", `{"id":"5"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/", strings.NewReader(test.reqbody))
+		req.Header.Set("Accept", "application/json")
+		Handler(test.handler).ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if loc := res.Header().Get("Location"); loc != test.location {
+			t.Errorf("Test %d: expected location %q, got: %q", i, test.location, loc)
+		}
+		if b := res.Body.String(); b != test.resbody+"\n" {
+			t.Errorf("Test %d: body was wrong: %s", i, b)
+		}
+	}
+}
+
+func TestCreatedCodec(t *testing.T) {
+	t.Parallel()
+
+	type user struct {
+		UserName string `json:"user_name"`
+	}
+
+	h := Handler(func(r *http.Request) *Created {
+		return &Created{Value: &user{UserName: "bob"}, Location: "/users/bob"}
+	}).Codec(&Codec{Keys: CamelCase})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(res, req)
+
+	if res.Code != http.StatusCreated || res.Header().Get("Location") != "/users/bob" {
+		t.Errorf("expected a 201 with a location, got: %d %q", res.Code, res.Header().Get("Location"))
+	}
+	if b := res.Body.String(); b != `{"userName":"bob"}`+"\n" {
+		t.Errorf("expected camel cased body, got: %s", b)
+	}
+}
+
+func TestHead(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		handler interface{}
+		status  int
+		length  string
+	}{
+		{func(r *http.Request) *testType { return &testType{"bob"} }, 200, "15"},
+		{func(r *http.Request) (*testType, error) { return nil, Err{Status: 404, Err: errors.New("nope")} }, 404, ""},
+		{func(r *http.Request, in *testType) *testType { return in }, 400, ""},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("HEAD", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		Handler(test.handler).ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if l := res.Header().Get("Content-Length"); l != test.length {
+			t.Errorf("Test %d: expected length %q, got: %q", i, test.length, l)
+		}
+		if res.Body.Len() != 0 {
+			t.Errorf("Test %d: expected no body, got: %s", i, res.Body.String())
+		}
+	}
+}
+
+func TestStrictMethods(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		handler interface{}
+		method  string
+		status  int
+		allow   string
+	}{
+		{testHandler1, "POST", 200, ""},
+		{testHandler1, "GET", 405, "POST, PUT, PATCH"},
+		{testHandler1, "DELETE", 405, "POST, PUT, PATCH"},
+		{(&testController{"hello"}).testHandler2, "GET", 200, ""},
+		{(&testController{"hello"}).testHandler2, "POST", 405, "GET, HEAD, DELETE"},
+		{(&testController{"hello"}).testHandler2, "PATCH", 405, "GET, HEAD, DELETE"},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/", bytes.NewBufferString(`{ "name": "hi" }`))
+		req.Header.Set("Accept", "application/json")
+		Handler(test.handler).StrictMethods().ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if allow := res.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("Test %d: expected allow %q, got: %q", i, test.allow, allow)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package jsonwaretest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update-golden", false, "rewrite jsonwaretest golden files instead of comparing against them")
+
+// GoldenDir is the directory golden files are kept in, relative to the
+// package under test.
+var GoldenDir = "testdata"
+
+/*
+Golden compares body against the golden file testdata/name.golden.json and
+fails the test with a line diff if they differ. Both sides are canonicalized
+first so that key order and formatting never cause a mismatch.
+
+Run the tests with -update-golden to record the current bodies as the new
+golden files.
+
+	res := jsonwaretest.Request[Order](t, handler, "GET", "/orders/1", nil)
+	jsonwaretest.Golden(t, "order", res.Body)
+*/
+func Golden(t testing.TB, name string, body []byte) {
+	t.Helper()
+
+	got, err := Canonicalize(body)
+	if err != nil {
+		t.Fatalf("jsonwaretest: response is not valid json: %v\nbody: %s", err, body)
+	}
+
+	path := filepath.Join(GoldenDir, name+".golden.json")
+	if *update {
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("jsonwaretest: failed to create golden dir: %v", err)
+		}
+		if err = os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("jsonwaretest: failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("jsonwaretest: failed to read golden file (run with -update-golden to create it): %v", err)
+	}
+	if want, err = Canonicalize(want); err != nil {
+		t.Fatalf("jsonwaretest: golden file %s is not valid json: %v", path, err)
+	}
+
+	if !bytes.Equal(want, got) {
+		t.Errorf("Response does not match golden file %s:\n%s", path, Diff(want, got))
+	}
+}
+
+// AssertGolden fails the test if the response body does not match the golden
+// file for name, see Golden.
+func (r *Result[T]) AssertGolden(name string) *Result[T] {
+	r.t.Helper()
+	Golden(r.t, name, r.Body)
+	return r
+}
+
+// Canonicalize reformats json so that equivalent documents are byte for byte
+// equal: object keys are sorted, everything is indented by two spaces and
+// numbers are written in their shortest form.
+func Canonicalize(body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after top-level value")
+	}
+
+	out, err := json.MarshalIndent(normalizeNumbers(v), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// normalizeNumbers rewrites non-integer numbers into their shortest form
+// so that 1.50 and 1.5 compare equal. Integers are left alone so that they
+// never lose precision.
+func normalizeNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, e := range val {
+			val[k] = normalizeNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range val {
+			val[i] = normalizeNumbers(e)
+		}
+	case json.Number:
+		s := string(val)
+		if !strings.ContainsAny(s, ".eE") {
+			return val
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	}
+	return v
+}
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// maxDiffCells caps the size of the table Diff works out the changes with,
+// past it only the first change is shown.
+const maxDiffCells = 1 << 22
+
+// diffLine is a line of a diff, op is ' ' for unchanged lines.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// Diff returns a line diff of want and got, lines only in want are prefixed
+// with "-", lines only in got with "+". Long runs of unchanged lines are
+// elided, and only the first change is shown when the changed parts are too
+// large to compare.
+func Diff(want, got []byte) string {
+	a := strings.Split(strings.TrimRight(string(want), "\n"), "\n")
+	b := strings.Split(strings.TrimRight(string(got), "\n"), "\n")
+
+	// The lines both start and end with don't need comparing, which keeps
+	// the usual small change to a large document cheap.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	var lines []diffLine
+	for _, text := range a[:pre] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	midA, midB := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	if len(midA)*len(midB) > maxDiffCells {
+		lines = append(lines,
+			diffLine{'-', midA[0]},
+			diffLine{'+', midB[0]},
+			diffLine{'!', "the rest is too large to diff"},
+		)
+	} else {
+		lines = append(lines, diffLines(midA, midB)...)
+		for _, text := range a[len(a)-suf:] {
+			lines = append(lines, diffLine{' ', text})
+		}
+	}
+
+	// Only show lines that are within diffContext of a change.
+	show := make([]bool, len(lines))
+	for n, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		for k := n - diffContext; k <= n+diffContext; k++ {
+			if k >= 0 && k < len(lines) {
+				show[k] = true
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	skipped := false
+	for n, l := range lines {
+		if !show[n] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			buf.WriteString("  ...\n")
+			skipped = false
+		}
+		fmt.Fprintf(buf, "%c %s\n", l.op, l.text)
+	}
+	if skipped {
+		buf.WriteString("  ...\n")
+	}
+
+	return buf.String()
+}
+
+// diffLines diffs a and b with a longest common subsequence table.
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the length of the lcs of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	return lines
+}
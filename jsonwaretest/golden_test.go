@@ -0,0 +1,117 @@
+package jsonwaretest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aarondl/jsonware"
+)
+
+func TestCanonicalize(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		in  string
+		out string
+	}{
+		{`{"b":1,"a":2}`, "{\n  \"a\": 2,\n  \"b\": 1\n}\n"},
+		{`[1.50, 10, 1e2]`, "[\n  1.5,\n  10,\n  100\n]\n"},
+		{`{"big":12345678901234567890}`, "{\n  \"big\": 12345678901234567890\n}\n"},
+		{` "str" `, "\"str\"\n"},
+	}
+
+	for i, test := range tests {
+		got, err := Canonicalize([]byte(test.in))
+		if err != nil {
+			t.Errorf("Test %d: %v", i, err)
+			continue
+		}
+		if string(got) != test.out {
+			t.Errorf("Test %d: Expected:\n%s\ngot:\n%s", i, test.out, got)
+		}
+	}
+
+	if _, err := Canonicalize([]byte(`{"a":1} {}`)); err == nil {
+		t.Error("Expected an error for trailing data")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	want := "a\nb\nc\nd\ne\nf\ng\nh\ni\n"
+	got := "a\nb\nc\nd\nE\nf\ng\nh\ni\n"
+
+	d := Diff([]byte(want), []byte(got))
+	if !strings.Contains(d, "- e\n+ E\n") {
+		t.Errorf("Diff was wrong:\n%s", d)
+	}
+	if strings.Contains(d, "  a\n") || strings.Contains(d, "  i\n") {
+		t.Errorf("Diff should elide far away lines:\n%s", d)
+	}
+	if !strings.Contains(d, "  ...\n") {
+		t.Errorf("Diff should mark elided lines:\n%s", d)
+	}
+}
+
+func TestDiffLarge(t *testing.T) {
+	t.Parallel()
+
+	var want, got, changed strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&want, "a%d\n", i)
+		fmt.Fprintf(&got, "b%d\n", i)
+		if i == 2500 {
+			changed.WriteString("changed\n")
+		} else {
+			fmt.Fprintf(&changed, "a%d\n", i)
+		}
+	}
+
+	d := Diff([]byte(want.String()), []byte(changed.String()))
+	if !strings.Contains(d, "- a2500\n+ changed\n") || strings.Contains(d, "a0\n") {
+		t.Errorf("Diff of a small change was wrong:\n%s", d)
+	}
+
+	d = Diff([]byte(want.String()), []byte(got.String()))
+	if d != "- a0\n+ b0\n! the rest is too large to diff\n" {
+		t.Errorf("Diff should only show the first change:\n%s", d)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	dir := t.TempDir()
+	old := GoldenDir
+	GoldenDir = dir
+	defer func() { GoldenDir = old }()
+
+	*update = true
+	Golden(t, "user", []byte(`{"name":"bob","age":5}`))
+	*update = false
+
+	b, err := os.ReadFile(filepath.Join(dir, "user.golden.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "{\n  \"age\": 5,\n  \"name\": \"bob\"\n}\n" {
+		t.Errorf("Golden file was wrong:\n%s", b)
+	}
+
+	// Same document, different formatting.
+	Golden(t, "user", []byte(`{ "age": 5, "name": "bob" }`))
+
+	fake := &recordingTB{TB: t}
+	Golden(fake, "user", []byte(`{"name":"alice","age":5}`))
+	if fake.failures != 1 {
+		t.Error("Expected a mismatch")
+	}
+
+	res := Request[user](t, jsonware.Handler(getUser), "GET", "/", nil)
+	*update = true
+	res.AssertGolden("bob")
+	*update = false
+	res.AssertGolden("bob")
+}
@@ -0,0 +1,229 @@
+/*
+Package jsonwaretest has helpers for testing jsonware handlers. It takes care of
+building the request, recording and decoding the response, and capturing any
+cloaked errors the handler logged so tests can focus on the behavior under
+test.
+
+	func TestGetUser(t *testing.T) {
+		res := jsonwaretest.Request[User](t, jsonware.Handler(getUser), "GET", "/users/5", nil)
+		res.AssertStatus(http.StatusOK)
+		if res.Value.Name != "bob" {
+			t.Error("wrong name:", res.Value.Name)
+		}
+	}
+*/
+package jsonwaretest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aarondl/jsonware"
+)
+
+// cloakedMessage is what jsonware sends to clients in place of a cloaked error.
+const cloakedMessage = "an internal server error occurred"
+
+// Result is the recorded outcome of a Request.
+type Result[T any] struct {
+	t testing.TB
+
+	// Code is the http status code of the response.
+	Code int
+	// Header is the header of the response.
+	Header http.Header
+	// Body is the raw body of the response.
+	Body []byte
+	// Log is everything the handler logged while serving the request. It is
+	// only captured when the handler is a *jsonware.JSONHandler.
+	Log string
+
+	// Value is the decoded response, it is nil if the response was an error
+	// or had no body.
+	Value *T
+	// Err is the decoded error envelope, it is nil if the response was not
+	// an error.
+	Err *jsonware.Err
+}
+
+/*
+Request serves a request to handler and decodes the response into T, or into
+Err if the handler responded with a json error. The body is sent as-is if it's
+a string or []byte, otherwise it's serialized to json. A nil body sends no
+body at all.
+
+If handler is a *jsonware.JSONHandler its cloaked errors are captured into
+Result.Log instead of going to the handler's own logger.
+*/
+func Request[T any](t testing.TB, handler http.Handler, method, path string, body any) *Result[T] {
+	t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		buf, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("jsonwaretest: failed to serialize request body: %v", err)
+		}
+		reader = bytes.NewReader(buf)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Accept", "application/json")
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	log := &bytes.Buffer{}
+	if j, ok := handler.(*jsonware.JSONHandler); ok {
+		// Copy the handler so that redirecting the log doesn't affect
+		// anyone else using it.
+		cpy := *j
+		handler = cpy.Log(log)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	res := &Result[T]{
+		t:      t,
+		Code:   rec.Code,
+		Header: rec.Header(),
+		Body:   rec.Body.Bytes(),
+		Log:    log.String(),
+	}
+
+	if len(bytes.TrimSpace(res.Body)) == 0 {
+		return res
+	}
+
+	if e, ok := decodeErr(res.Code, res.Body); ok {
+		res.Err = e
+		return res
+	}
+
+	res.Value = new(T)
+	if err := json.Unmarshal(res.Body, res.Value); err != nil {
+		t.Fatalf("jsonwaretest: failed to deserialize response into %T: %v\nbody: %s", res.Value, err, res.Body)
+	}
+
+	return res
+}
+
+// decodeErr decodes body as a jsonware error envelope. Errors can be sent
+// with any status (Err with no Status is a 200) so the shape of the body is
+// what decides it, unless the status itself says it must be an error.
+func decodeErr(code int, body []byte) (*jsonware.Err, bool) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, false
+	}
+
+	msg, ok := envelope["error"]
+	if !ok {
+		return nil, false
+	}
+	if code < 400 && !isEnvelope(envelope) {
+		return nil, false
+	}
+
+	var text string
+	if err := json.Unmarshal(msg, &text); err != nil {
+		return nil, false
+	}
+
+	e := &jsonware.Err{Status: code, Err: errors.New(text)}
+	if reason, ok := envelope["reason"]; ok {
+		var r interface{}
+		if err := json.Unmarshal(reason, &r); err == nil {
+			e.Reason = r
+		}
+	}
+
+	return e, true
+}
+
+// isEnvelope checks that an object has only the keys of an error envelope.
+func isEnvelope(obj map[string]json.RawMessage) bool {
+	for k := range obj {
+		if k != "error" && k != "reason" {
+			return false
+		}
+	}
+	return true
+}
+
+// AssertStatus fails the test if the response status was not code.
+func (r *Result[T]) AssertStatus(code int) *Result[T] {
+	r.t.Helper()
+	if r.Code != code {
+		r.t.Errorf("Expected status: %d, got: %d\nbody: %s", code, r.Code, r.Body)
+	}
+	return r
+}
+
+// AssertEnvelope fails the test if the response is not a well formed json
+// error envelope.
+func (r *Result[T]) AssertEnvelope() *Result[T] {
+	r.t.Helper()
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(r.Body, &envelope); err != nil {
+		r.t.Errorf("Expected an error envelope, body was not a json object: %s", r.Body)
+		return r
+	}
+	if _, ok := envelope["error"]; !ok || !isEnvelope(envelope) {
+		r.t.Errorf("Expected an error envelope, got: %s", r.Body)
+		return r
+	}
+	if r.Err == nil {
+		r.t.Errorf("Expected an error envelope with a string error, got: %s", r.Body)
+	}
+	return r
+}
+
+// AssertError fails the test if the response is not an error envelope whose
+// message contains msg.
+func (r *Result[T]) AssertError(msg string) *Result[T] {
+	r.t.Helper()
+	if r.Err == nil {
+		r.t.Errorf("Expected error: %q, got: %s", msg, r.Body)
+		return r
+	}
+	if e := r.Err.Error(); !strings.Contains(e, msg) {
+		r.t.Errorf("Expected error: %q, got: %q", msg, e)
+	}
+	return r
+}
+
+// AssertLogged fails the test if the handler did not log something
+// containing msg.
+func (r *Result[T]) AssertLogged(msg string) *Result[T] {
+	r.t.Helper()
+	if !strings.Contains(r.Log, msg) {
+		r.t.Errorf("Expected log: %q, got: %q", msg, r.Log)
+	}
+	return r
+}
+
+// AssertCloaked fails the test if the response was not a cloaked internal
+// error whose logged cause contains msg.
+func (r *Result[T]) AssertCloaked(msg string) *Result[T] {
+	r.t.Helper()
+	r.AssertStatus(http.StatusInternalServerError)
+	if r.Err == nil || r.Err.Error() != cloakedMessage {
+		r.t.Errorf("Expected a cloaked error, got: %s", r.Body)
+	}
+	return r.AssertLogged(msg)
+}
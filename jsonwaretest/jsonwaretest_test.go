@@ -0,0 +1,117 @@
+package jsonwaretest
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aarondl/jsonware"
+)
+
+type user struct {
+	Name string `json:"name"`
+}
+
+func getUser(w http.ResponseWriter, r *http.Request) (*user, error) {
+	return &user{Name: "bob"}, nil
+}
+
+func createUser(w http.ResponseWriter, r *http.Request, u *user) (*user, error) {
+	if u.Name == "" {
+		return nil, jsonware.Err{
+			Status: http.StatusBadRequest,
+			Err:    errors.New("name is required"),
+			Reason: map[string]string{"name": "missing"},
+		}
+	}
+	return u, nil
+}
+
+func brokenUser(w http.ResponseWriter, r *http.Request) (*user, error) {
+	return nil, errors.New("database is down")
+}
+
+func TestRequest(t *testing.T) {
+	t.Parallel()
+
+	res := Request[user](t, jsonware.Handler(getUser), "GET", "/", nil)
+	res.AssertStatus(http.StatusOK)
+	if res.Value == nil || res.Value.Name != "bob" {
+		t.Error("Value was wrong:", res.Value)
+	}
+	if res.Err != nil {
+		t.Error("Err should be nil:", res.Err)
+	}
+}
+
+func TestRequestBody(t *testing.T) {
+	t.Parallel()
+
+	h := jsonware.Handler(createUser)
+
+	res := Request[user](t, h, "POST", "/", user{Name: "alice"})
+	res.AssertStatus(http.StatusOK)
+	if res.Value == nil || res.Value.Name != "alice" {
+		t.Error("Value was wrong:", res.Value)
+	}
+
+	res = Request[user](t, h, "POST", "/", `{"name":"carol"}`)
+	if res.Value == nil || res.Value.Name != "carol" {
+		t.Error("Value was wrong:", res.Value)
+	}
+
+	res = Request[user](t, h, "POST", "/", []byte(`{}`))
+	res.AssertStatus(http.StatusBadRequest).AssertEnvelope().AssertError("name is required")
+	if res.Value != nil {
+		t.Error("Value should be nil:", res.Value)
+	}
+	if reason, ok := res.Err.Reason.(map[string]interface{}); !ok || reason["name"] != "missing" {
+		t.Error("Reason was wrong:", res.Err.Reason)
+	}
+}
+
+func TestRequestCloaked(t *testing.T) {
+	t.Parallel()
+
+	res := Request[user](t, jsonware.Handler(brokenUser), "GET", "/", nil)
+	res.AssertCloaked("database is down")
+}
+
+func TestRequestErrorWithoutStatus(t *testing.T) {
+	t.Parallel()
+
+	h := jsonware.Handler(func(w http.ResponseWriter, r *http.Request) (*user, error) {
+		return nil, jsonware.Err{Err: errors.New("soft failure")}
+	})
+
+	res := Request[user](t, h, "GET", "/", nil)
+	res.AssertStatus(http.StatusOK).AssertError("soft failure")
+}
+
+func TestAssertionsFail(t *testing.T) {
+	t.Parallel()
+
+	res := Request[user](t, jsonware.Handler(getUser), "GET", "/", nil)
+	fake := &recordingTB{TB: t}
+	res.t = fake
+
+	res.AssertStatus(http.StatusTeapot)
+	res.AssertEnvelope()
+	res.AssertError("anything")
+	res.AssertLogged("anything")
+
+	if fake.failures != 4 {
+		t.Error("Expected 4 failures, got:", fake.failures)
+	}
+}
+
+// recordingTB counts failures instead of failing the real test.
+type recordingTB struct {
+	testing.TB
+	failures int
+}
+
+func (r *recordingTB) Helper() {}
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.failures++
+}
@@ -0,0 +1,306 @@
+package jsonware
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// joseContentType is the content type of JWE and JWS compact serializations.
+const joseContentType = "application/jose"
+
+var (
+	errDecrypt        = errors.New("could not decrypt request body")
+	errMustEncrypt    = errors.New("request body must be encrypted")
+	errNoResponseKey  = errors.New("no key to encrypt the response with")
+	errUnsupportedJWE = errors.New("unsupported jwe algorithm")
+	errBadEncryptKey  = errors.New("jwe key must be a 32 byte []byte, *rsa.PrivateKey or *rsa.PublicKey")
+)
+
+/*
+Encryption decrypts JWE (RFC 7516) request bodies and encrypts responses for
+the group of handlers it wraps, for payloads that must stay encrypted above
+TLS. Requests with a Content-Type of application/jose are decrypted before
+the handler sees them, and responses are encrypted when the client accepts
+application/jose or sent an encrypted request. Both use the compact
+serialization and A256GCM content encryption.
+
+Key is the server's key that requests are decrypted with. It's either a 32
+byte []byte shared with clients for the dir algorithm, or an *rsa.PrivateKey
+for RSA-OAEP-256. ResponseKey finds the key to encrypt the response to a
+request with, either the shared []byte or the client's *rsa.PublicKey. When
+it's nil a []byte Key is used for responses too.
+
+When Required is set plaintext request bodies are rejected with a 415 and
+every response is encrypted. Responses are buffered in full to encrypt them
+so streaming handlers lose their streaming.
+
+	enc := &jsonware.Encryption{Key: serverKey, ResponseKey: func(r *http.Request) (interface{}, error) {
+		return partners.PublicKey(r)
+	}, Required: true}
+	http.Handle("/records/", enc.Wrap(jsonware.Handler(getRecord)))
+*/
+type Encryption struct {
+	Key         interface{}
+	ResponseKey func(r *http.Request) (interface{}, error)
+	Required    bool
+
+	// Log is where errors are reported, the global logger is used when it's
+	// nil.
+	Log io.Writer
+}
+
+// Wrap returns a handler that decrypts requests and encrypts the responses
+// of h.
+func (e *Encryption) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encrypted := strings.HasPrefix(r.Header.Get("Content-Type"), joseContentType)
+		hasBody := r.Body != nil && r.Body != http.NoBody
+
+		if hasBody && encrypted {
+			var err error
+			if r, err = e.decryptBody(r); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				writeError(w, e.Log, err)
+				return
+			}
+		} else if hasBody && e.Required && isDataMethod(r.Method) {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, e.Log, Err{Status: http.StatusUnsupportedMediaType, Err: errMustEncrypt})
+			return
+		}
+
+		accept := r.Header.Get("Accept")
+		if !e.Required && !encrypted && !strings.Contains(accept, joseContentType) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := e.responseKey(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, e.Log, err)
+			return
+		}
+
+		// The handler only speaks json, the encryption is ours to do.
+		if strings.Contains(accept, joseContentType) {
+			r = r.Clone(r.Context())
+			r.Header.Set("Accept", "application/json")
+		}
+
+		buf := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(buf, r)
+
+		token, err := encryptJWE(key, buf.body.Bytes())
+		if err != nil {
+			logf(e.Log, "internal error: failed to encrypt response: %v", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `{"error":"an internal server error occurred"}`)
+			return
+		}
+
+		w.Header().Set("Content-Type", joseContentType)
+		w.Header().Del("Content-Length")
+		w.WriteHeader(buf.status)
+		io.WriteString(w, token)
+	})
+}
+
+// responseKey finds the key to encrypt the response to r with.
+func (e *Encryption) responseKey(r *http.Request) (interface{}, error) {
+	if e.ResponseKey == nil {
+		if key, ok := e.Key.([]byte); ok {
+			return key, nil
+		}
+		return nil, errNoResponseKey
+	}
+
+	key, err := e.ResponseKey(r)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errNoResponseKey
+	}
+	return key, nil
+}
+
+// decryptBody returns a copy of r whose encrypted body is replaced with its
+// plaintext.
+func (e *Encryption) decryptBody(r *http.Request) (*http.Request, error) {
+	token, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, Err{Status: http.StatusBadRequest, Err: errors.New("could not read request body")}
+	}
+
+	plain, err := decryptJWE(e.Key, strings.TrimSpace(string(token)))
+	if err != nil {
+		return nil, Err{Status: http.StatusBadRequest, Err: errDecrypt}
+	}
+
+	r2 := *r
+	r2.Body = io.NopCloser(bytes.NewReader(plain))
+	r2.ContentLength = int64(len(plain))
+	r2.Header = r.Header.Clone()
+	r2.Header.Set("Content-Type", "application/json")
+	r2.Header.Set("Content-Length", strconv.Itoa(len(plain)))
+	return &r2, nil
+}
+
+// jweHeader is the protected header of a JWE.
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+}
+
+// encryptJWE encrypts plaintext for key into a JWE compact serialization.
+func encryptJWE(key interface{}, plaintext []byte) (string, error) {
+	var header jweHeader
+	var cek, encryptedKey []byte
+	switch k := key.(type) {
+	case []byte:
+		if len(k) != 32 {
+			return "", errBadEncryptKey
+		}
+		header = jweHeader{Alg: "dir", Enc: "A256GCM"}
+		cek = k
+	case *rsa.PublicKey:
+		header = jweHeader{Alg: "RSA-OAEP-256", Enc: "A256GCM"}
+		cek = make([]byte, 32)
+		if _, err := rand.Read(cek); err != nil {
+			return "", err
+		}
+		var err error
+		if encryptedKey, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, k, cek, nil); err != nil {
+			return "", err
+		}
+	default:
+		return "", errBadEncryptKey
+	}
+
+	rawHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	protected := b64url.EncodeToString(rawHeader)
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nil, iv, plaintext, []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return strings.Join([]string{
+		protected,
+		b64url.EncodeToString(encryptedKey),
+		b64url.EncodeToString(iv),
+		b64url.EncodeToString(ciphertext),
+		b64url.EncodeToString(tag),
+	}, "."), nil
+}
+
+// decryptJWE decrypts a JWE compact serialization with key.
+func decryptJWE(key interface{}, token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return nil, errors.New("jwe must have 5 parts")
+	}
+
+	decoded := make([][]byte, 5)
+	for i, part := range parts {
+		var err error
+		if decoded[i], err = b64url.DecodeString(part); err != nil {
+			return nil, err
+		}
+	}
+
+	var header jweHeader
+	if err := json.Unmarshal(decoded[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Enc != "A256GCM" {
+		return nil, errUnsupportedJWE
+	}
+
+	var cek []byte
+	switch k := key.(type) {
+	case []byte:
+		if header.Alg != "dir" || len(decoded[1]) != 0 {
+			return nil, errUnsupportedJWE
+		}
+		cek = k
+	case *rsa.PrivateKey:
+		if header.Alg != "RSA-OAEP-256" {
+			return nil, errUnsupportedJWE
+		}
+		var err error
+		if cek, err = rsa.DecryptOAEP(sha256.New(), nil, k, decoded[1], nil); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errBadEncryptKey
+	}
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded[2]) != gcm.NonceSize() {
+		return nil, fmt.Errorf("jwe iv must be %d bytes", gcm.NonceSize())
+	}
+
+	sealed := append(decoded[3], decoded[4]...)
+	return gcm.Open(nil, decoded[2], sealed, []byte(parts[0]))
+}
+
+func newGCM(cek []byte) (cipher.AEAD, error) {
+	if len(cek) != 32 {
+		return nil, errBadEncryptKey
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// bufferedWriter holds on to a response so it can be changed before it's
+// sent.
+type bufferedWriter struct {
+	http.ResponseWriter
+
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedWriter) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.status = status
+		b.wroteHeader = true
+	}
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
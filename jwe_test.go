@@ -0,0 +1,111 @@
+package jsonware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEncryption(t *testing.T) {
+	t.Parallel()
+
+	shared := bytes.Repeat([]byte{7}, 32)
+	serverKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	clientKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	echo := Handler(func(r *http.Request, in *testType) *testType { return in })
+
+	dir := (&Encryption{Key: shared}).Wrap(echo)
+	required := (&Encryption{Key: shared, Required: true}).Wrap(echo)
+	asym := (&Encryption{Key: serverKey, ResponseKey: func(r *http.Request) (interface{}, error) {
+		return &clientKey.PublicKey, nil
+	}}).Wrap(echo)
+
+	encrypt := func(key interface{}, s string) string {
+		token, err := encryptJWE(key, []byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	var tests = []struct {
+		handler   http.Handler
+		ctype     string
+		accept    string
+		body      string
+		status    int
+		decryptBy interface{}
+		resbody   string
+	}{
+		{dir, "application/json", "application/json", `{"name":"bob"}`, 200, nil, `{"name":"bob"}`},
+		{dir, "application/json", "application/jose", `{"name":"bob"}`, 200, shared, `{"name":"bob"}`},
+		{dir, "application/jose", "application/json", encrypt(shared, `{"name":"bob"}`), 200, shared, `{"name":"bob"}`},
+		{dir, "application/jose", "application/json", encrypt(bytes.Repeat([]byte{8}, 32), `{"name":"bob"}`), 400, nil, `{"error":"could not decrypt request body"}`},
+		{dir, "application/jose", "application/json", "abc", 400, nil, `{"error":"could not decrypt request body"}`},
+		{required, "application/json", "application/json", `{"name":"bob"}`, 415, nil, `{"error":"request body must be encrypted"}`},
+		{required, "application/jose", "application/json", encrypt(shared, `{"name":"bob"}`), 200, shared, `{"name":"bob"}`},
+		{asym, "application/jose", "application/jose", encrypt(&serverKey.PublicKey, `{"name":"bob"}`), 200, clientKey, `{"name":"bob"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.ctype)
+		req.Header.Set("Accept", test.accept)
+		test.handler.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if ct := req.Header.Get("Content-Type"); ct != test.ctype {
+			t.Errorf("Test %d: expected the caller's headers to be left alone, got: %s", i, ct)
+		}
+
+		body := res.Body.String()
+		if test.decryptBy != nil {
+			if ct := res.Header().Get("Content-Type"); ct != "application/jose" {
+				t.Errorf("Test %d: expected an encrypted response, got: %s", i, ct)
+			}
+			plain, err := decryptJWE(test.decryptBy, body)
+			if err != nil {
+				t.Errorf("Test %d: could not decrypt response: %v", i, err)
+				continue
+			}
+			body = string(plain)
+		}
+		if body != test.resbody+"\n" {
+			t.Errorf("Test %d: body was wrong: %s", i, body)
+		}
+	}
+}
+
+func TestJWERoundTrip(t *testing.T) {
+	t.Parallel()
+
+	key := bytes.Repeat([]byte{1}, 32)
+	token, err := encryptJWE(key, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := encryptJWE([]byte("short"), []byte("hello")); err == nil {
+		t.Error("Expected short keys to be rejected")
+	}
+
+	plain, err := decryptJWE(key, token)
+	if err != nil || string(plain) != "hello" {
+		t.Errorf("Round trip failed: %q %v", plain, err)
+	}
+
+	// Tampering with the protected header must break authentication.
+	parts := strings.Split(token, ".")
+	parts[0] = b64url.EncodeToString([]byte(`{"alg":"dir","enc":"A256GCM","x":1}`))
+	if _, err := decryptJWE(key, strings.Join(parts, ".")); err == nil {
+		t.Error("Expected a tampered header to fail")
+	}
+}
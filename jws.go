@@ -0,0 +1,132 @@
+package jsonware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// JWSHeader is the response header that detached JWS signatures are sent in.
+const JWSHeader = "Jws-Signature"
+
+/*
+JWS is the key a JSONHandler signs its responses with, see SignJWS. Alg is
+the JWS algorithm and decides what Key must be:
+
+	HS256  []byte
+	RS256  *rsa.PrivateKey
+	ES256  *ecdsa.PrivateKey on the P-256 curve
+	EdDSA  ed25519.PrivateKey
+
+KeyID is sent as the kid header parameter when it's set so clients can pick
+the right key to verify with.
+*/
+type JWS struct {
+	Alg   string
+	KeyID string
+	Key   interface{}
+
+	// Detached sends the signature in the Jws-Signature header and leaves
+	// the body as it is, otherwise the body is replaced with the compact
+	// serialization of the JWS.
+	Detached bool
+}
+
+/*
+SignJWS makes the JSONHandler sign its responses with key so that clients
+can verify their authenticity end to end, even through intermediaries that
+terminate TLS. Only successful non-streaming responses are signed.
+
+Detached signatures (RFC 7515 Appendix F) are sent in the Jws-Signature
+header as a compact serialization with an empty payload, clients verify them
+against the base64url encoded response body. Otherwise the response body is
+the compact serialization itself and its Content-Type is application/jose.
+
+	jsonware.Handler(getInvoice).SignJWS(&jsonware.JWS{
+		Alg:      "ES256",
+		KeyID:    "2024-01",
+		Key:      privateKey,
+		Detached: true,
+	})
+
+SignJWS panics if the algorithm is not supported or the key doesn't suit it.
+*/
+func (j *JSONHandler) SignJWS(key *JWS) *JSONHandler {
+	ok := false
+	switch key.Alg {
+	case "HS256":
+		_, ok = key.Key.([]byte)
+	case "RS256":
+		_, ok = key.Key.(*rsa.PrivateKey)
+	case "ES256":
+		var k *ecdsa.PrivateKey
+		k, ok = key.Key.(*ecdsa.PrivateKey)
+		ok = ok && k.Curve.Params().BitSize == 256
+	case "EdDSA":
+		_, ok = key.Key.(ed25519.PrivateKey)
+	default:
+		panic(fmt.Sprintf("JWS algorithm %s is not supported", key.Alg))
+	}
+	if !ok {
+		panic(fmt.Sprintf("JWS key %T cannot be used with %s", key.Key, key.Alg))
+	}
+
+	j.jws = key
+	return j
+}
+
+var b64url = base64.RawURLEncoding
+
+// sign creates the compact serialization of a JWS over payload, leaving the
+// payload out if the signature is detached.
+func (key *JWS) sign(payload []byte) (string, error) {
+	header := map[string]string{"alg": key.Alg}
+	if len(key.KeyID) != 0 {
+		header["kid"] = key.KeyID
+	}
+	rawHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	encPayload := b64url.EncodeToString(payload)
+	input := b64url.EncodeToString(rawHeader) + "." + encPayload
+
+	var sig []byte
+	switch k := key.Key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256([]byte(input))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum[:])
+	case *ecdsa.PrivateKey:
+		sum := sha256.Sum256([]byte(input))
+		var r, s *big.Int
+		if r, s, err = ecdsa.Sign(rand.Reader, k, sum[:]); err == nil {
+			// JWS wants the raw r and s rather than asn.1.
+			sig = make([]byte, 64)
+			r.FillBytes(sig[:32])
+			s.FillBytes(sig[32:])
+		}
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(input))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if key.Detached {
+		encPayload = ""
+	}
+	return b64url.EncodeToString(rawHeader) + "." + encPayload + "." + b64url.EncodeToString(sig), nil
+}
@@ -0,0 +1,127 @@
+package jsonware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSignJWS(t *testing.T) {
+	t.Parallel()
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	hsKey := []byte("secret")
+
+	verify := map[string]func(input, sig []byte) bool{
+		"HS256": func(input, sig []byte) bool {
+			mac := hmac.New(sha256.New, hsKey)
+			mac.Write(input)
+			return hmac.Equal(mac.Sum(nil), sig)
+		},
+		"RS256": func(input, sig []byte) bool {
+			sum := sha256.Sum256(input)
+			return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, sum[:], sig) == nil
+		},
+		"ES256": func(input, sig []byte) bool {
+			sum := sha256.Sum256(input)
+			r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+			return len(sig) == 64 && ecdsa.Verify(&ecKey.PublicKey, sum[:], r, s)
+		},
+		"EdDSA": func(input, sig []byte) bool {
+			return ed25519.Verify(edKey.Public().(ed25519.PublicKey), input, sig)
+		},
+	}
+
+	var tests = []struct {
+		key *JWS
+	}{
+		{&JWS{Alg: "HS256", Key: hsKey, KeyID: "k1"}},
+		{&JWS{Alg: "HS256", Key: hsKey, Detached: true}},
+		{&JWS{Alg: "RS256", Key: rsaKey, Detached: true}},
+		{&JWS{Alg: "ES256", Key: ecKey}},
+		{&JWS{Alg: "EdDSA", Key: edKey, Detached: true}},
+	}
+
+	for i, test := range tests {
+		h := Handler(func(r *http.Request) *testType { return &testType{"bob"} }).SignJWS(test.key)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		body := `{"name":"bob"}` + "\n"
+		token := res.Body.String()
+		if test.key.Detached {
+			if res.Body.String() != body {
+				t.Errorf("Test %d: body was wrong: %s", i, res.Body.String())
+			}
+			token = res.Header().Get(JWSHeader)
+		} else if ct := res.Header().Get("Content-Type"); ct != "application/jose" {
+			t.Errorf("Test %d: content type was wrong: %s", i, ct)
+		}
+
+		parts := strings.Split(token, ".")
+		if len(parts) != 3 {
+			t.Fatalf("Test %d: token was wrong: %s", i, token)
+		}
+		if test.key.Detached {
+			if len(parts[1]) != 0 {
+				t.Errorf("Test %d: expected a detached payload", i)
+			}
+			parts[1] = b64url.EncodeToString([]byte(body))
+		} else if payload, _ := b64url.DecodeString(parts[1]); string(payload) != body {
+			t.Errorf("Test %d: payload was wrong: %s", i, payload)
+		}
+
+		rawHeader, _ := b64url.DecodeString(parts[0])
+		header := map[string]string{}
+		if err := json.Unmarshal(rawHeader, &header); err != nil {
+			t.Fatalf("Test %d: %v", i, err)
+		}
+		if header["alg"] != test.key.Alg || header["kid"] != test.key.KeyID {
+			t.Errorf("Test %d: header was wrong: %s", i, rawHeader)
+		}
+
+		sig, _ := b64url.DecodeString(parts[2])
+		if !verify[test.key.Alg]([]byte(parts[0]+"."+parts[1]), sig) {
+			t.Errorf("Test %d: signature did not verify", i)
+		}
+	}
+}
+
+func TestSignJWSPanics(t *testing.T) {
+	t.Parallel()
+
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+
+	var tests = []*JWS{
+		{Alg: "none"},
+		{Alg: "HS256", Key: "secret"},
+		{Alg: "ES256", Key: p384},
+	}
+
+	for i, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Test %d: expected a panic", i)
+				}
+			}()
+			Handler(func(r *http.Request) *testType { return nil }).SignJWS(test)
+		}()
+	}
+}
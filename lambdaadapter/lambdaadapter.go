@@ -0,0 +1,148 @@
+/*
+Package lambdaadapter runs jsonware handlers on AWS Lambda behind API Gateway.
+
+API Gateway proxy events are translated into net/http requests so that the
+same handlers, with the same binding, content negotiation and error envelope,
+can be deployed to Lambda or run in process.
+
+	reg := &jsonware.Registry{}
+	reg.Handle("GET", "/users/{id}", getUser)
+	lambda.Start(lambdaadapter.Registry(reg))
+*/
+package lambdaadapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aarondl/jsonware"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Func is a Lambda handler for API Gateway proxy events.
+type Func func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// Handler converts h, typically a JSONHandler, into a Lambda handler. Path
+// parameters that API Gateway matched are bound to the path tagged fields of
+// the handler's request object.
+func Handler(h http.Handler) Func {
+	return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		r, err := request(ctx, event)
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+		if len(event.PathParameters) != 0 {
+			params := event.PathParameters
+			r = jsonware.WithPathParams(r, func(name string) string {
+				return params[name]
+			})
+		}
+
+		w := &responseWriter{header: make(http.Header)}
+		h.ServeHTTP(w, r)
+		return w.response(), nil
+	}
+}
+
+// Registry converts all the routes of reg into a single Lambda handler,
+// routing the events by their method and path as reg.Mount would.
+func Registry(reg *jsonware.Registry) Func {
+	mux := http.NewServeMux()
+	reg.Mount(mux)
+
+	h := Handler(mux)
+	return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		// The mux matches path parameters itself.
+		event.PathParameters = nil
+		return h(ctx, event)
+	}
+}
+
+// request builds the http request for an event.
+func request(ctx context.Context, event events.APIGatewayProxyRequest) (*http.Request, error) {
+	body := []byte(event.Body)
+	if event.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(event.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	query := url.Values{}
+	for k, v := range event.QueryStringParameters {
+		query.Set(k, v)
+	}
+	for k, vs := range event.MultiValueQueryStringParameters {
+		query[k] = vs
+	}
+
+	u := &url.URL{Path: event.Path, RawQuery: query.Encode()}
+	r, err := http.NewRequestWithContext(ctx, event.HTTPMethod, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range event.Headers {
+		r.Header.Set(k, v)
+	}
+	for k, vs := range event.MultiValueHeaders {
+		r.Header.Del(k)
+		for _, v := range vs {
+			r.Header.Add(k, v)
+		}
+	}
+	r.Host = r.Header.Get("Host")
+	r.RemoteAddr = event.RequestContext.Identity.SourceIP
+
+	return r, nil
+}
+
+// responseWriter collects the response to an event.
+type responseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+func (w *responseWriter) response() events.APIGatewayProxyResponse {
+	res := events.APIGatewayProxyResponse{
+		StatusCode:        w.status,
+		Headers:           make(map[string]string, len(w.header)),
+		MultiValueHeaders: map[string][]string(w.header),
+	}
+	if res.StatusCode == 0 {
+		res.StatusCode = http.StatusOK
+	}
+	for k, vs := range w.header {
+		res.Headers[k] = strings.Join(vs, ",")
+	}
+
+	if utf8.Valid(w.body.Bytes()) {
+		res.Body = w.body.String()
+	} else {
+		res.Body = base64.StdEncoding.EncodeToString(w.body.Bytes())
+		res.IsBase64Encoded = true
+	}
+
+	return res
+}
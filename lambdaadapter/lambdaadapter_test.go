@@ -0,0 +1,116 @@
+package lambdaadapter
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/aarondl/jsonware"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+type user struct {
+	ID   int    `path:"id" json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+func getUser(r *http.Request, in *user) *user {
+	in.Role = r.URL.Query().Get("role")
+	return in
+}
+
+func updateUser(r *http.Request, in *user) (*user, error) { return in, nil }
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	reg := &jsonware.Registry{}
+	reg.Handle("GET", "/users/{id}", getUser)
+	reg.Handle("PUT", "/users/{id}", updateUser)
+
+	accept := map[string]string{"Accept": "application/json"}
+
+	var tests = []struct {
+		handler Func
+		event   events.APIGatewayProxyRequest
+		status  int
+		resbody string
+	}{
+		{
+			Handler(jsonware.Handler(getUser)),
+			events.APIGatewayProxyRequest{
+				HTTPMethod:            "GET",
+				Path:                  "/users/5",
+				Headers:               accept,
+				PathParameters:        map[string]string{"id": "5"},
+				QueryStringParameters: map[string]string{"role": "admin"},
+			},
+			200, `{"id":5,"name":"","role":"admin"}` + "\n",
+		},
+		{
+			Registry(reg),
+			events.APIGatewayProxyRequest{
+				HTTPMethod: "GET",
+				Path:       "/users/6",
+				Headers:    accept,
+				MultiValueQueryStringParameters: map[string][]string{
+					"role": {"user"},
+				},
+			},
+			200, `{"id":6,"name":"","role":"user"}` + "\n",
+		},
+		{
+			Registry(reg),
+			events.APIGatewayProxyRequest{
+				HTTPMethod:      "PUT",
+				Path:            "/users/6",
+				Headers:         accept,
+				Body:            base64.StdEncoding.EncodeToString([]byte(`{"name":"bob"}`)),
+				IsBase64Encoded: true,
+			},
+			200, `{"id":6,"name":"bob","role":""}` + "\n",
+		},
+		{
+			Registry(reg),
+			events.APIGatewayProxyRequest{
+				HTTPMethod: "GET",
+				Path:       "/users/x",
+				Headers:    accept,
+			},
+			400, `{"error":"invalid path parameter id"}` + "\n",
+		},
+		{
+			Handler(jsonware.Handler(getUser)),
+			events.APIGatewayProxyRequest{
+				HTTPMethod: "GET",
+				Path:       "/users/5",
+				Headers:    map[string]string{"Accept": "text/html"},
+			},
+			400, "this endpoint only responds to json-accepting clients",
+		},
+	}
+
+	for i, test := range tests {
+		res, err := test.handler(context.Background(), test.event)
+		if err != nil {
+			t.Errorf("Test %d: unexpected error: %v", i, err)
+			continue
+		}
+
+		if res.StatusCode != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, res.StatusCode)
+		}
+
+		if res.Body != test.resbody {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %q, got: %q", test.resbody, res.Body)
+		}
+
+		if res.StatusCode == 200 && res.Headers["Content-Type"] != "application/json" {
+			t.Errorf("Test %d: content type was wrong: %v", i, res.Headers)
+		}
+	}
+}
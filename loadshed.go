@@ -0,0 +1,161 @@
+package jsonware
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errOverloaded = errors.New("server is overloaded, try again later")
+
+// LoadStats are what a LoadShedder decides to shed requests on.
+type LoadStats struct {
+	// InFlight is how many requests are being served, including this one.
+	InFlight int
+	// P99 is the 99th percentile latency of recent requests.
+	P99 time.Duration
+	// ShedRate is the share of requests being shed, from 0 to 1.
+	ShedRate float64
+}
+
+/*
+LoadShedder rejects some of the requests to the handlers it wraps with a 503
+Service Unavailable when they're overloaded, so that overload slows some
+clients down instead of every request timing out and the failure cascading.
+
+The handlers are overloaded when more than MaxInFlight requests are being
+served at once, or when the 99th percentile latency of the last Samples
+requests is over MaxLatency. The further over a threshold they are the more
+requests are shed, twice the threshold sheds half of them, so that the load
+that gets through stays around the threshold. Either threshold is ignored
+when it's zero.
+
+	shed := &jsonware.LoadShedder{MaxInFlight: 200, MaxLatency: time.Second}
+	http.Handle("/", shed.Wrap(mux))
+*/
+type LoadShedder struct {
+	MaxInFlight int
+	MaxLatency  time.Duration
+	// Samples is how many recent latencies the 99th percentile is taken
+	// from, 1000 when it's zero.
+	Samples int
+	// MaxShedRate caps the share of requests that are shed, so some always
+	// get through to show when the overload has passed. 0.9 when it's zero.
+	MaxShedRate float64
+
+	// Metrics is called with the stats of every request and whether it was
+	// shed.
+	Metrics func(stats LoadStats, shed bool)
+	// Log is where errors are reported, the global logger is used when it's
+	// nil.
+	Log io.Writer
+
+	inFlight int64
+
+	mut       sync.Mutex
+	latencies []time.Duration
+	next      int
+	// fresh counts the latencies added since p99 was last worked out.
+	fresh int
+	p99   time.Duration
+
+	// random is swapped out in tests.
+	random func() float64
+}
+
+// Wrap returns a handler that sheds requests before serving them with h.
+func (ls *LoadShedder) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight := atomic.AddInt64(&ls.inFlight, 1)
+		defer atomic.AddInt64(&ls.inFlight, -1)
+
+		stats := ls.stats(int(inFlight))
+		shed := stats.ShedRate > 0 && ls.roll() < stats.ShedRate
+		if ls.Metrics != nil {
+			ls.Metrics(stats, shed)
+		}
+		if shed {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "1")
+			writeError(w, ls.Log, Err{Status: http.StatusServiceUnavailable, Err: errOverloaded})
+			return
+		}
+
+		start := time.Now()
+		h.ServeHTTP(w, r)
+		ls.observe(time.Since(start))
+	})
+}
+
+// stats works out the load with inFlight requests being served.
+func (ls *LoadShedder) stats(inFlight int) LoadStats {
+	ls.mut.Lock()
+	stats := LoadStats{InFlight: inFlight, P99: ls.p99}
+	ls.mut.Unlock()
+
+	// Shedding 1 - threshold/load of the requests lets the threshold's
+	// worth of them through.
+	if ls.MaxInFlight > 0 && inFlight > ls.MaxInFlight {
+		stats.ShedRate = 1 - float64(ls.MaxInFlight)/float64(inFlight)
+	}
+	if ls.MaxLatency > 0 && stats.P99 > ls.MaxLatency {
+		if rate := 1 - float64(ls.MaxLatency)/float64(stats.P99); rate > stats.ShedRate {
+			stats.ShedRate = rate
+		}
+	}
+
+	maxRate := ls.MaxShedRate
+	if maxRate <= 0 {
+		maxRate = 0.9
+	}
+	if stats.ShedRate > maxRate {
+		stats.ShedRate = maxRate
+	}
+	return stats
+}
+
+// observe records the latency of a request that was served.
+func (ls *LoadShedder) observe(latency time.Duration) {
+	if ls.MaxLatency <= 0 && ls.Metrics == nil {
+		return
+	}
+
+	ls.mut.Lock()
+	defer ls.mut.Unlock()
+
+	samples := ls.Samples
+	if samples <= 0 {
+		samples = 1000
+	}
+	if len(ls.latencies) < samples {
+		ls.latencies = append(ls.latencies, latency)
+	} else {
+		ls.latencies[ls.next] = latency
+		ls.next = (ls.next + 1) % samples
+	}
+
+	// Sorting every time would cost more than the requests, the percentile
+	// only has to keep up with trends.
+	ls.fresh++
+	if ls.fresh < samples/16 && ls.p99 != 0 {
+		return
+	}
+	ls.fresh = 0
+
+	sorted := make([]time.Duration, len(ls.latencies))
+	copy(sorted, ls.latencies)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a] < sorted[b] })
+	ls.p99 = sorted[len(sorted)*99/100]
+}
+
+func (ls *LoadShedder) roll() float64 {
+	if ls.random != nil {
+		return ls.random()
+	}
+	return rand.Float64()
+}
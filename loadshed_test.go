@@ -0,0 +1,107 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadShedderStats(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		shedder  *LoadShedder
+		inFlight int
+		p99      time.Duration
+		want     float64
+	}{
+		{&LoadShedder{}, 1000, time.Hour, 0},
+		{&LoadShedder{MaxInFlight: 10}, 10, 0, 0},
+		{&LoadShedder{MaxInFlight: 10}, 20, 0, 0.5},
+		{&LoadShedder{MaxInFlight: 10}, 1000, 0, 0.9},
+		{&LoadShedder{MaxInFlight: 10, MaxShedRate: 0.5}, 40, 0, 0.5},
+		{&LoadShedder{MaxLatency: time.Second}, 1, 4 * time.Second, 0.75},
+		{&LoadShedder{MaxInFlight: 10, MaxLatency: time.Second}, 20, 4 * time.Second, 0.75},
+		{&LoadShedder{MaxInFlight: 10, MaxLatency: time.Second}, 40, 2 * time.Second, 0.75},
+	}
+
+	for i, test := range tests {
+		test.shedder.p99 = test.p99
+		stats := test.shedder.stats(test.inFlight)
+		if stats.ShedRate != test.want {
+			t.Errorf("Test %d: expected shed rate %v, got: %v", i, test.want, stats.ShedRate)
+		}
+	}
+}
+
+func TestLoadShedderInFlight(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+
+	var mut sync.Mutex
+	var shed []bool
+	ls := &LoadShedder{
+		MaxInFlight: 1,
+		Metrics: func(stats LoadStats, s bool) {
+			mut.Lock()
+			shed = append(shed, s)
+			mut.Unlock()
+		},
+		random: func() float64 { return 0.4 },
+	}
+	h := ls.Wrap(slow)
+
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		close(done)
+	}()
+	<-started
+
+	// Two in flight sheds half, the roll of 0.4 is under it.
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+	if res.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got: %d", res.Code)
+	}
+	if res.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected a Retry-After, got: %q", res.Header().Get("Retry-After"))
+	}
+
+	close(release)
+	<-done
+
+	mut.Lock()
+	defer mut.Unlock()
+	if len(shed) != 2 || shed[0] || !shed[1] {
+		t.Errorf("expected the second request to be shed, got: %v", shed)
+	}
+}
+
+func TestLoadShedderLatency(t *testing.T) {
+	t.Parallel()
+
+	ls := &LoadShedder{MaxLatency: time.Second, Samples: 16}
+	for i := 1; i <= 16; i++ {
+		ls.observe(time.Duration(i) * 250 * time.Millisecond)
+	}
+
+	if stats := ls.stats(1); stats.P99 != 4*time.Second || stats.ShedRate != 0.75 {
+		t.Errorf("expected a p99 of 4s shedding 0.75, got: %s shedding %v", stats.P99, stats.ShedRate)
+	}
+
+	for i := 0; i < 16; i++ {
+		ls.observe(time.Millisecond)
+	}
+	if stats := ls.stats(1); stats.ShedRate != 0 {
+		t.Errorf("expected no shedding once latency recovered, got: %v (p99 %s)", stats.ShedRate, stats.P99)
+	}
+}
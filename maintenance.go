@@ -0,0 +1,117 @@
+package jsonware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var errMaintenance = errors.New("service is down for maintenance")
+
+// defaultMaintenanceRetry is the Retry-After of maintenance that doesn't say
+// when it ends.
+const defaultMaintenanceRetry = time.Minute
+
+/*
+Maintenance describes a maintenance window. While it's on, the handlers it
+covers respond to every request with a 503 Service Unavailable and a
+Retry-After header, and a body with the Message and end of the maintenance as
+its reason:
+
+	{"error":"service is down for maintenance","reason":{"message":"Upgrading the database","until":"2024-01-02T03:00:00Z"}}
+*/
+type Maintenance struct {
+	// Message is told to clients, it's left out when it's empty.
+	Message string
+	// Until is when the maintenance is expected to end, Retry-After is a
+	// minute when it's zero.
+	Until time.Time
+	// Groups are the handler groups the maintenance covers, see
+	// JSONHandler.Group. All handlers are covered when it's empty.
+	Groups []string
+}
+
+var (
+	maintenance         atomic.Pointer[Maintenance]
+	maintenanceProvider atomic.Pointer[func() *Maintenance]
+)
+
+/*
+SetMaintenance puts handlers into maintenance, or takes them out of it when
+m is nil. It's safe to call at any time, from an admin endpoint or a signal
+handler, without restarting the server.
+
+	jsonware.SetMaintenance(&jsonware.Maintenance{
+		Message: "Upgrading the database",
+		Until:   time.Now().Add(30 * time.Minute),
+		Groups:  []string{"billing"},
+	})
+*/
+func SetMaintenance(m *Maintenance) {
+	maintenance.Store(m)
+}
+
+// MaintenanceFrom makes provider decide whether handlers are in
+// maintenance, in place of SetMaintenance. It's called on every request so
+// it should be cheap, like reading a value another goroutine keeps up to date
+// from a config service. A nil provider goes back to SetMaintenance.
+func MaintenanceFrom(provider func() *Maintenance) {
+	if provider == nil {
+		maintenanceProvider.Store(nil)
+		return
+	}
+	maintenanceProvider.Store(&provider)
+}
+
+// Group names the group of handlers the JSONHandler is part of, so that
+// maintenance can cover some groups and not others and each group can have
+// its own Config.
+func (j *JSONHandler) Group(name string) *JSONHandler {
+	j.group = name
+	return j
+}
+
+// currentMaintenance finds the maintenance that covers group, if any.
+func currentMaintenance(group string) *Maintenance {
+	m := maintenance.Load()
+	if provider := maintenanceProvider.Load(); provider != nil {
+		m = (*provider)()
+	}
+	if m == nil {
+		return nil
+	}
+	if len(m.Groups) == 0 {
+		return m
+	}
+	for _, g := range m.Groups {
+		if g == group {
+			return m
+		}
+	}
+	return nil
+}
+
+// maintenanceErr is the error of a request made during m, it sets the
+// Retry-After header.
+func maintenanceErr(w http.ResponseWriter, m *Maintenance, now time.Time) error {
+	wait := defaultMaintenanceRetry
+	reason := make(map[string]interface{})
+	if len(m.Message) != 0 {
+		reason["message"] = m.Message
+	}
+	if !m.Until.IsZero() {
+		reason["until"] = m.Until.UTC().Format(time.RFC3339)
+		if wait = m.Until.Sub(now); wait < time.Second {
+			wait = time.Second
+		}
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	if len(reason) == 0 {
+		return Err{Status: http.StatusServiceUnavailable, Err: errMaintenance}
+	}
+	return Err{Status: http.StatusServiceUnavailable, Err: errMaintenance, Reason: reason}
+}
@@ -0,0 +1,59 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMaintenance isn't parallel, maintenance would affect the other tests.
+func TestMaintenance(t *testing.T) {
+	defer SetMaintenance(nil)
+	defer MaintenanceFrom(nil)
+
+	until := time.Now().Add(90 * time.Second).Truncate(time.Second)
+
+	var tests = []struct {
+		set        *Maintenance
+		provider   func() *Maintenance
+		group      string
+		status     int
+		retryAfter string
+		want       string
+	}{
+		{nil, nil, "", 200, "", `{"name":"a"}`},
+		{&Maintenance{}, nil, "", 503, "60", `{"error":"service is down for maintenance"}`},
+		{&Maintenance{Message: "db upgrade", Groups: []string{"billing"}}, nil, "billing", 503, "60",
+			`{"error":"service is down for maintenance","reason":{"message":"db upgrade"}}`},
+		{&Maintenance{Groups: []string{"billing"}}, nil, "users", 200, "", `{"name":"a"}`},
+		{&Maintenance{Until: until}, nil, "", 503, "90",
+			`{"error":"service is down for maintenance","reason":{"until":"` + until.UTC().Format(time.RFC3339) + `"}}`},
+		{&Maintenance{}, func() *Maintenance { return nil }, "", 200, "", `{"name":"a"}`},
+		{nil, func() *Maintenance { return &Maintenance{} }, "", 503, "60", `{"error":"service is down for maintenance"}`},
+	}
+
+	for i, test := range tests {
+		SetMaintenance(test.set)
+		MaintenanceFrom(test.provider)
+
+		h := Handler(func(r *http.Request) *testType { return &testType{Name: "a"} }).Group(test.group)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		// Retry-After may be a second short of until as time passes.
+		if got := res.Header().Get("Retry-After"); got != test.retryAfter && !(test.retryAfter == "90" && got == "89") {
+			t.Errorf("Test %d: expected Retry-After %q, got: %q", i, test.retryAfter, got)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
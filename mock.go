@@ -0,0 +1,203 @@
+package jsonware
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+/*
+Exampler can be implemented by a handler's output type to provide the example
+payload that mock servers respond with. It takes precedence over examples
+built from struct tags.
+
+	func (u *User) Example() interface{} {
+		return &User{ID: 5, Name: "bob"}
+	}
+*/
+type Exampler interface {
+	Example() interface{}
+}
+
+var (
+	examplerType       = reflect.TypeOf((*Exampler)(nil)).Elem()
+	jsonMarshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	emptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+/*
+Mock creates a mock server for every route in the registry. Instead of calling
+the handlers it responds with an example of each handler's output type so
+that clients can be developed against the api before the real handlers exist.
+
+Examples come from the output type's Example method if it implements
+Exampler, otherwise they're built from the type itself: fields are filled in
+from their example struct tags, pointers are allocated, and slices and maps
+are given a single element.
+
+	type User struct {
+		ID   int    `json:"id" example:"5"`
+		Name string `json:"name" example:"bob"`
+		Tags []string `json:"tags" example:"[\"admin\"]"`
+	}
+
+Example tags on string fields are used verbatim, for everything else they are
+json. Mock panics if an example tag cannot be deserialized into its field.
+Handlers returning interface{} have no type to build an example from, so
+their mock responds with a 501 error.
+*/
+func (reg *Registry) Mock() http.Handler {
+	mux := http.NewServeMux()
+
+	for _, route := range reg.routes {
+		body, err := mockBody(route.Handler.out)
+		if err != nil {
+			panic(fmt.Sprintf("Cannot mock %s %s: %v", route.Method, route.Pattern, err))
+		}
+
+		mux.Handle(route.Method+" "+route.Pattern, mockHandler(body))
+	}
+
+	return mux
+}
+
+func mockHandler(body []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if body == nil {
+			writeError(w, nil, Err{
+				Status: http.StatusNotImplemented,
+				Err:    errors.New("no example is available for this endpoint"),
+			})
+			return
+		}
+		w.Write(body)
+	}
+}
+
+// mockBody serializes an example of typ, or returns nil if there's no way of
+// knowing what typ should look like.
+func mockBody(typ reflect.Type) ([]byte, error) {
+	if typ == nil || typ == emptyInterfaceType || streamKindOf(typ) != streamNone {
+		return nil, nil
+	}
+
+	v, err := example(typ, make(map[reflect.Type]bool))
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err = json.NewEncoder(buf).Encode(v.Interface()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// example builds an example value of typ. seen holds the struct types that
+// are currently being built to stop recursive types from recursing forever.
+func example(typ reflect.Type, seen map[reflect.Type]bool) (reflect.Value, error) {
+	var ex Exampler
+	switch {
+	case typ.Kind() == reflect.Ptr && typ.Implements(examplerType):
+		ex = reflect.New(typ.Elem()).Interface().(Exampler)
+	case typ.Kind() != reflect.Interface && typ.Implements(examplerType):
+		ex = reflect.Zero(typ).Interface().(Exampler)
+	case reflect.PtrTo(typ).Implements(examplerType):
+		ex = reflect.New(typ).Interface().(Exampler)
+	}
+	if ex != nil {
+		v := reflect.ValueOf(ex.Example())
+		switch {
+		case v.IsValid() && v.Type().AssignableTo(typ):
+			return v, nil
+		case v.IsValid() && v.Kind() == reflect.Ptr && v.Type().Elem() == typ && !v.IsNil():
+			return v.Elem(), nil
+		}
+		return v, fmt.Errorf("%s.Example returned %T", typ, ex.Example())
+	}
+
+	v := reflect.New(typ).Elem()
+
+	// Types that serialize themselves are left alone.
+	if typ.Implements(jsonMarshalerType) || typ.Implements(textMarshalerType) ||
+		reflect.PtrTo(typ).Implements(jsonMarshalerType) || reflect.PtrTo(typ).Implements(textMarshalerType) {
+		return v, nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		if seen[typ.Elem()] {
+			return v, nil
+		}
+		elem, err := example(typ.Elem(), seen)
+		if err != nil {
+			return v, err
+		}
+		p := reflect.New(typ.Elem())
+		p.Elem().Set(elem)
+		return p, nil
+	case reflect.Slice:
+		if seen[typ.Elem()] {
+			return reflect.MakeSlice(typ, 0, 0), nil
+		}
+		elem, err := example(typ.Elem(), seen)
+		if err != nil {
+			return v, err
+		}
+		return reflect.Append(reflect.MakeSlice(typ, 0, 1), elem), nil
+	case reflect.Map:
+		m := reflect.MakeMap(typ)
+		if typ.Key().Kind() != reflect.String || seen[typ.Elem()] {
+			return m, nil
+		}
+		elem, err := example(typ.Elem(), seen)
+		if err != nil {
+			return v, err
+		}
+		m.SetMapIndex(reflect.ValueOf("key").Convert(typ.Key()), elem)
+		return m, nil
+	case reflect.Struct:
+		seen[typ] = true
+		defer delete(seen, typ)
+
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+
+			if tag, ok := field.Tag.Lookup("example"); ok {
+				if err := setExample(v.Field(i), tag); err != nil {
+					return v, fmt.Errorf("bad example tag on %s.%s: %v", typ.Name(), field.Name, err)
+				}
+				continue
+			}
+
+			fv, err := example(field.Type, seen)
+			if err != nil {
+				return v, err
+			}
+			v.Field(i).Set(fv)
+		}
+	}
+
+	return v, nil
+}
+
+func setExample(v reflect.Value, tag string) error {
+	if v.Kind() == reflect.String {
+		v.SetString(tag)
+		return nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(tag))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v.Addr().Interface())
+}
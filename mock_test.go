@@ -0,0 +1,100 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockUser struct {
+	ID      int               `json:"id" example:"5"`
+	Name    string            `json:"name" example:"bob"`
+	Tags    []string          `json:"tags" example:"[\"admin\"]"`
+	Created time.Time         `json:"created"`
+	Friend  *mockUser         `json:"friend"`
+	Pets    []mockPet         `json:"pets"`
+	Meta    map[string]string `json:"meta"`
+	secret  string
+}
+
+type mockPet struct {
+	Name string `json:"name" example:"rex"`
+}
+
+type mockExample struct {
+	Name string `json:"name"`
+}
+
+func (m *mockExample) Example() interface{} {
+	return &mockExample{Name: "from example"}
+}
+
+type badExample struct {
+	Count int `json:"count" example:"lots"`
+}
+
+func TestMock(t *testing.T) {
+	t.Parallel()
+
+	reg := &Registry{}
+	reg.Handle("GET", "/users/{id}", func(w http.ResponseWriter, r *http.Request) (*mockUser, error) {
+		return nil, nil
+	})
+	reg.Handle("GET", "/examples", func(w http.ResponseWriter, r *http.Request) ([]*mockExample, error) {
+		return nil, nil
+	})
+	reg.Handle("POST", "/anything", testHandler1)
+
+	mock := reg.Mock()
+
+	var tests = []struct {
+		method  string
+		path    string
+		status  int
+		resbody string
+	}{
+		{"GET", "/users/5", 200, `{"id":5,"name":"bob","tags":["admin"],"created":"0001-01-01T00:00:00Z","friend":null,"pets":[{"name":"rex"}],"meta":{"key":""}}`},
+		{"GET", "/examples", 200, `[{"name":"from example"}]`},
+		{"POST", "/anything", 501, `{"error":"no example is available for this endpoint"}`},
+		{"POST", "/users/5", 405, ""},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, nil)
+		mock.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, res.Code)
+		}
+
+		if b := strings.TrimSpace(res.Body.String()); test.resbody != "" && b != test.resbody {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+	}
+}
+
+func TestMockBadExample(t *testing.T) {
+	t.Parallel()
+
+	reg := &Registry{}
+	reg.Handle("GET", "/bad", func(w http.ResponseWriter, r *http.Request) (*badExample, error) {
+		return nil, nil
+	})
+
+	defer func() {
+		err := recover()
+		if err == nil {
+			t.Fatal("Expected a panic")
+		}
+		if msg := err.(string); !strings.Contains(msg, "bad example tag on badExample.Count") {
+			t.Error("Panic message was wrong:", msg)
+		}
+	}()
+
+	reg.Mock()
+}
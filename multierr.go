@@ -0,0 +1,106 @@
+package jsonware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+/*
+MultiErr reports several failures at once, like every validation failure of
+a composite operation instead of only the first. It's sent as an errors
+array with the status, message and reason of each Err:
+
+	{"errors":[{"status":422,"error":"name is required"},{"status":409,"error":"email is taken"}]}
+
+The response's status is the status the errors share, or 400 Bad Request when
+they differ and 500 Internal Server Error when any of them is a 5xx. Errors
+without a status count as 400s.
+
+	var errs jsonware.MultiErr
+	for _, item := range order.Items {
+		if err := reserve(item); err != nil {
+			errs = append(errs, jsonware.Err{Status: http.StatusConflict, Err: err})
+		}
+	}
+	return nil, errs.ErrOrNil()
+*/
+type MultiErr []Err
+
+// Error joins the messages of the errors.
+func (m MultiErr) Error() string {
+	msgs := make([]string, len(m))
+	for i, e := range m {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors for errors.Is and errors.As.
+func (m MultiErr) Unwrap() []error {
+	errs := make([]error, len(m))
+	for i, e := range m {
+		errs[i] = e
+	}
+	return errs
+}
+
+// StatusCode is the status the response is sent with.
+func (m MultiErr) StatusCode() int {
+	status := 0
+	for _, e := range m {
+		s := e.Status
+		if s == 0 {
+			s = http.StatusBadRequest
+		}
+		switch {
+		case s >= 500:
+			return http.StatusInternalServerError
+		case status == 0:
+			status = s
+		case s != status:
+			status = http.StatusBadRequest
+		}
+	}
+	if status == 0 {
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+// ErrOrNil returns nil when there are no errors, so that an empty MultiErr
+// isn't returned as a non-nil error.
+func (m MultiErr) ErrOrNil() error {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// writeMultiErr writes the errors of m out to the response.
+func writeMultiErr(w http.ResponseWriter, logger io.Writer, m MultiErr) {
+	type item struct {
+		Status int         `json:"status,omitempty"`
+		Error  string      `json:"error"`
+		Reason interface{} `json:"reason,omitempty"`
+	}
+	items := make([]item, len(m))
+	for i, e := range m {
+		items[i] = item{Status: e.Status, Error: e.Err.Error(), Reason: e.Reason}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(map[string]interface{}{"errors": items}); err != nil {
+		logf(logger, "failed to serialize err: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, `{"error":"an internal server error occurred"}`)
+		return
+	}
+
+	w.WriteHeader(m.StatusCode())
+	if _, err := io.Copy(w, buf); err != nil {
+		logf(logger, "failed to send response: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package jsonware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMultiErr(t *testing.T) {
+	t.Parallel()
+
+	errTaken := errors.New("email is taken")
+	var tests = []struct {
+		err    error
+		status int
+		want   string
+	}{
+		{
+			MultiErr{{Status: 422, Err: errors.New("name is required"), Reason: map[string]string{"name": "required"}}, {Status: 422, Err: errors.New("age is negative")}},
+			422,
+			`{"errors":[{"status":422,"error":"name is required","reason":{"name":"required"}},{"status":422,"error":"age is negative"}]}`,
+		},
+		{MultiErr{{Status: 422, Err: errors.New("a")}, {Status: 409, Err: errTaken}}, 400, `{"errors":[{"status":422,"error":"a"},{"status":409,"error":"email is taken"}]}`},
+		{MultiErr{{Status: 404, Err: errors.New("a")}, {Err: errors.New("b")}}, 400, `{"errors":[{"status":404,"error":"a"},{"error":"b"}]}`},
+		{MultiErr{{Status: 409, Err: errors.New("a")}, {Status: 503, Err: errors.New("b")}}, 500, `{"errors":[{"status":409,"error":"a"},{"status":503,"error":"b"}]}`},
+		{MultiErr{}, 500, `{"error":"an internal server error occurred"}`},
+	}
+
+	for i, test := range tests {
+		err := test.err
+		h := Handler(func(r *http.Request) (*testType, error) { return nil, err }).Log(&strings.Builder{})
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+
+	m := MultiErr{{Status: 422, Err: errors.New("a")}, {Status: 409, Err: errTaken}}
+	if !errors.Is(m, errTaken) {
+		t.Error("expected the errors to be unwrapped")
+	}
+	if m.Error() != "a; email is taken" {
+		t.Errorf("expected the messages to be joined, got: %s", m.Error())
+	}
+	if MultiErr(nil).ErrOrNil() != nil || m.ErrOrNil() == nil {
+		t.Error("expected only an empty MultiErr to be nil")
+	}
+}
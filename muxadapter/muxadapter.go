@@ -0,0 +1,81 @@
+/*
+Package muxadapter registers jsonware handlers on gorilla/mux routers.
+
+Route variables matched by mux are bound to the path tagged fields of the
+handlers' request objects, and requests with the wrong method get a json 405
+error with an Allow header listing the methods the path supports.
+
+	reg := &jsonware.Registry{}
+	reg.Handle("GET", "/users/{id}", getUser)
+	reg.Handle("POST", "/users", createUser)
+
+	r := mux.NewRouter()
+	muxadapter.RegisterRoutes(r, reg.Routes())
+*/
+package muxadapter
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/aarondl/jsonware"
+	"github.com/gorilla/mux"
+)
+
+// methods are the methods checked when working out which methods a path
+// supports.
+var methods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// RegisterRoutes registers each of the routes on r, GET routes answer HEAD
+// requests as well like they do on an http.ServeMux.
+func RegisterRoutes(r *mux.Router, routes []jsonware.Route) {
+	for _, route := range routes {
+		routeMethods := []string{route.Method}
+		if route.Method == http.MethodGet {
+			routeMethods = append(routeMethods, http.MethodHead)
+		}
+		r.Handle(route.Pattern, Bind(route.Handler)).Methods(routeMethods...)
+	}
+
+	if r.MethodNotAllowedHandler == nil {
+		r.MethodNotAllowedHandler = methodNotAllowed(r)
+	}
+}
+
+// Bind wraps h so that it receives the route variables mux matched as path
+// parameters.
+func Bind(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		h.ServeHTTP(w, jsonware.WithPathParams(r, func(name string) string {
+			return vars[name]
+		}))
+	})
+}
+
+// methodNotAllowed writes a 405 with the methods router has routes for on
+// the request's path.
+func methodNotAllowed(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allowed(router, r), ", "))
+		jsonware.WriteError(w, nil, jsonware.Err{
+			Status: http.StatusMethodNotAllowed,
+			Err:    errors.New("method not allowed"),
+		})
+	})
+}
+
+// allowed finds the methods router has routes for on the request's path.
+func allowed(router *mux.Router, r *http.Request) []string {
+	var allow []string
+	for _, m := range methods {
+		req := *r
+		req.Method = m
+		var match mux.RouteMatch
+		if router.Match(&req, &match) && match.MatchErr == nil {
+			allow = append(allow, m)
+		}
+	}
+	return allow
+}
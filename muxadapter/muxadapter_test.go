@@ -0,0 +1,67 @@
+package muxadapter
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aarondl/jsonware"
+	"github.com/gorilla/mux"
+)
+
+type user struct {
+	ID   int    `path:"id" json:"id"`
+	Name string `json:"name"`
+}
+
+func TestRegisterRoutes(t *testing.T) {
+	t.Parallel()
+
+	reg := &jsonware.Registry{}
+	reg.Handle("GET", "/users/{id}", func(r *http.Request, in *user) *user { return in })
+	reg.Handle("PUT", "/users/{id:[0-9]+}", func(r *http.Request, in *user) (*user, error) { return in, nil })
+
+	r := mux.NewRouter()
+	RegisterRoutes(r, reg.Routes())
+
+	var tests = []struct {
+		method  string
+		path    string
+		reqbody string
+		status  int
+		allow   string
+		resbody string
+	}{
+		{"GET", "/users/5", "", 200, "", `{"id":5,"name":""}`},
+		{"GET", "/users/x", "", 400, "", `{"error":"invalid path parameter id"}`},
+		{"HEAD", "/users/5", "", 200, "", ``},
+		{"PUT", "/users/5", `{"name":"bob"}`, 200, "", `{"id":5,"name":"bob"}`},
+		{"DELETE", "/users/5", "", 405, "GET, HEAD, PUT", `{"error":"method not allowed"}`},
+		{"DELETE", "/users/x", "", 405, "GET, HEAD", `{"error":"method not allowed"}`},
+		{"GET", "/nothing", "", 404, "", "404 page not found"},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, bytes.NewBufferString(test.reqbody))
+		req.Header.Set("Accept", "application/json")
+		r.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, res.Code)
+		}
+
+		if allow := res.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected allow: %q, got: %q", test.allow, allow)
+		}
+
+		if b := strings.TrimSpace(res.Body.String()); b != test.resbody {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package jsonware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+/*
+Page is a page of the items of a list, for list handlers to respond with.
+Items is sent as a json array, along with the X-Total-Count header holding
+Total and a Content-Range header like items 20-29/134 saying which of them
+it is, the headers data grids read to page through a list.
+
+	func listUsers(r *http.Request, in *ListUsers) (*jsonware.Page[*User], error) {
+		users, total, err := db.Users(r.Context(), in.Offset, in.Limit)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonware.Page[*User]{Items: users, Offset: in.Offset, Total: total}, nil
+	}
+
+An empty page has a * in place of the range in its Content-Range. The
+headers are exposed to cross origin clients with
+Access-Control-Expose-Headers.
+*/
+type Page[T any] struct {
+	Items []T
+	// Offset is the index of the first item of the page in the list.
+	Offset int
+	// Total is how many items the list has.
+	Total int
+}
+
+// MarshalJSON serializes the Items.
+func (p *Page[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.items())
+}
+
+func (p *Page[T]) envelope() (interface{}, http.Header, int) {
+	rng := "items */" + strconv.Itoa(p.Total)
+	if len(p.Items) != 0 {
+		rng = fmt.Sprintf("items %d-%d/%d", p.Offset, p.Offset+len(p.Items)-1, p.Total)
+	}
+	header := http.Header{
+		"X-Total-Count":                 {strconv.Itoa(p.Total)},
+		"Content-Range":                 {rng},
+		"Access-Control-Expose-Headers": {"X-Total-Count, Content-Range"},
+	}
+	return p.items(), header, 0
+}
+
+// items is the Items, an empty slice rather than nil so that it's sent as
+// [].
+func (p *Page[T]) items() []T {
+	if p.Items == nil {
+		return []T{}
+	}
+	return p.Items
+}
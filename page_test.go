@@ -0,0 +1,46 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPage(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		page  *Page[testType]
+		want  string
+		total string
+		rng   string
+	}{
+		{&Page[testType]{Items: []testType{{Name: "a"}, {Name: "b"}}, Offset: 20, Total: 134}, `[{"name":"a"},{"name":"b"}]`, "134", "items 20-21/134"},
+		{&Page[testType]{Items: []testType{{Name: "a"}}, Total: 1}, `[{"name":"a"}]`, "1", "items 0-0/1"},
+		{&Page[testType]{Offset: 140, Total: 134}, `[]`, "134", "items */134"},
+	}
+
+	for i, test := range tests {
+		page := test.page
+		h := Handler(func(r *http.Request) (*Page[testType], error) { return page, nil })
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/users", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("Test %d: expected status 200, got: %d", i, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+		if got := res.Header().Get("X-Total-Count"); got != test.total {
+			t.Errorf("Test %d: expected X-Total-Count %s, got: %s", i, test.total, got)
+		}
+		if got := res.Header().Get("Content-Range"); got != test.rng {
+			t.Errorf("Test %d: expected Content-Range %q, got: %q", i, test.rng, got)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package jsonware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+/*
+Partial is the response of a composite handler that does several things at
+once, like updating three resources, where some parts may succeed while
+others fail. It's sent as the result of every part in the order they were
+recorded:
+
+	{"results":[{"id":"a","status":200,"value":{...}},{"id":"b","status":409,"error":"version conflict"}]}
+
+The response is a 200 OK when every part succeeded and a 207 Multi-Status
+when any failed. Parts that failed with an Err are reported with its status,
+message and reason, other errors are cloaked and logged like a handler's
+errors are. It's safe to record parts from several goroutines.
+
+	func updateAll(r *http.Request, req *BatchUpdate) (*jsonware.Partial, error) {
+		p := &jsonware.Partial{}
+		for _, u := range req.Updates {
+			if user, err := update(u); err != nil {
+				p.Fail(u.ID, err)
+			} else {
+				p.Succeed(u.ID, user)
+			}
+		}
+		return p, nil
+	}
+*/
+type Partial struct {
+	mut   sync.Mutex
+	parts []part
+}
+
+type part struct {
+	ID     string      `json:"id"`
+	Status int         `json:"status"`
+	Value  interface{} `json:"value,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	Reason interface{} `json:"reason,omitempty"`
+
+	err error
+}
+
+// Succeed records the part id as a success, v is sent as its value and may
+// be nil.
+func (p *Partial) Succeed(id string, v interface{}) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.parts = append(p.parts, part{ID: id, Status: http.StatusOK, Value: v})
+}
+
+// Fail records the part id as a failure.
+func (p *Partial) Fail(id string, err error) {
+	pt := part{ID: id, Status: http.StatusInternalServerError, Error: "an internal server error occurred", err: err}
+	if e, ok := asErr(err); ok {
+		if e.Status != 0 {
+			pt.Status = e.Status
+		}
+		pt.Error, pt.Reason, pt.err = e.Err.Error(), e.Reason, nil
+	} else {
+		pt.Status = cloakedStatus(err)
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.parts = append(p.parts, pt)
+}
+
+// Failed is how many parts failed.
+func (p *Partial) Failed() int {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	n := 0
+	for _, pt := range p.parts {
+		if pt.Status >= 300 {
+			n++
+		}
+	}
+	return n
+}
+
+// StatusCode is 200 OK when every part succeeded and 207 Multi-Status when
+// any didn't.
+func (p *Partial) StatusCode() int {
+	if p.Failed() != 0 {
+		return http.StatusMultiStatus
+	}
+	return http.StatusOK
+}
+
+// MarshalJSON serializes the results of the parts.
+func (p *Partial) MarshalJSON() ([]byte, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	parts := p.parts
+	if parts == nil {
+		parts = []part{}
+	}
+	return json.Marshal(struct {
+		Results []part `json:"results"`
+	}{parts})
+}
+
+// logErrors logs the cloaked errors of the parts.
+func (p *Partial) logErrors(logger io.Writer, r *http.Request) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	for _, pt := range p.parts {
+		if pt.err != nil {
+			logf(logger, "part %s of %s %s failed: %s", pt.ID, r.Method, r.URL.Path, formatErr(pt.err))
+		}
+	}
+}
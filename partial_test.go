@@ -0,0 +1,57 @@
+package jsonware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPartial(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		fill   func(p *Partial)
+		status int
+		want   string
+		log    string
+	}{
+		{func(p *Partial) {}, 200, `{"results":[]}`, ``},
+		{func(p *Partial) {
+			p.Succeed("a", &testType{Name: "x"})
+			p.Succeed("b", nil)
+		}, 200, `{"results":[{"id":"a","status":200,"value":{"name":"x"}},{"id":"b","status":200}]}`, ``},
+		{func(p *Partial) {
+			p.Succeed("a", nil)
+			p.Fail("b", Err{Status: http.StatusConflict, Err: errors.New("version conflict"), Reason: "stale"})
+			p.Fail("c", errors.New("db is down"))
+		}, 207, `{"results":[{"id":"a","status":200},{"id":"b","status":409,"error":"version conflict","reason":"stale"},` +
+			`{"id":"c","status":500,"error":"an internal server error occurred"}]}`, `part c of POST /batch failed: db is down`},
+	}
+
+	for i, test := range tests {
+		fill := test.fill
+		logs := &strings.Builder{}
+		h := Handler(func(r *http.Request, in *testType) (*Partial, error) {
+			p := &Partial{}
+			fill(p)
+			return p, nil
+		}).Log(logs)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/batch", strings.NewReader(`{}`))
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected:\n%s\ngot:\n%s", i, test.want, b)
+		}
+		if l := logs.String(); l != test.log {
+			t.Errorf("Test %d: expected log %q, got: %q", i, test.log, l)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package jsonware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Principal is the identity of a client that authenticated with a verified
+// tls client certificate.
+type Principal struct {
+	CommonName     string
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []string
+	// Fingerprint is the hex encoded SHA-256 of the certificate.
+	Fingerprint string
+
+	Certificate *x509.Certificate
+}
+
+type principalKey struct{}
+
+// PrincipalFrom retrieves the Principal of the client that made the request
+// from its context. JSONHandlers put it there when the server verified a
+// client certificate.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// WithPrincipal returns a copy of ctx that carries the principal, this is
+// useful for testing handlers and for servers behind a proxy that
+// terminates mtls and passes the identity along some other way.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+var (
+	errNoClientCert     = errors.New("client certificate required")
+	errClientNotAllowed = errors.New("client certificate is not allowed")
+)
+
+func init() {
+	// Handlers may take the Principal as an argument directly.
+	RegisterExtractor(func(r *http.Request) (Principal, error) {
+		p, ok := PrincipalFrom(r.Context())
+		if !ok {
+			return Principal{}, Err{Status: http.StatusUnauthorized, Err: errNoClientCert}
+		}
+		return p, nil
+	})
+}
+
+// withCertPrincipal puts the Principal of the verified client certificate
+// of the request, if any, into its context.
+func withCertPrincipal(r *http.Request) *http.Request {
+	if r.TLS == nil {
+		return r
+	}
+	if _, ok := PrincipalFrom(r.Context()); ok {
+		return r
+	}
+	p, ok := certPrincipal(r.TLS)
+	if !ok {
+		return r
+	}
+	return r.WithContext(WithPrincipal(r.Context(), p))
+}
+
+// certPrincipal creates the Principal of a connection's verified client
+// certificate. Certificates that were presented but not verified don't
+// count.
+func certPrincipal(state *tls.ConnectionState) (Principal, bool) {
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return Principal{}, false
+	}
+
+	cert := state.VerifiedChains[0][0]
+	sum := sha256.Sum256(cert.Raw)
+	p := Principal{
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		Fingerprint:    hex.EncodeToString(sum[:]),
+		Certificate:    cert,
+	}
+	for _, u := range cert.URIs {
+		p.URIs = append(p.URIs, u.String())
+	}
+
+	return p, true
+}
+
+// matches checks if the principal is identified by id, which is compared
+// against the common name, subject alternative names and fingerprint.
+func (p Principal) matches(id string) bool {
+	if len(id) == 0 {
+		return false
+	}
+	if strings.EqualFold(id, p.CommonName) {
+		return true
+	}
+	for _, names := range [][]string{p.DNSNames, p.EmailAddresses, p.URIs} {
+		for _, name := range names {
+			if strings.EqualFold(id, name) {
+				return true
+			}
+		}
+	}
+
+	fingerprint := strings.ToLower(strings.ReplaceAll(id, ":", ""))
+	return len(p.Fingerprint) != 0 && fingerprint == p.Fingerprint
+}
+
+/*
+AllowClients restricts the JSONHandler to clients whose verified tls client
+certificate is identified by one of ids. An id matches a certificate's
+common name, any of its DNS, email or URI subject alternative names, or its
+SHA-256 fingerprint in hex, with or without colons. Requests without a client
+certificate get a 401 and clients that aren't allowed get a 403.
+
+	jsonware.Handler(settle).AllowClients("payments.internal", "ledger.internal")
+*/
+func (j *JSONHandler) AllowClients(ids ...string) *JSONHandler {
+	j.allowClients = append(j.allowClients, ids...)
+	return j
+}
+
+// checkClient checks the request's principal against the allowed clients.
+func (j JSONHandler) checkClient(r *http.Request) error {
+	p, ok := PrincipalFrom(r.Context())
+	if !ok {
+		return Err{Status: http.StatusUnauthorized, Err: errNoClientCert}
+	}
+	for _, id := range j.allowClients {
+		if p.matches(id) {
+			return nil
+		}
+	}
+	return Err{Status: http.StatusForbidden, Err: errClientNotAllowed}
+}
@@ -0,0 +1,109 @@
+package jsonware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func testClientCert(t *testing.T, cn string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spiffe, _ := url.Parse("spiffe://example.com/ledger")
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: cn},
+		DNSNames:       []string{cn + ".internal"},
+		EmailAddresses: []string{cn + "@example.com"},
+		URIs:           []*url.URL{spiffe},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestPrincipal(t *testing.T) {
+	t.Parallel()
+
+	cert := testClientCert(t, "ledger")
+	sum := sha256.Sum256(cert.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	whoami := func(r *http.Request, p Principal) *testType {
+		from, _ := PrincipalFrom(r.Context())
+		return &testType{Name: p.CommonName + "/" + from.Fingerprint}
+	}
+
+	var tests = []struct {
+		handler *JSONHandler
+		tls     *tls.ConnectionState
+		status  int
+		resbody string
+	}{
+		{Handler(whoami), &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, 200, `{"name":"ledger/` + fingerprint + `"}`},
+		{Handler(whoami), nil, 401, `{"error":"client certificate required"}`},
+		// Presented but unverified certificates don't count.
+		{Handler(whoami), &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}, 401, `{"error":"client certificate required"}`},
+		{Handler(whoami).AllowClients("payments", "LEDGER"), &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, 200, `{"name":"ledger/` + fingerprint + `"}`},
+		{Handler(whoami).AllowClients("ledger.internal"), &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, 200, `{"name":"ledger/` + fingerprint + `"}`},
+		{Handler(whoami).AllowClients("ledger@example.com"), &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, 200, `{"name":"ledger/` + fingerprint + `"}`},
+		{Handler(whoami).AllowClients("spiffe://example.com/ledger"), &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, 200, `{"name":"ledger/` + fingerprint + `"}`},
+		{Handler(whoami).AllowClients(fingerprint[:2] + ":" + fingerprint[2:]), &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, 200, `{"name":"ledger/` + fingerprint + `"}`},
+		{Handler(whoami).AllowClients("payments"), &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, 403, `{"error":"client certificate is not allowed"}`},
+		{Handler(whoami).AllowClients("ledger"), nil, 401, `{"error":"client certificate required"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		req.TLS = test.tls
+		test.handler.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := res.Body.String(); b != test.resbody+"\n" {
+			t.Errorf("Test %d: body was wrong: %s", i, b)
+		}
+	}
+}
+
+func TestWithPrincipal(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(func(r *http.Request, p Principal) *testType { return &testType{p.CommonName} }).AllowClients("proxy")
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	req = req.WithContext(WithPrincipal(req.Context(), Principal{CommonName: "proxy"}))
+	h.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK || res.Body.String() != `{"name":"proxy"}`+"\n" {
+		t.Errorf("Wrong response: %d %s", res.Code, res.Body.String())
+	}
+}
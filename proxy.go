@@ -0,0 +1,245 @@
+package jsonware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+ProxyHandler forwards requests to an upstream server and can transform the
+json bodies of requests and responses on the way through. It can be
+constructed by passing the upstream url to the Proxy function.
+
+Transforms are typed in the same way as handlers, the json is deserialized
+into the transform's input type, the transform may modify it or return
+something else entirely, and the result is serialized again before being
+sent on.
+
+	// Rename a field before the client sees it.
+	func rename(res *http.Response, u *upstreamUser) (*User, error) {
+		return &User{Name: u.FullName}, nil
+	}
+
+	http.Handle("/users/", Proxy(upstream).Response(rename).Log(myLogger))
+
+Errors returned from transforms are handled exactly like errors returned from
+a JSONHandler, see Err for details.
+*/
+type ProxyHandler struct {
+	logger io.Writer
+	proxy  *httputil.ReverseProxy
+
+	requestFns  []transform
+	responseFns []transform
+}
+
+// transform is a validated json transformation function.
+type transform struct {
+	fn reflect.Value
+	in reflect.Type
+}
+
+// Proxy creates a ProxyHandler that forwards to target. The request path is
+// joined to target's path in the same way as httputil's single host proxy.
+func Proxy(target *url.URL) *ProxyHandler {
+	p := &ProxyHandler{
+		proxy: httputil.NewSingleHostReverseProxy(target),
+	}
+	p.proxy.ModifyResponse = p.modifyResponse
+	p.proxy.ErrorHandler = p.errorHandler
+	return p
+}
+
+// Log sets the ProxyHandler's logging io.Writer for writing out cloaked errors.
+func (p *ProxyHandler) Log(logger io.Writer) *ProxyHandler {
+	p.logger = logger
+	return p
+}
+
+/*
+Request adds a transform for the json body of incoming requests. Transforms
+are run in the order they were added. Only requests that carry a body (see
+JSONHandler for which methods carry bodies) are transformed.
+
+	func Fn(r *http.Request, m *MyStruct) (interface{}, error)
+	func Fn(r *http.Request, m []*MyStruct) (*OtherStruct, error)
+	func Fn(r *http.Request, m map[string]*MyStruct) (map[string]string, error)
+*/
+func (p *ProxyHandler) Request(fn interface{}) *ProxyHandler {
+	p.requestFns = append(p.requestFns, newTransform(fn, "*http.Request"))
+	return p
+}
+
+/*
+Response adds a transform for the json body of upstream responses. Transforms
+are run in the order they were added. Only successful (2xx) responses with a
+json Content-Type are transformed.
+
+	func Fn(res *http.Response, m *MyStruct) (interface{}, error)
+	func Fn(res *http.Response, m []*MyStruct) (*OtherStruct, error)
+	func Fn(res *http.Response, m map[string]*MyStruct) (map[string]string, error)
+*/
+func (p *ProxyHandler) Response(fn interface{}) *ProxyHandler {
+	p.responseFns = append(p.responseFns, newTransform(fn, "*http.Response"))
+	return p
+}
+
+// ServeHTTP proxies the request, see ProxyHandler documentation for details.
+func (p *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	transformBody := len(p.requestFns) != 0 && r.Body != nil && isDataMethod(r.Method)
+	if transformBody || len(p.responseFns) != 0 {
+		// What's sent upstream is changed on a copy, the caller's request is
+		// left alone.
+		r2 := *r
+		r2.Header = r.Header.Clone()
+		r = &r2
+	}
+
+	if transformBody {
+		body, err := runTransforms(p.requestFns, reflect.ValueOf(r), r.Body, Err{
+			Status: http.StatusBadRequest,
+			Err:    errors.New("could not deserialize json request body"),
+		})
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, p.logger, err)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	if len(p.responseFns) != 0 {
+		// Let the transport negotiate compression so that the body
+		// we get back is always plain json.
+		r.Header.Del("Accept-Encoding")
+	}
+
+	p.proxy.ServeHTTP(w, r)
+}
+
+func (p *ProxyHandler) modifyResponse(res *http.Response) error {
+	if len(p.responseFns) == 0 || res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil
+	}
+	if !strings.Contains(res.Header.Get("Content-Type"), "json") {
+		return nil
+	}
+
+	body, err := runTransforms(p.responseFns, reflect.ValueOf(res), res.Body, Err{
+		Status: http.StatusBadGateway,
+		Err:    errors.New("could not deserialize upstream response"),
+	})
+	if err != nil {
+		return err
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+	res.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+func (p *ProxyHandler) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch e := err.(type) {
+	case proxyErr:
+		// Errors returned from transforms are treated like handler errors.
+		writeError(w, p.logger, e.error)
+	case Err:
+		writeError(w, p.logger, e)
+	default:
+		logf(p.logger, "proxy error: %v", err)
+		writeError(w, p.logger, Err{
+			Status: http.StatusBadGateway,
+			Err:    errors.New("upstream server unavailable"),
+		})
+	}
+}
+
+// proxyErr marks an error that came from a transform function rather than
+// from the upstream connection.
+type proxyErr struct {
+	error
+}
+
+func (p proxyErr) Unwrap() error { return p.error }
+
+// runTransforms deserializes body and passes it through each transform in
+// turn, returning the serialized result of the final transform. badJSON is
+// returned if the body cannot be deserialized.
+func runTransforms(fns []transform, first reflect.Value, body io.ReadCloser, badJSON Err) ([]byte, error) {
+	raw, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, proxyErr{err}
+	}
+
+	for _, t := range fns {
+		ptr, arg := allocate(t.in)
+		if err = json.Unmarshal(raw, ptr.Interface()); err != nil {
+			return nil, badJSON
+		}
+
+		out := t.fn.Call([]reflect.Value{first, arg})
+		if !out[1].IsNil() {
+			return nil, proxyErr{out[1].Interface().(error)}
+		}
+
+		if raw, err = json.Marshal(out[0].Interface()); err != nil {
+			return nil, proxyErr{err}
+		}
+	}
+
+	return raw, nil
+}
+
+// newTransform validates a transform function in the same way that Handler
+// validates handlers. first is the name of the type of the first argument.
+func newTransform(fn interface{}, first string) transform {
+	typ := reflect.TypeOf(fn)
+	if typ == nil || typ.Kind() != reflect.Func {
+		panic("Can only register functions.")
+	}
+
+	if typ.NumIn() != 2 {
+		panic(fmt.Sprintf("Transform must have 2 arguments: %s, Object", first))
+	}
+
+	if first != typ.In(0).String() {
+		panic(fmt.Sprintf("First argument must be a %s", first))
+	}
+
+	in := typ.In(1)
+	if in.Kind() != reflect.Ptr && in.Kind() != reflect.Map && in.Kind() != reflect.Slice {
+		panic("Second argument must be an *object, map, or slice")
+	}
+
+	if typ.NumOut() != 2 {
+		panic("Transform must have two returns: *object or interface{}, and error")
+	}
+
+	o1, o2 := typ.Out(0), typ.Out(1)
+
+	if "interface {}" != o1.String() && o1.Kind() != reflect.Ptr && o1.Kind() != reflect.Slice && o1.Kind() != reflect.Map {
+		panic("First return must be an empty *object, map, slice or interface{}")
+	}
+
+	if "error" != o2.String() {
+		panic("Second return must be an error")
+	}
+
+	return transform{fn: reflect.ValueOf(fn), in: in}
+}
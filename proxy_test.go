@@ -0,0 +1,172 @@
+package jsonware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type upstreamUser struct {
+	FullName string `json:"full_name"`
+}
+
+func renameRequest(r *http.Request, t *testType) (*upstreamUser, error) {
+	return &upstreamUser{FullName: t.Name}, nil
+}
+
+func renameResponse(res *http.Response, u *upstreamUser) (*testType, error) {
+	return &testType{Name: u.FullName}, nil
+}
+
+func enrichResponse(res *http.Response, t map[string]interface{}) (interface{}, error) {
+	t["enriched"] = true
+	return t, nil
+}
+
+func failResponse(res *http.Response, u *upstreamUser) (interface{}, error) {
+	return nil, Err{Status: http.StatusConflict, Err: errors.New("conflicted")}
+}
+
+func failRequest(r *http.Request, t *testType) (interface{}, error) {
+	return nil, Err{Status: http.StatusBadRequest, Err: errors.New("name is taken")}
+}
+
+func TestProxy(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "GET" {
+			io.WriteString(w, `{"full_name":"upstream"}`)
+			return
+		}
+		// Echo the body back so we can see what the upstream received.
+		io.Copy(w, r.Body)
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+
+	var tests = []struct {
+		proxy   *ProxyHandler
+		method  string
+		reqbody string
+		status  int
+		resbody string
+	}{
+		{Proxy(u), "GET", "", 200, `{"full_name":"upstream"}`},
+		{Proxy(u).Response(renameResponse), "GET", "", 200, `{"name":"upstream"}`},
+		{Proxy(u).Response(renameResponse).Response(enrichResponse), "GET", "", 200, `{"enriched":true,"name":"upstream"}`},
+		{Proxy(u).Request(renameRequest), "POST", `{"name":"client"}`, 200, `{"full_name":"client"}`},
+		{Proxy(u).Request(renameRequest).Response(renameResponse), "POST", `{"name":"client"}`, 200, `{"name":"client"}`},
+		{Proxy(u).Request(renameRequest), "POST", `{"name"`, 400, "could not deserialize"},
+		{Proxy(u).Response(failResponse), "GET", "", 409, `{"error":"conflicted"}`},
+		{Proxy(u).Request(failRequest), "POST", `{"name":"client"}`, 400, `{"error":"name is taken"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/", bytes.NewBufferString(test.reqbody))
+		req.Header.Set("Accept-Encoding", "identity")
+		body := req.Body
+
+		test.proxy.ServeHTTP(res, req)
+
+		if req.Body != body || req.Header.Get("Accept-Encoding") != "identity" || len(req.Header.Get("Content-Length")) != 0 {
+			t.Errorf("Test %d: expected the caller's request to be left alone", i)
+		}
+
+		if res.Code != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, res.Code)
+		}
+
+		if b := strings.TrimSpace(res.Body.String()); b != test.resbody && !strings.Contains(b, test.resbody) {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+	}
+}
+
+func TestProxyUpstreamDown(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	u, _ := url.Parse(upstream.URL)
+	upstream.Close()
+
+	log := &bytes.Buffer{}
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	Proxy(u).Log(log).ServeHTTP(res, req)
+
+	if res.Code != http.StatusBadGateway {
+		t.Error("Expected a 502 status:", res.Code)
+	}
+
+	var body map[string]string
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["error"] != "upstream server unavailable" {
+		t.Error("Body was wrong:", body)
+	}
+
+	if l := log.String(); !strings.Contains(l, "proxy error:") {
+		t.Error("Log was wrong:", l)
+	}
+}
+
+func TestProxyBadUpstreamJSON(t *testing.T) {
+	t.Parallel()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"full_name"`)
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	Proxy(u).Response(renameResponse).ServeHTTP(res, req)
+
+	if res.Code != http.StatusBadGateway {
+		t.Error("Expected a 502 status:", res.Code)
+	}
+	if b := res.Body.String(); !strings.Contains(b, "could not deserialize upstream response") {
+		t.Error("Body was wrong:", b)
+	}
+}
+
+func TestProxyTransformPanics(t *testing.T) {
+	t.Parallel()
+
+	var tests = []interface{}{
+		5,
+		func(r *http.Request) (interface{}, error) { return nil, nil },
+		func(r *http.Response, t *testType) (interface{}, error) { return nil, nil },
+		func(r *http.Request, t testType) (interface{}, error) { return nil, nil },
+		func(r *http.Request, t *testType) interface{} { return nil },
+		func(r *http.Request, t *testType) (testType, error) { return testType{}, nil },
+		func(r *http.Request, t *testType) (interface{}, int) { return nil, 0 },
+	}
+
+	for i, fn := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Test %d: expected a panic", i)
+				}
+			}()
+			Proxy(&url.URL{}).Request(fn)
+		}()
+	}
+}
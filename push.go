@@ -0,0 +1,300 @@
+package jsonware
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	errNotEnvelope     = errors.New("request body is not a push message")
+	errBadPushToken    = errors.New("push message token is invalid")
+	errPushNotAllowed  = errors.New("push message is from a source that is not allowed")
+	errBadSNSSignature = errors.New("sns message signature is invalid")
+	errPubSubUnchecked = errors.New("PubSub has neither a Token nor Verify to check push requests with")
+)
+
+// allowed checks if v is one of list, an empty list allows everything.
+func allowed(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, l := range list {
+		if l == v {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+SNS unwraps the notifications AWS SNS pushes to http subscriptions, so
+handlers take the message that was published as their request object. The
+signature of every notification is verified against the certificate SNS
+signed it with, and notifications with a bad signature get a 401.
+
+	sns := &jsonware.SNS{TopicARNs: []string{"arn:aws:sns:us-east-1:123456789012:orders"}}
+	http.Handle("/orders", jsonware.Handler(createOrder).BeforeDecode(sns.Unwrap))
+
+Only notifications are unwrapped, subscription confirmations get a 400 and
+subscriptions have to be confirmed with the SubscribeURL some other way.
+*/
+type SNS struct {
+	// TopicARNs are the topics notifications may come from, all topics
+	// are allowed when it's empty. Others get a 403.
+	TopicARNs []string
+	// Certificate finds the certificate at a notification's
+	// SigningCertURL. By default certificates are only fetched from
+	// amazonaws.com over https, with Client, and kept for reuse.
+	Certificate func(certURL string) (*x509.Certificate, error)
+	// Client fetches certificates, http.DefaultClient is used when it's nil.
+	Client *http.Client
+
+	certs sync.Map
+}
+
+// snsMessage is the body of a request SNS pushes.
+type snsMessage struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	TopicARN         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+}
+
+// Unwrap verifies an SNS notification and returns the message inside it,
+// it's meant to be given to a JSONHandler's BeforeDecode.
+func (s *SNS) Unwrap(r *http.Request, body io.Reader) (io.Reader, error) {
+	var msg snsMessage
+	if err := json.NewDecoder(body).Decode(&msg); err != nil || msg.Type != "Notification" || len(msg.Signature) == 0 {
+		return nil, Err{Status: http.StatusBadRequest, Err: errNotEnvelope}
+	}
+	if !allowed(s.TopicARNs, msg.TopicARN) {
+		return nil, Err{Status: http.StatusForbidden, Err: errPushNotAllowed}
+	}
+
+	var hash crypto.Hash
+	switch msg.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return nil, Err{Status: http.StatusUnauthorized, Err: errBadSNSSignature}
+	}
+
+	cert, err := s.certificate(msg.SigningCertURL)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	sig, err := base64.StdEncoding.DecodeString(msg.Signature)
+	if !ok || err != nil {
+		return nil, Err{Status: http.StatusUnauthorized, Err: errBadSNSSignature}
+	}
+	if err := rsa.VerifyPKCS1v15(key, hash, snsDigest(hash, msg), sig); err != nil {
+		return nil, Err{Status: http.StatusUnauthorized, Err: errBadSNSSignature}
+	}
+
+	return strings.NewReader(msg.Message), nil
+}
+
+// snsDigest hashes the canonical string SNS signs notifications with.
+func snsDigest(hash crypto.Hash, msg snsMessage) []byte {
+	var b strings.Builder
+	b.WriteString("Message\n" + msg.Message + "\n")
+	b.WriteString("MessageId\n" + msg.MessageID + "\n")
+	if len(msg.Subject) != 0 {
+		b.WriteString("Subject\n" + msg.Subject + "\n")
+	}
+	b.WriteString("Timestamp\n" + msg.Timestamp + "\n")
+	b.WriteString("TopicArn\n" + msg.TopicARN + "\n")
+	b.WriteString("Type\n" + msg.Type + "\n")
+
+	if hash == crypto.SHA1 {
+		sum := sha1.Sum([]byte(b.String()))
+		return sum[:]
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return sum[:]
+}
+
+func (s *SNS) certificate(certURL string) (*x509.Certificate, error) {
+	if s.Certificate != nil {
+		cert, err := s.Certificate(certURL)
+		if err != nil {
+			return nil, err
+		}
+		if cert == nil {
+			return nil, Err{Status: http.StatusUnauthorized, Err: errBadSNSSignature}
+		}
+		return cert, nil
+	}
+
+	if cert, ok := s.certs.Load(certURL); ok {
+		return cert.(*x509.Certificate), nil
+	}
+
+	u, err := url.Parse(certURL)
+	if err != nil || u.Scheme != "https" || !isSNSHost(u.Hostname()) {
+		return nil, Err{Status: http.StatusUnauthorized, Err: errBadSNSSignature}
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Get(certURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sns certificate: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch sns certificate: status %d", res.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sns certificate: %v", err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("failed to parse sns certificate: no pem block")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sns certificate: %v", err)
+	}
+
+	s.certs.Store(certURL, cert)
+	return cert, nil
+}
+
+// isSNSHost checks if host is an SNS endpoint, like sns.us-east-1.amazonaws.com.
+func isSNSHost(host string) bool {
+	for _, suffix := range []string{".amazonaws.com", ".amazonaws.com.cn"} {
+		if strings.HasPrefix(host, "sns.") && strings.HasSuffix(host, suffix) {
+			region := strings.TrimSuffix(strings.TrimPrefix(host, "sns."), suffix)
+			return len(region) != 0 && !strings.Contains(region, ".")
+		}
+	}
+	return false
+}
+
+/*
+PubSub unwraps the messages Google Cloud Pub/Sub pushes to http endpoints,
+so handlers take the data that was published as their request object.
+
+Push requests are verified with Token, a secret put in the token query
+parameter of the push endpoint's url, or with Verify, which can check the
+OIDC token Pub/Sub sends in the Authorization header. Requests that fail
+either get a 401. One of the two must be set, every request fails with a
+logged internal error otherwise so that an endpoint isn't left open by
+mistake.
+
+	pubsub := &jsonware.PubSub{Token: os.Getenv("PUSH_TOKEN")}
+	http.Handle("/orders", jsonware.Handler(createOrder).BeforeDecode(pubsub.Unwrap))
+*/
+type PubSub struct {
+	// Subscriptions are the full names of the subscriptions messages may
+	// come from, like projects/shop/subscriptions/orders, all are allowed
+	// when it's empty. Others get a 403.
+	Subscriptions []string
+	// Token is the secret the token query parameter must have, it isn't
+	// checked when it's empty.
+	Token string
+	// Verify checks a push request, it's skipped when it's nil.
+	Verify func(r *http.Request) error
+}
+
+// pubsubPush is the body of a request Pub/Sub pushes.
+type pubsubPush struct {
+	Message *struct {
+		Data      []byte `json:"data"`
+		MessageID string `json:"messageId"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+// Unwrap verifies a Pub/Sub push request and returns the data of its
+// message, it's meant to be given to a JSONHandler's BeforeDecode.
+func (p *PubSub) Unwrap(r *http.Request, body io.Reader) (io.Reader, error) {
+	if len(p.Token) == 0 && p.Verify == nil {
+		return nil, errPubSubUnchecked
+	}
+	if len(p.Token) != 0 && !hmac.Equal([]byte(r.URL.Query().Get("token")), []byte(p.Token)) {
+		return nil, Err{Status: http.StatusUnauthorized, Err: errBadPushToken}
+	}
+	if p.Verify != nil {
+		if err := p.Verify(r); err != nil {
+			return nil, Err{Status: http.StatusUnauthorized, Err: err}
+		}
+	}
+
+	var push pubsubPush
+	if err := json.NewDecoder(body).Decode(&push); err != nil || push.Message == nil {
+		return nil, Err{Status: http.StatusBadRequest, Err: errNotEnvelope}
+	}
+	if !allowed(p.Subscriptions, push.Subscription) {
+		return nil, Err{Status: http.StatusForbidden, Err: errPushNotAllowed}
+	}
+
+	return bytes.NewReader(push.Message.Data), nil
+}
+
+/*
+EventBridge unwraps the events EventBridge sends to API destinations, so
+handlers take the detail of the event as their request object. API
+destinations authenticate with the connection's credentials, which should be
+checked with BasicAuth or APIKeys.
+
+	events := &jsonware.EventBridge{Sources: []string{"com.shop.orders"}}
+	http.Handle("/orders", jsonware.Handler(createOrder).BeforeDecode(events.Unwrap))
+*/
+type EventBridge struct {
+	// Sources and DetailTypes are the sources and detail types events may
+	// have, all are allowed when they're empty. Others get a 403.
+	Sources     []string
+	DetailTypes []string
+}
+
+// eventBridgeEvent is the body of a request EventBridge sends.
+type eventBridgeEvent struct {
+	ID         string          `json:"id"`
+	Source     string          `json:"source"`
+	DetailType string          `json:"detail-type"`
+	Detail     json.RawMessage `json:"detail"`
+}
+
+// Unwrap checks an EventBridge event and returns its detail, it's meant to
+// be given to a JSONHandler's BeforeDecode.
+func (e *EventBridge) Unwrap(r *http.Request, body io.Reader) (io.Reader, error) {
+	var event eventBridgeEvent
+	err := json.NewDecoder(body).Decode(&event)
+	if err != nil || len(event.ID) == 0 || len(event.Source) == 0 || len(event.DetailType) == 0 || event.Detail == nil {
+		return nil, Err{Status: http.StatusBadRequest, Err: errNotEnvelope}
+	}
+	if !allowed(e.Sources, event.Source) || !allowed(e.DetailTypes, event.DetailType) {
+		return nil, Err{Status: http.StatusForbidden, Err: errPushNotAllowed}
+	}
+
+	return bytes.NewReader(event.Detail), nil
+}
@@ -0,0 +1,200 @@
+package jsonware
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func snsTestCert(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, cert
+}
+
+func TestSNS(t *testing.T) {
+	t.Parallel()
+
+	key, cert := snsTestCert(t)
+	sns := &SNS{
+		TopicARNs:   []string{"arn:aws:sns:us-east-1:1:orders"},
+		Certificate: func(string) (*x509.Certificate, error) { return cert, nil },
+	}
+
+	notification := func(version, topic string, tamper bool) string {
+		msg := snsMessage{
+			Type:             "Notification",
+			MessageID:        "1",
+			TopicARN:         topic,
+			Message:          `{"name":"order"}`,
+			Timestamp:        "2024-01-02T03:04:05.000Z",
+			SignatureVersion: version,
+			SigningCertURL:   "https://sns.us-east-1.amazonaws.com/cert.pem",
+		}
+		hash := crypto.SHA256
+		if version == "1" {
+			hash = crypto.SHA1
+		}
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, hash, snsDigest(hash, msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg.Signature = base64.StdEncoding.EncodeToString(sig)
+		if tamper {
+			msg.Message = `{"name":"forged"}`
+		}
+		b, _ := json.Marshal(msg)
+		return string(b)
+	}
+
+	var tests = []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{notification("1", "arn:aws:sns:us-east-1:1:orders", false), 200, `{"name":"order"}`},
+		{notification("2", "arn:aws:sns:us-east-1:1:orders", false), 200, `{"name":"order"}`},
+		{notification("2", "arn:aws:sns:us-east-1:1:orders", true), 401, `{"error":"sns message signature is invalid"}`},
+		{notification("3", "arn:aws:sns:us-east-1:1:orders", false), 401, `{"error":"sns message signature is invalid"}`},
+		{notification("2", "arn:aws:sns:us-east-1:1:users", false), 403, `{"error":"push message is from a source that is not allowed"}`},
+		{`{"Type":"SubscriptionConfirmation"}`, 400, `{"error":"request body is not a push message"}`},
+	}
+
+	for i, test := range tests {
+		h := Handler(func(r *http.Request, in *testType) *testType { return in }).BeforeDecode(sns.Unwrap)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
+
+func TestIsSNSHost(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		host string
+		want bool
+	}{
+		{"sns.us-east-1.amazonaws.com", true},
+		{"sns.cn-north-1.amazonaws.com.cn", true},
+		{"sns.amazonaws.com", false},
+		{"sns.evil.com.amazonaws.com", false},
+		{"sns.us-east-1.amazonaws.com.evil.com", false},
+		{"s3.us-east-1.amazonaws.com", false},
+	}
+
+	for i, test := range tests {
+		if got := isSNSHost(test.host); got != test.want {
+			t.Errorf("Test %d: expected %t for %s, got: %t", i, test.want, test.host, got)
+		}
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	t.Parallel()
+
+	pubsub := &PubSub{Token: "secret", Subscriptions: []string{"projects/shop/subscriptions/orders"}}
+	unchecked := &PubSub{}
+	data := base64.StdEncoding.EncodeToString([]byte(`{"name":"order"}`))
+
+	var tests = []struct {
+		pubsub *PubSub
+		url    string
+		body   string
+		status int
+		want   string
+	}{
+		{pubsub, "/?token=secret", `{"message":{"data":"` + data + `","messageId":"1"},"subscription":"projects/shop/subscriptions/orders"}`, 200, `{"name":"order"}`},
+		{pubsub, "/?token=wrong", `{"message":{"data":"` + data + `"},"subscription":"projects/shop/subscriptions/orders"}`, 401, `{"error":"push message token is invalid"}`},
+		{pubsub, "/?token=secret", `{"message":{"data":"` + data + `"},"subscription":"projects/shop/subscriptions/users"}`, 403, `{"error":"push message is from a source that is not allowed"}`},
+		{pubsub, "/?token=secret", `{"name":"order"}`, 400, `{"error":"request body is not a push message"}`},
+		{unchecked, "/", `{"message":{"data":"` + data + `"}}`, 500, `{"error":"an internal server error occurred"}`},
+	}
+
+	for i, test := range tests {
+		h := Handler(func(r *http.Request, in *testType) *testType { return in }).Log(io.Discard).BeforeDecode(test.pubsub.Unwrap)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", test.url, strings.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
+
+func TestEventBridge(t *testing.T) {
+	t.Parallel()
+
+	events := &EventBridge{Sources: []string{"com.shop.orders"}, DetailTypes: []string{"Order Created"}}
+
+	var tests = []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{`{"id":"1","source":"com.shop.orders","detail-type":"Order Created","detail":{"name":"order"}}`, 200, `{"name":"order"}`},
+		{`{"id":"1","source":"com.shop.users","detail-type":"Order Created","detail":{"name":"order"}}`, 403, `{"error":"push message is from a source that is not allowed"}`},
+		{`{"id":"1","source":"com.shop.orders","detail-type":"Order Deleted","detail":{"name":"order"}}`, 403, `{"error":"push message is from a source that is not allowed"}`},
+		{`{"name":"order"}`, 400, `{"error":"request body is not a push message"}`},
+	}
+
+	for i, test := range tests {
+		h := Handler(func(r *http.Request, in *testType) *testType { return in }).BeforeDecode(events.Unwrap)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package jsonware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuotaStore keeps count of how many requests each api key has made in each
+// quota period. It's shared by every server so that quotas hold across a
+// fleet, and is usually backed by something like redis.
+type QuotaStore interface {
+	// Increment adds one to the count for key and returns the new count.
+	// The count can be forgotten once expires has passed.
+	Increment(ctx context.Context, key string, expires time.Time) (int64, error)
+}
+
+// MemoryQuotaStore is a QuotaStore that keeps counts in memory, it's only
+// suitable for a single server.
+type MemoryQuotaStore struct {
+	mut    sync.Mutex
+	counts map[string]*quotaCount
+	// now is swapped out in tests.
+	now func() time.Time
+}
+
+type quotaCount struct {
+	n       int64
+	expires time.Time
+}
+
+// Increment adds one to the count for key and returns the new count.
+func (m *MemoryQuotaStore) Increment(ctx context.Context, key string, expires time.Time) (int64, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if m.counts == nil {
+		m.counts = make(map[string]*quotaCount)
+	}
+
+	c, ok := m.counts[key]
+	if !ok {
+		now := time.Now()
+		if m.now != nil {
+			now = m.now()
+		}
+		// Expired counts are swept out as new ones come in.
+		for k, old := range m.counts {
+			if !old.expires.After(now) {
+				delete(m.counts, k)
+			}
+		}
+		c = &quotaCount{expires: expires}
+		m.counts[key] = c
+	}
+	c.n++
+	return c.n, nil
+}
+
+// QuotaLimit is how many requests an api key may make per utc calendar day
+// and month. Zero is unlimited.
+type QuotaLimit struct {
+	Daily   int64
+	Monthly int64
+}
+
+var errNoAPIKey = errors.New("api key required")
+
+/*
+Quota enforces daily and monthly request quotas per api key on the handlers
+it wraps. Unlike a RateLimit, which smooths out bursts, a quota is an
+allowance that resets at the start of each utc day or month. Requests over
+quota get a 429 Too Many Requests whose reason says which quota ran out, its
+limit and when it resets:
+
+	{"error":"quota exceeded","reason":["daily","limit=1000","reset=2024-01-02T00:00:00Z"]}
+
+Every response carries X-Quota-Limit, X-Quota-Remaining and X-Quota-Reset
+headers for the quota closest to running out, where X-Quota-Reset is the
+number of seconds until it resets. Rejected responses also have a
+Retry-After.
+
+Key finds the request's api key, by default it's the id of the key that
+APIKeys authenticated, or the X-Api-Key header without APIKeys.
+Requests without one get a 401. Limit returns each key's quota. Store is
+where counts are kept, a MemoryQuotaStore is used when it's nil. If the
+store fails the failure is logged and the request is let through, so an
+outage of the store doesn't become an outage of the api. Requests over quota
+still count against it.
+
+	quota := &jsonware.Quota{
+		Limit: func(key string) jsonware.QuotaLimit {
+			return jsonware.QuotaLimit{Daily: 1000, Monthly: 20000}
+		},
+		Store: redisQuotas,
+	}
+	http.Handle("/search", quota.Wrap(jsonware.Handler(search)))
+*/
+type Quota struct {
+	Key   func(r *http.Request) string
+	Limit func(key string) QuotaLimit
+	Store QuotaStore
+
+	// Log is where errors are reported, the global logger is used when it's
+	// nil.
+	Log io.Writer
+
+	once sync.Once
+	// now is swapped out in tests.
+	now func() time.Time
+}
+
+// Wrap returns a handler that enforces quotas before serving requests with
+// h.
+func (q *Quota) Wrap(h http.Handler) http.Handler {
+	q.once.Do(func() {
+		if q.Store == nil {
+			q.Store = &MemoryQuotaStore{}
+		}
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimSpace(r.Header.Get("X-Api-Key"))
+		if k, ok := APIKeyFrom(r.Context()); ok {
+			key = k.ID
+		}
+		if q.Key != nil {
+			key = q.Key(r)
+		}
+		if len(key) == 0 {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, q.Log, Err{Status: http.StatusUnauthorized, Err: errNoAPIKey})
+			return
+		}
+
+		if err := q.use(w, r, key); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, q.Log, err)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// quotaPeriod is one of the periods a quota is counted over.
+type quotaPeriod struct {
+	name  string
+	limit int64
+	start time.Time
+	reset time.Time
+}
+
+// use counts the request against the key's quotas, setting the quota
+// headers, and returns an error when a quota has run out.
+func (q *Quota) use(w http.ResponseWriter, r *http.Request, key string) error {
+	limit := q.Limit(key)
+
+	now := time.Now()
+	if q.now != nil {
+		now = q.now()
+	}
+	now = now.UTC()
+
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	periods := []quotaPeriod{
+		{"daily", limit.Daily, day, day.AddDate(0, 0, 1)},
+		{"monthly", limit.Monthly, month, month.AddDate(0, 1, 0)},
+	}
+
+	// The headers describe the quota closest to running out, or the one
+	// that ran out.
+	var tightest, exceeded *quotaPeriod
+	var tightestRemaining int64 = math.MaxInt64
+	for i := range periods {
+		p := &periods[i]
+		if p.limit <= 0 {
+			continue
+		}
+
+		storeKey := fmt.Sprintf("%s:%s:%d", key, p.name, p.start.Unix())
+		n, err := q.Store.Increment(r.Context(), storeKey, p.reset)
+		if err != nil {
+			logf(q.Log, "quota store failed, letting request through: %v", err)
+			return nil
+		}
+
+		if n > p.limit {
+			exceeded = p
+			break
+		}
+		if remaining := p.limit - n; remaining < tightestRemaining {
+			tightest, tightestRemaining = p, remaining
+		}
+	}
+
+	if exceeded != nil {
+		tightest, tightestRemaining = exceeded, 0
+	}
+	if tightest == nil {
+		return nil
+	}
+
+	resetIn := int64(math.Ceil(tightest.reset.Sub(now).Seconds()))
+	header := w.Header()
+	header.Set("X-Quota-Limit", strconv.FormatInt(tightest.limit, 10))
+	header.Set("X-Quota-Remaining", strconv.FormatInt(tightestRemaining, 10))
+	header.Set("X-Quota-Reset", strconv.FormatInt(resetIn, 10))
+
+	if exceeded == nil {
+		return nil
+	}
+
+	header.Set("Retry-After", strconv.FormatInt(resetIn, 10))
+	return Err{
+		Status: http.StatusTooManyRequests,
+		Err:    errors.New("quota exceeded"),
+		Reason: []string{
+			exceeded.name,
+			"limit=" + strconv.FormatInt(exceeded.limit, 10),
+			"reset=" + exceeded.reset.Format(time.RFC3339),
+		},
+	}
+}
@@ -0,0 +1,135 @@
+package jsonware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuota(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC)
+	quota := &Quota{
+		Limit: func(key string) QuotaLimit {
+			if key == "free" {
+				return QuotaLimit{Daily: 2, Monthly: 3}
+			}
+			return QuotaLimit{}
+		},
+		Store: &MemoryQuotaStore{now: func() time.Time { return now }},
+		now:   func() time.Time { return now },
+	}
+	h := quota.Wrap(Handler(func(r *http.Request) *testType { return &testType{"bob"} }))
+
+	var tests = []struct {
+		key       string
+		advance   time.Duration
+		status    int
+		limit     string
+		remaining string
+		reset     string
+		resbody   string
+	}{
+		{"free", 0, 200, "2", "1", "3600", ""},
+		{"free", 0, 200, "2", "0", "3600", ""},
+		{"free", 0, 429, "2", "0", "3600", `{"error":"quota exceeded","reason":["daily","limit=2","reset=2024-02-01T00:00:00Z"]}`},
+		// A new day and month.
+		{"free", time.Hour, 200, "2", "1", "86400", ""},
+		{"paid", 0, 200, "", "", "", ""},
+		{"", 0, 401, "", "", "", `{"error":"api key required"}`},
+	}
+
+	for i, test := range tests {
+		now = now.Add(test.advance)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("X-Api-Key", test.key)
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if v := res.Header().Get("X-Quota-Limit"); v != test.limit {
+			t.Errorf("Test %d: expected limit %q, got: %q", i, test.limit, v)
+		}
+		if v := res.Header().Get("X-Quota-Remaining"); v != test.remaining {
+			t.Errorf("Test %d: expected remaining %q, got: %q", i, test.remaining, v)
+		}
+		if v := res.Header().Get("X-Quota-Reset"); v != test.reset {
+			t.Errorf("Test %d: expected reset %q, got: %q", i, test.reset, v)
+		}
+		if test.status == 429 && res.Header().Get("Retry-After") != test.reset {
+			t.Errorf("Test %d: expected Retry-After %s, got: %s", i, test.reset, res.Header().Get("Retry-After"))
+		}
+		if len(test.resbody) != 0 && res.Body.String() != test.resbody+"\n" {
+			t.Errorf("Test %d: body was wrong: %s", i, res.Body.String())
+		}
+	}
+}
+
+func TestQuotaMonthly(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	quota := &Quota{
+		Limit: func(key string) QuotaLimit { return QuotaLimit{Daily: 10, Monthly: 2} },
+		Store: &MemoryQuotaStore{now: func() time.Time { return now }},
+		now:   func() time.Time { return now },
+	}
+	h := quota.Wrap(Handler(func(r *http.Request) *testType { return &testType{"bob"} }))
+
+	var res *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		res = httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("X-Api-Key", "k")
+		h.ServeHTTP(res, req)
+	}
+
+	if res.Code != http.StatusTooManyRequests {
+		t.Fatal("Expected the monthly quota to run out, got:", res.Code)
+	}
+	if !strings.Contains(res.Body.String(), `"monthly","limit=2","reset=2024-04-01T00:00:00Z"`) {
+		t.Error("Body was wrong:", res.Body.String())
+	}
+}
+
+type brokenQuotaStore struct{}
+
+func (brokenQuotaStore) Increment(ctx context.Context, key string, expires time.Time) (int64, error) {
+	return 0, errors.New("redis went away")
+}
+
+func TestQuotaStoreFailure(t *testing.T) {
+	t.Parallel()
+
+	log := &bytes.Buffer{}
+	quota := &Quota{
+		Key:   func(r *http.Request) string { return "k" },
+		Limit: func(key string) QuotaLimit { return QuotaLimit{Daily: 1} },
+		Store: brokenQuotaStore{},
+		Log:   log,
+	}
+	h := quota.Wrap(Handler(func(r *http.Request) *testType { return &testType{"bob"} }))
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Error("Expected the request to be let through, got:", res.Code)
+	}
+	if !strings.Contains(log.String(), "redis went away") {
+		t.Error("Expected the failure to be logged:", log.String())
+	}
+}
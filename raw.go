@@ -0,0 +1,59 @@
+package jsonware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+var errInvalidRaw = errors.New("raw response is not valid json")
+
+/*
+Raw is json that's already been serialized, like a response kept in a cache.
+A handler that responds with a Raw has it sent as it is, without encoding it
+again or copying it, so the bytes must not be changed after they've been
+returned. An empty Raw is sent as null, and a nil one like any nil response.
+Nested in other responses a Raw is written in place like a json.RawMessage.
+
+	func getProduct(r *http.Request) (jsonware.Raw, error) {
+		if b, ok := cache.Get(r.URL.Path); ok {
+			return jsonware.Raw(b), nil
+		}
+		...
+	}
+
+Raw responses aren't checked to be json unless ValidateRaw is set, and fail
+with a 500 when they're larger than the handler's MaxResponseSize whatever
+its policy.
+*/
+type Raw []byte
+
+// MarshalJSON returns the Raw as it is.
+func (r Raw) MarshalJSON() ([]byte, error) {
+	if len(r) == 0 {
+		return []byte("null"), nil
+	}
+	return r, nil
+}
+
+// ValidateRaw checks Raw responses are valid json before they're sent, and
+// fails the ones that aren't with a 500. It's meant for development, where
+// the cost of checking is worth catching a broken cache. Not safe for use by
+// multiple goroutines, set it before your http server has been started.
+var ValidateRaw bool
+
+// rawBody is the body of a Raw response, the Raw itself rather than a copy.
+// Its capacity is capped so that writing to the body can't reach into
+// whatever the Raw is a slice of.
+func (j JSONHandler) rawBody(raw Raw) (*bytes.Buffer, error) {
+	if len(raw) == 0 {
+		raw = Raw("null")
+	}
+	if ValidateRaw && !json.Valid(raw) {
+		return nil, errInvalidRaw
+	}
+	if j.responseLimit != nil && len(raw) > j.responseLimit.size {
+		return nil, errResponseTooLarge
+	}
+	return bytes.NewBuffer(raw[:len(raw):len(raw)]), nil
+}
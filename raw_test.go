@@ -0,0 +1,86 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRaw(t *testing.T) {
+	// Not parallel, it sets ValidateRaw.
+	defer func() { ValidateRaw = false }()
+
+	type wrapped struct {
+		Cached Raw `json:"cached"`
+	}
+
+	var tests = []struct {
+		raw      Raw
+		limit    int
+		validate bool
+		nested   bool
+		status   int
+		want     string
+	}{
+		{Raw(`{"name":"bob"}`), 0, false, false, 200, `{"name":"bob"}`},
+		{Raw(`{"name":`), 0, false, false, 200, `{"name":`},
+		{Raw{}, 0, false, false, 200, `null`},
+		{nil, 0, false, false, 200, ``},
+		{Raw(`{"name":`), 0, true, false, 500, `{"error":"problem preparing response"}` + "\n"},
+		{Raw(`{"name":"bob"}`), 10, false, false, 500, `{"error":"problem preparing response"}` + "\n"},
+		{Raw(`{"name":"bob"}`), 0, false, true, 200, `{"cached":{"name":"bob"}}` + "\n"},
+	}
+
+	for i, test := range tests {
+		ValidateRaw = test.validate
+		raw := test.raw
+		var h *JSONHandler
+		if test.nested {
+			h = Handler(func(r *http.Request) (*wrapped, error) { return &wrapped{Cached: raw}, nil })
+		} else {
+			h = Handler(func(r *http.Request) (Raw, error) { return raw, nil })
+		}
+		if test.limit != 0 {
+			h.MaxResponseSize(test.limit, SizeFail)
+		}
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := res.Body.String(); b != test.want {
+			t.Errorf("Test %d: expected %q, got: %q", i, test.want, b)
+		}
+	}
+}
+
+func TestRawNotCopied(t *testing.T) {
+	t.Parallel()
+
+	cache := []byte(`{"name":"bob"}` + "xxxx")
+	h := Handler(func(r *http.Request) (Raw, error) {
+		return Raw(cache[:14]), nil
+	}).AfterEncode(func(r *http.Request, v interface{}, body []byte) []byte {
+		if &body[0] != &cache[0] {
+			t.Error("expected the body to be the cached bytes")
+		}
+		return append(body, '\n')
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(res, req)
+
+	if b := res.Body.String(); b != `{"name":"bob"}`+"\n" {
+		t.Errorf("expected the raw body, got: %q", b)
+	}
+	if !strings.HasSuffix(string(cache), "xxxx") {
+		t.Errorf("expected the cache to be left alone, got: %s", cache)
+	}
+}
@@ -0,0 +1,268 @@
+package jsonware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultRedactedHeaders are the headers a Recorder redacts when its Redact
+// field is nil.
+var DefaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+// redacted replaces the values of redacted headers in recordings.
+const redacted = "REDACTED"
+
+// Exchange is a recorded request and the response that was served for it.
+type Exchange struct {
+	Method        string      `json:"method"`
+	URI           string      `json:"uri"`
+	RequestHeader http.Header `json:"request_header,omitempty"`
+	RequestBody   string      `json:"request_body,omitempty"`
+
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+	ResponseBody   string      `json:"response_body,omitempty"`
+}
+
+/*
+Recorder records every request and response that passes through the handlers
+it wraps into Dir, one json file per exchange. The recordings can be served
+back with Replay to give integration tests of clients deterministic
+responses.
+
+Headers listed in Redact have their values replaced before being written,
+when Redact is nil DefaultRedactedHeaders is used. Sanitize can be set to
+scrub anything else, such as personal data in bodies.
+
+	rec := &jsonware.Recorder{Dir: "testdata/recordings"}
+	http.Handle("/users/", rec.Wrap(jsonware.Handler(getUser)))
+*/
+type Recorder struct {
+	Dir      string
+	Redact   []string
+	Sanitize func(*Exchange)
+
+	// Log is where failures to write recordings are reported, the global
+	// logger is used when it's nil.
+	Log io.Writer
+
+	seq uint64
+}
+
+// Wrap returns a handler that serves requests with h and records them.
+func (rec *Recorder) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody []byte
+		if r.Body != nil {
+			var err error
+			if reqBody, err = io.ReadAll(r.Body); err != nil {
+				logf(rec.Log, "recorder failed to read request body: %v", err)
+			}
+			r.Body.Close()
+			r2 := *r
+			r2.Body = io.NopCloser(bytes.NewReader(reqBody))
+			r = &r2
+		}
+
+		tee := &teeWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(tee, r)
+
+		ex := &Exchange{
+			Method:         r.Method,
+			URI:            r.URL.RequestURI(),
+			RequestHeader:  rec.redact(r.Header),
+			RequestBody:    string(reqBody),
+			Status:         tee.status,
+			ResponseHeader: rec.redact(w.Header()),
+			ResponseBody:   tee.body.String(),
+		}
+		if rec.Sanitize != nil {
+			rec.Sanitize(ex)
+		}
+
+		if err := rec.write(ex); err != nil {
+			logf(rec.Log, "recorder failed to write exchange: %v", err)
+		}
+	})
+}
+
+var unsafeFilename = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+func (rec *Recorder) write(ex *Exchange) error {
+	if err := os.MkdirAll(rec.Dir, 0755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(ex, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	n := atomic.AddUint64(&rec.seq, 1)
+	name := strings.Trim(unsafeFilename.ReplaceAllString(ex.URI, "_"), "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	file := fmt.Sprintf("%06d-%s-%s.json", n, ex.Method, name)
+
+	return os.WriteFile(filepath.Join(rec.Dir, file), b, 0644)
+}
+
+func (rec *Recorder) redact(h http.Header) http.Header {
+	names := rec.Redact
+	if names == nil {
+		names = DefaultRedactedHeaders
+	}
+	return redactHeader(h, names)
+}
+
+// redactHeader copies h with the values of the headers in names redacted.
+func redactHeader(h http.Header, names []string) http.Header {
+	cpy := h.Clone()
+	for _, name := range names {
+		if _, ok := cpy[http.CanonicalHeaderKey(name)]; ok {
+			cpy.Set(name, redacted)
+		}
+	}
+	return cpy
+}
+
+// teeWriter passes everything through to the ResponseWriter while keeping a
+// copy of the status and body.
+type teeWriter struct {
+	http.ResponseWriter
+
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (t *teeWriter) WriteHeader(status int) {
+	if !t.wroteHeader {
+		t.status = status
+		t.wroteHeader = true
+	}
+	t.ResponseWriter.WriteHeader(status)
+}
+
+func (t *teeWriter) Write(b []byte) (int, error) {
+	t.wroteHeader = true
+	t.body.Write(b)
+	return t.ResponseWriter.Write(b)
+}
+
+/*
+Replay creates a handler that serves back the exchanges recorded by a
+Recorder into dir. Requests are matched on method, uri and body, where json
+bodies match if they're equivalent regardless of formatting. If the same
+request was recorded several times the recorded responses are served in
+order, repeating the last one once they run out.
+
+Requests that were never recorded get a 404 json error.
+*/
+func Replay(dir string) (http.Handler, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	rp := &replayer{served: make(map[*Exchange]bool)}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		ex := &Exchange{}
+		if err = json.Unmarshal(b, ex); err != nil {
+			return nil, fmt.Errorf("failed to read recording %s: %v", file, err)
+		}
+		rp.exchanges = append(rp.exchanges, ex)
+	}
+
+	return rp, nil
+}
+
+type replayer struct {
+	exchanges []*Exchange
+
+	mut    sync.Mutex
+	served map[*Exchange]bool
+}
+
+func (rp *replayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+		r.Body.Close()
+	}
+
+	ex := rp.match(r.Method, r.URL.RequestURI(), body)
+	if ex == nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, nil, Err{
+			Status: http.StatusNotFound,
+			Err:    fmt.Errorf("no recorded exchange for %s %s", r.Method, r.URL.RequestURI()),
+		})
+		return
+	}
+
+	for k, v := range ex.ResponseHeader {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(ex.Status)
+	io.WriteString(w, ex.ResponseBody)
+}
+
+// match finds the first unserved exchange for the request, or the last
+// matching one if they've all been served.
+func (rp *replayer) match(method, uri string, body []byte) *Exchange {
+	rp.mut.Lock()
+	defer rp.mut.Unlock()
+
+	var last *Exchange
+	for _, ex := range rp.exchanges {
+		if ex.Method != method || ex.URI != uri || !sameBody([]byte(ex.RequestBody), body) {
+			continue
+		}
+
+		if !rp.served[ex] {
+			rp.served[ex] = true
+			return ex
+		}
+		last = ex
+	}
+
+	return last
+}
+
+// sameBody compares bodies as json if they both are json, otherwise as bytes.
+func sameBody(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+
+	var av, bv interface{}
+	if json.Unmarshal(a, &av) != nil || json.Unmarshal(b, &bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
@@ -0,0 +1,111 @@
+package jsonware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	calls := 0
+	rec := &Recorder{
+		Dir: dir,
+		Sanitize: func(ex *Exchange) {
+			ex.ResponseBody = strings.Replace(ex.ResponseBody, "secret", "xxx", -1)
+		},
+	}
+	h := rec.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"call": calls, "token": "secret"})
+	}))
+
+	var requests = []struct {
+		method string
+		uri    string
+		body   string
+	}{
+		{"GET", "/users/1?full=true", ""},
+		{"GET", "/users/1?full=true", ""},
+		{"POST", "/users", `{"name":"bob","age":5}`},
+	}
+
+	for _, r := range requests {
+		req, _ := http.NewRequest(r.method, r.uri, strings.NewReader(r.body))
+		req.Header.Set("Authorization", "Bearer hunter2")
+		body := req.Body
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if req.Body != body {
+			t.Error("Expected the caller's request body to be left alone")
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 3 {
+		t.Fatal("Expected 3 recordings, got:", len(files))
+	}
+	for _, f := range files {
+		b, _ := os.ReadFile(f)
+		if bytes.Contains(b, []byte("hunter2")) || bytes.Contains(b, []byte("secret")) {
+			t.Errorf("Recording %s was not sanitized:\n%s", f, b)
+		}
+	}
+
+	replay, err := Replay(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		method  string
+		uri     string
+		body    string
+		status  int
+		resbody string
+	}{
+		{"GET", "/users/1?full=true", "", 200, `"call":1`},
+		{"GET", "/users/1?full=true", "", 200, `"call":2`},
+		{"GET", "/users/1?full=true", "", 200, `"call":2`},
+		{"POST", "/users", `{ "age": 5, "name": "bob" }`, 201, `"call":3`},
+		{"POST", "/users", `{"name":"alice"}`, 404, "no recorded exchange for POST /users"},
+		{"GET", "/users/1", "", 404, "no recorded exchange"},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.uri, strings.NewReader(test.body))
+		replay.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, res.Code)
+		}
+
+		if b := res.Body.String(); !strings.Contains(b, test.resbody) {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+	}
+}
+
+func TestReplayBadRecording(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{"), 0644)
+
+	if _, err := Replay(dir); err == nil {
+		t.Error("Expected an error")
+	}
+}
@@ -0,0 +1,140 @@
+package jsonware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+/*
+Registry keeps track of which JSONHandler serves which method and path so that
+other things, like mock servers, can be built from the complete set of
+routes. The zero value is an empty registry ready for use.
+
+Patterns use the same syntax as the patterns of http.ServeMux, without the
+method, which is given separately. Registering a route that matches the same
+requests as one that's already registered, like GET /users/{name} after GET
+/users/{id}, panics, so that it's caught at startup rather than by finding the
+wrong handler serving traffic.
+
+	reg := &jsonware.Registry{}
+	reg.Handle("GET", "/users/{id}", getUser)
+	reg.Handle("POST", "/users", createUser).Log(myLogger)
+*/
+type Registry struct {
+	routes []Route
+	// mux has every route registered, to find conflicts between them.
+	mux *http.ServeMux
+}
+
+// Route is a JSONHandler registered for a method and path pattern.
+type Route struct {
+	Method  string
+	Pattern string
+	Handler *JSONHandler
+}
+
+// Handle creates a JSONHandler from fn (see Handler) and registers it for
+// method and pattern.
+func (reg *Registry) Handle(method, pattern string, fn interface{}) *JSONHandler {
+	h := Handler(fn)
+	reg.Add(method, pattern, h)
+	return h
+}
+
+// Add registers an existing JSONHandler for method and pattern.
+func (reg *Registry) Add(method, pattern string, h *JSONHandler) {
+	reg.checkConflicts(method, pattern)
+	reg.routes = append(reg.routes, Route{Method: method, Pattern: pattern, Handler: h})
+}
+
+// Routes returns the registered routes in the order they were registered.
+func (reg *Registry) Routes() []Route {
+	routes := make([]Route, len(reg.routes))
+	copy(routes, reg.routes)
+	return routes
+}
+
+/*
+Mount registers every route on mux, using the method and pattern syntax of
+http.ServeMux. The wildcards of the patterns are bound to the path tagged
+fields of the handlers' request objects.
+
+	type GetUser struct {
+		ID int `path:"id"`
+	}
+
+	reg.Handle("GET", "/users/{id}", func(r *http.Request, in *GetUser) (*User, error) {
+		return db.FindUser(in.ID)
+	})
+	reg.Mount(http.DefaultServeMux)
+*/
+func (reg *Registry) Mount(mux *http.ServeMux) {
+	for _, route := range reg.routes {
+		mux.Handle(route.Method+" "+route.Pattern, route.Handler)
+	}
+}
+
+// checkConflicts panics if method and pattern match the same requests as a
+// route that's already registered. Patterns are compared the way
+// http.ServeMux does, and those in the syntax of other routers, like regexp
+// wildcards, are only compared for being the same as another.
+func (reg *Registry) checkConflicts(method, pattern string) {
+	normal := normalPattern(pattern)
+	for _, route := range reg.routes {
+		if route.Method == method && normalPattern(route.Pattern) == normal {
+			panic(fmt.Sprintf("Cannot register %s %s, %s %s is already registered", method, pattern, route.Method, route.Pattern))
+		}
+	}
+
+	if !isMuxPattern(method + " " + pattern) {
+		return
+	}
+	if reg.mux == nil {
+		reg.mux = http.NewServeMux()
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			panic(fmt.Sprintf("Cannot register %s %s: %v", method, pattern, p))
+		}
+	}()
+	reg.mux.Handle(method+" "+pattern, http.NotFoundHandler())
+}
+
+// isMuxPattern checks if pattern is one http.ServeMux can parse.
+func isMuxPattern(pattern string) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	http.NewServeMux().Handle(pattern, http.NotFoundHandler())
+	return true
+}
+
+// normalPattern removes the names of a pattern's wildcards, so that patterns
+// that only differ by them are the same.
+func normalPattern(pattern string) string {
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(pattern, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(pattern[open:], '}')
+		if end < 0 {
+			break
+		}
+		wildcard := pattern[open+1 : open+end]
+		b.WriteString(pattern[:open+1])
+		if wildcard == "$" {
+			b.WriteString(wildcard)
+		} else if i := strings.IndexAny(wildcard, ":."); i >= 0 {
+			b.WriteString(wildcard[i:])
+		}
+		b.WriteByte('}')
+		pattern = pattern[open+end+1:]
+	}
+	b.WriteString(pattern)
+	return b.String()
+}
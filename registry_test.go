@@ -0,0 +1,125 @@
+package jsonware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	reg := &Registry{}
+	log := &bytes.Buffer{}
+
+	h1 := reg.Handle("POST", "/things", testHandler1).Log(log)
+	h2 := Handler(testHandler6)
+	reg.Add("GET", "/things", h2)
+
+	routes := reg.Routes()
+	if len(routes) != 2 {
+		t.Fatal("Expected 2 routes, got:", len(routes))
+	}
+
+	if r := routes[0]; r.Method != "POST" || r.Pattern != "/things" || r.Handler != h1 {
+		t.Error("Route 0 was wrong:", r)
+	}
+	if r := routes[1]; r.Method != "GET" || r.Pattern != "/things" || r.Handler != h2 {
+		t.Error("Route 1 was wrong:", r)
+	}
+	if routes[0].Handler.logger != log {
+		t.Error("Chained options should apply to the registered handler")
+	}
+
+	// Routes must be a copy.
+	routes[0].Method = "PUT"
+	if reg.Routes()[0].Method != "POST" {
+		t.Error("Routes should not expose the registry's storage")
+	}
+}
+
+func TestRegistryMount(t *testing.T) {
+	t.Parallel()
+
+	type getThing struct {
+		ID   int    `path:"id" json:"id"`
+		Name string `json:"name"`
+	}
+
+	reg := &Registry{}
+	reg.Handle("GET", "/things/{id}", func(r *http.Request, in *getThing) *getThing { return in })
+	reg.Handle("PUT", "/things/{id}", func(r *http.Request, in *getThing) (*getThing, error) { return in, nil })
+
+	mux := http.NewServeMux()
+	reg.Mount(mux)
+
+	var tests = []struct {
+		method  string
+		path    string
+		reqbody string
+		status  int
+		resbody string
+	}{
+		{"GET", "/things/5", "", 200, `{"id":5,"name":""}`},
+		{"PUT", "/things/6", `{"id":1,"name":"bob"}`, 200, `{"id":6,"name":"bob"}`},
+		{"GET", "/things/x", "", 400, `{"error":"invalid path parameter id"}`},
+		{"POST", "/things/5", "", 405, ""},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, test.path, bytes.NewBufferString(test.reqbody))
+		req.Header.Set("Accept", "*/*")
+		mux.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, res.Code)
+		}
+
+		if b := strings.TrimSpace(res.Body.String()); test.resbody != "" && b != test.resbody {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+	}
+}
+
+func TestRegistryConflicts(t *testing.T) {
+	t.Parallel()
+
+	fn := func(r *http.Request) (*testType, error) { return nil, nil }
+
+	var tests = []struct {
+		first, second [2]string
+		panics        bool
+	}{
+		{[2]string{"GET", "/users/{id}"}, [2]string{"GET", "/users/{name}"}, true},
+		{[2]string{"GET", "/users/{id}"}, [2]string{"GET", "/users/me"}, false},
+		{[2]string{"GET", "/users/{id}"}, [2]string{"DELETE", "/users/{id}"}, false},
+		{[2]string{"GET", "/{a}/posts"}, [2]string{"GET", "/users/{b}"}, true},
+		{[2]string{"GET", "/files/{path...}"}, [2]string{"GET", "/files/{rest...}"}, true},
+		{[2]string{"GET", "/users/{$}"}, [2]string{"GET", "/users/{id}"}, false},
+		{[2]string{"PUT", "/users/{id:[0-9]+}"}, [2]string{"PUT", "/users/{uid:[0-9]+}"}, true},
+		{[2]string{"PUT", "/users/{id:[0-9]+}"}, [2]string{"PUT", "/users/{name:[a-z]+}"}, false},
+	}
+
+	for i, test := range tests {
+		reg := &Registry{}
+		reg.Handle(test.first[0], test.first[1], fn)
+
+		var panicked interface{}
+		func() {
+			defer func() { panicked = recover() }()
+			reg.Handle(test.second[0], test.second[1], fn)
+		}()
+
+		if (panicked != nil) != test.panics {
+			t.Errorf("Test %d: expected panic %t, got: %v", i, test.panics, panicked)
+		}
+		if msg, ok := panicked.(string); ok && !strings.Contains(msg, test.second[1]) {
+			t.Errorf("Test %d: expected the panic to name the route, got: %s", i, msg)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package jsonware
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+/*
+Response wraps the body a handler responds with to send it with headers and
+a status of the handler's choosing, without writing to the ResponseWriter
+and going around the encoding, ETags and signing of the response. Headers are
+added to the response's, and Status takes the place of the body's own when
+it isn't zero. A nil Body sends just the headers and status.
+
+	func listUsers(r *http.Request) (*jsonware.Response[[]*User], error) {
+		users, total, err := db.Users(r.Context())
+		...
+		return &jsonware.Response[[]*User]{
+			Body:    users,
+			Headers: http.Header{"X-Total-Count": {strconv.Itoa(total)}},
+		}, nil
+	}
+*/
+type Response[T any] struct {
+	Body    T
+	Headers http.Header
+	Status  int
+}
+
+// MarshalJSON serializes the Body.
+func (r *Response[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Body)
+}
+
+func (r *Response[T]) envelope() (interface{}, http.Header, int) {
+	return r.Body, r.Headers, r.Status
+}
+
+// envelope is a response that carries its body along with headers and a
+// status, a Response.
+type envelope interface {
+	envelope() (body interface{}, header http.Header, status int)
+}
+
+// bodyOf finds the body of the response v, v itself unless it's a Response.
+// It's false when there's no body to send.
+func bodyOf(v interface{}) (interface{}, bool) {
+	env, ok := v.(envelope)
+	if !ok {
+		return v, true
+	}
+	body, _, _ := env.envelope()
+	rv := reflect.ValueOf(body)
+	if !rv.IsValid() {
+		return nil, false
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Chan, reflect.Func:
+		if rv.IsNil() {
+			return nil, false
+		}
+	}
+	return body, true
+}
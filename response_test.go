@@ -0,0 +1,56 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponse(t *testing.T) {
+	t.Parallel()
+
+	total := http.Header{"X-Total-Count": {"42"}, "link": {`</users?page=2>; rel="next"`}}
+
+	var tests = []struct {
+		fn     interface{}
+		status int
+		header http.Header
+		want   string
+	}{
+		{func(r *http.Request) (*Response[[]testType], error) {
+			return &Response[[]testType]{Body: []testType{{Name: "a"}}, Headers: total}, nil
+		}, 200, total, `[{"name":"a"}]`},
+		{func(r *http.Request) (*Response[*testType], error) {
+			return &Response[*testType]{Body: &testType{Name: "a"}, Status: http.StatusAccepted}, nil
+		}, 202, nil, `{"name":"a"}`},
+		{func(r *http.Request) (*Response[*createdType], error) {
+			return &Response[*createdType]{Body: &createdType{Name: "a"}}, nil
+		}, 201, nil, `{"name":"a"}`},
+		{func(r *http.Request) (*Response[*createdType], error) {
+			return &Response[*createdType]{Body: &createdType{Name: "a"}, Status: http.StatusOK}, nil
+		}, 200, nil, `{"name":"a"}`},
+		{func(r *http.Request) (*Response[*testType], error) {
+			return &Response[*testType]{Headers: http.Header{"Retry-After": {"5"}}, Status: http.StatusNoContent}, nil
+		}, 204, http.Header{"Retry-After": {"5"}}, ``},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/users", nil)
+		req.Header.Set("Accept", "application/json")
+		Handler(test.fn).ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+		for name, values := range test.header {
+			if got, want := res.Header().Get(name), values[0]; got != want {
+				t.Errorf("Test %d: expected header %s to be %q, got: %q", i, name, want, got)
+			}
+		}
+	}
+}
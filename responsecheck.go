@@ -0,0 +1,159 @@
+package jsonware
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxCheckDepth bounds how deep CheckResponses looks into a response, so
+// that values which refer to themselves can't hang the check.
+const maxCheckDepth = 64
+
+/*
+ResponseCheck declares the invariants responses are checked against by
+CheckResponses.
+
+Responses may not have a NaN or infinite float anywhere, since json can't
+hold them, unless the Codec has a NonFinite policy for them, and may not have nulls except in fields tagged nullable:"true".
+Nil slices and maps count as nulls unless the Codec writes them as empty
+collections, fields tagged omitempty are left out instead of being null, and
+values that marshal themselves aren't looked into.
+
+	type User struct {
+		Name    string  `json:"name"`
+		Manager *User   `json:"manager" nullable:"true"`
+		Score   float64 `json:"score"`
+	}
+*/
+type ResponseCheck struct {
+	// Schema is the json schema responses must match, it's not checked when
+	// it's nil.
+	Schema *Schema
+	// Alert is called with every violation found in a response, before
+	// it's sent. When it's nil the violations are logged.
+	Alert func(r *http.Request, violations []string)
+	// Reject fails responses with violations as a cloaked 500 error instead
+	// of sending them.
+	Reject bool
+}
+
+/*
+CheckResponses checks every response the JSONHandler encodes against check
+and reports the ones that violate it. It's a safety net for refactors of
+response types shared by many handlers, meant for staging environments where
+a broken response should raise an alarm before it reaches clients.
+
+	h := jsonware.Handler(getUser)
+	if staging {
+		h.CheckResponses(jsonware.ResponseCheck{Alert: pageOnCall})
+	}
+*/
+func (j *JSONHandler) CheckResponses(check ResponseCheck) *JSONHandler {
+	j.responseCheck = &check
+	return j
+}
+
+// inspect finds the NaNs, infinities and unexpected nulls in a response.
+func (c *ResponseCheck) inspect(v reflect.Value, codec *Codec) []string {
+	var violations []string
+	checkValue(v, "$", codec, 0, &violations)
+	return violations
+}
+
+// report hands the violations to Alert, or logs them, and checks whether the
+// response should be rejected for them.
+func (c *ResponseCheck) report(r *http.Request, logger io.Writer, violations []string) bool {
+	if len(violations) == 0 {
+		return false
+	}
+	if c.Alert != nil {
+		c.Alert(r, violations)
+	} else {
+		logf(logger, "response to %s %s violates invariants: %s", r.Method, r.URL.Path, strings.Join(violations, "; "))
+	}
+	return c.Reject
+}
+
+func checkValue(v reflect.Value, path string, codec *Codec, depth int, violations *[]string) {
+	if depth > maxCheckDepth || !v.IsValid() {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			*violations = append(*violations, path+": is null")
+			return
+		}
+		if v.Kind() == reflect.Pointer && implementsMarshaler(v.Type()) {
+			return
+		}
+		checkValue(v.Elem(), path, codec, depth+1, violations)
+		return
+	case reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			if codec == nil || !codec.EmptyCollections {
+				*violations = append(*violations, path+": is null")
+			}
+			return
+		}
+	}
+	if implementsMarshaler(v.Type()) {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); (codec == nil || codec.NonFinite == FloatError) && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			*violations = append(*violations, fmt.Sprintf("%s: is %v", path, f))
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			checkValue(v.Index(i), path+"["+strconv.Itoa(i)+"]", codec, depth+1, violations)
+		}
+	case reflect.Map:
+		for _, k := range sortedKeys(v) {
+			checkValue(v.MapIndex(k), fmt.Sprintf("%s[%v]", path, k), codec, depth+1, violations)
+		}
+	case reflect.Struct:
+		typ := v.Type()
+		for _, f := range codecFields(typ) {
+			field, ok := fieldByIndex(v, f.index)
+			if !ok || (f.omitEmpty && isEmptyValue(field)) {
+				continue
+			}
+			if typ.FieldByIndex(f.index).Tag.Get("nullable") == "true" && isNull(field) {
+				continue
+			}
+			checkValue(field, path+"."+codec.fieldKey(f.name), codec, depth+1, violations)
+		}
+	}
+}
+
+// isNull checks if a value is written as null.
+func isNull(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer, reflect.Slice, reflect.Map:
+		return v.IsNil()
+	}
+	return false
+}
+
+// sortedKeys orders the keys of a map so violations are reported the same way
+// every time.
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(a, b int) bool {
+		return fmt.Sprint(keys[a]) < fmt.Sprint(keys[b])
+	})
+	return keys
+}
@@ -0,0 +1,112 @@
+package jsonware
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type checkedType struct {
+	Name    string            `json:"name"`
+	Manager *checkedType      `json:"manager" nullable:"true"`
+	Score   float64           `json:"score"`
+	Tags    []string          `json:"tags,omitempty"`
+	Attrs   map[string]string `json:"attrs"`
+	Seen    time.Time         `json:"seen"`
+}
+
+func TestResponseCheckInspect(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		value interface{}
+		codec *Codec
+		want  []string
+	}{
+		{&checkedType{Attrs: map[string]string{}}, nil, nil},
+		{&checkedType{}, nil, []string{"$.attrs: is null"}},
+		{&checkedType{}, &Codec{EmptyCollections: true}, nil},
+		{&checkedType{Score: math.NaN(), Attrs: map[string]string{}}, nil, []string{"$.score: is NaN"}},
+		{
+			&checkedType{Manager: &checkedType{Score: math.Inf(1)}, Attrs: map[string]string{}},
+			&Codec{Keys: PascalCase},
+			[]string{"$.Manager.Score: is +Inf", "$.Manager.Attrs: is null"},
+		},
+		{map[string]interface{}{"b": nil, "a": []float64{1, math.Inf(-1)}}, nil, []string{"$[a][1]: is -Inf", "$[b]: is null"}},
+	}
+
+	for i, test := range tests {
+		got := (&ResponseCheck{}).inspect(reflect.ValueOf(test.value), test.codec)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Test %d: expected %q, got: %q", i, test.want, got)
+		}
+	}
+}
+
+func TestCheckResponses(t *testing.T) {
+	t.Parallel()
+
+	schema := MustParseSchema([]byte(`{"type":"object","required":["name"],"properties":{"name":{"minLength":1}}}`))
+
+	var tests = []struct {
+		value  *checkedType
+		reject bool
+		status int
+		alert  string
+	}{
+		{&checkedType{Name: "a", Attrs: map[string]string{}}, false, 200, ""},
+		{&checkedType{Name: "a"}, false, 200, "$.attrs: is null"},
+		{&checkedType{Name: "a"}, true, 500, "$.attrs: is null"},
+		{&checkedType{Attrs: map[string]string{}}, true, 500, "$.name: length 0 is less than the minimum 1"},
+		{&checkedType{Name: "a", Score: math.NaN(), Attrs: map[string]string{}}, false, 500, "$.score: is NaN"},
+	}
+
+	for i, test := range tests {
+		var alerts []string
+		h := Handler(func(r *http.Request) *checkedType { return test.value }).
+			Log(&bytes.Buffer{}).
+			CheckResponses(ResponseCheck{
+				Schema: schema,
+				Alert:  func(r *http.Request, violations []string) { alerts = append(alerts, violations...) },
+				Reject: test.reject,
+			})
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if got := strings.Join(alerts, "; "); !strings.Contains(got, test.alert) || (len(test.alert) == 0 && len(got) != 0) {
+			t.Errorf("Test %d: expected alert %q, got: %q", i, test.alert, got)
+		}
+	}
+}
+
+func TestCheckResponsesLogs(t *testing.T) {
+	t.Parallel()
+
+	logger := &bytes.Buffer{}
+	h := Handler(func(r *http.Request) *checkedType { return &checkedType{} }).
+		Log(logger).
+		CheckResponses(ResponseCheck{})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users", nil)
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Errorf("expected status 200, got: %d", res.Code)
+	}
+	if want := "response to GET /users violates invariants: $.attrs: is null"; !strings.Contains(logger.String(), want) {
+		t.Errorf("expected log %q, got: %q", want, logger.String())
+	}
+}
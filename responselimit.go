@@ -0,0 +1,117 @@
+package jsonware
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+)
+
+var errResponseTooLarge = errors.New("response is too large")
+
+// truncatedMeta closes a list response that was cut short by SizeTruncate.
+const truncatedMeta = `],"meta":{"truncated":true}}`
+
+// SizePolicy is what a JSONHandler does with a response larger than its
+// MaxResponseSize.
+type SizePolicy int
+
+// SizePolicies a JSONHandler can have.
+const (
+	// SizeFail fails the response with a cloaked 500.
+	SizeFail SizePolicy = iota
+	// SizeStream sends the response anyway, encoding it straight to the
+	// client instead of holding all of it in memory. Responses the handler
+	// needs whole, to sign, digest, check against a schema, pass to
+	// AfterEncode hooks or emit, fail like with SizeFail.
+	SizeStream
+	// SizeTruncate sends as many of the items of a list response as fit,
+	// as {"data": [...], "meta": {"truncated": true}} so clients can tell.
+	// Responses that aren't lists fail like with SizeFail.
+	SizeTruncate
+)
+
+/*
+MaxResponseSize limits how large the JSONHandler's encoded responses may be,
+so that a runaway query can't take the server's memory with it. Encoding
+stops as soon as a response passes limit bytes and policy decides what the
+client gets instead. Responses that are too large are logged whatever the
+policy.
+
+	h := jsonware.Handler(searchOrders).MaxResponseSize(10<<20, jsonware.SizeTruncate)
+
+Handlers with a limit are encoded with their Codec, or a zero Codec in place
+of encoding/json, since encoding/json can't be stopped partway through.
+Streaming handlers aren't limited.
+*/
+func (j *JSONHandler) MaxResponseSize(limit int, policy SizePolicy) *JSONHandler {
+	j.responseLimit = &responseLimit{size: limit, policy: policy}
+	return j
+}
+
+type responseLimit struct {
+	size   int
+	policy SizePolicy
+}
+
+// encode serializes v into buf unless it's larger than the limit, in which
+// case it's truncated or errResponseTooLarge is returned.
+func (l *responseLimit) encode(buf *bytes.Buffer, v interface{}, c *Codec) (truncated bool, err error) {
+	if c == nil {
+		c = &Codec{}
+	}
+
+	// The newline is part of the response, and no response fits in one byte
+	// with it.
+	if l.size < 2 {
+		return false, errResponseTooLarge
+	}
+	e := &codecState{codec: c, limit: l.size - 1}
+	err = e.value(reflect.ValueOf(v), false)
+	if err == nil && !e.over() {
+		buf.Write(e.Bytes())
+		buf.WriteByte('\n')
+		return false, nil
+	}
+	if err != nil && err != errResponseTooLarge {
+		return false, err
+	}
+	if l.policy != SizeTruncate {
+		return false, errResponseTooLarge
+	}
+
+	list := reflect.ValueOf(v)
+	for list.IsValid() && (list.Kind() == reflect.Pointer || list.Kind() == reflect.Interface) && !list.IsNil() {
+		list = list.Elem()
+	}
+	const start = `{"data":[`
+	limit := l.size - 1 - len(truncatedMeta)
+	if !list.IsValid() || (list.Kind() != reflect.Slice && list.Kind() != reflect.Array) || limit < len(start) {
+		return false, errResponseTooLarge
+	}
+
+	e = &codecState{codec: c, limit: limit}
+	e.WriteString(start)
+	for i := 0; i < list.Len(); i++ {
+		mark := e.Len()
+		if i > 0 {
+			e.WriteByte(',')
+		}
+		err := e.value(list.Index(i), false)
+		if err == errResponseTooLarge || (err == nil && e.over()) {
+			e.Truncate(mark)
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	e.WriteString(truncatedMeta)
+	buf.Write(e.Bytes())
+	buf.WriteByte('\n')
+	return true, nil
+}
+
+// streams checks if a response that's too large is streamed to the client.
+func (l *responseLimit) streams(j JSONHandler) bool {
+	return l.policy == SizeStream && !needsWholeBody(j)
+}
@@ -0,0 +1,91 @@
+package jsonware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type limitItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func limitItems(n int) []limitItem {
+	items := make([]limitItem, n)
+	for i := range items {
+		items[i] = limitItem{ID: i, Name: fmt.Sprint("item", i)}
+	}
+	return items
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	t.Parallel()
+
+	list := func(n int) func(r *http.Request) ([]limitItem, error) {
+		return func(r *http.Request) ([]limitItem, error) { return limitItems(n), nil }
+	}
+	object := func(r *http.Request) (*limitItem, error) {
+		return &limitItem{Name: strings.Repeat("a", 100)}, nil
+	}
+	var tests = []struct {
+		handler *JSONHandler
+		status  int
+		want    string
+		log     string
+	}{
+		{Handler(list(2)).MaxResponseSize(100, SizeFail), 200, `[{"id":0,"name":"item0"},{"id":1,"name":"item1"}]`, ``},
+		{Handler(list(2)).MaxResponseSize(49, SizeFail), 500, `{"error":"problem preparing response"}`, `failed to encode response to GET /: response is too large`},
+		{Handler(object).MaxResponseSize(50, SizeTruncate), 500, `{"error":"problem preparing response"}`, `response is too large`},
+		{Handler(list(5)).MaxResponseSize(100, SizeTruncate), 200,
+			`{"data":[{"id":0,"name":"item0"},{"id":1,"name":"item1"}],"meta":{"truncated":true}}`, `larger than 100 bytes, it was truncated`},
+		{Handler(list(5)).MaxResponseSize(45, SizeTruncate), 200, `{"data":[],"meta":{"truncated":true}}`, `truncated`},
+		{Handler(list(5)).MaxResponseSize(10, SizeTruncate), 500, `{"error":"problem preparing response"}`, `response is too large`},
+		{Handler(list(5)).MaxResponseSize(1, SizeFail), 500, `{"error":"problem preparing response"}`, `response is too large`},
+		{Handler(list(3)).MaxResponseSize(50, SizeStream), 200,
+			`[{"id":0,"name":"item0"},{"id":1,"name":"item1"},{"id":2,"name":"item2"}]`, `larger than 50 bytes, it was streamed`},
+		{Handler(list(3)).MaxResponseSize(50, SizeStream).Digest("sha-256"), 500, `{"error":"problem preparing response"}`, `response is too large`},
+		{Handler(list(3)).MaxResponseSize(50, SizeStream).Codec(&Codec{Keys: PascalCase}), 200,
+			`[{"Id":0,"Name":"item0"},{"Id":1,"Name":"item1"},{"Id":2,"Name":"item2"}]`, `streamed`},
+	}
+
+	for i, test := range tests {
+		logs := &strings.Builder{}
+		test.handler.Log(logs)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		test.handler.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected body:\n%s\ngot:\n%s", i, test.want, b)
+		}
+		if l := logs.String(); len(test.log) == 0 && len(l) != 0 || !strings.Contains(l, test.log) {
+			t.Errorf("Test %d: expected log %q, got: %q", i, test.log, l)
+		}
+	}
+}
+
+func TestMaxResponseSizeStreams(t *testing.T) {
+	t.Parallel()
+
+	items := limitItems(20000)
+	h := Handler(func(r *http.Request) ([]limitItem, error) { return items, nil }).
+		MaxResponseSize(1<<10, SizeStream).Log(&strings.Builder{})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(res, req)
+
+	want, _ := (&Codec{}).Marshal(items)
+	if got := res.Body.Bytes(); string(got) != string(want)+"\n" {
+		t.Errorf("expected the whole response to be streamed, got %d bytes instead of %d", len(got), len(want)+1)
+	}
+}
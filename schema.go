@@ -0,0 +1,445 @@
+package jsonware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+Schema is a JSON Schema that json documents can be validated against. It
+understands the commonly used subset of the specification: type, enum, const,
+properties, required, additionalProperties, items, the numeric, string and
+array bounds, pattern, allOf, anyOf, oneOf, not, and local $refs. The OpenAPI
+3.0 "nullable" keyword and boolean exclusiveMinimum and exclusiveMaximum are
+also honored. A $ref that leads back to itself without descending into the
+document fails validation instead of looping.
+
+Schemas inside a larger document, such as the components of an OpenAPI
+spec, can be found with Ref, and references inside them are resolved against
+the whole document.
+
+	spec, err := jsonware.ParseSchema(openAPIBytes)
+	user := spec.Ref("#/components/schemas/User")
+*/
+type Schema struct {
+	root interface{}
+	node interface{}
+
+	// patterns caches compiled patterns, it's shared by all the schemas
+	// of a document.
+	patterns *sync.Map
+}
+
+/*
+Contract validates every decoded request body against request and every
+encoded response against response, either may be nil to skip validation of
+that side. A mismatch is a contract violation and fails loudly as a cloaked
+500 error so that it shows up in the logs.
+
+This is meant for development and staging environments, where drift between
+the implementation and the published spec should be caught before it ships.
+
+	h := jsonware.Handler(createUser)
+	if !production {
+		h.Contract(spec.Ref("#/components/schemas/NewUser"), spec.Ref("#/components/schemas/User"))
+	}
+*/
+func (j *JSONHandler) Contract(request, response *Schema) *JSONHandler {
+	j.requestSchema = request
+	j.responseSchema = response
+	return j
+}
+
+// ParseSchema parses a json schema document.
+func ParseSchema(doc []byte) (*Schema, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+
+	var root interface{}
+	if err := dec.Decode(&root); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %v", err)
+	}
+
+	return &Schema{root: root, node: root, patterns: &sync.Map{}}, nil
+}
+
+// MustParseSchema is like ParseSchema but panics if the schema cannot be
+// parsed. It's intended for schemas that are compiled into the program.
+func MustParseSchema(doc []byte) *Schema {
+	s, err := ParseSchema(doc)
+	if err != nil {
+		panic(err.Error())
+	}
+	return s
+}
+
+// Ref returns the schema at the json pointer ref (eg. "#/definitions/User")
+// within s's document, or nil if there is nothing there.
+func (s *Schema) Ref(ref string) *Schema {
+	node, ok := s.resolve(ref)
+	if !ok {
+		return nil
+	}
+	return &Schema{root: s.root, node: node, patterns: s.patterns}
+}
+
+// Validate checks a json document against the schema, returning an error that
+// describes every mismatch if it does not conform.
+func (s *Schema) Validate(doc []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("invalid json: %v", err)
+	}
+
+	var problems []string
+	s.validate(s.node, v, "$", &problems, nil)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(problems, "; "))
+}
+
+func (s *Schema) resolve(ref string) (interface{}, bool) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, false
+	}
+
+	node := s.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#"), "/") {
+		if part == "" {
+			continue
+		}
+		part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+
+		switch n := node.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if node, ok = n[part]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, false
+			}
+			node = n[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return node, true
+}
+
+// validate checks v against the schema node, refs are the $refs being
+// resolved for v so that cycles are caught.
+func (s *Schema) validate(node interface{}, v interface{}, path string, problems *[]string, refs map[string]bool) {
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	sc, ok := node.(map[string]interface{})
+	if !ok {
+		if allowed, isBool := node.(bool); isBool && !allowed {
+			fail("no value is allowed here")
+		}
+		return
+	}
+
+	if ref, ok := sc["$ref"].(string); ok {
+		target, ok := s.resolve(ref)
+		if !ok {
+			fail("unresolvable $ref %q", ref)
+			return
+		}
+		if refs[ref] {
+			fail("$ref %q refers back to itself", ref)
+			return
+		}
+		if refs == nil {
+			refs = make(map[string]bool)
+		}
+		refs[ref] = true
+		s.validate(target, v, path, problems, refs)
+		delete(refs, ref)
+		return
+	}
+
+	if v == nil {
+		if nullable, _ := sc["nullable"].(bool); nullable {
+			return
+		}
+	}
+
+	if t, ok := sc["type"]; ok {
+		var types []string
+		switch tt := t.(type) {
+		case string:
+			types = []string{tt}
+		case []interface{}:
+			for _, e := range tt {
+				if str, ok := e.(string); ok {
+					types = append(types, str)
+				}
+			}
+		}
+
+		matched := false
+		for _, typ := range types {
+			if isSchemaType(typ, v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("expected %s but got %s", strings.Join(types, " or "), jsonTypeName(v))
+			return
+		}
+	}
+
+	if enum, ok := sc["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value is not one of the allowed values")
+		}
+	}
+
+	if c, ok := sc["const"]; ok && !jsonEqual(c, v) {
+		fail("value does not equal the constant")
+	}
+
+	switch val := v.(type) {
+	case json.Number:
+		f, _ := val.Float64()
+		// OpenAPI 3.0 makes the bounds exclusive with a boolean instead.
+		exclusiveMin, _ := sc["exclusiveMinimum"].(bool)
+		exclusiveMax, _ := sc["exclusiveMaximum"].(bool)
+		if min, ok := schemaNumber(sc, "minimum"); ok {
+			if exclusiveMin && f <= min {
+				fail("%v must be greater than %v", val, min)
+			} else if f < min {
+				fail("%v is less than the minimum %v", val, min)
+			}
+		}
+		if max, ok := schemaNumber(sc, "maximum"); ok {
+			if exclusiveMax && f >= max {
+				fail("%v must be less than %v", val, max)
+			} else if f > max {
+				fail("%v is greater than the maximum %v", val, max)
+			}
+		}
+		if min, ok := schemaNumber(sc, "exclusiveMinimum"); ok && f <= min {
+			fail("%v must be greater than %v", val, min)
+		}
+		if max, ok := schemaNumber(sc, "exclusiveMaximum"); ok && f >= max {
+			fail("%v must be less than %v", val, max)
+		}
+		if mult, ok := schemaNumber(sc, "multipleOf"); ok && mult != 0 {
+			if q := f / mult; math.Abs(q-math.Round(q)) > 1e-9 {
+				fail("%v is not a multiple of %v", val, mult)
+			}
+		}
+	case string:
+		length := len([]rune(val))
+		if min, ok := schemaNumber(sc, "minLength"); ok && float64(length) < min {
+			fail("length %d is less than the minimum %v", length, min)
+		}
+		if max, ok := schemaNumber(sc, "maxLength"); ok && float64(length) > max {
+			fail("length %d is greater than the maximum %v", length, max)
+		}
+		if pattern, ok := sc["pattern"].(string); ok {
+			re, err := s.pattern(pattern)
+			if err != nil {
+				fail("invalid pattern %q in schema", pattern)
+			} else if !re.MatchString(val) {
+				fail("%q does not match pattern %q", val, pattern)
+			}
+		}
+	case []interface{}:
+		if min, ok := schemaNumber(sc, "minItems"); ok && float64(len(val)) < min {
+			fail("%d items is less than the minimum %v", len(val), min)
+		}
+		if max, ok := schemaNumber(sc, "maxItems"); ok && float64(len(val)) > max {
+			fail("%d items is greater than the maximum %v", len(val), max)
+		}
+		if unique, _ := sc["uniqueItems"].(bool); unique {
+			for i := range val {
+				for k := i + 1; k < len(val); k++ {
+					if jsonEqual(val[i], val[k]) {
+						fail("items %d and %d are not unique", i, k)
+					}
+				}
+			}
+		}
+		if items, ok := sc["items"]; ok {
+			for i, e := range val {
+				s.validate(items, e, fmt.Sprintf("%s[%d]", path, i), problems, nil)
+			}
+		}
+	case map[string]interface{}:
+		if required, ok := sc["required"].([]interface{}); ok {
+			for _, r := range required {
+				if name, ok := r.(string); ok {
+					if _, ok := val[name]; !ok {
+						fail("missing required property %q", name)
+					}
+				}
+			}
+		}
+
+		props, _ := sc["properties"].(map[string]interface{})
+		additional, hasAdditional := sc["additionalProperties"]
+
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if prop, ok := props[k]; ok {
+				s.validate(prop, val[k], path+"."+k, problems, nil)
+			} else if hasAdditional {
+				s.validate(additional, val[k], path+"."+k, problems, nil)
+			}
+		}
+	}
+
+	if all, ok := sc["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			s.validate(sub, v, path, problems, refs)
+		}
+	}
+
+	if anyOf, ok := sc["anyOf"].([]interface{}); ok {
+		if s.countMatches(anyOf, v, path, refs) == 0 {
+			fail("value does not match any of the allowed schemas")
+		}
+	}
+
+	if one, ok := sc["oneOf"].([]interface{}); ok {
+		if n := s.countMatches(one, v, path, refs); n != 1 {
+			fail("value must match exactly one schema but matched %d", n)
+		}
+	}
+
+	if not, ok := sc["not"]; ok {
+		var sub []string
+		s.validate(not, v, path, &sub, refs)
+		if len(sub) == 0 {
+			fail("value must not match the schema")
+		}
+	}
+}
+
+func (s *Schema) countMatches(schemas []interface{}, v interface{}, path string, refs map[string]bool) int {
+	n := 0
+	for _, sub := range schemas {
+		var problems []string
+		s.validate(sub, v, path, &problems, refs)
+		if len(problems) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Schema) pattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := s.patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	s.patterns.Store(pattern, re)
+	return re, nil
+}
+
+func schemaNumber(sc map[string]interface{}, key string) (float64, bool) {
+	n, ok := sc[key].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+func isSchemaType(typ string, v interface{}) bool {
+	switch typ {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(json.Number)
+		return ok
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	}
+	return false
+}
+
+func jsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+// jsonEqual compares two decoded json values, numbers are compared by value.
+func jsonEqual(a, b interface{}) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+	if aok && bok {
+		af, _ := an.Float64()
+		bf, _ := bn.Float64()
+		return af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
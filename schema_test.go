@@ -0,0 +1,185 @@
+package jsonware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var testSchemaDoc = []byte(`{
+	"definitions": {
+		"testType": {
+			"type": "object",
+			"required": ["name"],
+			"properties": {
+				"name": {"type": "string", "minLength": 1, "maxLength": 5}
+			},
+			"additionalProperties": false
+		},
+		"list": {
+			"type": "array",
+			"items": {"$ref": "#/definitions/testType"},
+			"maxItems": 2
+		}
+	}
+}`)
+
+func TestSchemaValidate(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		schema string
+		doc    string
+		errs   []string
+	}{
+		{`{"type":"string"}`, `"hi"`, nil},
+		{`{"type":"string"}`, `5`, []string{"$: expected string but got number"}},
+		{`{"type":["string","null"]}`, `null`, nil},
+		{`{"type":"string","nullable":true}`, `null`, nil},
+		{`{"type":"integer"}`, `5.5`, []string{"expected integer"}},
+		{`{"type":"integer"}`, `5.0`, nil},
+		{`{"enum":["a","b"]}`, `"c"`, []string{"not one of the allowed"}},
+		{`{"enum":[1,2]}`, `2.0`, nil},
+		{`{"const":"a"}`, `"b"`, []string{"does not equal"}},
+		{`{"minimum":5,"maximum":10}`, `4`, []string{"less than the minimum"}},
+		{`{"minimum":5,"maximum":10}`, `11`, []string{"greater than the maximum"}},
+		{`{"exclusiveMinimum":5}`, `5`, []string{"must be greater than"}},
+		{`{"exclusiveMaximum":5}`, `5`, []string{"must be less than"}},
+		{`{"minimum":5,"exclusiveMinimum":true}`, `5`, []string{"must be greater than 5"}},
+		{`{"minimum":5,"exclusiveMinimum":false}`, `5`, nil},
+		{`{"maximum":5,"exclusiveMaximum":true}`, `5`, []string{"must be less than 5"}},
+		{`{"maximum":5,"exclusiveMaximum":true}`, `4.5`, nil},
+		{`{"multipleOf":0.5}`, `1.5`, nil},
+		{`{"multipleOf":2}`, `3`, []string{"not a multiple"}},
+		{`{"pattern":"^a+$"}`, `"aab"`, []string{"does not match pattern"}},
+		{`{"minLength":2}`, `"é"`, []string{"length 1 is less"}},
+		{`{"minItems":1}`, `[]`, []string{"0 items is less"}},
+		{`{"uniqueItems":true}`, `[1,2,1]`, []string{"items 0 and 2 are not unique"}},
+		{`{"items":{"type":"string"}}`, `["a",5]`, []string{"$[1]: expected string"}},
+		{`{"required":["a","b"]}`, `{"a":1}`, []string{`missing required property "b"`}},
+		{`{"properties":{"a":{"type":"string"}}}`, `{"a":1}`, []string{"$.a: expected string"}},
+		{`{"additionalProperties":false}`, `{"a":1}`, []string{"$.a: no value is allowed here"}},
+		{`{"additionalProperties":{"type":"number"}}`, `{"a":1}`, nil},
+		{`{"allOf":[{"minimum":1},{"maximum":3}]}`, `4`, []string{"greater than the maximum"}},
+		{`{"anyOf":[{"type":"string"},{"type":"number"}]}`, `true`, []string{"does not match any"}},
+		{`{"oneOf":[{"type":"number"},{"minimum":0}]}`, `5`, []string{"exactly one schema but matched 2"}},
+		{`{"not":{"type":"string"}}`, `"a"`, []string{"must not match"}},
+		{`{"$ref":"#/nope"}`, `1`, []string{"unresolvable $ref"}},
+		{`{"$ref":"#"}`, `1`, []string{`$ref "#" refers back to itself`}},
+		{`{"allOf":[{"$ref":"#/allOf/1"},{"anyOf":[{"$ref":"#/allOf/0"}]}]}`, `1`, []string{"does not match any"}},
+		{`{"type":"object","properties":{"child":{"$ref":"#"}}}`, `{"child":{"child":{}}}`, nil},
+		{`{"type":"string","minLength":3,"pattern":"^b"}`, `"a"`, []string{"length 1", "does not match pattern"}},
+	}
+
+	for i, test := range tests {
+		s, err := ParseSchema([]byte(test.schema))
+		if err != nil {
+			t.Fatalf("Test %d: %v", i, err)
+		}
+
+		err = s.Validate([]byte(test.doc))
+		if len(test.errs) == 0 {
+			if err != nil {
+				t.Errorf("Test %d: unexpected error: %v", i, err)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("Test %d: expected an error", i)
+			continue
+		}
+		for _, e := range test.errs {
+			if !strings.Contains(err.Error(), e) {
+				t.Errorf("Test %d: expected error to contain %q, got: %v", i, e, err)
+			}
+		}
+	}
+}
+
+func TestSchemaRef(t *testing.T) {
+	t.Parallel()
+
+	doc := MustParseSchema(testSchemaDoc)
+	if doc.Ref("#/definitions/missing") != nil {
+		t.Error("Expected nil for a missing ref")
+	}
+
+	list := doc.Ref("#/definitions/list")
+	if err := list.Validate([]byte(`[{"name":"a"},{"name":"b"}]`)); err != nil {
+		t.Error(err)
+	}
+
+	err := list.Validate([]byte(`[{"name":"a"},{},{"name":"toolong"}]`))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, e := range []string{"3 items", `$[1]: missing required property "name"`, "$[2].name: length 7"} {
+		if !strings.Contains(err.Error(), e) {
+			t.Errorf("Expected error to contain %q, got: %v", e, err)
+		}
+	}
+}
+
+func TestParseSchemaBad(t *testing.T) {
+	t.Parallel()
+
+	if _, err := ParseSchema([]byte(`{`)); err == nil {
+		t.Error("Expected an error")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic")
+		}
+	}()
+	MustParseSchema([]byte(`{`))
+}
+
+func TestContract(t *testing.T) {
+	t.Parallel()
+
+	doc := MustParseSchema(testSchemaDoc)
+	testType := doc.Ref("#/definitions/testType")
+
+	var tests = []struct {
+		handler interface{}
+		method  string
+		reqbody string
+		status  int
+		resbody string
+		log     string
+	}{
+		{testHandler1, "POST", `{"name":"bob"}`, 200, `{"name":"hi"}`, ""},
+		{testHandler1, "POST", `{"nam":"bob"}`, 500, "an internal server error", `request violates contract: $: missing required property "name"`},
+		{testHandler1, "POST", `{"name"`, 400, "could not deserialize", ""},
+		{testHandler6, "GET", ``, 500, "an internal server error", "response violates contract: $: expected object but got array"},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/", bytes.NewBufferString(test.reqbody))
+		req.Header = http.Header{"Accept": []string{"*/*"}}
+
+		log := &bytes.Buffer{}
+		j := Handler(test.handler).Log(log).Contract(testType, testType)
+		j.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, res.Code)
+		}
+
+		if b := res.Body.String(); !strings.Contains(b, test.resbody) {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+
+		if l := log.String(); !strings.Contains(l, test.log) {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected log: %s, got: %s", test.log, l)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package jsonware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*
+SelfTest checks that the request and response types of every registered
+handler can be serialized, so that a type that can't be fails at startup
+instead of on the first request that uses it. Handlers aren't called, it's a
+dry run with no side effects.
+
+Types are searched for fields that json can't hold, like channels, functions
+and maps with keys that can't be strings, including fields behind pointers
+that are nil in a zero value. Then a zero value of each type is encoded and
+decoded with the handler's Codec, with any panic along the way reported as
+well. The returned error describes every problem that was found.
+
+	if err := reg.SelfTest(); err != nil {
+		log.Fatal(err)
+	}
+*/
+func (reg *Registry) SelfTest() error {
+	var problems []string
+	for _, route := range reg.routes {
+		for _, p := range selfTest(route.Handler) {
+			problems = append(problems, fmt.Sprintf("%s %s: %s", route.Method, route.Pattern, p))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "\n"))
+}
+
+// selfTest finds the problems with the types of a handler.
+func selfTest(j *JSONHandler) (problems []string) {
+	defer func() {
+		if r := recover(); r != nil {
+			problems = append(problems, fmt.Sprintf("panicked: %v", r))
+		}
+	}()
+
+	codec := j.jsonCodec()
+	if j.in != nil && j.in != itemsType {
+		problems = append(problems, unserializable(j.in, "request", map[reflect.Type]bool{})...)
+		if len(problems) == 0 {
+			if err := roundTrip(j.in, codec); err != nil {
+				problems = append(problems, "request: "+err.Error())
+			}
+		}
+	}
+
+	out := j.out
+	if out != nil && j.stream != streamNone {
+		out = streamItemType(out)
+	}
+	if out == nil || out.Kind() == reflect.Interface {
+		return problems
+	}
+
+	outProblems := unserializable(out, "response", map[reflect.Type]bool{})
+	problems = append(problems, outProblems...)
+	if len(outProblems) == 0 {
+		zero, _ := allocate(out)
+		if !zero.IsValid() {
+			zero = reflect.New(out)
+		}
+		if _, err := codec.Marshal(zero.Interface()); err != nil {
+			problems = append(problems, "response: "+err.Error())
+		}
+	}
+	return problems
+}
+
+// checkResponseType panics if a handler's response type can't be serialized.
+func checkResponseType(out reflect.Type, stream streamKind) {
+	if stream != streamNone {
+		out = streamItemType(out)
+	}
+	if out.Kind() == reflect.Interface {
+		return
+	}
+	if problems := unserializable(out, "response", map[reflect.Type]bool{}); len(problems) != 0 {
+		panic(fmt.Sprintf("Response type %s cannot be serialized: %s", out, strings.Join(problems, ", ")))
+	}
+}
+
+// roundTrip encodes and decodes a zero value of a request type.
+func roundTrip(typ reflect.Type, codec *Codec) error {
+	zero, _ := allocate(typ)
+	b, err := codec.Marshal(zero.Interface())
+	if err != nil {
+		return err
+	}
+	decoded, _ := allocate(typ)
+	return codec.decode(json.NewDecoder(bytes.NewReader(b)), decoded.Interface())
+}
+
+// streamItemType is the type of the items a streaming response sends.
+func streamItemType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Chan {
+		return typ.Elem()
+	}
+	return typ.In(0).In(0)
+}
+
+// unserializable finds the parts of typ that json can't hold, path is where
+// typ is.
+func unserializable(typ reflect.Type, path string, seen map[reflect.Type]bool) []string {
+	if seen[typ] {
+		return nil
+	}
+	seen[typ] = true
+	if implementsMarshaler(typ) {
+		return nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return []string{fmt.Sprintf("%s has type %s which can't be serialized", path, typ)}
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return unserializable(typ.Elem(), path, seen)
+	case reflect.Map:
+		var problems []string
+		switch key := typ.Key(); key.Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			if !key.Implements(textMarshalerType) || !reflect.PointerTo(key).Implements(textUnmarshalerType) {
+				problems = append(problems, fmt.Sprintf("%s has map key type %s which can't be serialized", path, key))
+			}
+		}
+		return append(problems, unserializable(typ.Elem(), path+"[]", seen)...)
+	case reflect.Struct:
+		fields := codecFields(typ)
+		if len(fields) == 0 && typ.NumField() != 0 {
+			return []string{fmt.Sprintf("%s has type %s which has no exported fields and serializes to {}", path, typ)}
+		}
+		var problems []string
+		for _, f := range fields {
+			problems = append(problems, unserializable(f.typ, path+"."+f.name, seen)...)
+		}
+		return problems
+	}
+	return nil
+}
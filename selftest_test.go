@@ -0,0 +1,109 @@
+package jsonware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type selfTestKey struct{ a, b int }
+
+type selfTestNode struct {
+	Name     string          `json:"name"`
+	Children []*selfTestNode `json:"children"`
+}
+
+type selfTestBad struct {
+	Name    string                 `json:"name"`
+	Done    chan bool              `json:"done"`
+	Skipped func()                 `json:"-"`
+	Nested  *struct{ Fn func() }   `json:"nested"`
+	Keys    map[selfTestKey]string `json:"keys"`
+	Raw     json.RawMessage        `json:"raw"`
+}
+
+type selfTestPanics struct{}
+
+func (selfTestPanics) MarshalJSON() ([]byte, error) { panic("boom") }
+
+func TestSelfTest(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		fn   interface{}
+		want []string
+	}{
+		{func(r *http.Request, in *testType) (*selfTestNode, error) { return nil, nil }, nil},
+		{func(r *http.Request) (interface{}, error) { return nil, nil }, nil},
+		{func(r *http.Request) (<-chan selfTestNode, error) { return nil, nil }, nil},
+		{func(r *http.Request, in *selfTestBad) error { return nil }, []string{
+			"POST /x: request.done has type chan bool which can't be serialized",
+			"POST /x: request.nested.Fn has type func() which can't be serialized",
+			"POST /x: request.keys has map key type jsonware.selfTestKey which can't be serialized",
+		}},
+		{func(r *http.Request) (*selfTestPanics, error) { return nil, nil }, []string{"POST /x: panicked: boom"}},
+	}
+
+	for i, test := range tests {
+		reg := &Registry{}
+		reg.Add("POST", "/x", Handler(test.fn))
+
+		err := reg.SelfTest()
+		if len(test.want) == 0 {
+			if err != nil {
+				t.Errorf("Test %d: expected no problems, got: %v", i, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("Test %d: expected problems", i)
+			continue
+		}
+		if got := strings.Split(err.Error(), "\n"); strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("Test %d: expected:\n%s\ngot:\n%s", i, strings.Join(test.want, "\n"), err)
+		}
+	}
+}
+
+func TestHandlerResponseTypes(t *testing.T) {
+	t.Parallel()
+
+	type hidden struct{ name string }
+	type stamped struct {
+		At   time.Time `json:"at"`
+		Amps complex128
+	}
+
+	var tests = []struct {
+		fn   interface{}
+		want string
+	}{
+		{func(r *http.Request) (*selfTestNode, error) { return nil, nil }, ""},
+		{func(r *http.Request) (map[string]json.RawMessage, error) { return nil, nil }, ""},
+		{func(r *http.Request) (*struct{}, error) { return nil, nil }, ""},
+		{func(r *http.Request) (<-chan selfTestNode, error) { return nil, nil }, ""},
+		{func(r *http.Request) (*selfTestBad, error) { return nil, nil },
+			"Response type *jsonware.selfTestBad cannot be serialized: response.done has type chan bool which can't be serialized, " +
+				"response.nested.Fn has type func() which can't be serialized, response.keys has map key type jsonware.selfTestKey which can't be serialized"},
+		{func(r *http.Request) ([]hidden, error) { return nil, nil },
+			"Response type []jsonware.hidden cannot be serialized: response has type jsonware.hidden which has no exported fields and serializes to {}"},
+		{func(r *http.Request) (<-chan stamped, error) { return nil, nil },
+			"Response type jsonware.stamped cannot be serialized: response.Amps has type complex128 which can't be serialized"},
+	}
+
+	for i, test := range tests {
+		func() {
+			defer func() {
+				r := recover()
+				if len(test.want) == 0 && r != nil {
+					t.Errorf("Test %d: expected no panic, got: %v", i, r)
+				} else if len(test.want) != 0 && r != test.want {
+					t.Errorf("Test %d: expected panic:\n%s\ngot:\n%v", i, test.want, r)
+				}
+			}()
+			Handler(test.fn)
+		}()
+	}
+}
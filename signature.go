@@ -0,0 +1,225 @@
+package jsonware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// signatureScheme is the authorization scheme of signed requests.
+const signatureScheme = "JSONWARE-HMAC-SHA256"
+
+// defaultMaxSkew is the MaxSkew of a Signature that leaves it zero.
+const defaultMaxSkew = 5 * time.Minute
+
+var (
+	errUnsigned         = errors.New("request is not signed")
+	errBadSignature     = errors.New("request signature is invalid")
+	errUnknownKey       = errors.New("request is signed with an unknown key")
+	errSignatureExpired = errors.New("request date is missing or too far from the current time")
+)
+
+type signingKeyIDKey struct{}
+
+// SigningKeyFrom retrieves the id of the key that a request was signed with
+// from its context, as put there by a Signature.
+func SigningKeyFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(signingKeyIDKey{}).(string)
+	return id, ok
+}
+
+// KeyLookup finds the secret of the signing key with the given id, it
+// returns a nil key when there's no such key.
+type KeyLookup func(r *http.Request, keyID string) ([]byte, error)
+
+/*
+Signature verifies that every request that passes through the handlers it
+wraps is signed with one of the keys Keys knows about, rejecting requests
+that aren't with a 401. Handlers can find out which key signed the request
+with SigningKeyFrom.
+
+A request is signed with an HMAC-SHA256 over a canonical string made of the
+method, the path and query, the date, and a hex encoded SHA-256 of the body,
+one per line:
+
+	POST
+	/orders?dry=true
+	Mon, 02 Jan 2006 15:04:05 GMT
+	44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a
+
+The signature goes in the Authorization header along with the key's id and
+the date goes in the X-Jsonware-Date header, or the Date header if that's
+missing. Requests dated more than MaxSkew from now are rejected to limit
+replays, 5 minutes is used when it's zero. SignRequest signs requests for
+clients written in Go.
+
+	Authorization: JSONWARE-HMAC-SHA256 KeyId=partner1, Signature=5d41...
+
+If Keys returns an error the request gets a 401 when it's an Err, and
+otherwise the error is cloaked.
+
+	sig := &jsonware.Signature{Keys: func(r *http.Request, id string) ([]byte, error) {
+		return partners.Secret(id)
+	}}
+	http.Handle("/orders", sig.Wrap(jsonware.Handler(createOrder)))
+*/
+type Signature struct {
+	Keys    KeyLookup
+	MaxSkew time.Duration
+
+	// Log is where errors are reported, the global logger is used when it's
+	// nil.
+	Log io.Writer
+
+	// now is swapped out in tests.
+	now func() time.Time
+}
+
+// Wrap returns a handler that verifies request signatures before serving
+// requests with h.
+func (s *Signature) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, err := s.verify(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			if _, ok := err.(Err); ok {
+				w.Header().Set("WWW-Authenticate", signatureScheme)
+			}
+			writeError(w, s.Log, err)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// verify checks the request's signature, returning a copy of the request
+// that carries the id of the key it was signed with and whose body can be read
+// again.
+func (s *Signature) verify(r *http.Request) (*http.Request, error) {
+	keyID, sig, ok := parseSignature(r.Header.Get("Authorization"))
+	if !ok {
+		return nil, Err{Status: http.StatusUnauthorized, Err: errUnsigned}
+	}
+
+	date := signatureDate(r)
+	t, err := http.ParseTime(date)
+	now := time.Now()
+	if s.now != nil {
+		now = s.now()
+	}
+	skew := s.MaxSkew
+	if skew == 0 {
+		skew = defaultMaxSkew
+	}
+	if err != nil || t.Before(now.Add(-skew)) || t.After(now.Add(skew)) {
+		return nil, Err{Status: http.StatusUnauthorized, Err: errSignatureExpired}
+	}
+
+	key, err := s.Keys(r, keyID)
+	if err != nil {
+		if e, ok := err.(Err); ok {
+			e.Status = http.StatusUnauthorized
+			return nil, e
+		}
+		return nil, fmt.Errorf("failed to look up signing key %s: %w", keyID, err)
+	}
+	if key == nil {
+		return nil, Err{Status: http.StatusUnauthorized, Err: errUnknownKey}
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return nil, Err{Status: http.StatusBadRequest, Err: errors.New("could not read request body")}
+		}
+		r.Body.Close()
+	}
+
+	want := signatureOf(key, r.Method, r.URL.RequestURI(), date, body)
+	got, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, want) {
+		return nil, Err{Status: http.StatusUnauthorized, Err: errBadSignature}
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), signingKeyIDKey{}, keyID))
+	if body != nil {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return r, nil
+}
+
+/*
+SignRequest signs a request for a Signature with the key named keyID, setting
+its X-Jsonware-Date and Authorization headers. The body is read and replaced
+so that the request can still be sent.
+*/
+func SignRequest(r *http.Request, keyID string, key []byte) error {
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return err
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	date := signatureDate(r)
+	if len(date) == 0 {
+		date = time.Now().UTC().Format(http.TimeFormat)
+		r.Header.Set("X-Jsonware-Date", date)
+	}
+
+	sig := signatureOf(key, r.Method, r.URL.RequestURI(), date, body)
+	r.Header.Set("Authorization", fmt.Sprintf("%s KeyId=%s, Signature=%s", signatureScheme, keyID, hex.EncodeToString(sig)))
+	return nil
+}
+
+// signatureDate is the date a request was signed at.
+func signatureDate(r *http.Request) string {
+	if date := r.Header.Get("X-Jsonware-Date"); len(date) != 0 {
+		return date
+	}
+	return r.Header.Get("Date")
+}
+
+// signatureOf computes the signature of a request.
+func signatureOf(key []byte, method, uri, date string, body []byte) []byte {
+	bodySum := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, key)
+	io.WriteString(mac, strings.Join([]string{
+		method, uri, date, hex.EncodeToString(bodySum[:]),
+	}, "\n"))
+	return mac.Sum(nil)
+}
+
+// parseSignature parses the key id and signature out of an Authorization
+// header.
+func parseSignature(auth string) (keyID, sig string, ok bool) {
+	scheme, params, _ := strings.Cut(auth, " ")
+	if !strings.EqualFold(scheme, signatureScheme) {
+		return "", "", false
+	}
+
+	for _, param := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch strings.ToLower(name) {
+		case "keyid":
+			keyID = value
+		case "signature":
+			sig = value
+		}
+	}
+
+	return keyID, sig, len(keyID) != 0 && len(sig) != 0
+}
@@ -0,0 +1,123 @@
+package jsonware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignature(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	log := &bytes.Buffer{}
+	sig := &Signature{
+		Keys: func(r *http.Request, id string) ([]byte, error) {
+			switch id {
+			case "partner":
+				return []byte("secret"), nil
+			case "banned":
+				return nil, Err{Err: errors.New("key is revoked")}
+			case "broken":
+				return nil, errors.New("db went away")
+			}
+			return nil, nil
+		},
+		Log: log,
+		now: func() time.Time { return now },
+	}
+	h := sig.Wrap(Handler(func(r *http.Request, in *testType) (*testType, error) {
+		id, _ := SigningKeyFrom(r.Context())
+		return &testType{Name: id + ":" + in.Name}, nil
+	}))
+
+	sign := func(keyID, key string, date time.Time) func(*http.Request) {
+		return func(r *http.Request) {
+			r.Header.Set("X-Jsonware-Date", date.Format(http.TimeFormat))
+			SignRequest(r, keyID, []byte(key))
+		}
+	}
+
+	var tests = []struct {
+		sign    func(*http.Request)
+		tamper  bool
+		status  int
+		resbody string
+	}{
+		{sign("partner", "secret", now), false, 200, `{"name":"partner:bob"}`},
+		{sign("partner", "secret", now.Add(-4*time.Minute)), false, 200, `{"name":"partner:bob"}`},
+		{func(r *http.Request) {}, false, 401, `{"error":"request is not signed"}`},
+		{sign("partner", "wrong", now), false, 401, `{"error":"request signature is invalid"}`},
+		{sign("partner", "secret", now), true, 401, `{"error":"request signature is invalid"}`},
+		{sign("partner", "secret", now.Add(-6*time.Minute)), false, 401, `{"error":"request date is missing or too far from the current time"}`},
+		{sign("partner", "secret", now.Add(6*time.Minute)), false, 401, `{"error":"request date is missing or too far from the current time"}`},
+		{sign("nobody", "secret", now), false, 401, `{"error":"request is signed with an unknown key"}`},
+		{sign("banned", "secret", now), false, 401, `{"error":"key is revoked"}`},
+		{sign("broken", "secret", now), false, 500, `{"error":"an internal server error occurred"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/orders?dry=true", strings.NewReader(`{"name":"bob"}`))
+		req.Header.Set("Accept", "application/json")
+		test.sign(req)
+		if test.tamper {
+			req.Body.Close()
+			req.Body = nopBody{strings.NewReader(`{"name":"eve"}`)}
+		}
+
+		body := req.Body
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if req.Body != body {
+			t.Errorf("Test %d: expected the caller's request body to be left alone", i)
+		}
+		if b := res.Body.String(); b != test.resbody && b != test.resbody+"\n" {
+			t.Errorf("Test %d: body was wrong: %s", i, b)
+		}
+		if test.status == 401 && res.Header().Get("WWW-Authenticate") != signatureScheme {
+			t.Errorf("Test %d: expected a WWW-Authenticate header", i)
+		}
+	}
+
+	if !strings.Contains(log.String(), "failed to look up signing key broken: db went away") {
+		t.Error("Expected the key lookup failure to be logged:", log.String())
+	}
+}
+
+type nopBody struct {
+	*strings.Reader
+}
+
+func (nopBody) Close() error { return nil }
+
+func TestParseSignature(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		auth  string
+		keyID string
+		sig   string
+		ok    bool
+	}{
+		{"JSONWARE-HMAC-SHA256 KeyId=a, Signature=b", "a", "b", true},
+		{"jsonware-hmac-sha256 keyid=a,signature=b", "a", "b", true},
+		{"JSONWARE-HMAC-SHA256 KeyId=a", "a", "", false},
+		{"Bearer abc", "", "", false},
+		{"", "", "", false},
+	}
+
+	for i, test := range tests {
+		keyID, sig, ok := parseSignature(test.auth)
+		if keyID != test.keyID || sig != test.sig || ok != test.ok {
+			t.Errorf("Test %d: got %q %q %t", i, keyID, sig, ok)
+		}
+	}
+}
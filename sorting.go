@@ -0,0 +1,205 @@
+package jsonware
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var envelopeType = reflect.TypeOf((*envelope)(nil)).Elem()
+
+// SortParam is the query parameter clients ask for a sort order with.
+const SortParam = "sort"
+
+// Sort is a field to sort a list by.
+type Sort struct {
+	// Field is the json name of the field.
+	Field string
+	Desc  bool
+}
+
+/*
+Sortable makes the JSONHandler check the sort order clients ask for with the
+sort query parameter, like ?sort=-created_at,name, against the fields of its
+response type, so that handlers can pass it on to the database without
+building a query from names they don't know. Fields are named by their json
+keys and sort descending when they start with a -. Asking for any other field
+gets the client a 400.
+
+Fields are those of the response type, or of its items when it's a list, a
+Page or a Response. When any field is tagged with sortable:"true" only the
+ones that are can be sorted by. Fields tagged with visible can only be sorted
+by subjects that can see them, as the order would give their values away.
+Sortable panics if the response type has no fields.
+
+	type User struct {
+		ID      int       `json:"id" sortable:"true"`
+		Name    string    `json:"name" sortable:"true"`
+		Created time.Time `json:"created_at" sortable:"true"`
+		Bio     string    `json:"bio"`
+	}
+
+	jsonware.Handler(listUsers).Sortable()
+
+	func listUsers(r *http.Request) ([]*User, error) {
+		return db.Users(r.Context(), jsonware.SortOf(r))
+	}
+*/
+func (j *JSONHandler) Sortable() *JSONHandler {
+	fields := jsonFields(j.out, "sortable")
+	if len(fields) == 0 {
+		panic(fmt.Sprintf("Sortable needs a response type with fields, %v has none", j.out))
+	}
+	j.sortable = fields
+	return j
+}
+
+// SortOf is the sort order the request asks for in its sort query parameter,
+// the fields in the order they're sorted by. It's nil when none is asked for.
+func SortOf(r *http.Request) []Sort {
+	param := r.URL.Query().Get(SortParam)
+	if len(param) == 0 {
+		return nil
+	}
+
+	var order []Sort
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		s := Sort{Field: strings.TrimLeft(field, "+-")}
+		s.Desc = strings.HasPrefix(field, "-")
+		if len(s.Field) != 0 {
+			order = append(order, s)
+		}
+	}
+	return order
+}
+
+// checkSort checks the request only asks to sort by fields that can be
+// sorted by.
+func (j JSONHandler) checkSort(r *http.Request) error {
+	subject, _ := subjectOf(r)
+	for _, s := range SortOf(r) {
+		if !j.sortable.allows(s.Field, subject.Roles) {
+			return Err{Status: http.StatusBadRequest, Err: fmt.Errorf("cannot sort by: %s", s.Field), Reason: j.sortable.allowed(subject.Roles)}
+		}
+	}
+	return nil
+}
+
+// fieldRoles are the json names of fields with the roles of their visible
+// tag, which are nil for fields everyone can see.
+type fieldRoles map[string][]string
+
+// allows checks if a subject with roles can use field.
+func (f fieldRoles) allows(field string, roles []string) bool {
+	visible, ok := f[field]
+	return ok && (visible == nil || hasAny(roles, visible))
+}
+
+// allowed is the sorted names of the fields a subject with roles can use.
+func (f fieldRoles) allowed(roles []string) []string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		if f.allows(name, roles) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// jsonFields finds the json names of the fields of typ, or of its items,
+// that are tagged with tag:"true", or all of them when none are. The fields
+// of embedded structs are included like encoding/json promotes them, a field
+// is shadowed by one of the same name that's less deeply embedded.
+func jsonFields(typ reflect.Type, tag string) fieldRoles {
+	typ = itemType(typ)
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	type embedded struct {
+		typ   reflect.Type
+		roles []string
+	}
+
+	all := make(fieldRoles)
+	tagged := make(fieldRoles)
+	seen := make(map[reflect.Type]bool)
+	// Structs are walked breadth first so shallower fields are found first.
+	queue := []embedded{{typ: typ}}
+	for len(queue) != 0 {
+		e := queue[0]
+		queue = queue[1:]
+		if seen[e.typ] {
+			continue
+		}
+		seen[e.typ] = true
+
+		for i := 0; i < e.typ.NumField(); i++ {
+			f := e.typ.Field(i)
+			name, promoted := jsonFieldName(f)
+			if promoted {
+				queue = append(queue, embedded{typ: elemType(f.Type), roles: visibleRoles(f, e.roles)})
+				continue
+			}
+			if _, ok := all[name]; ok || len(name) == 0 {
+				continue
+			}
+			roles := visibleRoles(f, e.roles)
+			all[name] = roles
+			if f.Tag.Get(tag) == "true" {
+				tagged[name] = roles
+			}
+		}
+	}
+	if len(tagged) != 0 {
+		return tagged
+	}
+	return all
+}
+
+// visibleRoles is the roles that can see the field f of a struct that only
+// the inherited roles can see, nil means everyone.
+func visibleRoles(f reflect.StructField, inherited []string) []string {
+	visible, ok := f.Tag.Lookup("visible")
+	if !ok {
+		return inherited
+	}
+	roles := splitTag(visible)
+	if inherited == nil {
+		return roles
+	}
+
+	// Both have to be allowed, only roles in both lists are sure to be.
+	both := []string{}
+	for _, role := range roles {
+		if hasAny(inherited, []string{role}) {
+			both = append(both, role)
+		}
+	}
+	return both
+}
+
+// itemType is the type of the items of a list response type, or typ itself
+// when it isn't a list.
+func itemType(typ reflect.Type) reflect.Type {
+	for typ != nil {
+		switch {
+		case typ.Kind() == reflect.Ptr:
+			if typ.Implements(envelopeType) && typ.Elem().Kind() == reflect.Struct {
+				// Pages and Responses hold their body in their first field.
+				typ = typ.Elem().Field(0).Type
+				continue
+			}
+			typ = typ.Elem()
+		case typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array:
+			typ = typ.Elem()
+		default:
+			return typ
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type sortUser struct {
+	ID   int    `json:"id" sortable:"true"`
+	Name string `json:"name,omitempty" sortable:"true"`
+	Bio  string `json:"bio"`
+}
+
+func TestSortable(t *testing.T) {
+	t.Parallel()
+
+	var got []Sort
+	list := Handler(func(r *http.Request) ([]sortUser, error) {
+		got = SortOf(r)
+		return nil, nil
+	}).Sortable()
+	page := Handler(func(r *http.Request) (*Page[testType], error) {
+		got = SortOf(r)
+		return &Page[testType]{}, nil
+	}).Sortable()
+
+	var tests = []struct {
+		handler *JSONHandler
+		query   string
+		status  int
+		want    []Sort
+		body    string
+	}{
+		{list, "", 200, nil, ``},
+		{list, "?sort=-id,name", 200, []Sort{{Field: "id", Desc: true}, {Field: "name"}}, ``},
+		{list, "?sort=%2Bname", 200, []Sort{{Field: "name"}}, ``},
+		{list, "?sort=bio", 400, nil, `{"error":"cannot sort by: bio","reason":["id","name"]}`},
+		{page, "?sort=-name", 200, []Sort{{Field: "name", Desc: true}}, `[]`},
+		{page, "?sort=id", 400, nil, `{"error":"cannot sort by: id","reason":["name"]}`},
+	}
+
+	for i, test := range tests {
+		got = nil
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/users"+test.query, nil)
+		req.Header.Set("Accept", "application/json")
+		test.handler.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Test %d: expected sort %v, got: %v", i, test.want, got)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.body {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.body, b)
+		}
+	}
+}
+
+func TestSortablePanics(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	Handler(func(r *http.Request) (interface{}, error) { return nil, nil }).Sortable()
+}
+
+func TestSortableVisible(t *testing.T) {
+	t.Parallel()
+
+	type account struct {
+		ID    int    `json:"id"`
+		Email string `json:"email,omitempty" visible:"admin"`
+	}
+	h := Handler(func(r *http.Request) ([]account, error) { return nil, nil }).Sortable()
+
+	var tests = []struct {
+		subject *Subject
+		query   string
+		status  int
+		body    string
+	}{
+		{nil, "?sort=id", 200, ``},
+		{nil, "?sort=email", 400, `{"error":"cannot sort by: email","reason":["id"]}`},
+		{&Subject{ID: "bob", Roles: []string{"support"}}, "?sort=email", 400, `{"error":"cannot sort by: email","reason":["id"]}`},
+		{&Subject{ID: "ann", Roles: []string{"admin"}}, "?sort=-email", 200, ``},
+		{&Subject{ID: "ann", Roles: []string{"admin"}}, "?sort=bio", 400, `{"error":"cannot sort by: bio","reason":["email","id"]}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/accounts"+test.query, nil)
+		req.Header.Set("Accept", "application/json")
+		if test.subject != nil {
+			req = req.WithContext(WithSubject(req.Context(), *test.subject))
+		}
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.body {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.body, b)
+		}
+	}
+}
+
+type sortBase struct {
+	ID     int    `json:"id" sortable:"true"`
+	Name   string `json:"name" sortable:"true"`
+	Secret string `json:"secret" visible:"admin"`
+}
+
+type sortExtra struct {
+	Rank  int    `json:"rank"`
+	Notes string `json:"notes" visible:"admin,support"`
+}
+
+type sortEmbedded struct {
+	sortBase
+	*sortExtra `visible:"support"`
+	Name       string `json:"name"`
+}
+
+func TestJSONFieldsEmbedded(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		tag  string
+		want fieldRoles
+	}{
+		{"sortable", fieldRoles{"id": nil}},
+		{"filterable", fieldRoles{
+			"id":     nil,
+			"name":   nil,
+			"secret": {"admin"},
+			"rank":   {"support"},
+			"notes":  {"support"},
+		}},
+	}
+
+	for i, test := range tests {
+		if got := jsonFields(reflect.TypeOf([]sortEmbedded{}), test.tag); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Test %d: expected %v, got: %v", i, test.want, got)
+		}
+	}
+}
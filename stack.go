@@ -0,0 +1,84 @@
+package jsonware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+)
+
+/*
+WithStack records the stack at the point it's called on err, so that if err
+ends up as a cloaked error the log shows where it came from. It returns nil if
+err is nil.
+
+	func handler(w http.ResponseWriter, r *http.Request) (*User, error) {
+		user, err := db.FindUser(r.URL.Query().Get("id"))
+		if err != nil {
+			return nil, jsonware.WithStack(err)
+		}
+		return user, nil
+	}
+
+Errors that already carry a stack, such as those from github.com/pkg/errors,
+don't need this, their stacks are logged as is. Stacks aren't captured for
+cloaked errors that don't have one: by the time an error is written out the
+function that created it has returned, so the only stack left is the one
+serving the request, which says nothing about where the error came from.
+*/
+func WithStack(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	return stackErr{err: err, pcs: pcs[:n]}
+}
+
+// stackErr is an error with the stack of where it was created.
+type stackErr struct {
+	err error
+	pcs []uintptr
+}
+
+func (s stackErr) Error() string { return s.err.Error() }
+func (s stackErr) Unwrap() error { return s.err }
+
+// Format prints the stack along with the message for %+v, as
+// github.com/pkg/errors does.
+func (s stackErr) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('+'):
+		fmt.Fprintf(f, "%+v", s.err)
+		frames := runtime.CallersFrames(s.pcs)
+		for {
+			frame, more := frames.Next()
+			fmt.Fprintf(f, "\n%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+			if !more {
+				break
+			}
+		}
+	case verb == 'q':
+		fmt.Fprintf(f, "%q", s.err.Error())
+	default:
+		io.WriteString(f, s.err.Error())
+	}
+}
+
+// formatErr formats an error for the log. Errors that know how to print their
+// stack with %+v are asked to, if err doesn't but wraps one that does the
+// wrapped error's stack is printed after err's message.
+func formatErr(err error) string {
+	if _, ok := err.(fmt.Formatter); ok {
+		return fmt.Sprintf("%+v", err)
+	}
+
+	for inner := errors.Unwrap(err); inner != nil; inner = errors.Unwrap(inner) {
+		if _, ok := inner.(fmt.Formatter); ok {
+			return fmt.Sprintf("%v\n%+v", err, inner)
+		}
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,67 @@
+package jsonware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithStack(t *testing.T) {
+	t.Parallel()
+
+	if WithStack(nil) != nil {
+		t.Error("Expected nil error to stay nil")
+	}
+
+	base := errors.New("broken")
+	err := WithStack(base)
+	if err.Error() != "broken" {
+		t.Error("Message was wrong:", err.Error())
+	}
+	if !errors.Is(err, base) {
+		t.Error("Expected the error to unwrap to the original")
+	}
+	if s := fmt.Sprintf("%v", err); s != "broken" {
+		t.Error("Plain format was wrong:", s)
+	}
+
+	s := fmt.Sprintf("%+v", err)
+	if !strings.HasPrefix(s, "broken\n") || !strings.Contains(s, "jsonware.TestWithStack") || !strings.Contains(s, "stack_test.go:") {
+		t.Error("Verbose format did not have a stack:", s)
+	}
+}
+
+func TestStackLogged(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		err   error
+		stack bool
+	}{
+		{errors.New("broken"), false},
+		{WithStack(errors.New("broken")), true},
+		{fmt.Errorf("wrapped: %w", WithStack(errors.New("broken"))), true},
+	}
+
+	for i, test := range tests {
+		log := &bytes.Buffer{}
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header = http.Header{"Accept": []string{"*/*"}}
+
+		err := test.err
+		Handler(func(r *http.Request) error { return err }).Log(log).ServeHTTP(res, req)
+
+		l := log.String()
+		if !strings.HasPrefix(l, "internal error: ") || !strings.Contains(l, "broken") {
+			t.Errorf("Test %d: log was wrong: %s", i, l)
+		}
+		if hasStack := strings.Contains(l, "stack_test.go:"); hasStack != test.stack {
+			t.Errorf("Test %d: expected stack %v, log: %s", i, test.stack, l)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package jsonware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+/*
+Static returns a JSONHandler that serves v, serialized once when Static is
+called, for documents that don't change while the service runs like
+.well-known documents or terms of service metadata. Responses have an ETag
+so that clients can revalidate them with a 304, and may be cached for maxAge,
+or must be revalidated every time when it's zero. It panics if v can't be
+serialized.
+
+	reg.Add("GET", "/.well-known/openid-configuration", jsonware.Static(oidcConfig, time.Hour))
+*/
+func Static(v interface{}, maxAge time.Duration) *JSONHandler {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("Static cannot serialize %T: %v", v, err))
+	}
+	return staticHandler(b, maxAge)
+}
+
+/*
+StaticTemplate is like Static, but serves the json produced by executing
+tmpl, a text/template, with data. The template is executed once when
+StaticTemplate is called, and it panics if the template can't be parsed or
+executed or what it produces isn't json. The json function writes a value as
+json, which escapes strings properly.
+
+	jsonware.StaticTemplate(`{
+		"issuer": {{json .Issuer}},
+		"jwks_uri": {{json (print .Issuer "/jwks")}}
+	}`, cfg, time.Hour)
+*/
+func StaticTemplate(tmpl string, data interface{}, maxAge time.Duration) *JSONHandler {
+	t, err := template.New("static").Funcs(template.FuncMap{"json": templateJSON}).Parse(tmpl)
+	if err != nil {
+		panic(fmt.Sprintf("StaticTemplate cannot parse template: %v", err))
+	}
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, data); err != nil {
+		panic(fmt.Sprintf("StaticTemplate cannot execute template: %v", err))
+	}
+
+	compact := &bytes.Buffer{}
+	if err := json.Compact(compact, buf.Bytes()); err != nil {
+		panic(fmt.Sprintf("StaticTemplate produced invalid json: %v", err))
+	}
+	return staticHandler(compact.Bytes(), maxAge)
+}
+
+// templateJSON is the json function of StaticTemplate.
+func templateJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// staticHandler serves the json b.
+func staticHandler(b []byte, maxAge time.Duration) *JSONHandler {
+	sum := sha256.Sum256(b)
+	etag := base64.RawURLEncoding.EncodeToString(sum[:16])
+
+	cacheControl := "no-cache"
+	if maxAge > 0 {
+		cacheControl = "public, max-age=" + strconv.Itoa(int(maxAge/time.Second))
+	}
+
+	return Handler(func(r *http.Request) (Raw, error) {
+		return Raw(b), nil
+	}).Version(func(r *http.Request) (string, error) {
+		return etag, nil
+	}).CacheControl(cacheControl)
+}
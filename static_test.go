@@ -0,0 +1,73 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatic(t *testing.T) {
+	t.Parallel()
+
+	doc := map[string]string{"issuer": "https://example.com"}
+	tmpl := `{
+		"issuer": {{json .issuer}},
+		"jwks_uri": {{json (print .issuer "/jwks")}}
+	}`
+
+	var tests = []struct {
+		handler *JSONHandler
+		want    string
+		cache   string
+	}{
+		{Static(doc, time.Hour), `{"issuer":"https://example.com"}`, "public, max-age=3600"},
+		{StaticTemplate(tmpl, doc, 0), `{"issuer":"https://example.com","jwks_uri":"https://example.com/jwks"}`, "no-cache"},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/.well-known/doc", nil)
+		req.Header.Set("Accept", "application/json")
+		test.handler.ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("Test %d: expected status 200, got: %d", i, res.Code)
+		}
+		if b := res.Body.String(); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+		if c := res.Header().Get("Cache-Control"); c != test.cache {
+			t.Errorf("Test %d: expected Cache-Control %q, got: %q", i, test.cache, c)
+		}
+		etag := res.Header().Get("ETag")
+		if len(etag) == 0 {
+			t.Errorf("Test %d: expected an etag", i)
+		}
+
+		res = httptest.NewRecorder()
+		req.Header.Set("If-None-Match", etag)
+		test.handler.ServeHTTP(res, req)
+		if res.Code != http.StatusNotModified {
+			t.Errorf("Test %d: expected status 304, got: %d", i, res.Code)
+		}
+		if c := res.Header().Get("Cache-Control"); c != test.cache {
+			t.Errorf("Test %d: expected Cache-Control %q on the 304, got: %q", i, test.cache, c)
+		}
+	}
+}
+
+func TestStaticTemplatePanics(t *testing.T) {
+	t.Parallel()
+
+	for i, tmpl := range []string{`{{`, `{{.Missing.Field}}`, `{"a":}`} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Test %d: expected a panic", i)
+				}
+			}()
+			StaticTemplate(tmpl, struct{}{}, 0)
+		}()
+	}
+}
@@ -0,0 +1,396 @@
+package jsonware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// streamKind is the kind of producer a streaming handler returns.
+type streamKind int
+
+const (
+	streamNone streamKind = iota
+	// streamChan is a channel of items.
+	streamChan
+	// streamSeq is an iter.Seq of items.
+	streamSeq
+	// streamSeq2 is an iter.Seq2 of items and errors.
+	streamSeq2
+)
+
+// streamKindOf works out what kind of producer typ is, if any.
+func streamKindOf(typ reflect.Type) streamKind {
+	switch typ.Kind() {
+	case reflect.Chan:
+		if typ.ChanDir()&reflect.RecvDir != 0 {
+			return streamChan
+		}
+	case reflect.Func:
+		if typ.NumIn() != 1 || typ.NumOut() != 0 {
+			break
+		}
+		yield := typ.In(0)
+		if yield.Kind() != reflect.Func || yield.NumOut() != 1 || yield.Out(0).Kind() != reflect.Bool {
+			break
+		}
+		switch {
+		case yield.NumIn() == 1:
+			return streamSeq
+		case yield.NumIn() == 2 && "error" == yield.In(1).String():
+			return streamSeq2
+		}
+	}
+	return streamNone
+}
+
+/*
+FlushEvery sets how often a streaming handler's response is flushed to the
+client, after every n items or when interval has passed since the last
+flush, whichever comes first. A zero n or interval disables that trigger, by
+default every item is flushed as soon as it's written.
+
+Handlers stream their response by returning a channel, an iter.Seq or an
+iter.Seq2 of items instead of a value. Items are written as they're
+produced and only as fast as the client reads them, a producer blocks while
+the connection is backed up. The stream is a json array for clients that
+accept json, newline delimited json for clients that accept
+application/x-ndjson and server-sent events for clients that accept
+text/event-stream.
+
+	func watchOrders(r *http.Request) iter.Seq2[*Order, error] {
+		return func(yield func(*Order, error) bool) {
+			for o := range orders.Watch(r.Context()) {
+				if !yield(o, nil) {
+					return
+				}
+			}
+		}
+	}
+
+	jsonware.Handler(watchOrders).FlushEvery(50, time.Second)
+
+A producer ends the stream early with an error by yielding it from an
+iter.Seq2, or by sending it on a channel of interface{}. Cloaked errors are
+logged as usual. Since the response status has long been sent by then, the
+outcome of every stream is reported in trailers: Jsonware-Status is 200 when
+the stream completed and the error's status when it didn't, and
+Jsonware-Error holds the error message. Clients that can't read trailers
+get a terminal error record instead, an error body like the ones
+non-streaming handlers send with an added status field, as the last line of
+newline delimited json or as an "error" event for server-sent events. A
+completed server-sent event stream ends with an "end" event, so a stream that
+stopped with neither was truncated.
+
+Producers should stop when yield returns false or the request's context is
+done, which is when the client went away.
+*/
+func (j *JSONHandler) FlushEvery(n int, interval time.Duration) *JSONHandler {
+	j.flushItems = n
+	j.flushInterval = interval
+	return j
+}
+
+/*
+Heartbeat makes a streaming handler send a frame that carries no data
+whenever its stream has been idle for interval, so that load balancers and
+proxies don't close a connection that's only waiting on the producer. The
+frame is a comment for server-sent events, an empty line for newline
+delimited json and a space inside a json array. Clients reading newline
+delimited json should skip empty lines. A zero interval, the default, sends
+no heartbeats.
+
+	jsonware.Handler(watchOrders).Heartbeat(15 * time.Second)
+*/
+func (j *JSONHandler) Heartbeat(interval time.Duration) *JSONHandler {
+	j.heartbeat = interval
+	return j
+}
+
+// Stream formats.
+const (
+	streamArray = iota
+	streamNDJSON
+	streamSSE
+)
+
+// acceptsStream checks if the accept header asks for one of the streaming
+// formats.
+func acceptsStream(accept string) bool {
+	return strings.Contains(accept, "application/x-ndjson") || strings.Contains(accept, "text/event-stream")
+}
+
+// streamWriter writes the items of a streamed response.
+type streamWriter struct {
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	logger io.Writer
+	codec  *Codec
+	format int
+
+	flushItems int
+
+	mut       sync.Mutex
+	n         int
+	unflushed int
+	broken    bool
+	failed    bool
+	// active is set by every write, heartbeats are only sent when nothing
+	// has been written since the last one.
+	active bool
+}
+
+// writeStream streams the items produced by producer to the client.
+func (j JSONHandler) writeStream(w http.ResponseWriter, r *http.Request, producer reflect.Value) {
+	s := &streamWriter{
+		w:          w,
+		rc:         http.NewResponseController(w),
+		logger:     j.logger,
+		codec:      j.jsonCodec(),
+		flushItems: j.flushItems,
+	}
+	if j.flushItems == 0 && j.flushInterval == 0 {
+		s.flushItems = 1
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "text/event-stream"):
+		s.format = streamSSE
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	case strings.Contains(accept, "application/x-ndjson"):
+		s.format = streamNDJSON
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	default:
+		s.format = streamArray
+	}
+	w.Header().Set("Trailer", "Jsonware-Status, Jsonware-Error")
+	w.WriteHeader(http.StatusOK)
+
+	if s.format == streamArray {
+		s.raw("[")
+	}
+
+	if j.flushInterval > 0 || j.heartbeat > 0 {
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var flushTick, heartbeatTick <-chan time.Time
+			if j.flushInterval > 0 {
+				ticker := time.NewTicker(j.flushInterval)
+				defer ticker.Stop()
+				flushTick = ticker.C
+			}
+			if j.heartbeat > 0 {
+				ticker := time.NewTicker(j.heartbeat)
+				defer ticker.Stop()
+				heartbeatTick = ticker.C
+			}
+			for {
+				select {
+				case <-done:
+					return
+				case <-flushTick:
+					s.mut.Lock()
+					if s.unflushed > 0 {
+						s.flush()
+					}
+					s.mut.Unlock()
+				case <-heartbeatTick:
+					s.mut.Lock()
+					if !s.active {
+						s.beat()
+					}
+					s.active = false
+					s.mut.Unlock()
+				}
+			}
+		}()
+		defer wg.Wait()
+		defer close(done)
+	}
+
+	if err := s.consume(r, j.stream, producer); err != nil {
+		s.fail(err)
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.finish()
+}
+
+// consume feeds everything the producer produces to the writer, returning
+// the error that the producer ended the stream with, if any.
+func (s *streamWriter) consume(r *http.Request, kind streamKind, producer reflect.Value) error {
+	if producer.IsNil() {
+		return nil
+	}
+
+	var streamErr error
+	switch kind {
+	case streamChan:
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: producer},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(r.Context().Done())},
+		}
+		for {
+			chosen, v, ok := reflect.Select(cases)
+			if chosen == 1 || !ok {
+				return nil
+			}
+			if err, isErr := v.Interface().(error); isErr && producer.Type().Elem().Kind() == reflect.Interface {
+				return err
+			}
+			if !s.item(v.Interface()) {
+				return nil
+			}
+		}
+	case streamSeq, streamSeq2:
+		yieldType := producer.Type().In(0)
+		yield := reflect.MakeFunc(yieldType, func(args []reflect.Value) []reflect.Value {
+			if kind == streamSeq2 && !args[1].IsNil() {
+				streamErr = args[1].Interface().(error)
+				return []reflect.Value{reflect.ValueOf(false)}
+			}
+			return []reflect.Value{reflect.ValueOf(s.item(args[0].Interface()))}
+		})
+		producer.Call([]reflect.Value{yield})
+	}
+
+	return streamErr
+}
+
+// item writes a single item, it returns false when the client can't be
+// written to anymore.
+func (s *streamWriter) item(v interface{}) bool {
+	b, err := s.codec.Marshal(v)
+	if err != nil {
+		logf(s.logger, "failed to serialize stream item: %v", err)
+		return true
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	switch s.format {
+	case streamArray:
+		if s.n > 0 {
+			s.raw(",")
+		}
+		s.raw(string(b))
+	case streamNDJSON:
+		s.raw(string(b) + "\n")
+	case streamSSE:
+		s.raw("data: " + string(b) + "\n\n")
+	}
+
+	s.n++
+	s.unflushed++
+	if s.flushItems > 0 && s.unflushed >= s.flushItems {
+		s.flush()
+	}
+
+	return !s.broken
+}
+
+// fail reports the error that ended the stream to the client, both in the
+// trailers and as a terminal error record for the formats that can carry
+// one. A json array has nowhere to put it without breaking the array.
+func (s *streamWriter) fail(err error) {
+	status := http.StatusInternalServerError
+	record := map[string]interface{}{"error": "an internal server error occurred"}
+
+	if e, ok := asErr(err); ok {
+		if e.Status != 0 {
+			status = e.Status
+		}
+		record["error"] = e.Err.Error()
+		if e.Reason != nil {
+			record["reason"] = e.Reason
+		}
+	} else {
+		status = cloakedStatus(err)
+		logf(s.logger, "stream error: %s", formatErr(err))
+	}
+	record["status"] = status
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.failed = true
+	s.w.Header().Set("Jsonware-Status", strconv.Itoa(status))
+	s.w.Header().Set("Jsonware-Error", record["error"].(string))
+
+	b, merr := json.Marshal(record)
+	if merr != nil {
+		logf(s.logger, "failed to serialize stream error: %v", merr)
+		return
+	}
+	switch s.format {
+	case streamNDJSON:
+		s.raw(string(b) + "\n")
+	case streamSSE:
+		s.raw("event: error\ndata: " + string(b) + "\n\n")
+	}
+}
+
+// finish ends the stream, marking it complete if nothing failed. It must be
+// called with the lock held.
+func (s *streamWriter) finish() {
+	switch s.format {
+	case streamArray:
+		s.raw("]\n")
+	case streamSSE:
+		if !s.failed {
+			s.raw("event: end\ndata: {}\n\n")
+		}
+	}
+	if !s.failed {
+		s.w.Header().Set("Jsonware-Status", strconv.Itoa(http.StatusOK))
+	}
+	s.flush()
+}
+
+// raw writes to the client, it must be called with the lock held.
+func (s *streamWriter) raw(str string) {
+	if s.broken {
+		return
+	}
+	if _, err := io.WriteString(s.w, str); err != nil {
+		s.broken = true
+	}
+	s.active = true
+}
+
+// beat writes a heartbeat frame that clients ignore and flushes it, it must
+// be called with the lock held.
+func (s *streamWriter) beat() {
+	switch s.format {
+	case streamArray:
+		// Whitespace is allowed between array elements.
+		s.raw(" ")
+	case streamNDJSON:
+		s.raw("\n")
+	case streamSSE:
+		s.raw(":\n\n")
+	}
+	s.flush()
+}
+
+// flush flushes to the client, it must be called with the lock held.
+func (s *streamWriter) flush() {
+	if s.broken {
+		return
+	}
+	// Writers that can't flush are fine, they're just not as timely.
+	_ = s.rc.Flush()
+	s.unflushed = 0
+}
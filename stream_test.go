@@ -0,0 +1,260 @@
+package jsonware
+
+import (
+	"bytes"
+	"errors"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func streamSeqHandler(r *http.Request) iter.Seq[*testType] {
+	return func(yield func(*testType) bool) {
+		for _, name := range []string{"a", "b", "c"} {
+			if !yield(&testType{name}) {
+				return
+			}
+		}
+	}
+}
+
+func streamSeq2Handler(r *http.Request) iter.Seq2[*testType, error] {
+	return func(yield func(*testType, error) bool) {
+		if !yield(&testType{"a"}, nil) {
+			return
+		}
+		if r.URL.Query().Get("fail") == "cloaked" {
+			yield(nil, errors.New("db went away"))
+			return
+		}
+		yield(nil, Err{Status: http.StatusConflict, Err: errors.New("snapshot expired")})
+	}
+}
+
+func streamChanHandler(r *http.Request) (<-chan interface{}, error) {
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+		ch <- &testType{"a"}
+		ch <- &testType{"b"}
+		if r.URL.Query().Get("fail") != "" {
+			ch <- errors.New("producer failed")
+		}
+	}()
+	return ch, nil
+}
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		handler interface{}
+		query   string
+		accept  string
+		ctype   string
+		resbody string
+		status  string
+		trailer string
+		log     string
+	}{
+		{streamSeqHandler, "", "application/json", "application/json", `[{"name":"a"},{"name":"b"},{"name":"c"}]` + "\n", "200", "", ""},
+		{streamSeqHandler, "", "application/x-ndjson", "application/x-ndjson", "{\"name\":\"a\"}\n{\"name\":\"b\"}\n{\"name\":\"c\"}\n", "200", "", ""},
+		{streamSeqHandler, "", "text/event-stream", "text/event-stream", "data: {\"name\":\"a\"}\n\ndata: {\"name\":\"b\"}\n\ndata: {\"name\":\"c\"}\n\nevent: end\ndata: {}\n\n", "200", "", ""},
+		{streamSeq2Handler, "", "application/json", "application/json", `[{"name":"a"}]` + "\n", "409", "snapshot expired", ""},
+		{streamSeq2Handler, "?fail=cloaked", "application/json", "application/json", `[{"name":"a"}]` + "\n", "500", "an internal server error occurred", "stream error: db went away"},
+		{streamSeq2Handler, "", "application/x-ndjson", "application/x-ndjson", "{\"name\":\"a\"}\n{\"error\":\"snapshot expired\",\"status\":409}\n", "409", "snapshot expired", ""},
+		{streamSeq2Handler, "", "text/event-stream", "text/event-stream", "data: {\"name\":\"a\"}\n\nevent: error\ndata: {\"error\":\"snapshot expired\",\"status\":409}\n\n", "409", "snapshot expired", ""},
+		{streamChanHandler, "", "*/*", "application/json", `[{"name":"a"},{"name":"b"}]` + "\n", "200", "", ""},
+		{streamChanHandler, "?fail=1", "application/x-ndjson", "application/x-ndjson", "{\"name\":\"a\"}\n{\"name\":\"b\"}\n{\"error\":\"an internal server error occurred\",\"status\":500}\n", "500", "an internal server error occurred", "producer failed"},
+	}
+
+	for i, test := range tests {
+		log := &bytes.Buffer{}
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/"+test.query, nil)
+		req.Header.Set("Accept", test.accept)
+
+		Handler(test.handler).Log(log).ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Errorf("Test %d: expected status 200, got: %d", i, res.Code)
+		}
+		if ct := res.Header().Get("Content-Type"); ct != test.ctype {
+			t.Errorf("Test %d: expected content type %s, got: %s", i, test.ctype, ct)
+		}
+		if b := res.Body.String(); b != test.resbody {
+			t.Errorf("Test %d: expected body:\n%q\ngot:\n%q", i, test.resbody, b)
+		}
+		if st := res.Result().Trailer.Get("Jsonware-Status"); st != test.status {
+			t.Errorf("Test %d: expected status trailer %q, got: %q", i, test.status, st)
+		}
+		if tr := res.Result().Trailer.Get("Jsonware-Error"); tr != test.trailer {
+			t.Errorf("Test %d: expected trailer %q, got: %q", i, test.trailer, tr)
+		}
+		if l := log.String(); !strings.Contains(l, test.log) {
+			t.Errorf("Test %d: expected log %q, got: %q", i, test.log, l)
+		}
+	}
+}
+
+// flushRecorder counts flushes and what had been written when they happened.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes []string
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushes = append(f.flushes, f.Body.String())
+}
+
+func TestStreamFlushEvery(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(func(r *http.Request) iter.Seq[int] {
+		return func(yield func(int) bool) {
+			for i := 1; i <= 5; i++ {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+	}).FlushEvery(2, 0)
+
+	res := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	h.ServeHTTP(res, req)
+
+	expect := []string{"1\n2\n", "1\n2\n3\n4\n", "1\n2\n3\n4\n5\n"}
+	if strings.Join(res.flushes, "|") != strings.Join(expect, "|") {
+		t.Errorf("Flushes were wrong: %q", res.flushes)
+	}
+}
+
+func TestStreamFlushInterval(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan int)
+	h := Handler(func(r *http.Request) <-chan int { return ch }).FlushEvery(0, time.Millisecond)
+
+	res := &signalFlusher{ResponseRecorder: httptest.NewRecorder(), flushed: make(chan struct{}, 1)}
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(res, req)
+		close(done)
+	}()
+
+	ch <- 1
+	// Nothing else is produced, the interval must flush the item.
+	select {
+	case <-res.flushed:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the item to be flushed by the interval")
+	}
+	close(ch)
+	<-done
+}
+
+// signalFlusher signals when it's flushed.
+type signalFlusher struct {
+	*httptest.ResponseRecorder
+	flushed chan struct{}
+}
+
+func (s *signalFlusher) Flush() {
+	select {
+	case s.flushed <- struct{}{}:
+	default:
+	}
+}
+
+func TestStreamHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		accept string
+		body   string
+	}{
+		{"text/event-stream", ":\n\n"},
+		{"application/x-ndjson", "\n"},
+		{"application/json", "[ "},
+	}
+
+	for i, test := range tests {
+		ch := make(chan int)
+		h := Handler(func(r *http.Request) <-chan int { return ch }).Heartbeat(time.Millisecond)
+
+		res := &signalFlusher{ResponseRecorder: httptest.NewRecorder(), flushed: make(chan struct{}, 1)}
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", test.accept)
+
+		done := make(chan struct{})
+		go func() {
+			h.ServeHTTP(res, req)
+			close(done)
+		}()
+
+		// Nothing is produced so the only thing that can flush is a heartbeat.
+		select {
+		case <-res.flushed:
+		case <-time.After(5 * time.Second):
+			t.Errorf("Test %d: expected a heartbeat", i)
+		}
+		close(ch)
+		<-done
+
+		if b := res.Body.String(); !strings.HasPrefix(b, test.body) {
+			t.Errorf("Test %d: expected body to start with %q, got: %q", i, test.body, b)
+		}
+	}
+}
+
+func TestStreamClientGone(t *testing.T) {
+	t.Parallel()
+
+	produced := 0
+	h := Handler(func(r *http.Request) iter.Seq[int] {
+		return func(yield func(int) bool) {
+			for i := 0; i < 100; i++ {
+				produced++
+				if !yield(i) {
+					return
+				}
+			}
+		}
+	})
+
+	res := &brokenWriter{ResponseRecorder: httptest.NewRecorder(), budget: 3}
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	h.ServeHTTP(res, req)
+
+	// The fourth item is the one that fails to write.
+	if produced != 4 {
+		t.Error("Expected the producer to stop once the client was gone, produced:", produced)
+	}
+}
+
+// brokenWriter fails writes once budget writes have been made.
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+	budget int
+}
+
+func (b *brokenWriter) Write(p []byte) (int, error) {
+	if b.budget == 0 {
+		return 0, errors.New("connection reset")
+	}
+	b.budget--
+	return b.ResponseRecorder.Write(p)
+}
+
+func (b *brokenWriter) WriteString(s string) (int, error) {
+	return b.Write([]byte(s))
+}
@@ -0,0 +1,302 @@
+package jsonware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tenant identifies which customer of a multi-tenant api a request is for.
+type Tenant struct {
+	ID string
+}
+
+type tenantKey struct{}
+
+// TenantFrom retrieves the Tenant that a Tenancy resolved for the request
+// from its context.
+func TenantFrom(ctx context.Context) (Tenant, bool) {
+	t, ok := ctx.Value(tenantKey{}).(Tenant)
+	return t, ok
+}
+
+// WithTenant returns a copy of ctx that carries the tenant, this is mostly
+// useful for testing handlers without a Tenancy in front of them.
+func WithTenant(ctx context.Context, t Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, t)
+}
+
+func init() {
+	// Handlers may take the Tenant as an argument directly.
+	RegisterExtractor(func(r *http.Request) (Tenant, error) {
+		t, ok := TenantFrom(r.Context())
+		if !ok {
+			return Tenant{}, Err{Status: http.StatusBadRequest, Err: errNoTenant}
+		}
+		return t, nil
+	})
+}
+
+var errNoTenant = errors.New("could not determine tenant for request")
+
+// TenantResolver finds the id of the tenant a request is for. An empty id
+// means the request is not for any tenant. The id comes from the client, so
+// resolvers for a Tenancy with a Limit must check it's a tenant that exists,
+// or a client gets a fresh limit for every id it makes up.
+type TenantResolver func(r *http.Request) (string, error)
+
+// TenantFromHeader resolves the tenant from the value of a request header.
+func TenantFromHeader(name string) TenantResolver {
+	return func(r *http.Request) (string, error) {
+		return strings.TrimSpace(r.Header.Get(name)), nil
+	}
+}
+
+// TenantFromSubdomain resolves the tenant from the subdomain of domain the
+// request was made to, eg. acme.example.com is for the tenant acme when
+// domain is example.com.
+func TenantFromSubdomain(domain string) TenantResolver {
+	suffix := "." + strings.ToLower(strings.Trim(domain, "."))
+	return func(r *http.Request) (string, error) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(host)
+
+		if !strings.HasSuffix(host, suffix) {
+			return "", nil
+		}
+		sub := strings.TrimSuffix(host, suffix)
+		if i := strings.LastIndexByte(sub, '.'); i >= 0 {
+			sub = sub[i+1:]
+		}
+		return sub, nil
+	}
+}
+
+// Rate is a request rate limit. Up to Burst requests are allowed at once and
+// then PerSecond requests every second after that. The zero value is
+// unlimited.
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+/*
+Tenancy resolves the tenant of each request that passes through the handlers
+it wraps and stores it in the request's context, where handlers can get it
+with TenantFrom or by declaring a Tenant argument. Cloaked errors logged by
+JSONHandlers are tagged with the tenant.
+
+If the resolver returns an error the client gets a 400 Bad Request with its
+message, unless it's an Err in which case that is used as is. Requests for
+no tenant are rejected with a 400 unless Optional is set.
+
+When Limit is set every tenant is rate limited to the Rate it returns for
+them, and requests over the limit get a 429 Too Many Requests. Resolve must
+only return the ids of tenants that exist then, TenantFromHeader and
+TenantFromSubdomain return whatever the client sends, so wrap them in a
+resolver that looks the tenant up.
+
+	tenancy := &jsonware.Tenancy{
+		Resolve: jsonware.TenantFromSubdomain("example.com"),
+		Limit: func(t jsonware.Tenant) jsonware.Rate {
+			return jsonware.Rate{PerSecond: 10, Burst: 20}
+		},
+	}
+	http.Handle("/users/", tenancy.Wrap(jsonware.Handler(getUser)))
+*/
+type Tenancy struct {
+	Resolve  TenantResolver
+	Optional bool
+	Limit    func(Tenant) Rate
+
+	// Log is where errors are reported, the global logger is used when it's
+	// nil.
+	Log io.Writer
+
+	buckets buckets
+	// now is swapped out in tests.
+	now func() time.Time
+}
+
+// Wrap returns a handler that resolves the tenant before serving requests
+// with h.
+func (tn *Tenancy) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := tn.Resolve(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			if _, ok := asErr(err); !ok {
+				err = Err{Status: http.StatusBadRequest, Err: err}
+			}
+			writeError(w, tn.Log, err)
+			return
+		}
+
+		if len(id) == 0 {
+			if !tn.Optional {
+				w.Header().Set("Content-Type", "application/json")
+				writeError(w, tn.Log, Err{Status: http.StatusBadRequest, Err: errNoTenant})
+				return
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		t := Tenant{ID: id}
+		if tn.Limit != nil {
+			if wait, ok := tn.take(t); !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeError(w, tn.Log, Err{
+					Status: http.StatusTooManyRequests,
+					Err:    errors.New("rate limit exceeded"),
+				})
+				return
+			}
+		}
+
+		h.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), t)))
+	})
+}
+
+// take uses up one request from the tenant's limit, if there's none left it
+// returns how long until there will be.
+func (tn *Tenancy) take(t Tenant) (time.Duration, bool) {
+	rate := tn.Limit(t)
+	if rate.PerSecond <= 0 && rate.Burst <= 0 {
+		return 0, true
+	}
+
+	now := time.Now()
+	if tn.now != nil {
+		now = tn.now()
+	}
+
+	return tn.buckets.take(t.ID, rate, now)
+}
+
+// sweepBuckets is how many buckets a limiter keeps before it sweeps out the
+// ones that have filled back up.
+const sweepBuckets = 1024
+
+// buckets are the token buckets of the clients of a rate limiter. Clients can
+// be anyone, so to keep them from growing it without bound by changing who
+// they look like, the buckets that have filled back up are swept out once
+// there are enough of them, they're the same as new ones anyway.
+type buckets struct {
+	mut     sync.Mutex
+	buckets map[string]*bucket
+	// sweepAt is how many buckets there are when the next sweep happens.
+	sweepAt int
+}
+
+// take uses up one request from key's bucket, if there's none left it
+// returns how long until there will be.
+func (bs *buckets) take(key string, rate Rate, now time.Time) (time.Duration, bool) {
+	bs.mut.Lock()
+	defer bs.mut.Unlock()
+
+	if bs.buckets == nil {
+		bs.buckets = make(map[string]*bucket)
+		bs.sweepAt = sweepBuckets
+	}
+	b, ok := bs.buckets[key]
+	if !ok {
+		if len(bs.buckets) >= bs.sweepAt {
+			bs.sweep(now)
+		}
+		b = &bucket{tokens: math.Max(float64(rate.Burst), 1), last: now}
+		bs.buckets[key] = b
+	}
+
+	return b.take(rate, now)
+}
+
+// sweep forgets the buckets that are full at now.
+func (bs *buckets) sweep(now time.Time) {
+	for key, b := range bs.buckets {
+		if !b.full.IsZero() && !now.Before(b.full) {
+			delete(bs.buckets, key)
+		}
+	}
+	// Waiting for the buckets that are left to double before sweeping again
+	// keeps clients that are all busy from being swept on every take.
+	bs.sweepAt = sweepBuckets
+	if 2*len(bs.buckets) > bs.sweepAt {
+		bs.sweepAt = 2 * len(bs.buckets)
+	}
+}
+
+// bucket is a token bucket for rate limiting.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket will have filled back up, it's zero when it
+	// never will.
+	full time.Time
+}
+
+func (b *bucket) take(rate Rate, now time.Time) (time.Duration, bool) {
+	max := math.Max(float64(rate.Burst), 1)
+	b.tokens = math.Min(max, b.tokens+now.Sub(b.last).Seconds()*rate.PerSecond)
+	b.last = now
+
+	taken := b.tokens >= 1
+	if taken {
+		b.tokens--
+	}
+	b.full = time.Time{}
+	if rate.PerSecond > 0 {
+		b.full = now.Add(time.Duration((max - b.tokens) / rate.PerSecond * float64(time.Second)))
+	}
+
+	switch {
+	case taken:
+		return 0, true
+	case rate.PerSecond <= 0:
+		return time.Second, false
+	}
+	return time.Duration((1 - b.tokens) / rate.PerSecond * float64(time.Second)), false
+}
+
+// tenantLogger tags everything logged through it with the tenant.
+type tenantLogger struct {
+	tenant string
+	logger io.Writer
+}
+
+// tagLogger returns a logger that tags logs for the request with its tenant,
+// if it has one.
+func tagLogger(r *http.Request, logger io.Writer) io.Writer {
+	t, ok := TenantFrom(r.Context())
+	if !ok {
+		return logger
+	}
+	return tenantLogger{tenant: t.ID, logger: logger}
+}
+
+func (t tenantLogger) Write(b []byte) (int, error) {
+	logger := t.logger
+	if logger == nil {
+		logger = globalLogger
+	}
+	if logger == nil {
+		return len(b), nil
+	}
+
+	if _, err := fmt.Fprintf(logger, "tenant=%s %s", t.tenant, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,167 @@
+package jsonware
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTenantResolvers(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		resolver TenantResolver
+		host     string
+		header   string
+		tenant   string
+	}{
+		{TenantFromHeader("X-Tenant"), "example.com", "acme", "acme"},
+		{TenantFromHeader("X-Tenant"), "example.com", "", ""},
+		{TenantFromSubdomain("example.com"), "acme.example.com", "", "acme"},
+		{TenantFromSubdomain("example.com"), "api.acme.Example.com:8080", "", "acme"},
+		{TenantFromSubdomain("example.com"), "example.com", "", ""},
+		{TenantFromSubdomain("example.com"), "acme.other.com", "", ""},
+	}
+
+	for i, test := range tests {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Host = test.host
+		req.Header.Set("X-Tenant", test.header)
+
+		id, err := test.resolver(req)
+		if err != nil {
+			t.Errorf("Test %d: unexpected error: %v", i, err)
+		}
+		if id != test.tenant {
+			t.Errorf("Test %d: expected tenant %q, got: %q", i, test.tenant, id)
+		}
+	}
+}
+
+func tenantHandler(w http.ResponseWriter, r *http.Request, tenant Tenant) (*testType, error) {
+	if tenant.ID == "broken" {
+		return nil, errors.New("kaboom")
+	}
+	return &testType{Name: tenant.ID}, nil
+}
+
+func TestTenancy(t *testing.T) {
+	t.Parallel()
+
+	log := &bytes.Buffer{}
+	tenancy := &Tenancy{
+		Resolve: func(r *http.Request) (string, error) {
+			if r.Header.Get("X-Tenant") == "evil" {
+				return "", Err{Status: http.StatusForbidden, Err: errors.New("go away")}
+			}
+			return r.Header.Get("X-Tenant"), nil
+		},
+	}
+	h := tenancy.Wrap(Handler(tenantHandler).Log(log))
+
+	var tests = []struct {
+		tenant  string
+		status  int
+		resbody string
+	}{
+		{"acme", 200, `{"name":"acme"}`},
+		{"", 400, `{"error":"could not determine tenant for request"}`},
+		{"evil", 403, `{"error":"go away"}`},
+		{"broken", 500, `{"error":"an internal server error occurred"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header = http.Header{"Accept": []string{"*/*"}, "X-Tenant": []string{test.tenant}}
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected status: %d, got: %d", test.status, res.Code)
+		}
+
+		if b := strings.TrimSpace(res.Body.String()); b != test.resbody {
+			t.Errorf("Test: %d", i)
+			t.Errorf("Expected body: %s, got: %s", test.resbody, b)
+		}
+	}
+
+	if l := log.String(); l != "tenant=broken internal error: kaboom" {
+		t.Error("Log was wrong:", l)
+	}
+}
+
+func TestTenancyLimit(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(0, 0)
+	tenancy := &Tenancy{
+		Resolve: TenantFromHeader("X-Tenant"),
+		Limit: func(t Tenant) Rate {
+			if t.ID == "vip" {
+				return Rate{}
+			}
+			return Rate{PerSecond: 1, Burst: 2}
+		},
+		now: func() time.Time { return now },
+	}
+	h := tenancy.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var tests = []struct {
+		tenant  string
+		advance time.Duration
+		status  int
+	}{
+		{"acme", 0, 200},
+		{"acme", 0, 200},
+		{"acme", 0, 429},
+		{"other", 0, 200},
+		{"vip", 0, 200},
+		{"vip", 0, 200},
+		{"vip", 0, 200},
+		{"acme", 500 * time.Millisecond, 429},
+		{"acme", 500 * time.Millisecond, 200},
+		{"acme", 0, 429},
+	}
+
+	for i, test := range tests {
+		now = now.Add(test.advance)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Tenant", test.tenant)
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status: %d, got: %d", i, test.status, res.Code)
+		}
+		if res.Code == 429 && res.Header().Get("Retry-After") != "1" {
+			t.Errorf("Test %d: expected Retry-After: 1, got: %q", i, res.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestTenancySweeps(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(0, 0)
+	tenancy := &Tenancy{
+		Limit: func(t Tenant) Rate { return Rate{PerSecond: 1, Burst: 1} },
+		now:   func() time.Time { return now },
+	}
+
+	for i := 0; i < sweepBuckets; i++ {
+		tenancy.take(Tenant{ID: strconv.Itoa(i)})
+	}
+	now = now.Add(time.Second)
+	tenancy.take(Tenant{ID: "new"})
+	if n := len(tenancy.buckets.buckets); n != 1 {
+		t.Errorf("expected the full buckets to be swept out, got: %d", n)
+	}
+}
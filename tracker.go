@@ -0,0 +1,118 @@
+package jsonware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+var errShuttingDown = errors.New("server is shutting down")
+
+/*
+Tracker counts the requests being served by the handlers it wraps so that a
+server can let them finish before it shuts down. Once it's draining, new
+requests get a 503 Service Unavailable with Connection: close, pointing
+clients at another instance, while the ones in flight carry on.
+
+	tracker := &jsonware.Tracker{}
+	srv := &http.Server{Handler: tracker.Wrap(mux)}
+	...
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := tracker.Wait(ctx); err != nil {
+		log.Printf("%d requests didn't finish", tracker.InFlight())
+	}
+	srv.Shutdown(ctx)
+*/
+type Tracker struct {
+	// Log is where errors are reported, the global logger is used when it's
+	// nil.
+	Log io.Writer
+
+	mut      sync.Mutex
+	inFlight int
+	draining bool
+	// idle is closed when the last request in flight finishes while
+	// draining.
+	idle chan struct{}
+}
+
+// Wrap returns a handler that serves requests with h while counting them.
+func (t *Tracker) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !t.enter() {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Connection", "close")
+			writeError(w, t.Log, Err{Status: http.StatusServiceUnavailable, Err: errShuttingDown})
+			return
+		}
+		defer t.leave()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Drain makes the Tracker reject new requests.
+func (t *Tracker) Drain() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.draining = true
+}
+
+// Draining checks if the Tracker is rejecting new requests.
+func (t *Tracker) Draining() bool {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.draining
+}
+
+// InFlight is how many requests are being served.
+func (t *Tracker) InFlight() int {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.inFlight
+}
+
+// Wait drains the Tracker and waits for the requests in flight to finish,
+// returning ctx's error if it's done first.
+func (t *Tracker) Wait(ctx context.Context) error {
+	t.mut.Lock()
+	t.draining = true
+	if t.inFlight == 0 {
+		t.mut.Unlock()
+		return nil
+	}
+	if t.idle == nil {
+		t.idle = make(chan struct{})
+	}
+	idle := t.idle
+	t.mut.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *Tracker) enter() bool {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.draining {
+		return false
+	}
+	t.inFlight++
+	return true
+}
+
+func (t *Tracker) leave() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.inFlight--
+	if t.inFlight == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+}
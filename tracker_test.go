@@ -0,0 +1,68 @@
+package jsonware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	t.Parallel()
+
+	tracker := &Tracker{}
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := tracker.Wrap(Handler(func(r *http.Request) (*testType, error) {
+		started <- struct{}{}
+		<-release
+		return &testType{Name: "done"}, nil
+	}))
+
+	serve := func() *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+		return res
+	}
+
+	results := make(chan *httptest.ResponseRecorder, 2)
+	for i := 0; i < 2; i++ {
+		go func() { results <- serve() }()
+		<-started
+	}
+	if n := tracker.InFlight(); n != 2 {
+		t.Errorf("expected 2 requests in flight, got: %d", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tracker.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected the wait to time out, got: %v", err)
+	}
+	if !tracker.Draining() {
+		t.Error("expected the tracker to be draining")
+	}
+
+	res := serve()
+	if res.Code != http.StatusServiceUnavailable || res.Header().Get("Connection") != "close" {
+		t.Errorf("expected new requests to be rejected, got: %d %v", res.Code, res.Header())
+	}
+
+	waited := make(chan error)
+	go func() { waited <- tracker.Wait(context.Background()) }()
+	close(release)
+	for i := 0; i < 2; i++ {
+		if res := <-results; res.Code != http.StatusOK {
+			t.Errorf("Test %d: expected requests in flight to finish, got: %d", i, res.Code)
+		}
+	}
+	if err := <-waited; err != nil {
+		t.Errorf("expected the wait to finish, got: %v", err)
+	}
+	if n := tracker.InFlight(); n != 0 {
+		t.Errorf("expected no requests in flight, got: %d", n)
+	}
+}
@@ -0,0 +1,229 @@
+package jsonware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+)
+
+var filesType = reflect.TypeOf([]File(nil))
+
+var (
+	errNotMultipart    = errors.New("request must be multipart/form-data")
+	errNoMetadata      = errors.New("multipart request is missing its metadata part")
+	errTooManyFiles    = errors.New("too many files")
+	errBadMultipart    = errors.New("could not read multipart request body")
+	errUploadNotStored = errors.New("failed to store uploaded file")
+)
+
+/*
+File is a file uploaded in a multipart/form-data request. Handlers receive
+the uploaded files by declaring a []jsonware.File argument, and the request
+object, if they take one, is deserialized from the json in the part named
+metadata:
+
+	type UploadMeta struct {
+		Album string `json:"album" required:"true"`
+	}
+
+	func upload(r *http.Request, meta *UploadMeta, files []jsonware.File) (*Album, error) {
+		for _, f := range files {
+			rc, err := f.Open()
+			...
+		}
+	}
+
+Files are streamed to temporary files as they're read from the request, so
+they don't have to fit in memory, and are removed once the handler returns.
+Handlers must copy what they want to keep. The number, size and types of
+files are limited by the handler's UploadLimits.
+*/
+type File struct {
+	// Field is the name of the form field the file was uploaded in.
+	Field string
+	// Filename is the name of the file on the client, without any
+	// directories.
+	Filename    string
+	ContentType string
+	Size        int64
+
+	path string
+}
+
+// Open opens the uploaded file for reading.
+func (f File) Open() (io.ReadCloser, error) {
+	return os.Open(f.path)
+}
+
+// UploadLimits restricts the files a handler accepts in a multipart request.
+type UploadLimits struct {
+	// MaxFiles is how many files a request may upload.
+	MaxFiles int
+	// MaxFileSize is how many bytes each file may have.
+	MaxFileSize int64
+	// Types are the content types files may have, like image/png, or
+	// image/* for all images. Any type is allowed when it's empty.
+	Types []string
+	// Dir is where files are stored while the handler runs, the os default
+	// temporary directory is used when it's empty.
+	Dir string
+}
+
+// DefaultUploadLimits are the UploadLimits of handlers that weren't given
+// any with their Uploads option.
+var DefaultUploadLimits = UploadLimits{MaxFiles: 10, MaxFileSize: 32 << 20}
+
+/*
+Uploads sets the limits on the files the JSONHandler accepts. Requests with
+more files than allowed get a 400, files that are too big a 413, and files
+of a type that isn't allowed a 415.
+*/
+func (j *JSONHandler) Uploads(limits UploadLimits) *JSONHandler {
+	j.uploadLimits = &limits
+	return j
+}
+
+// allowsType checks if files of contentType may be uploaded.
+func (l UploadLimits) allowsType(contentType string) bool {
+	if len(l.Types) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range l.Types {
+		if ok, _ := path.Match(strings.ToLower(t), mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+readMultipart reads a multipart request, deserializing the metadata part
+into obj if it's valid and storing the file parts. The files must be removed
+with removeFiles even when there's an error.
+*/
+func (j JSONHandler) readMultipart(r *http.Request, obj reflect.Value) ([]File, error) {
+	limits := DefaultUploadLimits
+	if j.uploadLimits != nil {
+		limits = *j.uploadLimits
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, Err{Status: http.StatusUnsupportedMediaType, Err: errNotMultipart}
+	}
+
+	var files []File
+	decoded := false
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if isBodyTooLarge(err) {
+			return files, Err{Status: http.StatusRequestEntityTooLarge, Err: err}
+		} else if err != nil {
+			return files, Err{Status: http.StatusBadRequest, Err: errBadMultipart}
+		}
+
+		switch {
+		case len(part.FileName()) != 0:
+			f, err := storeFile(part, limits, len(files))
+			if len(f.path) != 0 {
+				files = append(files, f)
+			}
+			if err != nil {
+				return files, err
+			}
+		case part.FormName() == "metadata" && obj.IsValid():
+			if err = j.jsonCodec().decode(json.NewDecoder(part), obj.Interface()); err != nil {
+				return files, Err{
+					Status: http.StatusBadRequest,
+					Err:    fmt.Errorf("could not deserialize json request body"),
+				}
+			}
+			decoded = true
+		}
+		part.Close()
+	}
+
+	if obj.IsValid() && !decoded {
+		return files, Err{Status: http.StatusBadRequest, Err: errNoMetadata}
+	}
+	return files, nil
+}
+
+// storeFile copies a file part to a temporary file, n is how many files the
+// request has already uploaded.
+func storeFile(part *multipart.Part, limits UploadLimits, n int) (File, error) {
+	f := File{
+		Field:       part.FormName(),
+		Filename:    path.Base(strings.ReplaceAll(part.FileName(), `\`, "/")),
+		ContentType: part.Header.Get("Content-Type"),
+	}
+
+	if limits.MaxFiles > 0 && n >= limits.MaxFiles {
+		return f, Err{Status: http.StatusBadRequest, Err: errTooManyFiles}
+	}
+
+	// Files without a type of their own are sniffed.
+	var head bytes.Buffer
+	if len(f.ContentType) == 0 || f.ContentType == "application/octet-stream" {
+		if _, err := io.CopyN(&head, part, 512); err != nil && err != io.EOF {
+			return f, Err{Status: http.StatusBadRequest, Err: errBadMultipart}
+		}
+		f.ContentType = http.DetectContentType(head.Bytes())
+	}
+	if !limits.allowsType(f.ContentType) {
+		return f, Err{
+			Status: http.StatusUnsupportedMediaType,
+			Err:    fmt.Errorf("file %s has a type that is not allowed: %s", f.Filename, f.ContentType),
+		}
+	}
+
+	tmp, err := os.CreateTemp(limits.Dir, "jsonware-upload-*")
+	if err != nil {
+		return f, fmt.Errorf("%w: %v", errUploadNotStored, err)
+	}
+	f.path = tmp.Name()
+	defer tmp.Close()
+
+	var src io.Reader = io.MultiReader(&head, part)
+	if limits.MaxFileSize > 0 {
+		src = io.LimitReader(src, limits.MaxFileSize+1)
+	}
+	f.Size, err = io.Copy(tmp, src)
+	if isBodyTooLarge(err) {
+		return f, Err{Status: http.StatusRequestEntityTooLarge, Err: err}
+	} else if err != nil {
+		return f, Err{Status: http.StatusBadRequest, Err: errBadMultipart}
+	}
+	if limits.MaxFileSize > 0 && f.Size > limits.MaxFileSize {
+		return f, Err{
+			Status: http.StatusRequestEntityTooLarge,
+			Err:    fmt.Errorf("file %s is larger than %d bytes", f.Filename, limits.MaxFileSize),
+		}
+	}
+
+	return f, tmp.Close()
+}
+
+// removeFiles removes the temporary files of uploads.
+func removeFiles(files []File, logger io.Writer) {
+	for _, f := range files {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			logf(logger, "failed to remove uploaded file: %v", err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package jsonware
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type uploadMeta struct {
+	Album string `json:"album" required:"true"`
+}
+
+type uploadPart struct {
+	field, filename, contentType, body string
+}
+
+func multipartBody(t *testing.T, parts []uploadPart) (*bytes.Buffer, string) {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	for _, p := range parts {
+		h := textproto.MIMEHeader{}
+		disposition := `form-data; name="` + p.field + `"`
+		if len(p.filename) != 0 {
+			disposition += `; filename="` + p.filename + `"`
+		}
+		h.Set("Content-Disposition", disposition)
+		if len(p.contentType) != 0 {
+			h.Set("Content-Type", p.contentType)
+		}
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, p.body)
+	}
+	mw.Close()
+	return buf, mw.FormDataContentType()
+}
+
+func TestUpload(t *testing.T) {
+	t.Parallel()
+
+	meta := uploadPart{"metadata", "", "application/json", `{"album":"trip"}`}
+	png := uploadPart{"photo", `C:\pics\a.png`, "image/png", "png bytes"}
+	sniffed := uploadPart{"photo", "b", "", "<html><body>hi</body></html>"}
+
+	var tests = []struct {
+		parts  []uploadPart
+		limits *UploadLimits
+		status int
+		want   string
+	}{
+		{[]uploadPart{meta, png}, nil, 200, `trip:photo/a.png/image/png/9/png bytes`},
+		{[]uploadPart{png, meta, {"note", "", "", "ignored"}}, nil, 200, `trip:photo/a.png/image/png/9/png bytes`},
+		{[]uploadPart{meta}, nil, 200, `trip:`},
+		{[]uploadPart{png}, nil, 400, `missing its metadata part`},
+		{[]uploadPart{{"metadata", "", "", `{}`}}, nil, 422, `missing required fields`},
+		{[]uploadPart{{"metadata", "", "", `{`}}, nil, 400, `could not deserialize`},
+		{[]uploadPart{meta, png, png}, &UploadLimits{MaxFiles: 1}, 400, `too many files`},
+		{[]uploadPart{meta, png}, &UploadLimits{MaxFileSize: 4}, 413, `file a.png is larger than 4 bytes`},
+		{[]uploadPart{meta, png}, &UploadLimits{Types: []string{"image/*"}}, 200, `trip:photo/a.png`},
+		{[]uploadPart{meta, sniffed}, &UploadLimits{Types: []string{"image/*"}}, 415, `type that is not allowed: text/html; charset=utf-8`},
+	}
+
+	for i, test := range tests {
+		var stored []File
+		h := Handler(func(r *http.Request, meta *uploadMeta, files []File) (*testType, error) {
+			stored = files
+			out := meta.Album + ":"
+			for _, f := range files {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				b, _ := io.ReadAll(rc)
+				rc.Close()
+				out += strings.Join([]string{f.Field, f.Filename, f.ContentType, strconv.FormatInt(f.Size, 10), string(b)}, "/")
+			}
+			return &testType{Name: out}, nil
+		})
+		if test.limits != nil {
+			h.Uploads(*test.limits)
+		}
+
+		body, contentType := multipartBody(t, test.parts)
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", body)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Content-Type", contentType)
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d %s", i, test.status, res.Code, res.Body.String())
+		}
+		if b := res.Body.String(); !strings.Contains(b, test.want) {
+			t.Errorf("Test %d: expected body to contain %s, got: %s", i, test.want, b)
+		}
+		for _, f := range stored {
+			if _, err := os.Stat(f.path); !os.IsNotExist(err) {
+				t.Errorf("Test %d: expected %s to be removed", i, f.path)
+			}
+		}
+	}
+}
+
+func TestUploadRequest(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(func(r *http.Request, files []File) (*testType, error) {
+		return &testType{Name: strconv.Itoa(len(files))}, nil
+	})
+
+	var tests = []struct {
+		method      string
+		contentType string
+		status      int
+	}{
+		{"POST", "", 200},
+		{"POST", "application/json", 415},
+		{"GET", "", 400},
+	}
+
+	for i, test := range tests {
+		body, contentType := multipartBody(t, []uploadPart{{"f", "f.txt", "text/plain", "x"}})
+		if len(test.contentType) != 0 {
+			contentType = test.contentType
+		}
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/", body)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Content-Type", contentType)
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d %s", i, test.status, res.Code, res.Body.String())
+		}
+	}
+}
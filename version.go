@@ -0,0 +1,86 @@
+package jsonware
+
+import (
+	"net/http"
+	"strings"
+)
+
+/*
+Version gives the JSONHandler a cheap way to find out which version of its
+response a GET or HEAD request would get, like a row's updated_at or
+revision, without producing the response. fn runs before the handler and its
+result is sent as the ETag. When it matches the request's If-None-Match the
+client already has the response, it gets a 304 Not Modified and the handler
+isn't called at all, so unchanged resources cost only what fn does.
+
+	h := jsonware.Handler(getReport).Version(func(r *http.Request) (string, error) {
+		rev, err := reports.Revision(r.Context(), r.PathValue("id"))
+		return strconv.FormatInt(rev, 10), err
+	})
+
+The etag is quoted if it isn't already, an empty one disables the check for
+the request. Errors from fn are written like the handler's errors.
+*/
+func (j *JSONHandler) Version(fn func(r *http.Request) (etag string, err error)) *JSONHandler {
+	j.version = fn
+	return j
+}
+
+/*
+CacheControl sets the Cache-Control header of the JSONHandler's successful
+responses, and of the 304s sent by Version, to value. Error responses are
+left alone so that they aren't cached.
+
+	jsonware.Handler(getCountries).CacheControl("public, max-age=86400")
+*/
+func (j *JSONHandler) CacheControl(value string) *JSONHandler {
+	j.cacheControl = value
+	return j
+}
+
+// checkVersion sets the ETag of a request's response, returning true when
+// the client's copy is current and a 304 was written.
+func (j JSONHandler) checkVersion(w http.ResponseWriter, r *http.Request) (bool, error) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false, nil
+	}
+	etag, err := j.version(r)
+	if err != nil || len(etag) == 0 {
+		return false, err
+	}
+	if !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+		etag = `"` + etag + `"`
+	}
+
+	w.Header().Set("ETag", etag)
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false, nil
+	}
+	j.notModified(w)
+	return true, nil
+}
+
+// notModified writes a 304 Not Modified.
+func (j JSONHandler) notModified(w http.ResponseWriter) {
+	w.Header().Del("Content-Type")
+	if len(j.cacheControl) != 0 {
+		w.Header().Set("Cache-Control", j.cacheControl)
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// etagMatches checks if an If-None-Match header matches etag, comparing
+// weakly as If-None-Match does.
+func etagMatches(header, etag string) bool {
+	if len(header) == 0 {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package jsonware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		method      string
+		version     string
+		versionErr  error
+		ifNoneMatch string
+		status      int
+		etag        string
+		called      bool
+	}{
+		{"GET", "v1", nil, "", 200, `"v1"`, true},
+		{"GET", "v1", nil, `"v1"`, 304, `"v1"`, false},
+		{"HEAD", "v1", nil, `"v1"`, 304, `"v1"`, false},
+		{"GET", `W/"v1"`, nil, `"v0", "v1"`, 304, `W/"v1"`, false},
+		{"GET", `"v1"`, nil, `W/"v1"`, 304, `"v1"`, false},
+		{"GET", "v1", nil, `*`, 304, `"v1"`, false},
+		{"GET", "v2", nil, `"v1"`, 200, `"v2"`, true},
+		{"GET", "", nil, `"v1"`, 200, ``, true},
+		{"GET", "", Err{Status: 404, Err: errors.New("no such report")}, "", 404, ``, false},
+		{"DELETE", "v1", nil, `"v1"`, 200, ``, true},
+	}
+
+	for i, test := range tests {
+		called := false
+		test := test
+		h := Handler(func(r *http.Request) (*testType, error) {
+			called = true
+			return &testType{Name: "report"}, nil
+		}).Version(func(r *http.Request) (string, error) {
+			return test.version, test.versionErr
+		})
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(test.method, "/reports/1", nil)
+		req.Header.Set("Accept", "application/json")
+		if len(test.ifNoneMatch) != 0 {
+			req.Header.Set("If-None-Match", test.ifNoneMatch)
+		}
+		h.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if etag := res.Header().Get("ETag"); etag != test.etag {
+			t.Errorf("Test %d: expected etag %q, got: %q", i, test.etag, etag)
+		}
+		if called != test.called {
+			t.Errorf("Test %d: expected the handler to be called %t", i, test.called)
+		}
+		if test.status == 304 && len(strings.TrimSpace(res.Body.String())) != 0 {
+			t.Errorf("Test %d: expected no body, got: %s", i, res.Body.String())
+		}
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		err  error
+		want string
+	}{
+		{nil, "public, max-age=60"},
+		{NotFound(""), ""},
+	}
+
+	for i, test := range tests {
+		err := test.err
+		h := Handler(func(r *http.Request) (*testType, error) {
+			if err != nil {
+				return nil, err
+			}
+			return &testType{Name: "x"}, nil
+		}).CacheControl("public, max-age=60")
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if c := res.Header().Get("Cache-Control"); c != test.want {
+			t.Errorf("Test %d: expected Cache-Control %q, got: %q", i, test.want, c)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package jsonware
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+/*
+View makes the JSONHandler send the view of its responses named view. Fields
+tagged with view are only sent in the views their tag lists and are zeroed in
+the others, so they should also be tagged omitempty to be left out entirely.
+Fields without the tag are in every view. It lets one type be sent as a
+summary in a list and in detail on its own, without a struct for each.
+
+	type Order struct {
+		ID    string `json:"id"`
+		Total int    `json:"total"`
+		Items []Item `json:"items,omitempty" view:"detail"`
+		Notes string `json:"notes,omitempty" view:"detail,audit"`
+	}
+
+	http.Handle("/orders", jsonware.Handler(listOrders).View("summary"))
+	http.Handle("/orders/{id}", jsonware.Handler(getOrder).View("detail"))
+
+Fields in nested structs, pointers, slices, maps and interfaces are pruned
+too. Streamed responses don't have views.
+*/
+func (j *JSONHandler) View(view string) *JSONHandler {
+	j.view = view
+	return j
+}
+
+/*
+ViewParam lets clients choose the view of the JSONHandler's responses with
+the query parameter param, like ?view=detail, in place of the one set by
+View. Views that none of the fields of the response type are tagged with get
+a 400 Bad Request, when the handler's response type is known before it's
+called.
+
+	jsonware.Handler(getOrder).View("summary").ViewParam("view")
+*/
+func (j *JSONHandler) ViewParam(param string) *JSONHandler {
+	j.viewParam = param
+	return j
+}
+
+// viewOf finds the view a response to r is sent in, none when it's empty.
+func (j JSONHandler) viewOf(r *http.Request) string {
+	if len(j.viewParam) != 0 {
+		if view := r.URL.Query().Get(j.viewParam); len(view) != 0 {
+			return view
+		}
+	}
+	return j.view
+}
+
+// checkView checks the view a client asked for is one of the views of the
+// handler's response type.
+func (j JSONHandler) checkView(r *http.Request) error {
+	view := r.URL.Query().Get(j.viewParam)
+	if len(view) == 0 || j.out == nil || j.out.Kind() == reflect.Interface || j.stream != streamNone {
+		return nil
+	}
+	if !tagValues(j.out, "view")[view] {
+		return Err{Status: http.StatusBadRequest, Err: fmt.Errorf("unknown view: %s", view)}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type viewOrder struct {
+	ID    string      `json:"id"`
+	Items []viewItem  `json:"items,omitempty" view:"detail"`
+	Notes string      `json:"notes,omitempty" view:"detail, audit"`
+	Next  *viewOrder  `json:"next,omitempty"`
+	Tags  map[int]int `json:"tags,omitempty"`
+}
+
+type viewItem struct {
+	SKU   string `json:"sku"`
+	Price int    `json:"price,omitempty" view:"audit"`
+}
+
+func TestView(t *testing.T) {
+	t.Parallel()
+
+	order := &viewOrder{
+		ID:    "o1",
+		Items: []viewItem{{SKU: "a", Price: 3}},
+		Notes: "fragile",
+		Next:  &viewOrder{ID: "o2", Notes: "late"},
+	}
+	fn := func(r *http.Request) (*viewOrder, error) { return order, nil }
+
+	var tests = []struct {
+		handler *JSONHandler
+		query   string
+		status  int
+		want    string
+	}{
+		{Handler(fn), "", 200, `{"id":"o1","items":[{"sku":"a","price":3}],"notes":"fragile","next":{"id":"o2","notes":"late"}}`},
+		{Handler(fn).View("summary"), "", 200, `{"id":"o1","next":{"id":"o2"}}`},
+		{Handler(fn).View("detail"), "", 200, `{"id":"o1","items":[{"sku":"a"}],"notes":"fragile","next":{"id":"o2","notes":"late"}}`},
+		{Handler(fn).View("summary"), "?view=audit", 200, `{"id":"o1","next":{"id":"o2"}}`},
+		{Handler(fn).View("summary").ViewParam("view"), "?view=audit", 200, `{"id":"o1","notes":"fragile","next":{"id":"o2","notes":"late"}}`},
+		{Handler(fn).View("summary").ViewParam("view"), "", 200, `{"id":"o1","next":{"id":"o2"}}`},
+		{Handler(fn).ViewParam("view"), "?view=full", 400, `{"error":"unknown view: full"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/orders"+test.query, nil)
+		req.Header.Set("Accept", "application/json")
+		test.handler.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+
+	if len(order.Notes) == 0 || order.Items[0].Price != 3 {
+		t.Error("expected the handler's value to be left alone")
+	}
+}
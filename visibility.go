@@ -0,0 +1,273 @@
+package jsonware
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+/*
+FilterResponse adds a hook that's given the request's Subject, the zero
+Subject for anonymous requests, and the value the handler returned, and
+returns the value to send in its place. It's for pruning what a subject may
+not see, like the orders of other users in a list, in one place rather than
+in every handler. An error rejects the response and is written like the
+handler's own, so a hook returns an Err with a 403 or 404 to refuse it.
+Hooks run in the order they were added, each given what the one before
+returned, and before the BeforeEncode hooks.
+
+	h.FilterResponse(func(r *http.Request, s jsonware.Subject, v interface{}) (interface{}, error) {
+		if o := v.(*Order); o.Owner != s.ID {
+			return nil, jsonware.Err{Status: http.StatusNotFound, Err: errors.New("no such order")}
+		}
+		return v, nil
+	})
+
+Fields tagged with visible are pruned from responses on their own, without a
+hook. They're only sent to subjects that have one of the roles the tag lists,
+and are zeroed for everyone else, so they should also be tagged omitempty to
+be left out entirely. Fields of nested structs, pointers, slices, maps and
+interfaces are pruned too, as are the values wrapped by Created, WithCookies,
+Response and Page. The handler's value is copied rather than changed.
+
+	type User struct {
+		Name  string `json:"name"`
+		Email string `json:"email,omitempty" visible:"admin,support"`
+	}
+
+Streamed responses aren't filtered.
+*/
+func (j *JSONHandler) FilterResponse(fn func(r *http.Request, s Subject, v interface{}) (interface{}, error)) *JSONHandler {
+	j.filters = append(j.filters, fn)
+	return j
+}
+
+// filterResponse runs the FilterResponse hooks on v and prunes the fields the
+// request's subject may not see, the ones that aren't in its view and the
+// ones it didn't pick.
+func (j JSONHandler) filterResponse(r *http.Request, v interface{}) (interface{}, error) {
+	view := j.viewOf(r)
+	var sel selection
+	if j.selectable {
+		sel, _ = selectionOf(r)
+	}
+	rv := reflect.ValueOf(v)
+	if len(j.filters) == 0 && len(view) == 0 && sel == nil && (!rv.IsValid() || !mayHaveTaggedFields(rv.Type(), "visible")) {
+		return v, nil
+	}
+
+	s, _ := subjectOf(r)
+	for _, fn := range j.filters {
+		var err error
+		if v, err = fn(r, s, v); err != nil {
+			return nil, err
+		}
+	}
+
+	rv = reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return v, nil
+	}
+	rv = pruneFields(rv, "visible", func(roles []string) bool { return hasAny(s.Roles, roles) })
+	if len(view) != 0 {
+		rv = pruneFields(rv, "view", func(views []string) bool { return hasAny([]string{view}, views) })
+	}
+	rv = selectFields(rv, sel)
+	return rv.Interface(), nil
+}
+
+// taggedKey is a type and a struct tag it's looked for in.
+type taggedKey struct {
+	typ reflect.Type
+	tag string
+}
+
+// taggedTypes caches the values of struct tags in types.
+var taggedTypes sync.Map
+
+// hasTaggedFields checks if values of typ have fields tagged with tag, at any
+// depth that pruneFields looks at.
+func hasTaggedFields(typ reflect.Type, tag string) bool {
+	return len(tagValues(typ, tag)) != 0
+}
+
+// mayHaveTaggedFields checks if values of typ can have fields tagged with
+// tag, either in typ itself or in what it holds in interfaces.
+func mayHaveTaggedFields(typ reflect.Type, tag string) bool {
+	return hasTaggedFields(typ, tag) || holdsInterfaces(typ)
+}
+
+// interfaceTypes caches whether types hold interfaces.
+var interfaceTypes sync.Map
+
+// holdsInterfaces checks if values of typ are or hold interfaces, at any
+// depth that pruneFields looks at.
+func holdsInterfaces(typ reflect.Type) bool {
+	if holds, ok := interfaceTypes.Load(typ); ok {
+		return holds.(bool)
+	}
+	holds := findInterfaces(typ, make(map[reflect.Type]bool))
+	interfaceTypes.Store(typ, holds)
+	return holds
+}
+
+func findInterfaces(typ reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[typ] {
+		return false
+	}
+	seen[typ] = true
+
+	switch typ.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return findInterfaces(typ.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if isEncoded(f) && findInterfaces(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tagValues finds the values in the comma separated lists of tag in the
+// fields of typ, at any depth that pruneFields looks at.
+func tagValues(typ reflect.Type, tag string) map[string]bool {
+	key := taggedKey{typ: typ, tag: tag}
+	if values, ok := taggedTypes.Load(key); ok {
+		return values.(map[string]bool)
+	}
+	values := make(map[string]bool)
+	findTagValues(typ, tag, values, make(map[reflect.Type]bool))
+	taggedTypes.Store(key, values)
+	return values
+}
+
+func findTagValues(typ reflect.Type, tag string, values map[string]bool, seen map[reflect.Type]bool) {
+	if seen[typ] {
+		return
+	}
+	seen[typ] = true
+
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		findTagValues(typ.Elem(), tag, values, seen)
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if !isEncoded(f) {
+				continue
+			}
+			if list, ok := f.Tag.Lookup(tag); ok {
+				for _, v := range splitTag(list) {
+					values[v] = true
+				}
+			}
+			findTagValues(f.Type, tag, values, seen)
+		}
+	}
+}
+
+// pruneFields returns a copy of v with the fields tagged with tag zeroed when
+// keep doesn't allow the values of their tag.
+func pruneFields(v reflect.Value, tag string, keep func(values []string) bool) reflect.Value {
+	if !mayHaveTaggedFields(v.Type(), tag) {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		i := reflect.New(v.Type()).Elem()
+		i.Set(pruneFields(v.Elem(), tag, keep))
+		return i
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(pruneFields(v.Elem(), tag, keep))
+		return p
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s.Index(i).Set(pruneFields(v.Index(i), tag, keep))
+		}
+		return s
+	case reflect.Array:
+		a := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			a.Index(i).Set(pruneFields(v.Index(i), tag, keep))
+		}
+		return a
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), pruneFields(iter.Value(), tag, keep))
+		}
+		return m
+	case reflect.Struct:
+		typ := v.Type()
+		s := reflect.New(typ).Elem()
+		s.Set(v)
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if !isEncoded(f) {
+				continue
+			}
+			field := s.Field(i)
+			if !field.CanSet() {
+				// Embedded structs of unexported types can't be set through
+				// reflect, but their fields are still encoded.
+				field = reflect.NewAt(f.Type, unsafe.Pointer(field.UnsafeAddr())).Elem()
+			}
+			if list, ok := f.Tag.Lookup(tag); ok && !keep(splitTag(list)) {
+				field.Set(reflect.Zero(f.Type))
+				continue
+			}
+			field.Set(pruneFields(field, tag, keep))
+		}
+		return s
+	}
+	return v
+}
+
+// isEncoded checks if encoding/json encodes the struct field f: exported
+// fields, and embedded structs whose fields it promotes even when their type
+// is unexported.
+func isEncoded(f reflect.StructField) bool {
+	if len(f.PkgPath) == 0 {
+		return true
+	}
+	if !f.Anonymous {
+		return false
+	}
+	typ := f.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct
+}
+
+// splitTag reads the comma separated values of a tag.
+func splitTag(tag string) []string {
+	values := strings.Split(tag, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values
+}
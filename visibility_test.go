@@ -0,0 +1,185 @@
+package jsonware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type visibleUser struct {
+	Name    string          `json:"name"`
+	Email   string          `json:"email,omitempty" visible:"admin, support"`
+	Notes   string          `json:"notes,omitempty" visible:"admin"`
+	Manager *visibleUser    `json:"manager,omitempty"`
+	Teams   []visibleTeam   `json:"teams,omitempty"`
+	Extra   map[string]bool `json:"extra,omitempty"`
+}
+
+type visibleTeam struct {
+	Name   string `json:"name"`
+	Budget int    `json:"budget,omitempty" visible:"admin"`
+}
+
+func TestFilterResponse(t *testing.T) {
+	t.Parallel()
+
+	user := &visibleUser{
+		Name:    "bob",
+		Email:   "bob@example.com",
+		Notes:   "on leave",
+		Manager: &visibleUser{Name: "ann", Email: "ann@example.com"},
+		Teams:   []visibleTeam{{Name: "ops", Budget: 10}},
+	}
+	fn := func(r *http.Request) (*visibleUser, error) { return user, nil }
+	owner := func(r *http.Request, s Subject, v interface{}) (interface{}, error) {
+		if s.ID == "eve" {
+			return nil, Err{Status: http.StatusNotFound, Err: errors.New("no such user")}
+		}
+		return v, nil
+	}
+
+	var tests = []struct {
+		handler *JSONHandler
+		subject *Subject
+		status  int
+		want    string
+	}{
+		{Handler(fn), nil, 200, `{"name":"bob","manager":{"name":"ann"},"teams":[{"name":"ops"}]}`},
+		{Handler(fn), &Subject{ID: "cat", Roles: []string{"support"}}, 200, `{"name":"bob","email":"bob@example.com","manager":{"name":"ann","email":"ann@example.com"},"teams":[{"name":"ops"}]}`},
+		{Handler(fn), &Subject{ID: "ann", Roles: []string{"admin"}}, 200, `{"name":"bob","email":"bob@example.com","notes":"on leave","manager":{"name":"ann","email":"ann@example.com"},"teams":[{"name":"ops","budget":10}]}`},
+		{Handler(fn).FilterResponse(owner), &Subject{ID: "eve"}, 404, `{"error":"no such user"}`},
+		{Handler(fn).FilterResponse(owner), &Subject{ID: "dan"}, 200, `{"name":"bob","manager":{"name":"ann"},"teams":[{"name":"ops"}]}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/user", nil)
+		req.Header.Set("Accept", "application/json")
+		if test.subject != nil {
+			req = req.WithContext(WithSubject(req.Context(), *test.subject))
+		}
+		test.handler.ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+
+	if len(user.Email) == 0 || len(user.Manager.Email) == 0 || user.Teams[0].Budget != 10 {
+		t.Error("expected the handler's value to be left alone")
+	}
+}
+
+func TestFilterResponseWrapped(t *testing.T) {
+	t.Parallel()
+
+	user := &visibleUser{Name: "bob", Email: "bob@example.com"}
+	type holder struct {
+		User interface{} `json:"user"`
+	}
+
+	var tests = []struct {
+		handler interface{}
+		status  int
+	}{
+		{func(r *http.Request) *Created { return &Created{Value: user, Location: "/users/bob"} }, 201},
+		{func(r *http.Request) *WithCookies { return &WithCookies{Value: user} }, 200},
+		{func(r *http.Request) *Response[interface{}] { return &Response[interface{}]{Body: user} }, 200},
+		{func(r *http.Request) *holder { return &holder{User: user} }, 200},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/user", nil)
+		req.Header.Set("Accept", "application/json")
+		Handler(test.handler).ServeHTTP(res, req)
+
+		if res.Code != test.status {
+			t.Errorf("Test %d: expected status %d, got: %d", i, test.status, res.Code)
+		}
+		if b := res.Body.String(); strings.Contains(b, "bob@example.com") {
+			t.Errorf("Test %d: expected the email to be pruned, got: %s", i, b)
+		}
+	}
+
+	if len(user.Email) == 0 {
+		t.Error("expected the handler's value to be left alone")
+	}
+}
+
+type visibleBase struct {
+	Secret string `json:"secret,omitempty" visible:"admin"`
+}
+
+type visibleEmbedded struct {
+	visibleBase
+	*visibleTeam
+	Name string `json:"name"`
+}
+
+func TestFilterResponseEmbedded(t *testing.T) {
+	t.Parallel()
+
+	account := &visibleEmbedded{
+		visibleBase: visibleBase{Secret: "s3cret"},
+		visibleTeam: &visibleTeam{Name: "ops", Budget: 10},
+		Name:        "bob",
+	}
+	h := Handler(func(r *http.Request) (*visibleEmbedded, error) { return account, nil })
+
+	var tests = []struct {
+		subject *Subject
+		want    string
+	}{
+		{nil, `{"name":"bob"}`},
+		{&Subject{ID: "ann", Roles: []string{"admin"}}, `{"secret":"s3cret","budget":10,"name":"bob"}`},
+	}
+
+	for i, test := range tests {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/account", nil)
+		req.Header.Set("Accept", "application/json")
+		if test.subject != nil {
+			req = req.WithContext(WithSubject(req.Context(), *test.subject))
+		}
+		h.ServeHTTP(res, req)
+
+		if b := strings.TrimSpace(res.Body.String()); b != test.want {
+			t.Errorf("Test %d: expected %s, got: %s", i, test.want, b)
+		}
+	}
+
+	if len(account.Secret) == 0 || account.Budget != 10 {
+		t.Error("expected the handler's value to be left alone")
+	}
+}
+
+func TestFilterResponseReplaces(t *testing.T) {
+	t.Parallel()
+
+	h := Handler(func(r *http.Request) ([]testType, error) {
+		return []testType{{Name: "a"}, {Name: "b"}, {Name: "c"}}, nil
+	}).FilterResponse(func(r *http.Request, s Subject, v interface{}) (interface{}, error) {
+		var kept []testType
+		for _, t := range v.([]testType) {
+			if t.Name != "b" {
+				kept = append(kept, t)
+			}
+		}
+		return kept, nil
+	})
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	h.ServeHTTP(res, req)
+
+	if b := strings.TrimSpace(res.Body.String()); b != `[{"name":"a"},{"name":"c"}]` {
+		t.Errorf("expected b to be filtered out, got: %s", b)
+	}
+}
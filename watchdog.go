@@ -0,0 +1,46 @@
+package jsonware
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// maxGoroutineDump caps how large a watchdog's goroutine dump gets.
+const maxGoroutineDump = 8 << 20
+
+/*
+Watchdog logs a dump of every goroutine's stack when the handler has been
+running for more than multiple times its expected latency, to find out what
+a stuck handler holding on to a connection is waiting for. The handler isn't
+stopped, it's only reported once per request.
+
+	h := jsonware.Handler(getReport).Watchdog(200*time.Millisecond, 10)
+*/
+func (j *JSONHandler) Watchdog(expected time.Duration, multiple float64) *JSONHandler {
+	j.watchdog = time.Duration(float64(expected) * multiple)
+	return j
+}
+
+// watch starts the watchdog for a call of the handler, the returned function
+// stops it.
+func (j JSONHandler) watch(r *http.Request) func() bool {
+	logger, start := j.logger, time.Now()
+	t := time.AfterFunc(j.watchdog, func() {
+		logf(logger, "%s %s has been running for %v, more than the watchdog's %v:\n%s",
+			r.Method, r.URL.Path, time.Since(start).Round(time.Millisecond), j.watchdog, goroutineDump())
+	})
+	return t.Stop
+}
+
+// goroutineDump is the stacks of every goroutine.
+func goroutineDump() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxGoroutineDump {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,65 @@
+package jsonware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedLog is a log that's safe to write to from other goroutines.
+type lockedLog struct {
+	mut sync.Mutex
+	b   strings.Builder
+}
+
+func (l *lockedLog) Write(p []byte) (int, error) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	return l.b.Write(p)
+}
+
+func (l *lockedLog) String() string {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	return l.b.String()
+}
+
+func TestWatchdog(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		sleep time.Duration
+		bark  bool
+	}{
+		{0, false},
+		{200 * time.Millisecond, true},
+	}
+
+	for i, test := range tests {
+		log := &lockedLog{}
+		sleep := test.sleep
+		h := Handler(func(r *http.Request) (*testType, error) {
+			time.Sleep(sleep)
+			return &testType{Name: "done"}, nil
+		}).Watchdog(10*time.Millisecond, 2).Log(log)
+
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/report", nil)
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK || !strings.Contains(res.Body.String(), "done") {
+			t.Errorf("Test %d: expected the handler to finish, got: %d %s", i, res.Code, res.Body.String())
+		}
+		l := log.String()
+		if barked := strings.Contains(l, "GET /report has been running for"); barked != test.bark {
+			t.Errorf("Test %d: expected the watchdog to log %t, got: %q", i, test.bark, l)
+		}
+		if test.bark && (!strings.Contains(l, "more than the watchdog's 20ms") || !strings.Contains(l, "TestWatchdog")) {
+			t.Errorf("Test %d: expected a goroutine dump, got: %q", i, l)
+		}
+	}
+}
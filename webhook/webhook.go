@@ -0,0 +1,262 @@
+/*
+Package webhook sends events to the urls of subscribers as json, the outbound
+side of the webhooks a jsonware api receives. Events are encoded with the
+same jsonware.Codec as the api's responses and signed the same way a
+jsonware.Signature verifies requests, so an api that receives webhooks from
+another can check them with the handlers it already has.
+
+	d := &webhook.Dispatcher{
+		DeadLetter: func(sub webhook.Subscriber, ev webhook.Event, err error) {
+			failed.Save(sub, ev, err)
+		},
+	}
+	d.Dispatch(ctx, subscribers, webhook.Event{ID: id, Type: "order.created", Data: order})
+*/
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aarondl/jsonware"
+)
+
+// Defaults of the Dispatcher fields that are left zero.
+const (
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = time.Second
+	DefaultMaxBackoff  = 5 * time.Minute
+)
+
+// Event is what's sent to subscribers. It's encoded as an object with its
+// fields under the keys id, type, created_at and data.
+type Event struct {
+	// ID identifies the event, it's also sent as the Idempotency-Key header
+	// so subscribers can ignore retries they've already handled.
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Subscriber is where events are sent.
+type Subscriber struct {
+	URL string
+	// KeyID and Secret sign the requests with jsonware.SignRequest, they
+	// aren't signed when Secret is empty.
+	KeyID  string
+	Secret []byte
+}
+
+/*
+Dispatcher sends events to subscribers. Deliveries that fail with a network
+error, a 429 or a 5xx are retried with exponential backoff, starting at
+Backoff and doubling up to MaxBackoff, or after the time given by a
+Retry-After header. Deliveries that fail with any other status, or that run
+out of attempts, are handed to DeadLetter.
+
+A Dispatcher is safe for use by multiple goroutines.
+*/
+type Dispatcher struct {
+	// Codec encodes events, jsonware.DefaultCodec is used when it's nil.
+	Codec *jsonware.Codec
+	// Client sends the requests, http.DefaultClient is used when it's nil.
+	Client *http.Client
+
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+
+	// DeadLetter is given the deliveries that failed for good.
+	DeadLetter func(sub Subscriber, ev Event, err error)
+	// Log is where failed attempts are reported, the global logger is not
+	// used since this isn't a handler.
+	Log io.Writer
+
+	// sleep is swapped out in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// deliveryError is the error of an attempt that got a response.
+type deliveryError struct {
+	status     int
+	retryAfter time.Duration
+}
+
+func (e deliveryError) Error() string {
+	return fmt.Sprintf("subscriber responded with status %d", e.status)
+}
+
+// retryable checks if a delivery that failed with err should be tried again.
+func retryable(err error) bool {
+	var de deliveryError
+	if !errors.As(err, &de) {
+		return true
+	}
+	return de.status == http.StatusTooManyRequests || de.status >= 500
+}
+
+/*
+Dispatch sends ev to every subscriber at once and waits for the deliveries
+to finish. Failed deliveries go to DeadLetter, run it in a goroutine to not
+wait for retries.
+*/
+func (d *Dispatcher) Dispatch(ctx context.Context, subs []Subscriber, ev Event) {
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub Subscriber) {
+			defer wg.Done()
+			d.Send(ctx, sub, ev)
+		}(sub)
+	}
+	wg.Wait()
+}
+
+// Send delivers ev to sub, retrying until it succeeds, fails for good or ctx
+// is done. It returns the error the delivery failed with after handing it to
+// DeadLetter.
+func (d *Dispatcher) Send(ctx context.Context, sub Subscriber, ev Event) error {
+	if ev.CreatedAt.IsZero() {
+		ev.CreatedAt = time.Now().UTC()
+	}
+
+	codec := d.Codec
+	if codec == nil {
+		codec = jsonware.DefaultCodec
+	}
+	body, err := codec.Marshal(ev)
+	if err != nil {
+		err = fmt.Errorf("failed to encode event %s: %w", ev.ID, err)
+		d.deadLetter(sub, ev, err)
+		return err
+	}
+
+	attempts := d.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultMaxAttempts
+	}
+	for attempt := 0; ; attempt++ {
+		err = d.attempt(ctx, sub, ev, body)
+		if err == nil {
+			return nil
+		}
+
+		logf(d.Log, "webhook %s to %s failed on attempt %d: %v", ev.ID, sub.URL, attempt+1, err)
+		if !retryable(err) || attempt+1 >= attempts {
+			break
+		}
+		if serr := d.wait(ctx, d.backoff(attempt, err)); serr != nil {
+			err = serr
+			break
+		}
+	}
+
+	d.deadLetter(sub, ev, err)
+	return err
+}
+
+// attempt makes one delivery of an encoded event.
+func (d *Dispatcher) attempt(ctx context.Context, sub Subscriber, ev Event, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if len(ev.ID) != 0 {
+		req.Header.Set("Idempotency-Key", ev.ID)
+	}
+	if len(sub.Secret) != 0 {
+		if err := jsonware.SignRequest(req, sub.KeyID, sub.Secret); err != nil {
+			return err
+		}
+	}
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	// Drain what's left so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	de := deliveryError{status: res.StatusCode}
+	if secs, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && secs > 0 {
+		de.retryAfter = time.Duration(secs) * time.Second
+	}
+	return de
+}
+
+// backoff is how long to wait after the attempt numbered attempt failed
+// with err.
+func (d *Dispatcher) backoff(attempt int, err error) time.Duration {
+	maxBackoff := d.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	var de deliveryError
+	if errors.As(err, &de) && de.retryAfter != 0 {
+		if de.retryAfter > maxBackoff {
+			return maxBackoff
+		}
+		return de.retryAfter
+	}
+
+	wait := d.Backoff
+	if wait <= 0 {
+		wait = DefaultBackoff
+	}
+	for i := 0; i < attempt && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	// Jitter keeps subscribers that failed together from being retried
+	// together.
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+func (d *Dispatcher) wait(ctx context.Context, dur time.Duration) error {
+	if d.sleep != nil {
+		return d.sleep(ctx, dur)
+	}
+
+	t := time.NewTimer(dur)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) deadLetter(sub Subscriber, ev Event, err error) {
+	if d.DeadLetter != nil {
+		d.DeadLetter(sub, ev, err)
+	}
+}
+
+func logf(logger io.Writer, format string, args ...interface{}) {
+	if logger != nil {
+		fmt.Fprintf(logger, format, args...)
+	}
+}
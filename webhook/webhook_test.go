@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aarondl/jsonware"
+)
+
+type order struct {
+	OrderID string `json:"order_id"`
+}
+
+// subscriber receives events with a jsonware handler, failing the first
+// failures deliveries with status. Events must be signed when signed is set.
+func subscriber(t *testing.T, signed bool, failures int32, status int, retryAfter string) (*httptest.Server, *[]Event) {
+	var mut sync.Mutex
+	var received []Event
+	var calls int32
+
+	sig := &jsonware.Signature{Keys: func(r *http.Request, id string) ([]byte, error) {
+		if id == "k1" {
+			return []byte("secret"), nil
+		}
+		return nil, nil
+	}}
+	h := jsonware.Handler(func(w http.ResponseWriter, r *http.Request, ev *Event) (*Event, error) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			if len(retryAfter) != 0 {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			return nil, jsonware.Err{Status: status, Err: errors.New("failed")}
+		}
+		if r.Header.Get("Idempotency-Key") != ev.ID {
+			t.Errorf("expected the idempotency key to be the event id, got: %s", r.Header.Get("Idempotency-Key"))
+		}
+		mut.Lock()
+		received = append(received, *ev)
+		mut.Unlock()
+		return ev, nil
+	})
+
+	var handler http.Handler = h
+	if signed {
+		handler = sig.Wrap(h)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv, &received
+}
+
+func TestSend(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		failures   int32
+		status     int
+		retryAfter string
+		secret     string
+		wantErr    bool
+		wantWaits  []time.Duration
+	}{
+		{0, 0, "", "secret", false, nil},
+		{2, 503, "", "secret", false, []time.Duration{time.Second, 2 * time.Second}},
+		{1, 429, "7", "secret", false, []time.Duration{7 * time.Second}},
+		{1, 400, "", "secret", true, nil},
+		{5, 500, "", "secret", true, []time.Duration{time.Second, 2 * time.Second}},
+		{0, 0, "", "wrong", true, nil},
+	}
+
+	for i, test := range tests {
+		srv, received := subscriber(t, true, test.failures, test.status, test.retryAfter)
+
+		var waits []time.Duration
+		var deadLettered error
+		d := &Dispatcher{
+			MaxAttempts: 3,
+			DeadLetter:  func(sub Subscriber, ev Event, err error) { deadLettered = err },
+			sleep: func(ctx context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				return nil
+			},
+		}
+
+		sub := Subscriber{URL: srv.URL, KeyID: "k1", Secret: []byte(test.secret)}
+		err := d.Send(context.Background(), sub, Event{ID: "ev1", Type: "order.created", Data: order{OrderID: "o1"}})
+
+		if (err != nil) != test.wantErr || (deadLettered != nil) != test.wantErr {
+			t.Errorf("Test %d: expected error %t, got: %v (dead letter: %v)", i, test.wantErr, err, deadLettered)
+		}
+		if len(waits) != len(test.wantWaits) {
+			t.Fatalf("Test %d: expected %d waits, got: %v", i, len(test.wantWaits), waits)
+		}
+		for j, w := range waits {
+			// Backoff is jittered between half and all of the wait.
+			if want := test.wantWaits[j]; w > want || (test.retryAfter == "" && w < want/2) || (test.retryAfter != "" && w != want) {
+				t.Errorf("Test %d: expected wait %d to be about %s, got: %s", i, j, want, w)
+			}
+		}
+
+		if !test.wantErr {
+			if len(*received) != 1 {
+				t.Fatalf("Test %d: expected one event, got: %d", i, len(*received))
+			}
+			ev := (*received)[0]
+			if ev.ID != "ev1" || ev.Type != "order.created" || ev.CreatedAt.IsZero() {
+				t.Errorf("Test %d: event was wrong: %#v", i, ev)
+			}
+			if data, ok := ev.Data.(map[string]interface{}); !ok || data["order_id"] != "o1" {
+				t.Errorf("Test %d: data was wrong: %#v", i, ev.Data)
+			}
+		}
+	}
+}
+
+func TestSendCodec(t *testing.T) {
+	t.Parallel()
+
+	var body map[string]interface{}
+	h := jsonware.Handler(func(r *http.Request, in *map[string]interface{}) (*map[string]interface{}, error) {
+		body = *in
+		return in, nil
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	d := &Dispatcher{Codec: &jsonware.Codec{Keys: jsonware.CamelCase}}
+	if err := d.Send(context.Background(), Subscriber{URL: srv.URL}, Event{ID: "ev1", Data: order{OrderID: "o1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := body["createdAt"]; !ok {
+		t.Errorf("expected the codec's keys, got: %v", body)
+	}
+	if data, ok := body["data"].(map[string]interface{}); !ok || data["orderId"] != "o1" {
+		t.Errorf("expected the codec's keys in the data, got: %v", body["data"])
+	}
+}
+
+func TestSendCanceled(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := subscriber(t, false, 10, 503, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		Backoff: time.Hour,
+		DeadLetter: func(sub Subscriber, ev Event, err error) {
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected the dead letter to be canceled, got: %v", err)
+			}
+		},
+	}
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := d.Send(ctx, Subscriber{URL: srv.URL}, Event{ID: "ev1"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled, got: %v", err)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	t.Parallel()
+
+	srv1, received1 := subscriber(t, false, 0, 0, "")
+	srv2, received2 := subscriber(t, false, 0, 0, "")
+
+	d := &Dispatcher{}
+	d.Dispatch(context.Background(), []Subscriber{{URL: srv1.URL}, {URL: srv2.URL}}, Event{ID: "ev1"})
+
+	if len(*received1) != 1 || len(*received2) != 1 {
+		t.Errorf("expected both subscribers to get the event, got: %d and %d", len(*received1), len(*received2))
+	}
+}